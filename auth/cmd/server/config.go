@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/certs"
+)
+
+// configCheck collects the problems of the configuration, so they are all
+// reported at once instead of one per start.
+type configCheck []string
+
+// add records the error of the section, if any.
+func (c *configCheck) add(section string, err error) {
+	if err != nil {
+		*c = append(*c, section+": "+err.Error())
+	}
+}
+
+// err returns the problems as one error, nil without problems.
+func (c configCheck) err() error {
+	if len(c) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid config:\n\t%s", strings.Join(c, "\n\t"))
+}
+
+// checkOneOf checks the value is one of the allowed ones.
+func checkOneOf(value string, allowed ...string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown value %q, expected one of %s", value, strings.Join(allowed, ", "))
+}
+
+// checkPositive checks the durations are above zero, e.g. the intervals of
+// the tickers, which panic otherwise. The names are the ones of the fields.
+func checkPositive(durations map[string]time.Duration) error {
+	var bad []string
+	for name, d := range durations {
+		if d <= 0 {
+			bad = append(bad, name)
+		}
+	}
+	if len(bad) == 0 {
+		return nil
+	}
+	sort.Strings(bad)
+	return fmt.Errorf("%s must be positive", strings.Join(bad, ", "))
+}
+
+// checkDevOnly checks what is used only in the development mode.
+func checkDevOnly(dev bool, used bool, what string) error {
+	if used && !dev {
+		return fmt.Errorf("%s is only allowed with the emulator", what)
+	}
+	return nil
+}
+
+// checkKey checks the base64 encoded key is accepted by use. No key is
+// fine here, an ephemeral one is generated.
+func checkKey(encodedKey string, use func(key []byte) error) error {
+	if encodedKey == "" {
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return fmt.Errorf("decoding key: %w", err)
+	}
+	return use(key)
+}
+
+// checkTLS checks the TLS files can be loaded.
+func checkTLS(certFile string, keyFile string, minVersion string, debugClientCAFile string) error {
+	if _, err := certs.ParseVersion(minVersion); err != nil {
+		return err
+	}
+
+	if certFile == "" {
+		if keyFile != "" || debugClientCAFile != "" {
+			return errors.New("the key and client CAs files require a certificate file")
+		}
+		return nil
+	}
+
+	if _, err := certs.New(certFile, keyFile); err != nil {
+		return err
+	}
+	if debugClientCAFile != "" {
+		return certs.VerifyClientCerts(&tls.Config{}, debugClientCAFile)
+	}
+	return nil
+}
+
+// checkFirebase checks the project is set and the credentials are readable.
+// The emulator doesn't take credentials.
+func checkFirebase(projectID string, emulatorHost string, credentialsFile string) error {
+	switch {
+	case projectID == "":
+		return errors.New("project id is required")
+	case credentialsFile == "":
+		return nil
+	case emulatorHost != "":
+		return errors.New("credentials file is not used with the emulator")
+	}
+
+	f, err := os.Open(credentialsFile)
+	if err != nil {
+		return fmt.Errorf("credentials file: %w", err)
+	}
+	return f.Close()
+}
@@ -2,37 +2,62 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"expvar"
 	"fmt"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go/v4"
 	"github.com/ardanlabs/conf/v3"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/certs"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/conffile"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/csrf"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/health"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/keyring"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/lifecycle"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/loadshed"
 	"github.com/mroobert/go-tickets/auth/internal/foundation/logger"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/mail"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/memauth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/metrics"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/ratelimit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/reload"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/secretbox"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/trace"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/admin"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/apikey"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/auditlog"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/lockout"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/mfa"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/passkey"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/profile"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/role"
 	"github.com/mroobert/go-tickets/auth/internal/usecase/signin"
 	"github.com/mroobert/go-tickets/auth/internal/usecase/signup"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/token"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/mid"
 	"github.com/mroobert/go-tickets/auth/internal/webapp/mux"
 	"go.uber.org/automaxprocs/maxprocs"
 	"go.uber.org/zap"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
-/*
-1. Need to figure out timeouts for http service after a load testing.
-2. Need to add metrics middleware.
-3. CSRF
-4. Check the "sign up" mapping functions
-*/
-
 var build = "develop"
 
 func main() {
-	// Construct the application logger.
-	log, err := logger.New("AUTH-API")
+	// Construct the application logger, its level is configured later.
+	level := zap.NewAtomicLevel()
+	log, err := logger.New("AUTH-API", level)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -40,13 +65,13 @@ func main() {
 	defer log.Sync()
 
 	// Perform the startup and shutdown sequence.
-	if err := run(log); err != nil {
+	if err := run(log, level); err != nil {
 		log.Errorw("startup", "ERROR", err)
 		os.Exit(1)
 	}
 }
 
-func run(log *zap.SugaredLogger) error {
+func run(log *zap.SugaredLogger, level zap.AtomicLevel) error {
 	// =========================================================================
 	// GOMAXPROCS
 
@@ -60,13 +85,116 @@ func run(log *zap.SugaredLogger) error {
 	// Configuration
 	cfg := struct {
 		conf.Version
-		Web struct {
-			ReadTimeout     time.Duration `conf:"default:5s"`
-			WriteTimeout    time.Duration `conf:"default:10s"`
-			IdleTimeout     time.Duration `conf:"default:120s"`
-			ShutdownTimeout time.Duration `conf:"default:20s"`
-			APIHost         string        `conf:"default:0.0.0.0:3000"`
-			DebugHost       string        `conf:"default:0.0.0.0:8080"`
+		ConfigFile  string `conf:"help:YAML or JSON file layered under the environment and the flags"`
+		CheckConfig bool   `conf:"help:validate the configuration and exit"`
+		Provider    string `conf:"default:firebase,help:firebase or memory; memory signs up and signs in without the emulator"`
+		Storage     string `conf:"default:firestore,help:firestore or memory; memory keeps the mfa enrolments and the api keys and the passkeys in the instance (emulator only)"`
+		Web         struct {
+			ReadTimeout      time.Duration `conf:"default:5s"`
+			WriteTimeout     time.Duration `conf:"default:10s"`
+			IdleTimeout      time.Duration `conf:"default:120s"`
+			ShutdownTimeout  time.Duration `conf:"default:20s"`
+			DrainDelay       time.Duration `conf:"default:5s,help:time for the load balancers to notice the service is not ready"`
+			HardStop         time.Duration `conf:"default:30s,help:bound of the whole shutdown"`
+			ReadinessTimeout time.Duration `conf:"default:2s"`
+			RouteTimeout     time.Duration `conf:"default:5s"`
+			SlowRouteTimeout time.Duration `conf:"default:9s"`
+			APIHost          string        `conf:"default:0.0.0.0:3000"`
+			DebugHost        string        `conf:"default:0.0.0.0:8080"`
+			DebugToken       string        `conf:"mask,help:bearer token of the debug config endpoint, it is off without it"`
+			TrustedProxies   []string      `conf:"help:networks in CIDR notation of the proxies in front of the service; X-Forwarded-For is only honoured from them"`
+		}
+		Log struct {
+			Level string `conf:"default:info,help:debug, info, warn or error"`
+		}
+		TLS struct {
+			CertFile          string        `conf:"help:PEM certificate of the servers, they listen in plain text without it"`
+			KeyFile           string        `conf:"help:PEM private key of the certificate"`
+			MinVersion        string        `conf:"default:1.2,help:1.0 to 1.3"`
+			WatchInterval     time.Duration `conf:"default:30s,help:interval of the checks of the files for a new certificate"`
+			DebugClientCAFile string        `conf:"help:PEM CAs of the client certificates required by pprof and the config of the debug server"`
+		}
+		MFA struct {
+			Issuer        string `conf:"default:go-tickets"`
+			EncryptionKey string `conf:"mask,help:base64 encoded 32 bytes key used to encrypt the TOTP secrets"`
+		}
+		Mail struct {
+			Relay    string `conf:"help:host:port of the SMTP relay delivering the links; without it they are not delivered (emulator only)"`
+			Username string
+			Password string `conf:"mask"`
+			From     string `conf:"default:no-reply@localhost"`
+		}
+		CORS struct {
+			AllowedOrigins []string      `conf:"default:http://localhost:3000,help:exact origins or patterns like https://*.example.com"`
+			AllowedMethods []string      `conf:"default:GET;POST;PUT;PATCH;DELETE"`
+			AllowedHeaders []string      `conf:"default:Content-Type;Authorization;X-CSRF-Token"`
+			ExposedHeaders []string      `conf:"default:Retry-After;X-RateLimit-Limit;X-RateLimit-Remaining;X-RateLimit-Reset"`
+			MaxAge         time.Duration `conf:"default:10m"`
+		}
+		CSRF struct {
+			Key            string   `conf:"mask,help:base64 encoded key of at least 32 bytes used to sign the csrf cookies"`
+			AllowedOrigins []string `conf:"default:http://localhost:3000"`
+		}
+		RateLimit struct {
+			IP            ratelimit.Limit `conf:"default:600/min,help:per client ip over all the routes"`
+			Principal     ratelimit.Limit `conf:"default:300/min,help:per authenticated principal"`
+			SignUp        ratelimit.Limit `conf:"default:5/min"`
+			SignIn        ratelimit.Limit `conf:"default:20/min"`
+			EvictInterval time.Duration   `conf:"default:1m"`
+		}
+		Lockout struct {
+			Window          time.Duration `conf:"default:15m"`
+			FreeAttempts    int           `conf:"default:3"`
+			BaseDelay       time.Duration `conf:"default:1s"`
+			MaxDelay        time.Duration `conf:"default:1m"`
+			UserMaxAttempts int           `conf:"default:10"`
+			IPMaxAttempts   int           `conf:"default:100"`
+			LockDuration    time.Duration `conf:"default:15m"`
+		}
+		Trace struct {
+			Exporter    string  `conf:"default:none,help:none, file or otlp"`
+			Path        string  `conf:"default:spans.jsonl,help:path of the spans when the exporter is file"`
+			Endpoint    string  `conf:"default:http://localhost:4318/v1/traces,help:otlp/http traces endpoint of the collector"`
+			Headers     string  `conf:"mask,help:headers sent to the collector as key1=value1,key2=value2"`
+			SampleRatio float64 `conf:"default:1,help:ratio of the new traces recorded, the callers decide for their traces"`
+		}
+		LoadShed struct {
+			InitialLimit   int           `conf:"default:100"`
+			MinLimit       int           `conf:"default:10"`
+			MaxLimit       int           `conf:"default:1000"`
+			Tolerance      float64       `conf:"default:2,help:multiple of the baseline latency considered an overload"`
+			Backoff        float64       `conf:"default:0.9,help:factor applied to the limit on overload"`
+			Floor          time.Duration `conf:"default:25ms,help:latency under which a request never counts as an overload"`
+			BaselineWindow time.Duration `conf:"default:30s"`
+		}
+		Audit struct {
+			Sink string `conf:"default:stdout,help:stdout or file"`
+			Path string `conf:"default:audit.jsonl,help:path of the log when the sink is file"`
+		}
+		Passkey struct {
+			RPID    string   `conf:"default:localhost"`
+			RPName  string   `conf:"default:go-tickets"`
+			Origins []string `conf:"default:http://localhost:3000"`
+		}
+		Token struct {
+			Issuer           string        `conf:"default:go-tickets-auth"`
+			Audience         string        `conf:"default:go-tickets"`
+			TTL              time.Duration `conf:"default:5m"`
+			Algorithm        string        `conf:"default:EdDSA,help:RS256 or EdDSA"`
+			RotationInterval time.Duration `conf:"default:24h,help:rotation of the generated signing keys"`
+			SigningKeys      []string      `conf:"mask,help:base64 encoded PKCS #8 private keys shared by the instances; the first signs and the others stay published. Without them (emulator only) the instance generates its own"`
+		}
+		Tenant struct {
+			Registry []string `conf:"help:tenants like: id=promoter-a-k2j3 hosts=shop.promoter-a.com passwordMinLength=10 sessionTTL=24h"`
+			Header   string   `conf:"default:X-Tenant-ID,help:header naming the tenant; set it empty to resolve by host only"`
+			Default  string   `conf:"help:tenant of the hosts of no tenant; the project users serve them without it"`
+		}
+		Firebase struct {
+			ProjectID       string `conf:"default:demo-test"`
+			EmulatorHost    string `conf:"default:localhost:9099,help:host of the auth emulator; it marks the development mode; set it empty to use firebase"`
+			FirestoreHost   string `conf:"default:localhost:8085,help:host of the firestore emulator; set it empty to use firestore"`
+			CredentialsFile string `conf:"help:service account key file; the default credentials are used without it"`
+			APIKey          string `conf:"default:demo-key,mask,help:web api key used to exchange custom tokens"`
 		}
 	}{
 		Version: conf.Version{
@@ -76,6 +204,7 @@ func run(log *zap.SugaredLogger) error {
 	}
 
 	const prefix = "AUTH"
+	blank := cfg
 	help, err := conf.Parse(prefix, &cfg)
 	if err != nil {
 		if errors.Is(err, conf.ErrHelpWanted) {
@@ -85,22 +214,157 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("parsing config: %w", err)
 	}
 
+	// The file is known once the environment and the flags are parsed, its
+	// values then fill the fields they didn't set.
+	var file *conffile.File
+	if cfg.ConfigFile != "" {
+		file = conffile.New(cfg.ConfigFile)
+		if err := file.Apply(prefix, os.Args[1:], &cfg); err != nil {
+			return fmt.Errorf("parsing config: %w", err)
+		}
+	}
+
+	// =========================================================================
+	// Validate Configuration
+
+	// The sections are checked before anything starts, every problem is
+	// reported at once.
+	var check configCheck
+	check.add("web", lifecycle.Config{
+		DrainDelay:      cfg.Web.DrainDelay,
+		ShutdownTimeout: cfg.Web.ShutdownTimeout,
+		HardStop:        cfg.Web.HardStop,
+	}.Validate())
+	check.add("settings", newSettings(cfg.Log.Level, cfg.RateLimit.IP, cfg.RateLimit.Principal, cfg.RateLimit.SignUp, cfg.RateLimit.SignIn, cfg.CORS.AllowedOrigins, cfg.CSRF.AllowedOrigins).Validate())
+	_, err = web.ParseTrustedProxies(cfg.Web.TrustedProxies)
+	check.add("web", err)
+	check.add("tls", checkTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.MinVersion, cfg.TLS.DebugClientCAFile))
+	check.add("tls", checkPositive(map[string]time.Duration{"watchInterval": cfg.TLS.WatchInterval}))
+	check.add("token", checkPositive(map[string]time.Duration{"ttl": cfg.Token.TTL, "rotationInterval": cfg.Token.RotationInterval}))
+	check.add("ratelimit", checkPositive(map[string]time.Duration{"evictInterval": cfg.RateLimit.EvictInterval}))
+	check.add("loadshed", loadshed.Config{
+		InitialLimit:   cfg.LoadShed.InitialLimit,
+		MinLimit:       cfg.LoadShed.MinLimit,
+		MaxLimit:       cfg.LoadShed.MaxLimit,
+		Tolerance:      cfg.LoadShed.Tolerance,
+		Backoff:        cfg.LoadShed.Backoff,
+		Floor:          cfg.LoadShed.Floor,
+		BaselineWindow: cfg.LoadShed.BaselineWindow,
+	}.Validate())
+	_, err = lockout.NewPolicy(cfg.Lockout.Window, cfg.Lockout.FreeAttempts, cfg.Lockout.BaseDelay, cfg.Lockout.MaxDelay, cfg.Lockout.UserMaxAttempts, cfg.Lockout.LockDuration)
+	check.add("lockout user", err)
+	_, err = lockout.NewPolicy(cfg.Lockout.Window, cfg.Lockout.FreeAttempts, cfg.Lockout.BaseDelay, cfg.Lockout.MaxDelay, cfg.Lockout.IPMaxAttempts, cfg.Lockout.LockDuration)
+	check.add("lockout ip", err)
+	// The emulator marks the development mode, outside it the state has to
+	// survive a restart and be shared between the instances.
+	dev := cfg.Firebase.EmulatorHost != ""
+	check.add("storage", checkOneOf(cfg.Storage, "firestore", "memory"))
+	check.add("storage", checkDevOnly(dev, cfg.Storage == "memory", "the memory storage"))
+	check.add("mfa", checkDevOnly(dev, cfg.MFA.EncryptionKey == "", "an ephemeral encryption key"))
+	check.add("mfa", checkKey(cfg.MFA.EncryptionKey, func(key []byte) error {
+		_, err := secretbox.New(key)
+		return err
+	}))
+	check.add("mail", checkDevOnly(dev, cfg.Mail.Relay == "", "no mail relay"))
+	if cfg.Mail.Relay != "" {
+		_, err := mail.New(mail.Config{Addr: cfg.Mail.Relay, From: cfg.Mail.From})
+		check.add("mail", err)
+	}
+	check.add("csrf", checkKey(cfg.CSRF.Key, func(key []byte) error {
+		_, err := csrf.New(key, cfg.CSRF.AllowedOrigins)
+		return err
+	}))
+	check.add("trace", checkOneOf(cfg.Trace.Exporter, "none", "file", "otlp"))
+	if _, err := trace.ParseHeaders(cfg.Trace.Headers); err != nil {
+		// The error would show the headers, which are secret.
+		check.add("trace", errors.New("invalid headers"))
+	}
+	check.add("audit", checkOneOf(cfg.Audit.Sink, "stdout", "file"))
+	check.add("token", checkOneOf(cfg.Token.Algorithm, "RS256", "EdDSA"))
+	check.add("token", checkDevOnly(dev, len(cfg.Token.SigningKeys) == 0, "generated signing keys"))
+	if len(cfg.Token.SigningKeys) > 0 {
+		_, err := newKeyring(log, cfg.Token.Algorithm, cfg.Token.TTL, cfg.Token.SigningKeys)
+		check.add("token", err)
+	}
+	_, err = newTenantRegistry(cfg.Tenant.Registry, cfg.Tenant.Header, cfg.Tenant.Default)
+	check.add("tenant", err)
+	check.add("provider", checkOneOf(cfg.Provider, "firebase", "memory"))
+	check.add("firebase", checkFirebase(cfg.Firebase.ProjectID, cfg.Firebase.EmulatorHost, cfg.Firebase.CredentialsFile))
+	if err := check.err(); err != nil {
+		return err
+	}
+
 	// =========================================================================
 	// App Starting
+
+	// The secrets are masked.
 	out, err := conf.String(&cfg)
 	if err != nil {
 		return fmt.Errorf("generating config for output: %w", err)
 	}
+	if cfg.CheckConfig {
+		fmt.Printf("config ok\n%s\n", out)
+		return nil
+	}
 	log.Infow("startup", "config", out)
 
 	expvar.NewString("build").Set(build)
 
 	// =========================================================================
 	// Initialize Firebase Support
-	os.Setenv("FIREBASE_AUTH_EMULATOR_HOST", "localhost:9099")
-	os.Setenv("GCLOUD_PROJECT", "demo-test")
 
-	fbClient, err := firebase.NewApp(context.Background(), nil)
+	// The sdks read the emulator hosts from the environment only.
+	if cfg.Firebase.EmulatorHost != "" {
+		os.Setenv("FIREBASE_AUTH_EMULATOR_HOST", cfg.Firebase.EmulatorHost)
+	} else {
+		os.Unsetenv("FIREBASE_AUTH_EMULATOR_HOST")
+	}
+	if cfg.Firebase.FirestoreHost != "" {
+		os.Setenv("FIRESTORE_EMULATOR_HOST", cfg.Firebase.FirestoreHost)
+	} else {
+		os.Unsetenv("FIRESTORE_EMULATOR_HOST")
+	}
+
+	// =========================================================================
+	// Initialize Tenancy Support
+
+	// Every tenant is a user pool of its own, the requests are routed to it
+	// by host or header.
+	tenants, err := newTenantRegistry(cfg.Tenant.Registry, cfg.Tenant.Header, cfg.Tenant.Default)
+	if err != nil {
+		return fmt.Errorf("initializing tenants: %w", err)
+	}
+
+	// =========================================================================
+	// Initialize Lifecycle Support
+
+	// The supervisor owns the servers, the workers and the resources closed
+	// once they stop, and drains them in order on shutdown.
+	sup, err := lifecycle.New(lifecycle.Config{
+		DrainDelay:      cfg.Web.DrainDelay,
+		ShutdownTimeout: cfg.Web.ShutdownTimeout,
+		HardStop:        cfg.Web.HardStop,
+	}, log)
+	if err != nil {
+		return fmt.Errorf("initializing lifecycle: %w", err)
+	}
+
+	// =========================================================================
+	// Initialize Tracing Support
+
+	// The tracer is closed last, so the spans of the last requests are exported.
+	tracer, err := newTracer(log, cfg.Trace.Exporter, cfg.Trace.Path, cfg.Trace.Endpoint, cfg.Trace.Headers, cfg.Trace.SampleRatio)
+	if err != nil {
+		return fmt.Errorf("initializing tracing: %w", err)
+	}
+	sup.Closer("tracer", tracer.Shutdown)
+
+	fbOpts, err := firebaseOptions(context.Background(), cfg.Firebase.EmulatorHost, cfg.Firebase.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("error initializing firebase transport: %w", err)
+	}
+
+	fbClient, err := firebase.NewApp(context.Background(), &firebase.Config{ProjectID: cfg.Firebase.ProjectID}, fbOpts...)
 	if err != nil {
 		return fmt.Errorf("error initializing firebase client: %w", err)
 	}
@@ -110,90 +374,620 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("error initializing firebase auth client: %w", err)
 	}
 
+	// =========================================================================
+	// Initialize Storage Support
+
+	// The state of the use cases is shared between the instances through
+	// firestore. The memory storage keeps it in the instance.
+	var fsClient *firestore.Client
+	if cfg.Storage == "firestore" {
+		fsClient, err = firestore.NewClient(context.Background(), cfg.Firebase.ProjectID, firestoreOptions(cfg.Firebase.CredentialsFile)...)
+		if err != nil {
+			return fmt.Errorf("error initializing firestore client: %w", err)
+		}
+		sup.Closer("firestore", func(context.Context) error {
+			return fsClient.Close()
+		})
+	} else {
+		log.Warnw("startup", "status", "memory storage, the mfa enrolments, the api keys and the passkeys are lost on restart and not shared between instances")
+	}
+
+	// =========================================================================
+	// Initialize Authentication Provider
+
+	// The memory provider takes the sign-up, the sign-in, the sessions, the
+	// roles, the api key owners and the passkey sessions over from firebase,
+	// its users are lost on restart.
+	var memAuth *memauth.Provider
+	if cfg.Provider == "memory" {
+		log.Warnw("startup", "status", "memory provider, the users are lost on restart and the profile and admin routes still call firebase")
+
+		memAuth, err = memauth.New()
+		if err != nil {
+			return fmt.Errorf("initializing memory provider: %w", err)
+		}
+	}
+
+	// =========================================================================
+	// Initialize Runtime Settings
+
+	// The settings are applied by their subscribers, they are read again on
+	// SIGHUP and can be changed on the debug server.
+	settingsStore, err := reload.New(newSettings(cfg.Log.Level, cfg.RateLimit.IP, cfg.RateLimit.Principal, cfg.RateLimit.SignUp, cfg.RateLimit.SignIn, cfg.CORS.AllowedOrigins, cfg.CSRF.AllowedOrigins), log)
+	if err != nil {
+		return fmt.Errorf("initializing settings: %w", err)
+	}
+	level.SetLevel(settingsStore.Current().(settings).level())
+	settingsStore.Subscribe(func(cfg interface{}) {
+		level.SetLevel(cfg.(settings).level())
+	})
+
+	sup.OnReload("settings", func() error {
+		next := blank
+		if _, err := conf.Parse(prefix, &next); err != nil {
+			return fmt.Errorf("parsing config: %w", err)
+		}
+		if file != nil {
+			if err := file.Apply(prefix, os.Args[1:], &next); err != nil {
+				return fmt.Errorf("parsing config: %w", err)
+			}
+		}
+		_, err := settingsStore.Apply(newSettings(next.Log.Level, next.RateLimit.IP, next.RateLimit.Principal, next.RateLimit.SignUp, next.RateLimit.SignIn, next.CORS.AllowedOrigins, next.CSRF.AllowedOrigins))
+		return err
+	})
+
+	// =========================================================================
+	// Initialize TLS Support
+
+	// The certificate is reloaded on SIGHUP and when its files change.
+	apiTLS, debugTLS, err := newTLSConfigs(log, sup, cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.MinVersion, cfg.TLS.WatchInterval, cfg.TLS.DebugClientCAFile)
+	if err != nil {
+		return fmt.Errorf("initializing tls: %w", err)
+	}
+
 	// =========================================================================
 	// Start Debug Service
-	log.Infow("startup", "status", "debug router started", "host", cfg.Web.DebugHost)
 
 	// The Debug function returns a mux to listen and serve on for all the debug
 	// related endpoints. This include the standard library endpoints.
 
+	// The checks of the dependencies are registered as they are constructed.
+	checker := health.New(build, cfg.Web.ReadinessTimeout)
+	var sessionVerifier auth.SessionVerifier
+	if memAuth != nil {
+		sessionVerifier = auth.NewMemory(memAuth)
+	} else {
+		fbAuth := auth.NewFirebase(fbAuthClient)
+		checker.Register("firebase", fbAuth.Ping)
+		sessionVerifier = fbAuth
+	}
+
+	// The load shedding limit adapts to the latency of the api requests.
+	shed, err := loadshed.New(loadshed.Config{
+		InitialLimit:   cfg.LoadShed.InitialLimit,
+		MinLimit:       cfg.LoadShed.MinLimit,
+		MaxLimit:       cfg.LoadShed.MaxLimit,
+		Tolerance:      cfg.LoadShed.Tolerance,
+		Backoff:        cfg.LoadShed.Backoff,
+		Floor:          cfg.LoadShed.Floor,
+		BaselineWindow: cfg.LoadShed.BaselineWindow,
+	})
+	if err != nil {
+		return fmt.Errorf("initializing load shedding: %w", err)
+	}
+
 	// Construct the mux for the debug calls.
-	debugMux := mux.DebugStandardLibraryMux()
+	debugMux := mux.DebugMux(checker, shed, settingsStore.Handler(cfg.Web.DebugToken), cfg.TLS.DebugClientCAFile != "")
+
+	// The debug server is registered first so it is stopped last, the
+	// readiness stays observable while the api drains.
+	sup.Server("debug", &http.Server{
+		Addr:      cfg.Web.DebugHost,
+		Handler:   debugMux,
+		TLSConfig: debugTLS,
+		ErrorLog:  zap.NewStdLog(log.Desugar()),
+	})
 
-	// Start the service listening for debug requests.
-	// Not concerned with shutting this down with load shedding.
-	go func() {
-		if err := http.ListenAndServe(cfg.Web.DebugHost, debugMux); err != nil {
-			log.Errorw("shutdown", "status", "debug router closed", "host", cfg.Web.DebugHost, "ERROR", err)
-		}
-	}()
+	// Report not ready while the outstanding requests complete.
+	sup.OnDrain(checker.Drain)
 
 	// =========================================================================
 	// Start API Service
 	log.Infow("startup", "status", "initializing API support")
 
-	// Make a channel to listen for an interrupt or terminate signal from the OS.
-	// Use a buffered channel because the signal package requires it.
-	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+	// The audit log is closed after the servers stop, so no event is lost.
+	auditLog, err := newAuditLog(log, cfg.Audit.Sink, cfg.Audit.Path)
+	if err != nil {
+		return fmt.Errorf("initializing audit log: %w", err)
+	}
+	sup.Closer("audit", func(context.Context) error {
+		return auditLog.Close()
+	})
+	checker.Register("audit", auditLog.Check)
+
+	// The links of the notifications are mailed through the relay, without
+	// it they are only reported as issued.
+	var mailSender *mail.Sender
+	if cfg.Mail.Relay != "" {
+		mailSender, err = mail.New(mail.Config{
+			Addr:     cfg.Mail.Relay,
+			Username: cfg.Mail.Username,
+			Password: cfg.Mail.Password,
+			From:     cfg.Mail.From,
+		})
+		if err != nil {
+			return fmt.Errorf("initializing mail: %w", err)
+		}
+	} else {
+		log.Warnw("startup", "status", "no mail relay configured, the links of the notifications are not delivered")
+	}
+
+	// The cookies only travel over https, but in development without a
+	// certificate. Outside development a TLS terminating proxy is assumed
+	// when the service has no certificate.
+	cookies := auth.Cookies{Secure: cfg.TLS.CertFile != "" || cfg.Firebase.EmulatorHost == ""}
 
 	// Construct the mux for the API calls.
-	fbSignUp := signup.NewFirebase(fbAuthClient)
-	serviceSignUp := signup.NewService(fbSignUp)
+	var apSignUp signup.AuthnProvider = signup.NewFirebase(fbAuthClient)
+	if memAuth != nil {
+		apSignUp = signup.NewMemory(memAuth)
+	}
+	serviceSignUp := signup.NewService(apSignUp, tenants, auditLog)
 	handlerSignUp := signup.HttpHandler(serviceSignUp)
 
-	fbSignIn := signin.NewFirebase(fbAuthClient)
-	serviceSignIn := signin.NewService(fbSignIn)
-	handlerSignIn := signin.HttpHandler(serviceSignIn)
+	mfaBox, err := newSecretBox(log, cfg.MFA.EncryptionKey)
+	if err != nil {
+		return fmt.Errorf("initializing mfa encryption: %w", err)
+	}
+	var mfaStore mfa.Store = mfa.NewMemory()
+	if fsClient != nil {
+		mfaStore = mfa.NewFirestore(fsClient, "mfaEnrolments")
+	}
+	serviceMFA := mfa.NewService(mfaStore, mfaBox, cfg.MFA.Issuer)
+	handlersMFA := mfa.HttpHandlers(serviceMFA)
+
+	lockoutUser, err := lockout.NewPolicy(cfg.Lockout.Window, cfg.Lockout.FreeAttempts, cfg.Lockout.BaseDelay, cfg.Lockout.MaxDelay, cfg.Lockout.UserMaxAttempts, cfg.Lockout.LockDuration)
+	if err != nil {
+		return fmt.Errorf("invalid user lockout policy: %w", err)
+	}
+	lockoutIP, err := lockout.NewPolicy(cfg.Lockout.Window, cfg.Lockout.FreeAttempts, cfg.Lockout.BaseDelay, cfg.Lockout.MaxDelay, cfg.Lockout.IPMaxAttempts, cfg.Lockout.LockDuration)
+	if err != nil {
+		return fmt.Errorf("invalid ip lockout policy: %w", err)
+	}
+	serviceLockout := lockout.NewService(lockout.NewMemory(), lockoutUser, lockoutIP, auditLog)
+	handlerLockout := lockout.HttpHandler(serviceLockout)
+
+	var challenges signin.ChallengeStore = signin.NewMemoryChallenges()
+	if fsClient != nil {
+		challenges = signin.NewFirestoreChallenges(fsClient, "signinChallenges")
+	}
+	serviceSignIn := signin.NewService(signin.NewFirebase(fbAuthClient), serviceMFA, challenges, serviceLockout, tenants, auditLog)
+	var handlerSignInPassword web.Handler
+	if memAuth != nil {
+		serviceSignIn = signin.NewService(signin.NewMemory(memAuth), serviceMFA, challenges, serviceLockout, tenants, auditLog)
+		handlerSignInPassword = signin.PasswordHttpHandler(memAuth)
+	}
+	handlerSignIn := signin.HttpHandler(serviceSignIn, cookies)
+	handlerSignInMFA := signin.MFAHttpHandler(serviceSignIn, cookies)
+	handlerSignOut := signin.SignOutHttpHandler(serviceSignIn, cookies)
+
+	var (
+		passkeyCredentials passkey.CredentialStore = passkey.NewMemoryCredentials()
+		passkeyCeremonies  passkey.CeremonyStore   = passkey.NewMemoryCeremonies()
+	)
+	if fsClient != nil {
+		passkeyCredentials = passkey.NewFirestoreCredentials(fsClient, "passkeyCredentials")
+		passkeyCeremonies = passkey.NewFirestoreCeremonies(fsClient, "passkeyCeremonies")
+	}
+	var apPasskey passkey.AuthnProvider = passkey.NewFirebase(fbAuthClient, cfg.Firebase.APIKey)
+	if memAuth != nil {
+		apPasskey = passkey.NewMemory(memAuth)
+	}
+	servicePasskey := passkey.NewService(
+		passkey.Config{
+			RPID:    cfg.Passkey.RPID,
+			RPName:  cfg.Passkey.RPName,
+			Origins: cfg.Passkey.Origins,
+		},
+		passkeyCredentials,
+		passkeyCeremonies,
+		apPasskey,
+		tenants,
+		auditLog,
+	)
+	handlersPasskey := passkey.HttpHandlers(servicePasskey, cookies)
+
+	// The instances share the signing keys, so they publish the same key
+	// set. The generated keys are rotated here, the retired ones stay
+	// published until the last tokens they signed expire.
+	tokenKeyring, err := newKeyring(log, cfg.Token.Algorithm, cfg.Token.TTL, cfg.Token.SigningKeys)
+	if err != nil {
+		return fmt.Errorf("initializing token keyring: %w", err)
+	}
+	if len(cfg.Token.SigningKeys) == 0 {
+		sup.Worker("keyring", func(ctx context.Context) {
+			tokenKeyring.Run(ctx, cfg.Token.RotationInterval, func(err error) {
+				log.Errorw("keyring", "status", "rotation failed", "ERROR", err)
+			})
+		})
+	}
+
+	serviceToken := token.NewService(
+		token.Config{
+			Issuer:   cfg.Token.Issuer,
+			Audience: cfg.Token.Audience,
+			TTL:      cfg.Token.TTL,
+		},
+		tokenKeyring,
+	)
+	handlersToken := token.HttpHandlers(serviceToken)
+
+	var apAPIKey apikey.AuthnProvider = apikey.NewFirebase(fbAuthClient)
+	if memAuth != nil {
+		apAPIKey = apikey.NewMemoryOwners(memAuth)
+	}
+	var apiKeyStore apikey.Store = apikey.NewMemory()
+	if fsClient != nil {
+		apiKeyStore = apikey.NewFirestore(fsClient, "apiKeys")
+	}
+	serviceAPIKey := apikey.NewService(apiKeyStore, apAPIKey)
+	handlersAPIKey := apikey.HttpHandlers(serviceAPIKey)
+
+	var profileNotifier profile.Notifier = profile.NewLogNotifier(log)
+	if mailSender != nil {
+		profileNotifier = profile.NewMailer(mailSender)
+	}
+	fbProfile := profile.NewFirebase(fbAuthClient, cfg.Firebase.APIKey)
+	serviceProfile := profile.NewService(fbProfile, profileNotifier, tenants)
+	handlerProfile := profile.HttpHandler(serviceProfile, cookies)
+
+	var apRole role.AuthnProvider = role.NewFirebase(fbAuthClient)
+	if memAuth != nil {
+		apRole = role.NewMemory(memAuth)
+	}
+	serviceRole := role.NewService(apRole, auditLog)
+	handlerRole := role.HttpHandler(serviceRole)
+
+	var adminNotifier admin.Notifier = admin.NewLogNotifier(log)
+	if mailSender != nil {
+		adminNotifier = admin.NewMailer(mailSender)
+	}
+	fbAdmin := admin.NewFirebase(fbAuthClient)
+	serviceAdmin := admin.NewService(fbAdmin, adminNotifier, auditLog)
+	handlersAdmin := admin.HttpHandlers(serviceAdmin)
+
+	serviceAuditLog := auditlog.NewService(auditLog)
+	handlersAuditLog := auditlog.HttpHandlers(serviceAuditLog)
+
+	corsConfig := mid.CORSConfig{
+		AllowedOrigins: cfg.CORS.AllowedOrigins,
+		AllowedMethods: cfg.CORS.AllowedMethods,
+		AllowedHeaders: cfg.CORS.AllowedHeaders,
+		ExposedHeaders: cfg.CORS.ExposedHeaders,
+		MaxAge:         cfg.CORS.MaxAge,
+	}
+	corsPolicy, err := mid.NewCORSPolicy(corsConfig)
+	if err != nil {
+		return fmt.Errorf("invalid cors config: %w", err)
+	}
+	settingsStore.Subscribe(func(cfg interface{}) {
+		c := corsPolicy.Config()
+		c.AllowedOrigins = cfg.(settings).CORSAllowedOrigins
+		if err := corsPolicy.Update(c); err != nil {
+			log.Errorw("reload", "status", "cors config rejected", "ERROR", err)
+		}
+	})
+
+	csrfProtector, err := newCSRFProtector(log, cfg.CSRF.Key, cfg.CSRF.AllowedOrigins)
+	if err != nil {
+		return fmt.Errorf("initializing csrf protection: %w", err)
+	}
+	settingsStore.Subscribe(func(cfg interface{}) {
+		if err := csrfProtector.SetOrigins(cfg.(settings).CSRFAllowedOrigins); err != nil {
+			log.Errorw("reload", "status", "csrf origins rejected", "ERROR", err)
+		}
+	})
+
+	rateLimits := mux.RateLimits{
+		IP:        ratelimit.NewVar(cfg.RateLimit.IP),
+		Principal: ratelimit.NewVar(cfg.RateLimit.Principal),
+		SignUp:    ratelimit.NewVar(cfg.RateLimit.SignUp),
+		SignIn:    ratelimit.NewVar(cfg.RateLimit.SignIn),
+	}
+	settingsStore.Subscribe(func(cfg interface{}) {
+		l := cfg.(settings).RateLimit
+		rateLimits.IP.Store(l.IP)
+		rateLimits.Principal.Store(l.Principal)
+		rateLimits.SignUp.Store(l.SignUp)
+		rateLimits.SignIn.Store(l.SignIn)
+	})
+
+	rateLimitStore := ratelimit.NewMemory()
+	sup.Worker("ratelimit", func(ctx context.Context) {
+		rateLimitStore.Run(ctx, cfg.RateLimit.EvictInterval)
+	})
+
+	// The lockout and the rate limits count the client forwarded by the
+	// proxies, not the proxies themselves.
+	trustedProxies, err := web.ParseTrustedProxies(cfg.Web.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("parsing trusted proxies: %w", err)
+	}
 
 	apiMux := mux.APIMux(mux.APIMuxConfig{
-		Log:           log,
-		SignUpHandler: handlerSignUp,
-		SignInHandler: handlerSignIn,
+		Log:              log,
+		Tracer:           tracer,
+		SignUpHandler:    handlerSignUp,
+		SignInHandler:    handlerSignIn,
+		SignInMFAHandler: handlerSignInMFA,
+		SignInPassword:   handlerSignInPassword,
+		SignOutHandler:   handlerSignOut,
+		MFAHandlers:      handlersMFA,
+		PasskeyHandlers:  handlersPasskey,
+		TokenHandlers:    handlersToken,
+		APIKeyHandlers:   handlersAPIKey,
+		ProfileHandler:   handlerProfile,
+		RoleHandler:      handlerRole,
+		AdminHandlers:    handlersAdmin,
+		AuditLogHandlers: handlersAuditLog,
+		LockoutHandler:   handlerLockout,
+		SessionVerifier:  sessionVerifier,
+		APIKeyVerifier:   serviceAPIKey,
+		CORS:             corsPolicy,
+		Tenants:          tenants,
+		CSRF:             csrfProtector,
+		Cookies:          cookies,
+		RateLimitStore:   rateLimitStore,
+		Shed:             shed,
+		Shutdown:         sup.Shutdown(),
+		RateLimits:       rateLimits,
+		Timeouts: mux.Timeouts{
+			Default: cfg.Web.RouteTimeout,
+			Slow:    cfg.Web.SlowRouteTimeout,
+		},
+		TrustedProxies: trustedProxies,
 	})
 
 	// Construct a server to service the requests.
-	api := http.Server{
+	sup.Server("api", &http.Server{
 		Addr:         cfg.Web.APIHost,
 		Handler:      apiMux,
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
 		IdleTimeout:  cfg.Web.IdleTimeout,
+		TLSConfig:    apiTLS,
 		ErrorLog:     zap.NewStdLog(log.Desugar()),
+	})
+
+	// =========================================================================
+	// Start and Shutdown
+
+	// Blocking main until the service is drained.
+	return sup.Run()
+}
+
+// newTLSConfigs constructs the TLS configs of the api and debug servers.
+// Without a certificate both are nil and the servers listen in plain text,
+// e.g. behind a TLS terminating proxy. With a client CAs file the debug
+// server verifies the client certificates, the debug mux requires one on
+// pprof and the config.
+func newTLSConfigs(log *zap.SugaredLogger, sup *lifecycle.Supervisor, certFile string, keyFile string, minVersion string, watchInterval time.Duration, debugClientCAFile string) (*tls.Config, *tls.Config, error) {
+	if certFile == "" {
+		if debugClientCAFile != "" {
+			return nil, nil, errors.New("client certificates require a server certificate")
+		}
+		log.Warnw("startup", "status", "no tls certificate configured, serving plain text")
+		return nil, nil, nil
 	}
 
-	// Make a channel to listen for errors coming from the listener. Use a
-	// buffered channel so the goroutine can exit if we don't collect this error.
-	serverErrors := make(chan error, 1)
+	version, err := certs.ParseVersion(minVersion)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Start the service listening for api requests.
-	go func() {
-		log.Infow("startup", "status", "api router started", "host", api.Addr)
-		serverErrors <- api.ListenAndServe()
-	}()
+	reloader, err := certs.New(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	metrics.NewGaugeFunc("tls_certificate_expiry_timestamp_seconds", "Expiry of the served certificate.", func() float64 {
+		return float64(reloader.NotAfter().Unix())
+	})
 
-	// =========================================================================
-	// Shutdown
+	sup.OnReload("tls", reloader.Reload)
+	sup.Worker("tls", func(ctx context.Context) {
+		reloader.Watch(ctx, watchInterval, func(err error) {
+			if err != nil {
+				log.Errorw("tls", "status", "certificate reload failed", "ERROR", err)
+				return
+			}
+			log.Infow("tls", "status", "certificate reloaded", "notAfter", reloader.NotAfter())
+		})
+	})
 
-	// Blocking main and waiting for shutdown.
-	select {
-	case err := <-serverErrors:
-		return fmt.Errorf("server error: %w", err)
+	apiTLS := certs.ServerConfig(reloader, version)
+	debugTLS := certs.ServerConfig(reloader, version)
+	if debugClientCAFile != "" {
+		if err := certs.VerifyClientCerts(debugTLS, debugClientCAFile); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	case sig := <-shutdown:
-		log.Infow("shutdown", "status", "shutdown started", "signal", sig)
-		defer log.Infow("shutdown", "status", "shutdown complete", "signal", sig)
+	return apiTLS, debugTLS, nil
+}
 
-		// Give outstanding requests a deadline for completion.
-		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
-		defer cancel()
+// newTenantRegistry constructs the registry of the tenants of the
+// configuration. Without tenants every request goes to the project users.
+func newTenantRegistry(specs []string, header string, defaultID string) (*tenant.Registry, error) {
+	tenants := make([]tenant.Tenant, 0, len(specs))
+	for _, spec := range specs {
+		t, err := tenant.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenant.NewRegistry(tenants, header, defaultID)
+}
 
-		// Asking listener to shutdown and shed load.
-		if err := api.Shutdown(ctx); err != nil {
-			api.Close()
-			return fmt.Errorf("could not stop server gracefully: %w", err)
+// newSecretBox constructs the box used to encrypt secrets at rest. Without a
+// configured key, which is only allowed in development, an ephemeral one is
+// generated, so the sealed values do not survive a restart.
+func newSecretBox(log *zap.SugaredLogger, encodedKey string) (*secretbox.Box, error) {
+	if encodedKey == "" {
+		log.Warnw("startup", "status", "no encryption key configured, using an ephemeral key")
+
+		key, err := secretbox.NewKey()
+		if err != nil {
+			return nil, err
 		}
+		return secretbox.New(key)
 	}
 
-	return nil
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding key: %w", err)
+	}
+	return secretbox.New(key)
+}
+
+// newKeyring constructs the keyring of the access tokens on the shared keys.
+// Without them the keys are generated, so the tokens of the instance are not
+// verified with the key set of another one.
+func newKeyring(log *zap.SugaredLogger, alg string, ttl time.Duration, encodedKeys []string) (*keyring.Keyring, error) {
+	if len(encodedKeys) == 0 {
+		log.Warnw("startup", "status", "no signing keys configured, using generated keys of this instance only")
+		return keyring.New(alg, ttl)
+	}
+
+	keys := make([][]byte, len(encodedKeys))
+	for i, encodedKey := range encodedKeys {
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %d: %w", i+1, err)
+		}
+		keys[i] = key
+	}
+	return keyring.NewShared(alg, keys)
+}
+
+// newCSRFProtector constructs the csrf protection. Without a configured key
+// an ephemeral one is generated, so the issued tokens do not survive a
+// restart and are not shared between instances.
+func newCSRFProtector(log *zap.SugaredLogger, encodedKey string, origins []string) (*csrf.Protector, error) {
+	if encodedKey == "" {
+		log.Warnw("startup", "status", "no csrf key configured, using an ephemeral key")
+
+		key, err := csrf.NewKey()
+		if err != nil {
+			return nil, err
+		}
+		return csrf.New(key, origins)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding key: %w", err)
+	}
+	return csrf.New(key, origins)
+}
+
+// newAuditLog constructs the audit log writing to the configured sink. Only
+// the file sink can be queried and verified.
+func newAuditLog(log *zap.SugaredLogger, sink string, path string) (*audit.Log, error) {
+	var s audit.Sink
+	switch sink {
+	case "stdout":
+		s = audit.NewWriter(os.Stdout)
+	case "file":
+		f, err := audit.NewFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s = f
+	default:
+		return nil, fmt.Errorf("unknown sink %q", sink)
+	}
+
+	return audit.New(s, auditActor, log)
+}
+
+// auditActor returns the uid of the authenticated principal, if any.
+func auditActor(ctx context.Context) string {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return ""
+	}
+	return claims.UID
+}
+
+// newTracer constructs the tracer exporting the spans to the configured
+// exporter. With no exporter the trace context is still propagated.
+func newTracer(log *zap.SugaredLogger, exporter string, path string, endpoint string, headers string, ratio float64) (*trace.Tracer, error) {
+	var exp trace.Exporter
+	switch exporter {
+	case "none":
+	case "file":
+		f, err := trace.NewFile(path)
+		if err != nil {
+			return nil, err
+		}
+		exp = f
+	case "otlp":
+		h, err := trace.ParseHeaders(headers)
+		if err != nil {
+			return nil, err
+		}
+		exp = trace.NewOTLP(endpoint, "auth-api", h, &http.Client{Timeout: 10 * time.Second})
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", exporter)
+	}
+
+	cfg := trace.Config{
+		Service:     "auth-api",
+		SampleRatio: ratio,
+		OnError: func(err error) {
+			log.Errorw("trace", "status", "export failed", "ERROR", err)
+		},
+	}
+	return trace.NewTracer(cfg, exp), nil
+}
+
+// firebaseScopes are the scopes requested by the firebase sdk.
+var firebaseScopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/datastore",
+	"https://www.googleapis.com/auth/devstorage.full_control",
+	"https://www.googleapis.com/auth/firebase",
+	"https://www.googleapis.com/auth/identitytoolkit",
+	"https://www.googleapis.com/auth/userinfo.email",
+}
+
+// firebaseOptions returns the options making the firebase calls carry the
+// trace context, authenticated with the credentials file when there is one.
+// The sdk ignores the options when talking to the emulator.
+func firebaseOptions(ctx context.Context, emulatorHost string, credentialsFile string) ([]option.ClientOption, error) {
+	if emulatorHost != "" {
+		return nil, nil
+	}
+
+	opts := []option.ClientOption{option.WithScopes(firebaseScopes...)}
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	rt, err := htransport.NewTransport(ctx, &trace.Transport{}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithHTTPClient(&http.Client{Transport: rt})}, nil
+}
+
+// firestoreOptions returns the options authenticating the firestore calls
+// with the credentials file when there is one. The client ignores them when
+// talking to the emulator.
+func firestoreOptions(credentialsFile string) []option.ClientOption {
+	if credentialsFile == "" {
+		return nil
+	}
+	return []option.ClientOption{option.WithCredentialsFile(credentialsFile)}
 }
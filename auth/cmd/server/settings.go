@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/csrf"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/ratelimit"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/mid"
+	"go.uber.org/zap/zapcore"
+)
+
+// settings is the part of the configuration applied without a restart. It
+// is read again on SIGHUP and can be changed on the debug server.
+type settings struct {
+	LogLevel           string            `json:"logLevel"`
+	RateLimit          rateLimitSettings `json:"rateLimit"`
+	CORSAllowedOrigins []string          `json:"corsAllowedOrigins"`
+	CSRFAllowedOrigins []string          `json:"csrfAllowedOrigins"`
+}
+
+// rateLimitSettings holds the limits of the api routes.
+type rateLimitSettings struct {
+	IP        ratelimit.Limit `json:"ip"`
+	Principal ratelimit.Limit `json:"principal"`
+	SignUp    ratelimit.Limit `json:"signUp"`
+	SignIn    ratelimit.Limit `json:"signIn"`
+}
+
+// Validate checks the settings can be applied.
+func (s settings) Validate() error {
+	if _, err := zapcore.ParseLevel(s.LogLevel); err != nil {
+		return fmt.Errorf("log level: %w", err)
+	}
+	if err := mid.ValidateOrigins(s.CORSAllowedOrigins); err != nil {
+		return fmt.Errorf("cors: %w", err)
+	}
+	if err := csrf.ValidateOrigins(s.CSRFAllowedOrigins); err != nil {
+		return err
+	}
+	return nil
+}
+
+// level returns the log level of the settings, they are validated.
+func (s settings) level() zapcore.Level {
+	l, _ := zapcore.ParseLevel(s.LogLevel)
+	return l
+}
+
+// newSettings collects the settings from the configuration.
+func newSettings(logLevel string, ip ratelimit.Limit, principal ratelimit.Limit, signUp ratelimit.Limit, signIn ratelimit.Limit, corsOrigins []string, csrfOrigins []string) settings {
+	return settings{
+		LogLevel: logLevel,
+		RateLimit: rateLimitSettings{
+			IP:        ip,
+			Principal: principal,
+			SignUp:    signUp,
+			SignIn:    signIn,
+		},
+		CORSAllowedOrigins: corsOrigins,
+		CSRFAllowedOrigins: csrfOrigins,
+	}
+}
@@ -3,17 +3,19 @@ module github.com/mroobert/go-tickets/auth
 go 1.17
 
 require (
+	cloud.google.com/go/firestore v1.6.1
 	firebase.google.com/go/v4 v4.7.1
 	github.com/ardanlabs/conf/v3 v3.1.2
 	github.com/dimfeld/httptreemux/v5 v5.4.0
-	github.com/google/uuid v1.1.2
 	go.uber.org/automaxprocs v1.4.0
 	go.uber.org/zap v1.21.0
+	google.golang.org/api v0.63.0
+	google.golang.org/grpc v1.43.0
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
 
 require (
 	cloud.google.com/go v0.99.0 // indirect
-	cloud.google.com/go/firestore v1.6.1 // indirect
 	cloud.google.com/go/storage v1.10.0 // indirect
 	github.com/census-instrumentation/opencensus-proto v0.3.0 // indirect
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
@@ -33,9 +35,7 @@ require (
 	golang.org/x/sys v0.0.0-20211210111614-af8b64212486 // indirect
 	golang.org/x/text v0.3.7 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
-	google.golang.org/api v0.63.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto v0.0.0-20211208223120-3a66f561d7aa // indirect
-	google.golang.org/grpc v1.43.0 // indirect
 	google.golang.org/protobuf v1.27.1 // indirect
 )
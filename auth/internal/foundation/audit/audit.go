@@ -0,0 +1,235 @@
+// Package audit records the security events in an append-only log. Every
+// event holds the hash of the previous one, so removing or changing an event
+// breaks the chain and is detected by Verify.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"go.uber.org/zap"
+)
+
+// These are the outcomes of an action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// genesis is the previous hash of the first event.
+const genesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// ErrNotQueryable is used when the sink can't be read back.
+var ErrNotQueryable = errors.New("audit: sink can't be queried")
+
+// Event represents a security event.
+type Event struct {
+	Seq       uint64            `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Action    string            `json:"action"`
+	Outcome   string            `json:"outcome"`
+	Actor     string            `json:"actor,omitempty"`
+	Subject   string            `json:"subject,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"userAgent,omitempty"`
+	TraceID   string            `json:"traceid,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	PrevHash  string            `json:"prevHash"`
+	Hash      string            `json:"hash"`
+}
+
+// Failed sets the outcome of the event from the error of the action.
+func (e Event) Failed(err error) Event {
+	if err == nil {
+		if e.Outcome == "" {
+			e.Outcome = OutcomeSuccess
+		}
+		return e
+	}
+	e.Outcome = OutcomeFailure
+	e.Reason = err.Error()
+	return e
+}
+
+// hash returns the hash chaining the event to the previous one.
+func (e Event) hash() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(e.PrevHash))
+	h.Write([]byte{'\n'})
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Sink stores the events. Append must not reorder the lines.
+type Sink interface {
+	Append(line []byte) error
+	Close() error
+}
+
+// tailer is implemented by the sinks that can return their last event, so
+// the chain resumes after a restart.
+type tailer interface {
+	Last() (Event, bool, error)
+}
+
+// querier is implemented by the sinks that can be read back.
+type querier interface {
+	Query(ctx context.Context, f Filter) ([]Event, error)
+	Verify(ctx context.Context) (int, error)
+}
+
+// ActorFunc returns the principal of the request, if any.
+type ActorFunc func(ctx context.Context) string
+
+// Log appends the events to a sink, chaining them. It is safe for concurrent use.
+type Log struct {
+	sink  Sink
+	actor ActorFunc
+	log   *zap.SugaredLogger
+
+	mu      sync.Mutex
+	seq     uint64
+	last    string
+	lastErr error
+}
+
+// New constructs a Log writing to the sink. The actor of the events is
+// read from the context with the actor function when not set.
+func New(sink Sink, actor ActorFunc, log *zap.SugaredLogger) (*Log, error) {
+	l := Log{
+		sink:  sink,
+		actor: actor,
+		log:   log,
+		last:  genesis,
+	}
+
+	if t, ok := sink.(tailer); ok {
+		e, found, err := t.Last()
+		if err != nil {
+			return nil, fmt.Errorf("audit: reading last event: %w", err)
+		}
+		if found {
+			l.seq = e.Seq
+			l.last = e.Hash
+		}
+	}
+
+	return &l, nil
+}
+
+// Record completes the event with the request values, chains it and appends
+// it to the sink. Failures can't be returned to the action that is audited,
+// they are logged instead.
+func (l *Log) Record(ctx context.Context, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Outcome == "" {
+		e.Outcome = OutcomeSuccess
+	}
+	if e.Actor == "" && l.actor != nil {
+		e.Actor = l.actor(ctx)
+	}
+	if v, err := web.GetValues(ctx); err == nil {
+		if e.TraceID == "" {
+			e.TraceID = v.TraceID
+		}
+		if e.Tenant == "" {
+			e.Tenant = v.TenantID
+		}
+		if e.IP == "" {
+			e.IP = v.ClientIP
+		}
+		if e.UserAgent == "" {
+			e.UserAgent = v.UserAgent
+		}
+	}
+
+	if err := l.append(e); err != nil {
+		l.log.Errorw("audit", "traceid", e.TraceID, "action", e.Action, "subject", e.Subject, "ERROR", err)
+	}
+}
+
+// append chains the event and writes it to the sink.
+func (l *Log) append(e Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Seq = l.seq + 1
+	e.PrevHash = l.last
+
+	hash, err := e.hash()
+	if err != nil {
+		return fmt.Errorf("hashing event: %w", err)
+	}
+	e.Hash = hash
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+	if err := l.sink.Append(line); err != nil {
+		l.lastErr = err
+		return fmt.Errorf("appending event: %w", err)
+	}
+	l.lastErr = nil
+
+	l.seq = e.Seq
+	l.last = e.Hash
+	return nil
+}
+
+// Query returns the events matching the filter, the newest first.
+func (l *Log) Query(ctx context.Context, f Filter) ([]Event, error) {
+	q, ok := l.sink.(querier)
+	if !ok {
+		return nil, ErrNotQueryable
+	}
+	return q.Query(ctx, f)
+}
+
+// Verify checks the chain of the events in the sink and returns the number
+// of events verified.
+func (l *Log) Verify(ctx context.Context) (int, error) {
+	q, ok := l.sink.(querier)
+	if !ok {
+		return 0, ErrNotQueryable
+	}
+	return q.Verify(ctx)
+}
+
+// Check reports if the last event could not be appended, so the service
+// stops taking requests it can't audit.
+func (l *Log) Check(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lastErr != nil {
+		return fmt.Errorf("audit: sink failing: %w", l.lastErr)
+	}
+	return nil
+}
+
+// Close closes the sink.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.sink.Close()
+}
@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Filter selects the events returned by a query. Empty fields match all,
+// but the tenant: it always matches, the empty tenant is the project.
+type Filter struct {
+	Tenant  string
+	Actor   string
+	Subject string
+	Action  string
+	Outcome string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// match reports if the event matches the filter. Actions ending with a dot
+// match every action of the group, e.g. "users." matches "users.disable".
+func (f Filter) match(e Event) bool {
+	switch {
+	case e.Tenant != f.Tenant:
+		return false
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case f.Subject != "" && e.Subject != f.Subject:
+		return false
+	case f.Outcome != "" && e.Outcome != f.Outcome:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, ".") {
+			return strings.HasPrefix(e.Action, f.Action)
+		}
+		return e.Action == f.Action
+	}
+	return true
+}
+
+// Writer is a sink writing the events as JSON lines to a writer, e.g.
+// os.Stdout for a log collector to ship them. It can't be queried.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter constructs a sink writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Append writes the line.
+func (wr *Writer) Append(line []byte) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	_, err := wr.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close does nothing, the writer is owned by the caller.
+func (wr *Writer) Close() error {
+	return nil
+}
+
+// File is a sink appending the events to a JSONL file.
+type File struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFile opens the file for appending, creating it when missing.
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: opening file: %w", err)
+	}
+	return &File{path: path, f: f}, nil
+}
+
+// Append writes the line and syncs it to disk, an audit event must not be
+// lost in a crash.
+func (fl *File) Append(line []byte) error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	if _, err := fl.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return fl.f.Sync()
+}
+
+// Close closes the file.
+func (fl *File) Close() error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	return fl.f.Close()
+}
+
+// Last returns the last event of the file.
+func (fl *File) Last() (Event, bool, error) {
+	var last Event
+	var found bool
+	err := fl.scan(func(e Event) bool {
+		last, found = e, true
+		return true
+	})
+	return last, found, err
+}
+
+// Query returns the events matching the filter, the newest first.
+func (fl *File) Query(ctx context.Context, f Filter) ([]Event, error) {
+	var events []Event
+	err := fl.scan(func(e Event) bool {
+		if f.match(e) {
+			events = append(events, e)
+		}
+		return ctx.Err() == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Seq > events[j].Seq
+	})
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[:f.Limit]
+	}
+	return events, nil
+}
+
+// Verify checks the chain of the file.
+func (fl *File) Verify(ctx context.Context) (int, error) {
+	f, err := os.Open(fl.path)
+	if err != nil {
+		return 0, fmt.Errorf("audit: opening file: %w", err)
+	}
+	defer f.Close()
+
+	return Verify(f)
+}
+
+// scan calls fn for every event of the file until it returns false.
+func (fl *File) scan(fn func(e Event) bool) error {
+	f, err := os.Open(fl.path)
+	if err != nil {
+		return fmt.Errorf("audit: opening file: %w", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), maxLine)
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return fmt.Errorf("audit: decoding event: %w", err)
+		}
+		if !fn(e) {
+			break
+		}
+	}
+	return s.Err()
+}
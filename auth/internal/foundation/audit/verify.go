@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxLine is the size of the longest event accepted while reading a log.
+const maxLine = 1 << 20
+
+// ChainError is returned by Verify when the chain is broken.
+type ChainError struct {
+	Line   int
+	Reason string
+}
+
+// Error implements the error interface.
+func (ce *ChainError) Error() string {
+	return fmt.Sprintf("audit: chain broken at line %d: %s", ce.Line, ce.Reason)
+}
+
+// Verify reads a JSONL log and checks that every event hashes to its Hash
+// and points to the hash of the previous event. It returns the number of
+// events verified, and a *ChainError for the first broken link.
+func Verify(r io.Reader) (int, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxLine)
+
+	prev := genesis
+	var seq uint64
+	var line, n int
+	for s.Scan() {
+		line++
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+
+		var e Event
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return n, &ChainError{Line: line, Reason: "malformed event"}
+		}
+
+		switch {
+		case e.PrevHash != prev:
+			return n, &ChainError{Line: line, Reason: "previous hash mismatch"}
+		case e.Seq != seq+1:
+			return n, &ChainError{Line: line, Reason: fmt.Sprintf("expected seq %d, got %d", seq+1, e.Seq)}
+		}
+
+		hash, err := e.hash()
+		if err != nil {
+			return n, &ChainError{Line: line, Reason: err.Error()}
+		}
+		if hash != e.Hash {
+			return n, &ChainError{Line: line, Reason: "hash mismatch"}
+		}
+
+		prev = e.Hash
+		seq = e.Seq
+		n++
+	}
+	if err := s.Err(); err != nil {
+		return n, fmt.Errorf("audit: reading log: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,181 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"go.uber.org/zap"
+)
+
+// chain records n events through a Log and returns the lines of the log.
+func chain(t *testing.T, n int) []string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	l, err := audit.New(audit.NewWriter(&buf), nil, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("constructing log: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		l.Record(context.Background(), audit.Event{Action: "users.disable", Actor: "uid-admin", Subject: "uid-1"})
+	}
+
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+// edit decodes the event of the line, changes it with fn and encodes it back.
+func edit(t *testing.T, line string, fn func(e *audit.Event)) string {
+	t.Helper()
+
+	var e audit.Event
+	if err := json.Unmarshal([]byte(line), &e); err != nil {
+		t.Fatalf("decoding event: %v", err)
+	}
+	fn(&e)
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("encoding event: %v", err)
+	}
+	return string(b)
+}
+
+func TestVerify(t *testing.T) {
+	lines := chain(t, 5)
+
+	n, err := audit.Verify(strings.NewReader(strings.Join(lines, "\n") + "\n"))
+	if err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("got %d events verified, want 5", n)
+	}
+
+	n, err = audit.Verify(strings.NewReader(""))
+	if err != nil || n != 0 {
+		t.Fatalf("got %d events and error %v for an empty log, want none", n, err)
+	}
+}
+
+func TestVerifyBroken(t *testing.T) {
+	lines := chain(t, 5)
+
+	tests := []struct {
+		name   string
+		lines  func() []string
+		line   int
+		reason string
+	}{
+		{
+			name: "tampered event",
+			lines: func() []string {
+				l := append([]string(nil), lines...)
+				l[2] = edit(t, l[2], func(e *audit.Event) { e.Subject = "uid-2" })
+				return l
+			},
+			line:   3,
+			reason: "hash mismatch",
+		},
+		{
+			name: "tampered hash",
+			lines: func() []string {
+				l := append([]string(nil), lines...)
+				l[2] = edit(t, l[2], func(e *audit.Event) {
+					e.Subject = "uid-2"
+					e.Hash = strings.Repeat("f", 64)
+				})
+				return l
+			},
+			line:   3,
+			reason: "hash mismatch",
+		},
+		{
+			name: "removed event",
+			lines: func() []string {
+				l := append([]string(nil), lines[:2]...)
+				return append(l, lines[3:]...)
+			},
+			line:   3,
+			reason: "previous hash mismatch",
+		},
+		{
+			name: "removed first event",
+			lines: func() []string {
+				return append([]string(nil), lines[1:]...)
+			},
+			line:   1,
+			reason: "previous hash mismatch",
+		},
+		{
+			name: "reordered events",
+			lines: func() []string {
+				l := append([]string(nil), lines...)
+				l[1], l[2] = l[2], l[1]
+				return l
+			},
+			line:   2,
+			reason: "previous hash mismatch",
+		},
+		{
+			name: "malformed event",
+			lines: func() []string {
+				l := append([]string(nil), lines...)
+				l[4] = "{"
+				return l
+			},
+			line:   5,
+			reason: "malformed event",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := audit.Verify(strings.NewReader(strings.Join(tt.lines(), "\n") + "\n"))
+
+			var ce *audit.ChainError
+			if !errors.As(err, &ce) {
+				t.Fatalf("got error %v, want a chain error", err)
+			}
+			if ce.Line != tt.line || ce.Reason != tt.reason {
+				t.Fatalf("got %q at line %d, want %q at line %d", ce.Reason, ce.Line, tt.reason, tt.line)
+			}
+			if n != tt.line-1 {
+				t.Fatalf("got %d events verified, want %d", n, tt.line-1)
+			}
+		})
+	}
+}
+
+func TestFileVerifyAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	// The chain resumes from the last event of the file.
+	for i := 0; i < 2; i++ {
+		f, err := audit.NewFile(path)
+		if err != nil {
+			t.Fatalf("opening file: %v", err)
+		}
+		l, err := audit.New(f, nil, zap.NewNop().Sugar())
+		if err != nil {
+			t.Fatalf("constructing log: %v", err)
+		}
+		l.Record(context.Background(), audit.Event{Action: "users.disable", Subject: "uid-1"})
+		l.Record(context.Background(), audit.Event{Action: "users.enable", Subject: "uid-1"})
+
+		n, err := l.Verify(context.Background())
+		if err != nil {
+			t.Fatalf("verifying: %v", err)
+		}
+		if n != 2*(i+1) {
+			t.Fatalf("got %d events verified, want %d", n, 2*(i+1))
+		}
+		if err := l.Close(); err != nil {
+			t.Fatalf("closing log: %v", err)
+		}
+	}
+}
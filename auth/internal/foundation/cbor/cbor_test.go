@@ -0,0 +1,166 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/cbor"
+)
+
+func TestDecode(t *testing.T) {
+	// The examples of RFC 8949 appendix A in the supported subset.
+	tests := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"3863", int64(-100)},
+		{"3903e7", int64(-1000)},
+		{"f90000", float64(0)},
+		{"f93c00", float64(1)},
+		{"f9c400", float64(-4)},
+		{"fa47c35000", float64(100000)},
+		{"fb3ff199999999999a", 1.1},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"40", []byte(nil)}, // the empty strings are nil
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6449455446", "IETF"},
+		{"80", []interface{}{}},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"a0", map[interface{}]interface{}{}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"c11a514b67b0", int64(1363896240)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatalf("decoding hex: %v", err)
+			}
+
+			got, rest, err := cbor.Decode(data)
+			if err != nil {
+				t.Fatalf("decoding: %v", err)
+			}
+			if len(rest) != 0 {
+				t.Errorf("got %d trailing bytes, want none", len(rest))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHalfFloats(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want float64
+	}{
+		{"f97c00", math.Inf(1)},
+		{"f9fc00", math.Inf(-1)},
+		{"f90001", 5.960464477539063e-8},
+		{"f97bff", 65504},
+	}
+
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.hex)
+		got, _, err := cbor.Decode(data)
+		if err != nil {
+			t.Fatalf("%s: decoding: %v", tt.hex, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.hex, got, tt.want)
+		}
+	}
+
+	data, _ := hex.DecodeString("f97e00")
+	got, _, err := cbor.Decode(data)
+	if err != nil {
+		t.Fatalf("decoding NaN: %v", err)
+	}
+	if f, ok := got.(float64); !ok || !math.IsNaN(f) {
+		t.Errorf("got %v, want NaN", got)
+	}
+}
+
+func TestDecodeRest(t *testing.T) {
+	data, _ := hex.DecodeString("a10102" + "6449455446")
+
+	got, rest, err := cbor.Decode(data)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if want := (map[interface{}]interface{}{int64(1): int64(2)}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if hex.EncodeToString(rest) != "6449455446" {
+		t.Errorf("got rest %x, want 6449455446", rest)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want string
+	}{
+		{"empty", "", "unexpected end"},
+		{"short argument", "19 03", "unexpected end"},
+		{"short bytes", "44 0102", "unexpected end"},
+		{"short array", "83 0102", "unexpected end"},
+		{"huge array", "9b ffffffffffffffff", "unexpected end"},
+		{"huge map", "bb ffffffffffffffff", "unexpected end"},
+		{"indefinite bytes", "5f 41 01 ff", "indefinite length"},
+		{"indefinite map", "bf 01 02 ff", "indefinite length"},
+		{"reserved argument", "1c", "invalid additional information"},
+		{"uint overflow", "1b ffffffffffffffff", "overflows int64"},
+		{"negint overflow", "3b ffffffffffffffff", "overflows int64"},
+		{"duplicate key", "a2 01 02 01 03", "duplicate map key"},
+		{"byte string key", "a1 41 01 02", "unsupported map key"},
+		{"undefined simple", "f0", "unsupported simple value"},
+		{"too deep", strings.Repeat("81", 20) + "00", "maximum nesting depth"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(strings.ReplaceAll(tt.hex, " ", ""))
+			if err != nil {
+				t.Fatalf("decoding hex: %v", err)
+			}
+
+			_, _, err = cbor.Decode(data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeUnexpectedEnd(t *testing.T) {
+	// Every strict prefix of a valid item is incomplete.
+	data, _ := hex.DecodeString("a26161016162820203")
+
+	for i := 0; i < len(data); i++ {
+		_, _, err := cbor.Decode(data[:i])
+		if !errors.Is(err, cbor.ErrUnexpectedEnd) {
+			t.Errorf("prefix %d: got error %v, want %v", i, err, cbor.ErrUnexpectedEnd)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Package certs serves TLS certificates that can be replaced on disk while
+// the service runs. The certificate is picked at every handshake, so a
+// reload affects the new connections only, the open ones are kept.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader holds the certificate of a cert and key file pair. It is safe
+// for concurrent use.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	stamps [2]stamp
+}
+
+// stamp identifies a version of a file.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// New constructs a reloader with the certificate of the files.
+func New(certFile string, keyFile string) (*Reloader, error) {
+	r := Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetCertificate returns the current certificate, it is meant for
+// tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// NotAfter returns the expiry of the current certificate.
+func (r *Reloader) NotAfter() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert.Leaf.NotAfter
+}
+
+// Reload reads the files again. On failure the current certificate stays
+// in use.
+func (r *Reloader) Reload() error {
+	stamps, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("certs: loading key pair: %w", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("certs: parsing certificate: %w", err)
+	}
+	if time.Now().After(cert.Leaf.NotAfter) {
+		return fmt.Errorf("certs: certificate expired on %s", cert.Leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.stamps = stamps
+
+	return nil
+}
+
+// Watch checks the files every interval until the context is canceled and
+// reloads the certificate when they change. The outcome of every reload is
+// reported to onReload. A failed reload is retried once the files change
+// again, e.g. when the key was not yet replaced along with the certificate.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, onReload func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var failed [2]stamp
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stamps, err := r.stat()
+			if err != nil {
+				if onReload != nil {
+					onReload(err)
+				}
+				continue
+			}
+
+			r.mu.RLock()
+			changed := stamps != r.stamps && stamps != failed
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err = r.Reload(); err != nil {
+				failed = stamps
+			}
+			if onReload != nil {
+				onReload(err)
+			}
+		}
+	}
+}
+
+// stat returns the stamps of the files.
+func (r *Reloader) stat() ([2]stamp, error) {
+	var stamps [2]stamp
+	for i, path := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return stamps, fmt.Errorf("certs: %w", err)
+		}
+		stamps[i] = stamp{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return stamps, nil
+}
+
+// =============================================================================
+
+// ParseVersion returns the TLS version of its name, 1.0 to 1.3.
+func ParseVersion(s string) (uint16, error) {
+	switch s {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("certs: unknown tls version %q", s)
+}
+
+// ServerConfig returns the TLS config of a server presenting the certificate
+// of the reloader.
+func ServerConfig(r *Reloader, minVersion uint16) *tls.Config {
+	return &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// VerifyClientCerts makes the server verify the client certificates against
+// the CAs of the PEM file. The clients without a certificate are still
+// accepted, the routes needing one are wrapped with RequireVerified.
+func VerifyClientCerts(cfg *tls.Config, caFile string) error {
+	b, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("certs: reading client CAs: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return errors.New("certs: no certificate found in the client CAs file")
+	}
+
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	return nil
+}
+
+// RequireVerified only lets through the requests made with a verified client
+// certificate, the other ones get a 403.
+func RequireVerified(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,359 @@
+// Package conffile layers a YAML or JSON configuration file under the
+// environment variables and the flags parsed by conf.
+//
+// The values of the file are set once conf parsed the configuration, on
+// the fields the environment and the flags didn't set, so the precedence
+// is the flags, then the environment, then the file, then the defaults.
+// The environment is only read, the values of the file, secrets included,
+// never end up in it. The keys of the file are the names of the fields of
+// the configuration, in any case:
+//
+//	web:
+//	  apiHost: 0.0.0.0:3000
+//	cors:
+//	  allowedOrigins: [https://shop.example.com]
+package conffile
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ardanlabs/conf/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a configuration file. It can be applied again, e.g. on a reload.
+type File struct {
+	path string
+}
+
+// New constructs the configuration file of the path.
+func New(path string) *File {
+	return &File{path: path}
+}
+
+// Apply reads the file and sets its values on the fields of the
+// configuration the environment and the flags of args didn't set. It must
+// be called once conf parsed the configuration, with the same prefix and
+// args. It fails on the keys matching no field of the configuration.
+func (f *File) Apply(prefix string, args []string, cfg interface{}) error {
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("conffile: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("conffile: decoding %s: %w", f.path, err)
+	}
+
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("conffile: configuration must be a pointer to a struct, got %T", cfg)
+	}
+
+	a := applier{
+		prefix: prefix,
+		flags:  flagNames(args),
+	}
+	if err := a.apply(v.Elem(), doc, nil, ""); err != nil {
+		return fmt.Errorf("conffile: %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// applier sets the values of the file on the fields not set by the
+// environment or the flags.
+type applier struct {
+	prefix string
+	flags  map[string]bool
+}
+
+// apply walks the document along the struct and sets its values.
+func (a applier) apply(v reflect.Value, doc map[string]interface{}, key []string, path string) error {
+	for name, value := range doc {
+		sf, ok := field(v.Type(), name)
+		if !ok {
+			return fmt.Errorf("unknown key %s%s", path, name)
+		}
+
+		k := append(append([]string(nil), key...), camelSplit(sf.Name)...)
+		fv := v.FieldByIndex(sf.Index)
+		if isSection(sf.Type) {
+			sub, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("key %s%s must be a section", path, name)
+			}
+			if err := a.apply(fv, sub, k, path+name+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, err := format(value)
+		if err != nil {
+			return fmt.Errorf("key %s%s: %w", path, name, err)
+		}
+		if a.provided(k, sf.Tag.Get("conf")) {
+			continue
+		}
+		if err := set(fv, s); err != nil {
+			return fmt.Errorf("key %s%s: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+// provided reports if the environment or the flags set the field of the
+// key, named like conf names them.
+func (a applier) provided(key []string, tag string) bool {
+	env := strings.ToUpper(strings.Join(key, "_"))
+	if name := option(tag, "env"); name != "" {
+		env = strings.ToUpper(name)
+	}
+	if a.prefix != "" {
+		env = strings.ToUpper(a.prefix) + "_" + env
+	}
+	if _, ok := os.LookupEnv(env); ok {
+		return true
+	}
+
+	flag := strings.ToLower(strings.Join(key, "-"))
+	if name := option(tag, "flag"); name != "" {
+		flag = strings.ToLower(name)
+	}
+	if short := option(tag, "short"); short != "" && a.flags[short] {
+		return true
+	}
+	return a.flags[flag]
+}
+
+// flagNames returns the names of the flags of the arguments, read like conf
+// reads them: they end at the first argument that is not a flag or at --.
+func flagNames(args []string) map[string]bool {
+	names := make(map[string]bool)
+	for len(args) > 0 {
+		s := args[0]
+		if len(s) < 2 || s[0] != '-' || s == "--" {
+			break
+		}
+		args = args[1:]
+
+		name := strings.TrimPrefix(s[1:], "-")
+		if i := strings.Index(name, "="); i > 0 {
+			name = name[:i]
+		} else if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+			// The value is the next argument.
+			args = args[1:]
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// set sets the field from its text form, the way conf does.
+func set(field reflect.Value, s string) error {
+	if field.CanAddr() {
+		switch p := field.Addr().Interface().(type) {
+		case conf.Setter:
+			return p.Set(s)
+		case encoding.TextUnmarshaler:
+			return p.UnmarshalText([]byte(s))
+		case encoding.BinaryUnmarshaler:
+			return p.UnmarshalBinary([]byte(s))
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.Ptr:
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return set(field.Elem(), s)
+
+	case reflect.String:
+		field.SetString(s)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+
+	case reflect.Slice:
+		items := strings.Split(s, ";")
+		sl := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := set(sl.Index(i), item); err != nil {
+				return err
+			}
+		}
+		field.Set(sl)
+
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// field returns the field of the struct named like the key, ignoring the
+// case and the dashes or underscores of the key.
+func field(t reflect.Type, key string) (reflect.StructField, bool) {
+	key = strings.NewReplacer("-", "", "_", "").Replace(key)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" || sf.Anonymous || sf.Tag.Get("conf") == "-" {
+			continue
+		}
+		if strings.EqualFold(sf.Name, key) {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// option returns the value of the option of the conf tag, if any.
+func option(tag string, name string) string {
+	for _, opt := range strings.Split(tag, ",") {
+		if strings.HasPrefix(opt, name+":") {
+			return strings.TrimSpace(strings.TrimPrefix(opt, name+":"))
+		}
+	}
+	return ""
+}
+
+// isSection reports if the type is a section of the configuration, a struct
+// that conf doesn't read from text.
+func isSection(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	p := reflect.PtrTo(t)
+	for _, m := range []string{"Set", "UnmarshalText", "UnmarshalBinary"} {
+		if _, ok := p.MethodByName(m); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// format returns the value in the text form read by conf. The lists are
+// separated by semicolons.
+func format(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := format(item)
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(s, ";") {
+				return "", fmt.Errorf("list item %q can't hold a semicolon", s)
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ";"), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// camelSplit splits the name of a field into the words conf makes its
+// environment key of, e.g. APIHost into API and Host.
+func camelSplit(src string) []string {
+	runes := []rune(src)
+	if len(runes) < 2 {
+		return []string{src}
+	}
+
+	var out []string
+	lastClass := charClass(runes[0])
+	lastIdx := 0
+	for i, r := range runes {
+		class := charClass(r)
+		if class != lastClass {
+
+			// Going from uppercase to lowercase, the last uppercase letter
+			// starts the next word, like in FOOBar.
+			switch {
+			case lastClass == classUpper && class != classNumber:
+				if i-lastIdx > 1 {
+					out = append(out, string(runes[lastIdx:i-1]))
+					lastIdx = i - 1
+				}
+			default:
+				out = append(out, string(runes[lastIdx:i]))
+				lastIdx = i
+			}
+		}
+
+		if i == len(runes)-1 {
+			out = append(out, string(runes[lastIdx:]))
+		}
+		lastClass = class
+	}
+	return out
+}
+
+// These are the classes of the characters of a field name.
+const (
+	classLower = iota
+	classUpper
+	classNumber
+	classOther
+)
+
+func charClass(r rune) int {
+	switch {
+	case unicode.IsLower(r):
+		return classLower
+	case unicode.IsUpper(r):
+		return classUpper
+	case unicode.IsDigit(r):
+		return classNumber
+	}
+	return classOther
+}
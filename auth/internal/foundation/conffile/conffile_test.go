@@ -0,0 +1,122 @@
+package conffile_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/conf/v3"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/conffile"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/ratelimit"
+)
+
+const testPrefix = "CONFFILE_TEST"
+
+// testConfig looks like the configuration of the service.
+type testConfig struct {
+	Web struct {
+		APIHost      string        `conf:"default:0.0.0.0:3000"`
+		ReadTimeout  time.Duration `conf:"default:5s"`
+		DebugToken   string        `conf:"mask"`
+		WriteTimeout time.Duration `conf:"default:10s,flag:write-timeout"`
+	}
+	RateLimit struct {
+		SignUp ratelimit.Limit `conf:"default:5/min"`
+	}
+	CORS struct {
+		AllowedOrigins []string `conf:"default:http://localhost:3000"`
+	}
+	Verbose bool `conf:"short:x"`
+}
+
+// writeFile writes the configuration file of the test.
+func writeFile(t *testing.T, content string) *conffile.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "auth.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	return conffile.New(path)
+}
+
+// parse parses the configuration like the service, conf then the file,
+// with the arguments as the command line.
+func parse(t *testing.T, f *conffile.File, args []string) (testConfig, error) {
+	t.Helper()
+
+	osArgs := os.Args
+	os.Args = append([]string{"auth"}, args...)
+	defer func() { os.Args = osArgs }()
+
+	var cfg testConfig
+	if _, err := conf.Parse(testPrefix, &cfg); err != nil {
+		t.Fatalf("parsing: %v", err)
+	}
+	return cfg, f.Apply(testPrefix, args, &cfg)
+}
+
+func TestApply(t *testing.T) {
+	f := writeFile(t, `
+web:
+  apiHost: 0.0.0.0:4000
+  readTimeout: 1s
+  debugToken: secret-token
+  writeTimeout: 2s
+rateLimit:
+  signUp: 100/min
+cors:
+  allowedOrigins: [https://a.example.com, https://b.example.com]
+verbose: false
+`)
+
+	t.Setenv(testPrefix+"_WEB_API_HOST", "0.0.0.0:5000")
+
+	cfg, err := parse(t, f, []string{"--web-read-timeout=3s", "--write-timeout", "4s", "-x"})
+	if err != nil {
+		t.Fatalf("applying: %v", err)
+	}
+
+	// The environment and the flags win over the file, the file over the
+	// defaults.
+	if cfg.Web.APIHost != "0.0.0.0:5000" {
+		t.Errorf("got api host %q, want the one of the environment", cfg.Web.APIHost)
+	}
+	if cfg.Web.ReadTimeout != 3*time.Second {
+		t.Errorf("got read timeout %v, want the one of the flag", cfg.Web.ReadTimeout)
+	}
+	if cfg.Web.WriteTimeout != 4*time.Second {
+		t.Errorf("got write timeout %v, want the one of the named flag", cfg.Web.WriteTimeout)
+	}
+	if !cfg.Verbose {
+		t.Error("got verbose off, want the short flag to win")
+	}
+	if cfg.Web.DebugToken != "secret-token" {
+		t.Errorf("got debug token %q, want the one of the file", cfg.Web.DebugToken)
+	}
+	if cfg.RateLimit.SignUp.String() != "100/min" {
+		t.Errorf("got sign up limit %s, want the one of the file", cfg.RateLimit.SignUp)
+	}
+	want := []string{"https://a.example.com", "https://b.example.com"}
+	if !reflect.DeepEqual(cfg.CORS.AllowedOrigins, want) {
+		t.Errorf("got origins %v, want %v", cfg.CORS.AllowedOrigins, want)
+	}
+
+	// The values of the file are never put in the environment.
+	if v, ok := os.LookupEnv(testPrefix + "_WEB_DEBUG_TOKEN"); ok {
+		t.Errorf("got %q in the environment, want it unset", v)
+	}
+}
+
+func TestApplyUnknownKey(t *testing.T) {
+	f := writeFile(t, `
+web:
+  apiHots: 0.0.0.0:4000
+`)
+
+	if _, err := parse(t, f, nil); err == nil {
+		t.Fatal("got no error for an unknown key")
+	}
+}
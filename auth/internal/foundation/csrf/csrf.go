@@ -0,0 +1,162 @@
+// Package csrf provides the double-submit tokens and the origin checks used
+// to protect the cookie authenticated requests against cross-site request
+// forgery.
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Set of errors returned by the checks.
+var (
+	ErrOrigin = errors.New("csrf: origin not allowed")
+	ErrToken  = errors.New("csrf: invalid token")
+)
+
+// These are the names used to carry the token.
+const (
+	CookieName = "csrf"
+	HeaderName = "X-CSRF-Token"
+)
+
+// KeySize is the minimum size in bytes of the signing key.
+const KeySize = 32
+
+// Protector issues and verifies the tokens. The cookie holds the token and
+// its signature, so a cookie injected by a sibling domain can't be forged
+// without the key. It is safe for concurrent use.
+type Protector struct {
+	key []byte
+
+	mu      sync.RWMutex
+	origins []string
+}
+
+// New constructs a Protector signing with the key and accepting the
+// requests from the allowed origins, e.g. https://shop.example.com.
+func New(key []byte, origins []string) (*Protector, error) {
+	if len(key) < KeySize {
+		return nil, fmt.Errorf("csrf: key must be at least %d bytes long", KeySize)
+	}
+
+	p := Protector{key: key}
+	if err := p.SetOrigins(origins); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetOrigins replaces the allowed origins, e.g. when the configuration is
+// reloaded. The origins are left unchanged when one is invalid.
+func (p *Protector) SetOrigins(origins []string) error {
+	if err := ValidateOrigins(origins); err != nil {
+		return err
+	}
+
+	normalized := make([]string, 0, len(origins))
+	for _, o := range origins {
+		normalized = append(normalized, normalize(o))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.origins = normalized
+	return nil
+}
+
+// ValidateOrigins checks that the origins are exact http or https origins,
+// the patterns are not accepted.
+func ValidateOrigins(origins []string) error {
+	for _, o := range origins {
+		u, err := url.Parse(normalize(o))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.User != nil || strings.Contains(u.Host, "*") {
+			return fmt.Errorf("csrf: invalid origin %q", o)
+		}
+	}
+	return nil
+}
+
+// normalize returns the origin lower cased and without the trailing slash.
+func normalize(origin string) string {
+	return strings.TrimRight(strings.ToLower(origin), "/")
+}
+
+// NewKey generates a new random key.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("csrf: generating key: %w", err)
+	}
+	return key, nil
+}
+
+// Issue returns a new token and the signed value of the cookie holding it.
+func (p *Protector) Issue() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("csrf: generating token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, token + "." + p.sign(token), nil
+}
+
+// Verify checks that the cookie was signed by the protector and that the
+// token submitted in the header is the one it holds.
+func (p *Protector) Verify(cookie string, header string) error {
+	parts := strings.Split(cookie, ".")
+	if len(parts) != 2 || header == "" {
+		return ErrToken
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(p.sign(parts[0]))) {
+		return ErrToken
+	}
+	if subtle.ConstantTimeCompare([]byte(parts[0]), []byte(header)) != 1 {
+		return ErrToken
+	}
+	return nil
+}
+
+// CheckOrigin checks the Origin header of the request, or the origin of the
+// Referer when the browser did not send one, against the allowed origins.
+// Requests with neither are rejected.
+func (p *Protector) CheckOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		ref, err := url.Parse(r.Header.Get("Referer"))
+		if err != nil || ref.Scheme == "" || ref.Host == "" {
+			return ErrOrigin
+		}
+		origin = ref.Scheme + "://" + ref.Host
+	}
+
+	origin = strings.ToLower(origin)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, o := range p.origins {
+		if o == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrOrigin, origin)
+}
+
+// sign returns the signature of the token.
+func (p *Protector) sign(token string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte("csrf:" + token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,73 @@
+package csrf_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/csrf"
+)
+
+// checkOrigin checks a request sent from the origin.
+func checkOrigin(p *csrf.Protector, origin string) error {
+	r := httptest.NewRequest(http.MethodPost, "/api/profile", nil)
+	r.Header.Set("Origin", origin)
+	return p.CheckOrigin(r)
+}
+
+func TestSetOrigins(t *testing.T) {
+	key, err := csrf.NewKey()
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	p, err := csrf.New(key, []string{"https://shop.example.com/"})
+	if err != nil {
+		t.Fatalf("constructing protector: %v", err)
+	}
+
+	if err := checkOrigin(p, "https://SHOP.example.com"); err != nil {
+		t.Fatalf("got error %v, want the origin allowed", err)
+	}
+
+	if err := p.SetOrigins([]string{"https://tickets.example.com"}); err != nil {
+		t.Fatalf("setting origins: %v", err)
+	}
+	if err := checkOrigin(p, "https://tickets.example.com"); err != nil {
+		t.Fatalf("got error %v, want the new origin allowed", err)
+	}
+	if err := checkOrigin(p, "https://shop.example.com"); !errors.Is(err, csrf.ErrOrigin) {
+		t.Fatalf("got error %v, want the old origin rejected", err)
+	}
+
+	// An invalid list leaves the origins unchanged.
+	if err := p.SetOrigins([]string{"https://*.example.com"}); err == nil {
+		t.Fatal("got no error for a pattern")
+	}
+	if err := checkOrigin(p, "https://tickets.example.com"); err != nil {
+		t.Fatalf("got error %v, want the origin still allowed", err)
+	}
+}
+
+func TestValidateOrigins(t *testing.T) {
+	tests := []struct {
+		origin string
+		valid  bool
+	}{
+		{"https://shop.example.com", true},
+		{"http://localhost:3000/", true},
+		{"https://*.example.com", false},
+		{"https://shop.example.com/login", false},
+		{"shop.example.com", false},
+		{"ftp://shop.example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			err := csrf.ValidateOrigins([]string{tt.origin})
+			if (err == nil) != tt.valid {
+				t.Fatalf("got error %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+}
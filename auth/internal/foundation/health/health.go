@@ -0,0 +1,181 @@
+// Package health reports the liveness and the readiness of the service to
+// the orchestrator.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports if a dependency of the service is usable. It must return
+// once the context is done.
+type Check func(ctx context.Context) error
+
+// Checker runs the registered checks. It is safe for concurrent use.
+type Checker struct {
+	build   string
+	timeout time.Duration
+	started time.Time
+
+	draining int32
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// New constructs a Checker running every check with the timeout.
+func New(build string, timeout time.Duration) *Checker {
+	return &Checker{
+		build:   build,
+		timeout: timeout,
+		started: time.Now(),
+		checks:  make(map[string]Check),
+	}
+}
+
+// Register adds a check run by the readiness probe. Registering a name again
+// replaces the check.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+}
+
+// Drain makes the service report not ready, so the orchestrator stops
+// routing requests to it while the outstanding ones complete.
+func (c *Checker) Drain() {
+	atomic.StoreInt32(&c.draining, 1)
+}
+
+// Draining reports if the service is shutting down.
+func (c *Checker) Draining() bool {
+	return atomic.LoadInt32(&c.draining) == 1
+}
+
+// Liveness reports that the service is up, with some information about
+// where it runs. It never checks the dependencies, a failing dependency
+// must not get the service restarted.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unavailable"
+	}
+
+	data := struct {
+		Status     string `json:"status"`
+		Build      string `json:"build"`
+		Host       string `json:"host"`
+		Pod        string `json:"pod,omitempty"`
+		PodIP      string `json:"podIP,omitempty"`
+		Node       string `json:"node,omitempty"`
+		Namespace  string `json:"namespace,omitempty"`
+		GOMAXPROCS int    `json:"GOMAXPROCS"`
+		Uptime     string `json:"uptime"`
+	}{
+		Status:     "up",
+		Build:      c.build,
+		Host:       host,
+		Pod:        os.Getenv("KUBERNETES_PODNAME"),
+		PodIP:      os.Getenv("KUBERNETES_NAMESPACE_POD_IP"),
+		Node:       os.Getenv("KUBERNETES_NODENAME"),
+		Namespace:  os.Getenv("KUBERNETES_NAMESPACE"),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Uptime:     time.Since(c.started).Round(time.Second).String(),
+	}
+
+	respond(w, data, http.StatusOK)
+}
+
+// Readiness runs the checks concurrently and reports the service ready
+// when all of them pass. It reports not ready while draining.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	type result struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+		Failed []string          `json:"failed,omitempty"`
+	}
+
+	if c.Draining() {
+		respond(w, result{Status: "draining"}, http.StatusServiceUnavailable)
+		return
+	}
+
+	errs := c.run(r.Context())
+
+	res := result{
+		Status: "ok",
+		Checks: make(map[string]string, len(errs)),
+	}
+	for name, err := range errs {
+		if err != nil {
+			res.Checks[name] = err.Error()
+			res.Failed = append(res.Failed, name)
+			continue
+		}
+		res.Checks[name] = "ok"
+	}
+
+	status := http.StatusOK
+	if len(res.Failed) > 0 {
+		sort.Strings(res.Failed)
+		res.Status = "not ready"
+		status = http.StatusServiceUnavailable
+	}
+
+	respond(w, res, status)
+}
+
+// run runs the checks concurrently. A check still running at the timeout
+// is reported as failed.
+func (c *Checker) run(ctx context.Context) map[string]error {
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type outcome struct {
+		name string
+		err  error
+	}
+	ch := make(chan outcome, len(checks))
+	for name, check := range checks {
+		go func(name string, check Check) {
+			ch <- outcome{name: name, err: check(ctx)}
+		}(name, check)
+	}
+
+	errs := make(map[string]error, len(checks))
+	for len(errs) < len(checks) {
+		select {
+		case o := <-ch:
+			errs[o.name] = o.err
+		case <-ctx.Done():
+			for name := range checks {
+				if _, done := errs[name]; !done {
+					errs[name] = ctx.Err()
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// respond writes the data as json.
+func respond(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
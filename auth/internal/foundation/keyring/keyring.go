@@ -0,0 +1,233 @@
+// Package keyring manages the keys used to sign the access tokens. The active
+// key is rotated on a schedule and the retired keys stay published until every
+// token they signed has expired.
+//
+// The generated keys belong to a single instance, the instances behind the
+// same issuer share their keys instead. Those are rotated in the
+// configuration: a new key is added after the active one, then made the
+// first once every instance publishes it, and the old one is removed once
+// the tokens it signed have expired.
+package keyring
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/pkg/accesstoken"
+)
+
+// rsaBits is the size of the generated RSA keys.
+const rsaBits = 2048
+
+// ErrShared is returned when rotating the keys shared by the instances.
+var ErrShared = errors.New("keyring: shared keys are rotated in the configuration")
+
+// key represents a signing key of the ring.
+type key struct {
+	id        string
+	signer    crypto.Signer
+	retiredAt time.Time
+}
+
+// Keyring holds the active signing key and the retired ones that are still
+// needed to verify tokens. It is safe for concurrent use.
+type Keyring struct {
+	alg       string
+	retainFor time.Duration
+	shared    bool
+
+	mu      sync.RWMutex
+	active  key
+	retired []key
+}
+
+// New constructs a Keyring generating keys for the algorithm (RS256 or EdDSA).
+// Retired keys are kept for retainFor, which should be at least the lifetime
+// of the tokens.
+func New(alg string, retainFor time.Duration) (*Keyring, error) {
+	kr := Keyring{
+		alg:       alg,
+		retainFor: retainFor,
+	}
+
+	k, err := kr.generate()
+	if err != nil {
+		return nil, err
+	}
+	kr.active = k
+
+	return &kr, nil
+}
+
+// NewShared constructs a Keyring from the PKCS #8 encoded private keys shared
+// by the instances. The first key signs, the others are only published. The
+// ids of the keys are derived from them, so every instance publishes the
+// same set.
+func NewShared(alg string, keys [][]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keyring: no shared keys")
+	}
+
+	kr := Keyring{
+		alg:    alg,
+		shared: true,
+	}
+
+	for i, der := range keys {
+		k, err := parseKey(alg, der)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %d: %w", i+1, err)
+		}
+		if i == 0 {
+			kr.active = k
+			continue
+		}
+		kr.retired = append(kr.retired, k)
+	}
+
+	return &kr, nil
+}
+
+// Sign signs the claims with the active key.
+func (kr *Keyring) Sign(c accesstoken.Claims) (string, error) {
+	kr.mu.RLock()
+	k := kr.active
+	kr.mu.RUnlock()
+
+	return accesstoken.Sign(k.signer, k.id, c)
+}
+
+// JWKS returns the public keys of the active and the retired keys.
+func (kr *Keyring) JWKS() (accesstoken.JWKS, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	set := accesstoken.JWKS{Keys: []accesstoken.JWK{}}
+	for _, k := range append([]key{kr.active}, kr.retired...) {
+		jwk, err := accesstoken.NewJWK(k.id, k.signer.Public())
+		if err != nil {
+			return accesstoken.JWKS{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// Rotate generates a new active key, retires the previous one and drops the
+// retired keys whose retention has passed.
+func (kr *Keyring) Rotate() error {
+	if kr.shared {
+		return ErrShared
+	}
+
+	k, err := kr.generate()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	prev := kr.active
+	prev.retiredAt = now
+
+	retired := []key{prev}
+	for _, r := range kr.retired {
+		if now.Sub(r.retiredAt) < kr.retainFor {
+			retired = append(retired, r)
+		}
+	}
+
+	kr.active = k
+	kr.retired = retired
+
+	return nil
+}
+
+// Run rotates the keys every interval until the context is canceled. Rotation
+// errors are reported to onError and the current key stays active.
+func (kr *Keyring) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := kr.Rotate(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// generate creates a new key for the algorithm of the ring.
+func (kr *Keyring) generate() (key, error) {
+	var signer crypto.Signer
+	switch kr.alg {
+	case accesstoken.RS256:
+		pk, err := rsa.GenerateKey(rand.Reader, rsaBits)
+		if err != nil {
+			return key{}, fmt.Errorf("keyring: generating rsa key: %w", err)
+		}
+		signer = pk
+	case accesstoken.EdDSA:
+		_, pk, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return key{}, fmt.Errorf("keyring: generating ed25519 key: %w", err)
+		}
+		signer = pk
+	default:
+		return key{}, fmt.Errorf("keyring: unsupported algorithm %q", kr.alg)
+	}
+
+	return newKey(signer)
+}
+
+// parseKey parses a PKCS #8 encoded private key of the algorithm.
+func parseKey(alg string, der []byte) (key, error) {
+	pk, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return key{}, fmt.Errorf("parsing key: %w", err)
+	}
+
+	signer, ok := pk.(crypto.Signer)
+	if !ok {
+		return key{}, fmt.Errorf("unsupported key type %T", pk)
+	}
+	keyAlg, err := accesstoken.Algorithm(signer.Public())
+	if err != nil {
+		return key{}, err
+	}
+	if keyAlg != alg {
+		return key{}, fmt.Errorf("got a %s key, want a %s one", keyAlg, alg)
+	}
+
+	return newKey(signer)
+}
+
+// newKey returns the key of the signer. Its id is derived from the public
+// key, the same key gets the same id on every instance.
+func newKey(signer crypto.Signer) (key, error) {
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return key{}, fmt.Errorf("keyring: encoding public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+
+	return key{id: hex.EncodeToString(sum[:8]), signer: signer}, nil
+}
@@ -0,0 +1,99 @@
+package keyring_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/keyring"
+	"github.com/mroobert/go-tickets/auth/pkg/accesstoken"
+)
+
+// newKey generates a PKCS #8 encoded ed25519 key.
+func newKey(t *testing.T) []byte {
+	t.Helper()
+
+	_, pk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(pk)
+	if err != nil {
+		t.Fatalf("encoding key: %v", err)
+	}
+	return der
+}
+
+func TestNewShared(t *testing.T) {
+	keys := [][]byte{newKey(t), newKey(t)}
+
+	a, err := keyring.NewShared(accesstoken.EdDSA, keys)
+	if err != nil {
+		t.Fatalf("constructing keyring: %v", err)
+	}
+	b, err := keyring.NewShared(accesstoken.EdDSA, keys)
+	if err != nil {
+		t.Fatalf("constructing keyring: %v", err)
+	}
+
+	// Every instance publishes the same key set.
+	jwksA, err := a.JWKS()
+	if err != nil {
+		t.Fatalf("getting key set: %v", err)
+	}
+	jwksB, err := b.JWKS()
+	if err != nil {
+		t.Fatalf("getting key set: %v", err)
+	}
+	if len(jwksA.Keys) != 2 || !reflect.DeepEqual(jwksA, jwksB) {
+		t.Fatalf("got key sets %+v and %+v, want the same two keys", jwksA, jwksB)
+	}
+
+	// The token of one instance is verified with the key set of the other.
+	tkn, err := a.Sign(accesstoken.Claims{Subject: "uid-1"})
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	c, err := accesstoken.Parse(tkn, func(kid string) (crypto.PublicKey, error) {
+		for _, k := range jwksB.Keys {
+			if k.Kid == kid {
+				return k.PublicKey()
+			}
+		}
+		return nil, accesstoken.ErrUnknownKey
+	})
+	if err != nil {
+		t.Fatalf("parsing token: %v", err)
+	}
+	if c.Subject != "uid-1" {
+		t.Fatalf("got subject %q, want %q", c.Subject, "uid-1")
+	}
+
+	if err := a.Rotate(); !errors.Is(err, keyring.ErrShared) {
+		t.Fatalf("got error %v, want %v", err, keyring.ErrShared)
+	}
+}
+
+func TestNewSharedInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		alg  string
+		keys [][]byte
+	}{
+		{"no keys", accesstoken.EdDSA, nil},
+		{"not a key", accesstoken.EdDSA, [][]byte{[]byte("not a key")}},
+		{"other algorithm", accesstoken.RS256, [][]byte{newKey(t)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := keyring.NewShared(tt.alg, tt.keys); err == nil {
+				t.Fatal("got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+// Package lifecycle supervises the servers and the background workers of
+// the service, from their start to an ordered drain.
+//
+// A SIGHUP runs the registered reloads, e.g. of the TLS certificates.
+//
+// On a signal, or when a server fails, the supervisor drains the service:
+// the readiness goes false, the load balancers get a grace delay to stop
+// routing, the servers complete the outstanding requests, the workers stop
+// and the resources are closed. A hard stop bounds the whole sequence.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Config holds the timings of the drain.
+type Config struct {
+	// DrainDelay is the time left to the load balancers to notice the
+	// readiness went false, before the servers stop accepting requests.
+	DrainDelay time.Duration
+
+	// ShutdownTimeout is the time the servers have to complete the
+	// outstanding requests, after which they are closed.
+	ShutdownTimeout time.Duration
+
+	// HardStop bounds the whole drain, after it Run returns whatever is
+	// still running.
+	HardStop time.Duration
+}
+
+// Validate checks the timings are usable.
+func (cfg Config) Validate() error {
+	switch {
+	case cfg.DrainDelay < 0:
+		return errors.New("drain delay can't be negative")
+	case cfg.ShutdownTimeout <= 0:
+		return errors.New("shutdown timeout must be positive")
+	case cfg.HardStop <= cfg.DrainDelay+cfg.ShutdownTimeout:
+		return errors.New("hard stop must be longer than the drain delay and the shutdown timeout")
+	}
+	return nil
+}
+
+// Supervisor starts the registered servers and workers and drains them.
+// The registrations must happen before Run.
+type Supervisor struct {
+	cfg      Config
+	log      *zap.SugaredLogger
+	shutdown chan os.Signal
+
+	servers []server
+	workers []worker
+	drains  []func()
+	reloads []reload
+	closers []closer
+}
+
+type server struct {
+	name string
+	srv  *http.Server
+}
+
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+type reload struct {
+	name   string
+	reload func() error
+}
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// New constructs a supervisor.
+func New(cfg Config, log *zap.SugaredLogger) (*Supervisor, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := Supervisor{
+		cfg: cfg,
+		log: log,
+
+		// The signal package requires a buffered channel.
+		shutdown: make(chan os.Signal, 1),
+	}
+	return &s, nil
+}
+
+// Shutdown returns the channel the drain is requested on. The application
+// sends on it to shut down when its integrity is at risk.
+func (s *Supervisor) Shutdown() chan os.Signal {
+	return s.shutdown
+}
+
+// Server registers a server. The servers are shut down in the reverse
+// order of their registration, so the first ones, like the debug server,
+// stay reachable while the others drain. A server with a TLS config serves
+// TLS with the certificates the config provides.
+func (s *Supervisor) Server(name string, srv *http.Server) {
+	s.servers = append(s.servers, server{name: name, srv: srv})
+}
+
+// Worker registers a background worker. It runs until its context is
+// cancelled, once the servers stopped.
+func (s *Supervisor) Worker(name string, run func(ctx context.Context)) {
+	s.workers = append(s.workers, worker{name: name, run: run})
+}
+
+// OnDrain registers a function called first when the drain starts, e.g.
+// to report the service as not ready.
+func (s *Supervisor) OnDrain(f func()) {
+	s.drains = append(s.drains, f)
+}
+
+// OnReload registers a function called on SIGHUP. A failed reload is
+// logged, it must leave the previous state in place.
+func (s *Supervisor) OnReload(name string, f func() error) {
+	s.reloads = append(s.reloads, reload{name: name, reload: f})
+}
+
+// Closer registers a resource closed at the end of the drain. The closers
+// run in the reverse order of their registration.
+func (s *Supervisor) Closer(name string, close func(ctx context.Context) error) {
+	s.closers = append(s.closers, closer{name: name, close: close})
+}
+
+// Run starts the servers and the workers, then blocks until a signal or the
+// failure of a server and drains the service. It returns an error when a
+// server failed or the drain wasn't clean. A second interrupt during the
+// drain forces a hard stop.
+func (s *Supervisor) Run() error {
+	signal.Notify(s.shutdown, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(s.shutdown)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var workers sync.WaitGroup
+	for _, w := range s.workers {
+		workers.Add(1)
+		go func(w worker) {
+			defer workers.Done()
+			w.run(workersCtx)
+		}(w)
+	}
+
+	// Make a channel to listen for errors coming from the listeners. Use a
+	// buffered channel so the goroutines can exit if we don't collect them.
+	serverErrors := make(chan error, len(s.servers))
+	for _, sv := range s.servers {
+		go func(sv server) {
+			s.log.Infow("startup", "status", "router started", "server", sv.name, "host", sv.srv.Addr, "tls", sv.srv.TLSConfig != nil)
+			var err error
+			if sv.srv.TLSConfig != nil {
+				err = sv.srv.ListenAndServeTLS("", "")
+			} else {
+				err = sv.srv.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				serverErrors <- fmt.Errorf("server %s: %w", sv.name, err)
+			}
+		}(sv)
+	}
+
+	// Blocking main and waiting for shutdown.
+	var (
+		rep   Report
+		cause error
+	)
+	for rep.Cause == "" {
+		select {
+		case cause = <-serverErrors:
+			rep.Cause = cause.Error()
+		case sig := <-s.shutdown:
+			rep.Cause = "signal " + sig.String()
+		case <-hup:
+			s.reload()
+		}
+	}
+	s.log.Infow("shutdown", "status", "shutdown started", "cause", rep.Cause)
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.drain(&rep, serverErrors, stopWorkers, &workers)
+	}()
+
+	hardStop := time.NewTimer(s.cfg.HardStop)
+	defer hardStop.Stop()
+
+	for done != nil {
+		select {
+		case <-done:
+			done = nil
+		case <-hardStop.C:
+			rep.HardStop = "timeout"
+		case sig := <-s.shutdown:
+			if sig != syscall.SIGINT {
+				continue
+			}
+			rep.HardStop = "interrupt"
+		}
+
+		if rep.HardStop != "" {
+			rep.Duration = time.Since(start).String()
+			s.log.Errorw("shutdown", "status", "hard stop", "report", rep.snapshot())
+			return fmt.Errorf("hard stop on %s", rep.HardStop)
+		}
+	}
+
+	rep.Duration = time.Since(start).String()
+	s.log.Infow("shutdown", "status", "shutdown complete", "report", rep.snapshot())
+
+	switch {
+	case cause != nil:
+		return cause
+	case rep.failed():
+		return errors.New("drain failed, see the shutdown report")
+	}
+	return nil
+}
+
+// reload runs the registered reloads.
+func (s *Supervisor) reload() {
+	for _, r := range s.reloads {
+		if err := r.reload(); err != nil {
+			s.log.Errorw("reload", "status", "reload failed", "name", r.name, "ERROR", err)
+			continue
+		}
+		s.log.Infow("reload", "status", "reloaded", "name", r.name)
+	}
+}
+
+// drain runs the ordered steps of the shutdown, recording them in the
+// report.
+func (s *Supervisor) drain(rep *Report, serverErrors <-chan error, stopWorkers func(), workers *sync.WaitGroup) {
+
+	// Report not ready, then give the load balancers time to notice.
+	rep.step("readiness", func() error {
+		for _, f := range s.drains {
+			f()
+		}
+		return nil
+	})
+	if s.cfg.DrainDelay > 0 {
+		rep.step("drain delay", func() error {
+			time.Sleep(s.cfg.DrainDelay)
+			return nil
+		})
+	}
+
+	// Give outstanding requests a deadline for completion.
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	for i := len(s.servers) - 1; i >= 0; i-- {
+		sv := s.servers[i]
+		rep.step("server "+sv.name, func() error {
+			if err := sv.srv.Shutdown(ctx); err != nil {
+				sv.srv.Close()
+				return fmt.Errorf("could not stop server gracefully: %w", err)
+			}
+			return nil
+		})
+	}
+
+	// The servers are down, a late failure of theirs is only reported.
+	for len(serverErrors) > 0 {
+		s.log.Errorw("shutdown", "status", "server failed", "ERROR", <-serverErrors)
+	}
+
+	rep.step("workers", func() error {
+		stopWorkers()
+		workers.Wait()
+		return nil
+	})
+
+	// The closers get their own deadline, the servers may have spent theirs.
+	ctx, cancel = context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		c := s.closers[i]
+		rep.step("close "+c.name, func() error {
+			return c.close(ctx)
+		})
+	}
+}
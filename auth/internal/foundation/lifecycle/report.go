@@ -0,0 +1,70 @@
+package lifecycle
+
+import (
+	"sync"
+	"time"
+)
+
+// Report describes a shutdown, it is logged once the drain ends.
+type Report struct {
+	Cause    string `json:"cause"`
+	Duration string `json:"duration"`
+	HardStop string `json:"hardStop,omitempty"`
+	Steps    []Step `json:"steps"`
+
+	mu sync.Mutex
+}
+
+// Step is a step of the drain. Steps cut by a hard stop have no duration.
+type Step struct {
+	Name     string `json:"name"`
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Done     bool   `json:"done"`
+}
+
+// step runs f as the named step of the drain.
+func (r *Report) step(name string, f func() error) {
+	r.mu.Lock()
+	i := len(r.Steps)
+	r.Steps = append(r.Steps, Step{Name: name})
+	r.mu.Unlock()
+
+	start := time.Now()
+	err := f()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Steps[i].Duration = time.Since(start).String()
+	r.Steps[i].Done = true
+	if err != nil {
+		r.Steps[i].Error = err.Error()
+	}
+}
+
+// failed reports if a step of the drain failed.
+func (r *Report) failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.Steps {
+		if s.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot returns a copy of the report safe to log while the drain runs.
+func (r *Report) snapshot() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &Report{
+		Cause:    r.Cause,
+		Duration: r.Duration,
+		HardStop: r.HardStop,
+		Steps:    append([]Step(nil), r.Steps...),
+	}
+}
@@ -0,0 +1,257 @@
+// Package loadshed limits the requests handled concurrently with a limit
+// adapting to the observed latency, AIMD style. The limit grows by one per
+// limit of fast requests and is cut by a factor when the latency rises
+// above the tolerated multiple of the baseline, the lowest latency of the
+// successful requests seen recently. The errors are left out of it, a
+// rejected request is cheaper than a served one. The routes don't cost the
+// same, so each one has its baseline.
+package loadshed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// These are the priorities of the requests. The lower priorities only get
+// a share of the limit, so they are shed first.
+const (
+	PriorityLow = iota
+	PriorityNormal
+	PriorityCritical
+	priorities
+)
+
+// These are the outcomes of the requests given to the release function.
+const (
+	// OutcomeSuccess is a request served, its latency feeds the baseline.
+	OutcomeSuccess = iota
+
+	// OutcomeError is a request answered with an error. It can still be
+	// slow, but it doesn't lower the baseline.
+	OutcomeError
+
+	// OutcomeFailed is a request cut short (e.g. a timeout), it always
+	// counts as an overload.
+	OutcomeFailed
+)
+
+// shares are the share of the limit each priority can use.
+var shares = [priorities]float64{
+	PriorityLow:      0.75,
+	PriorityNormal:   1,
+	PriorityCritical: 1.25,
+}
+
+// Config holds the settings of a limiter.
+type Config struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+
+	// Tolerance is the multiple of the baseline latency above which the
+	// service is considered overloaded.
+	Tolerance float64
+
+	// Backoff is the factor applied to the limit on overload.
+	Backoff float64
+
+	// Floor is the latency under which a request never counts as an
+	// overload, the jitter of the fast routes is bigger than their baseline.
+	Floor time.Duration
+
+	// BaselineWindow is how long a baseline latency is kept. The baseline
+	// is reset after it, so a slower deployment doesn't stay overloaded.
+	BaselineWindow time.Duration
+}
+
+// Validate checks the settings.
+func (cfg Config) Validate() error {
+	switch {
+	case cfg.MinLimit < 1:
+		return errors.New("loadshed: min limit must be at least 1")
+	case cfg.MaxLimit < cfg.MinLimit:
+		return errors.New("loadshed: max limit must be at least the min limit")
+	case cfg.InitialLimit < cfg.MinLimit || cfg.InitialLimit > cfg.MaxLimit:
+		return errors.New("loadshed: initial limit must be between the min and max limits")
+	case cfg.Tolerance <= 1:
+		return errors.New("loadshed: tolerance must be above 1")
+	case cfg.Backoff <= 0 || cfg.Backoff >= 1:
+		return errors.New("loadshed: backoff must be between 0 and 1")
+	case cfg.Floor < 0:
+		return errors.New("loadshed: floor can't be negative")
+	case cfg.BaselineWindow <= 0:
+		return errors.New("loadshed: baseline window must be positive")
+	}
+	return nil
+}
+
+// State is a snapshot of the limiter.
+type State struct {
+	Limit      int               `json:"limit"`
+	InFlight   int               `json:"inFlight"`
+	Baselines  map[string]string `json:"baselines"`
+	Admitted   map[string]int64  `json:"admitted"`
+	Rejected   map[string]int64  `json:"rejected"`
+	Decreases  int64             `json:"decreases"`
+	LastChange time.Time         `json:"lastChange"`
+}
+
+// baseline is the lowest latency of a route seen since at.
+type baseline struct {
+	latency time.Duration
+	at      time.Time
+}
+
+// Limiter admits the requests while they are below the limit. It is safe
+// for concurrent use.
+type Limiter struct {
+	cfg Config
+
+	mu         sync.Mutex
+	limit      float64
+	inFlight   int
+	baselines  map[string]baseline
+	decreased  time.Time
+	lastChange time.Time
+	decreases  int64
+	admitted   [priorities]int64
+	rejected   [priorities]int64
+}
+
+// New constructs a limiter.
+func New(cfg Config) (*Limiter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Limiter{
+		cfg:       cfg,
+		limit:     float64(cfg.InitialLimit),
+		baselines: make(map[string]baseline),
+	}, nil
+}
+
+// Acquire admits a request of the route with the priority if there is room
+// for it. The release function must be called once the request completes,
+// with its outcome. The routes must be a bounded set, e.g. the route
+// patterns.
+func (l *Limiter) Acquire(route string, priority int) (release func(outcome int), ok bool) {
+	if priority < 0 || priority >= priorities {
+		return nil, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if float64(l.inFlight) >= math.Max(1, l.limit*shares[priority]) {
+		l.rejected[priority]++
+		return nil, false
+	}
+
+	l.inFlight++
+	l.admitted[priority]++
+	start := time.Now()
+	busy := float64(l.inFlight) >= l.limit/2
+
+	var once sync.Once
+	release = func(outcome int) {
+		once.Do(func() {
+			l.release(route, start, time.Since(start), busy, outcome)
+		})
+	}
+	return release, true
+}
+
+// release updates the limit with the latency of a completed request.
+func (l *Limiter) release(route string, start time.Time, latency time.Duration, busy bool, outcome int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	now := start.Add(latency)
+
+	b, ok := l.baselines[route]
+	if ok && now.Sub(b.at) > l.cfg.BaselineWindow {
+		delete(l.baselines, route)
+		ok = false
+	}
+	if outcome == OutcomeSuccess && (!ok || latency < b.latency) {
+		b = baseline{latency: latency, at: now}
+		l.baselines[route] = b
+		ok = true
+	}
+
+	// Without a baseline, i.e. only errors seen, the latency says nothing.
+	slow := ok && latency > l.cfg.Floor && float64(latency) > float64(b.latency)*l.cfg.Tolerance
+	overloaded := outcome == OutcomeFailed || slow
+	switch {
+	case overloaded:
+		// The requests in flight when the limit was cut finish slow too,
+		// they must not cut it again.
+		if start.Before(l.decreased) {
+			return
+		}
+		l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*l.cfg.Backoff)
+		l.decreased = now
+		l.lastChange = now
+		l.decreases++
+
+	case busy:
+		// An idle service says nothing about what it can take, the limit
+		// only grows when it is actually used.
+		l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+		l.lastChange = now
+	}
+}
+
+// RetryAfter is the delay suggested to the rejected clients of the route,
+// long enough for the requests in flight to drain.
+func (l *Limiter) RetryAfter(route string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d := time.Duration(float64(l.baselines[route].latency) * l.cfg.Tolerance)
+	if d < time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+// State returns a snapshot of the limiter.
+func (l *Limiter) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s := State{
+		Limit:      int(l.limit),
+		InFlight:   l.inFlight,
+		Baselines:  make(map[string]string, len(l.baselines)),
+		Admitted:   make(map[string]int64, priorities),
+		Rejected:   make(map[string]int64, priorities),
+		Decreases:  l.decreases,
+		LastChange: l.lastChange,
+	}
+	for route, b := range l.baselines {
+		s.Baselines[route] = b.latency.String()
+	}
+	for p := 0; p < priorities; p++ {
+		s.Admitted[priorityName(p)] = l.admitted[p]
+		s.Rejected[priorityName(p)] = l.rejected[p]
+	}
+	return s
+}
+
+func priorityName(p int) string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("priority(%d)", p)
+}
@@ -0,0 +1,114 @@
+package loadshed
+
+import (
+	"testing"
+	"time"
+)
+
+const testRoute = "POST /api/signin"
+
+// newTestLimiter constructs a limiter tolerating twice the baseline.
+func newTestLimiter(t *testing.T) *Limiter {
+	t.Helper()
+
+	l, err := New(Config{
+		InitialLimit:   10,
+		MinLimit:       1,
+		MaxLimit:       100,
+		Tolerance:      2,
+		Backoff:        0.5,
+		BaselineWindow: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("constructing limiter: %v", err)
+	}
+	return l
+}
+
+// complete runs a request of the route taking the latency.
+func complete(l *Limiter, start time.Time, latency time.Duration, outcome int) {
+	l.inFlight++
+	l.release(testRoute, start, latency, false, outcome)
+}
+
+func TestErrorsDontSetTheBaseline(t *testing.T) {
+	l := newTestLimiter(t)
+	now := time.Now()
+
+	complete(l, now, 100*time.Millisecond, OutcomeSuccess)
+
+	// The rejected requests are answered fast, they must not make the
+	// served ones look slow.
+	for i := 0; i < 10; i++ {
+		complete(l, now, time.Millisecond, OutcomeError)
+	}
+	if got := l.baselines[testRoute].latency; got != 100*time.Millisecond {
+		t.Fatalf("got baseline %v, want %v", got, 100*time.Millisecond)
+	}
+
+	complete(l, now, 150*time.Millisecond, OutcomeSuccess)
+	if l.decreases != 0 {
+		t.Fatalf("got %d decreases, want none", l.decreases)
+	}
+}
+
+func TestOverload(t *testing.T) {
+	tests := []struct {
+		name    string
+		latency time.Duration
+		outcome int
+		want    int64
+	}{
+		{"fast success", 150 * time.Millisecond, OutcomeSuccess, 0},
+		{"slow success", 300 * time.Millisecond, OutcomeSuccess, 1},
+		{"slow error", 300 * time.Millisecond, OutcomeError, 1},
+		{"fast failure", time.Millisecond, OutcomeFailed, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(t)
+			now := time.Now()
+
+			complete(l, now, 100*time.Millisecond, OutcomeSuccess)
+			complete(l, now.Add(time.Second), tt.latency, tt.outcome)
+
+			if l.decreases != tt.want {
+				t.Fatalf("got %d decreases, want %d", l.decreases, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorsWithoutBaseline(t *testing.T) {
+	l := newTestLimiter(t)
+	now := time.Now()
+
+	complete(l, now, time.Millisecond, OutcomeError)
+	complete(l, now, time.Second, OutcomeError)
+
+	if _, ok := l.baselines[testRoute]; ok {
+		t.Fatal("got a baseline, want none from errors")
+	}
+	if l.decreases != 0 {
+		t.Fatalf("got %d decreases, want none", l.decreases)
+	}
+}
+
+func TestBaselineWindow(t *testing.T) {
+	l := newTestLimiter(t)
+	now := time.Now()
+
+	complete(l, now, 10*time.Millisecond, OutcomeSuccess)
+
+	// After the window the baseline starts over from the slower deployment.
+	later := now.Add(2 * time.Minute)
+	complete(l, later, 100*time.Millisecond, OutcomeSuccess)
+
+	if got := l.baselines[testRoute].latency; got != 100*time.Millisecond {
+		t.Fatalf("got baseline %v, want %v", got, 100*time.Millisecond)
+	}
+	if l.decreases != 0 {
+		t.Fatalf("got %d decreases, want none", l.decreases)
+	}
+}
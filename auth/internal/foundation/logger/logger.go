@@ -8,9 +8,11 @@ import (
 )
 
 // New constructs a Sugared Logger that writes to stdout and
-// provides human readable timestamps.
-func New(service string) (*zap.SugaredLogger, error) {
+// provides human readable timestamps. The level can be changed while the
+// logger is in use.
+func New(service string, level zap.AtomicLevel) (*zap.SugaredLogger, error) {
 	config := zap.NewProductionConfig()
+	config.Level = level
 	config.OutputPaths = []string{"stdout"}
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	config.DisableStacktrace = true
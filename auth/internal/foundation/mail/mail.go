@@ -0,0 +1,150 @@
+// Package mail sends plain text mails through an SMTP relay. The connection
+// is upgraded with STARTTLS when the relay offers it, and the credentials
+// are only sent over TLS.
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Config holds the settings of the relay.
+type Config struct {
+	// Addr is the host:port of the relay.
+	Addr     string
+	Username string
+	Password string
+	// From is the sender, e.g. go-tickets <no-reply@example.com>.
+	From string
+}
+
+// Sender sends the mails through the relay. It is safe for concurrent use,
+// every mail has a connection of its own.
+type Sender struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from *mail.Address
+}
+
+// New constructs a sender for the relay.
+func New(cfg Config) (*Sender, error) {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid relay address: %w", err)
+	}
+
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+
+	s := Sender{
+		addr: cfg.Addr,
+		host: host,
+		from: from,
+	}
+	if cfg.Username != "" {
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	return &s, nil
+}
+
+// Send sends the mail to the recipient. The deadline of the context bounds
+// the whole exchange with the relay.
+func (s *Sender) Send(ctx context.Context, to string, subject string, body string) error {
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	msg, err := s.message(rcpt, subject, body)
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("dialing relay: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("greeting relay: %w", err)
+	}
+	defer c.Close()
+
+	if err := s.send(c, rcpt.Address, msg); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// send runs the smtp exchange of one mail.
+func (s *Sender) send(c *smtp.Client, rcpt string, msg []byte) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("starting tls: %w", err)
+		}
+	}
+	if s.auth != nil {
+		// The plain auth refuses to send the credentials in plain text,
+		// but to localhost.
+		if err := c.Auth(s.auth); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	if err := c.Mail(s.from.Address); err != nil {
+		return fmt.Errorf("sender: %w", err)
+	}
+	if err := c.Rcpt(rcpt); err != nil {
+		return fmt.Errorf("recipient: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+	return nil
+}
+
+// message formats the headers and the body of the mail.
+func (s *Sender) message(rcpt *mail.Address, subject string, body string) ([]byte, error) {
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, errors.New("subject must be a single line")
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + s.from.String() + "\r\n")
+	b.WriteString("To: " + rcpt.String() + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+
+	// The lines of the body end with CRLF, like smtp wants.
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,148 @@
+package mail_test
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/mail"
+)
+
+// received is what the fake relay got for a mail.
+type received struct {
+	from string
+	rcpt string
+	data string
+}
+
+// startRelay starts a fake relay accepting one mail, without tls and auth.
+func startRelay(t *testing.T) (string, <-chan received) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	ch := make(chan received, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		var r received
+		tp.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+			switch cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 fake")
+			case "MAIL":
+				r.from = line
+				tp.PrintfLine("250 ok")
+			case "RCPT":
+				r.rcpt = line
+				tp.PrintfLine("250 ok")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				r.data = string(data)
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				ch <- r
+				return
+			default:
+				tp.PrintfLine("502 unknown")
+			}
+		}
+	}()
+
+	return l.Addr().String(), ch
+}
+
+func TestSend(t *testing.T) {
+	addr, ch := startRelay(t)
+
+	s, err := mail.New(mail.Config{Addr: addr, From: "go-tickets <no-reply@example.com>"})
+	if err != nil {
+		t.Fatalf("constructing sender: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Send(ctx, "ana@example.com", "Reset your password", "Open the link:\nhttps://example.com/reset"); err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+
+	r := <-ch
+	if r.from != "MAIL FROM:<no-reply@example.com>" {
+		t.Errorf("got %q, want the sender", r.from)
+	}
+	if r.rcpt != "RCPT TO:<ana@example.com>" {
+		t.Errorf("got %q, want the recipient", r.rcpt)
+	}
+
+	for _, want := range []string{
+		"From: \"go-tickets\" <no-reply@example.com>\n",
+		"To: <ana@example.com>\n",
+		"Subject: Reset your password\n",
+		"Content-Type: text/plain; charset=utf-8\n",
+		"\n\nOpen the link:\nhttps://example.com/reset",
+	} {
+		if !strings.Contains(r.data, want) {
+			t.Errorf("got message %q, want it to contain %q", r.data, want)
+		}
+	}
+}
+
+func TestSendRejects(t *testing.T) {
+	s, err := mail.New(mail.Config{Addr: "127.0.0.1:1", From: "no-reply@example.com"})
+	if err != nil {
+		t.Fatalf("constructing sender: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		to      string
+		subject string
+	}{
+		{"header in subject", "ana@example.com", "Hi\r\nBcc: eve@example.com"},
+		{"header in recipient", "ana@example.com\r\nBcc: eve@example.com", "Hi"},
+		{"invalid recipient", "ana", "Hi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The relay is never dialed, the mail is rejected before.
+			err := s.Send(context.Background(), tt.to, tt.subject, "body")
+			if err == nil || strings.Contains(err.Error(), "dialing") {
+				t.Fatalf("got error %v, want a rejected mail", err)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := mail.New(mail.Config{Addr: "relay.example.com", From: "no-reply@example.com"}); err == nil {
+		t.Error("got no error for an address without port")
+	}
+	if _, err := mail.New(mail.Config{Addr: "relay.example.com:587", From: "no-reply"}); err == nil {
+		t.Error("got no error for an invalid sender")
+	}
+}
@@ -0,0 +1,412 @@
+// Package memauth is an in-memory identity provider standing in for
+// firebase auth. It runs the service without the emulator and backs the
+// tests of the sign-up and the sign-in. The users are lost on restart.
+//
+// The passwords are hashed, the id tokens and the session cookies are
+// signed with a key generated at construction. Like with firebase, the
+// users of every tenant are apart, the tenant is the one of the request.
+package memauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/pkg/accesstoken"
+)
+
+// Set of errors returned by the provider.
+var (
+	ErrEmailExists        = errors.New("memauth: email already in use")
+	ErrUserNotFound       = errors.New("memauth: user not found")
+	ErrInvalidCredentials = errors.New("memauth: invalid email or password")
+	ErrInvalidToken       = errors.New("memauth: invalid token")
+	ErrRevoked            = errors.New("memauth: token revoked")
+)
+
+// These are the settings of the issued tokens.
+const (
+	issuer        = "memauth"
+	idTokenTTL    = time.Hour
+	minSessionTTL = 5 * time.Minute
+	maxSessionTTL = 14 * 24 * time.Hour
+	keyID         = "memauth"
+)
+
+// These are the audiences telling the id tokens and the session cookies apart.
+const (
+	audienceIDToken = "id_token"
+	audienceSession = "session"
+)
+
+// These are the settings of the password hashing.
+const (
+	hashIterations = 100_000
+	hashSize       = 32
+	saltSize       = 16
+)
+
+// User is a user of the provider.
+type User struct {
+	UID         string
+	TenantID    string
+	Email       string
+	DisplayName string
+	Roles       []string
+}
+
+// Token is a verified id token or session cookie. AuthTime is when the user
+// signed in with the password.
+type Token struct {
+	UID      string
+	TenantID string
+	Email    string
+	Roles    []string
+	Issuer   string
+	Audience string
+	AuthTime int64
+	IssuedAt int64
+	Expires  int64
+}
+
+// account is a stored user.
+type account struct {
+	user       User
+	salt       []byte
+	hash       []byte
+	validAfter int64
+}
+
+// Provider holds the users. It is safe for concurrent use.
+type Provider struct {
+	key ed25519.PrivateKey
+
+	mu      sync.RWMutex
+	users   map[string]*account
+	byEmail map[string]string
+}
+
+// New constructs an empty provider.
+func New() (*Provider, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("memauth: generating key: %w", err)
+	}
+
+	p := Provider{
+		key:     key,
+		users:   make(map[string]*account),
+		byEmail: make(map[string]string),
+	}
+	return &p, nil
+}
+
+// CreateUser adds a user to the tenant of the request. The emails are
+// unique within a tenant, regardless of their case.
+func (p *Provider) CreateUser(ctx context.Context, email string, password string, displayName string) (User, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return User{}, fmt.Errorf("memauth: generating salt: %w", err)
+	}
+	hash := hashPassword(password, salt)
+
+	uid, err := newID()
+	if err != nil {
+		return User{}, err
+	}
+
+	tenantID := web.GetTenantID(ctx)
+	key := emailKey(tenantID, email)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.byEmail[key]; exists {
+		return User{}, ErrEmailExists
+	}
+
+	a := account{
+		user: User{
+			UID:         uid,
+			TenantID:    tenantID,
+			Email:       email,
+			DisplayName: displayName,
+		},
+		salt: salt,
+		hash: hash,
+	}
+	p.users[uid] = &a
+	p.byEmail[key] = uid
+
+	return a.user, nil
+}
+
+// GetUser returns the user of the tenant of the request.
+func (p *Provider) GetUser(ctx context.Context, uid string) (User, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	a, err := p.account(ctx, uid)
+	if err != nil {
+		return User{}, err
+	}
+	return copyUser(a.user), nil
+}
+
+// SetRoles replaces the roles of the user, the tokens issued from then on
+// carry them.
+func (p *Provider) SetRoles(ctx context.Context, uid string, roles []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	a, err := p.account(ctx, uid)
+	if err != nil {
+		return err
+	}
+	a.user.Roles = append([]string(nil), roles...)
+	return nil
+}
+
+// SignInWithPassword returns an id token for the user of the tenant of the
+// request, like the client sdk does with firebase.
+func (p *Provider) SignInWithPassword(ctx context.Context, email string, password string) (string, error) {
+	p.mu.RLock()
+	uid, exists := p.byEmail[emailKey(web.GetTenantID(ctx), email)]
+	var a account
+	if exists {
+		a = *p.users[uid]
+		a.user = copyUser(a.user)
+	}
+	p.mu.RUnlock()
+
+	// The password is hashed even for an unknown email, so the timing
+	// doesn't tell the registered emails.
+	salt := a.salt
+	if !exists {
+		salt = make([]byte, saltSize)
+	}
+	hash := hashPassword(password, salt)
+	if !exists || subtle.ConstantTimeCompare(hash, a.hash) != 1 {
+		return "", ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	return p.sign(a.user, audienceIDToken, now.Unix(), now.Add(idTokenTTL))
+}
+
+// VerifyIDToken verifies the id token, it must belong to the tenant of the
+// request.
+func (p *Provider) VerifyIDToken(ctx context.Context, idToken string) (Token, error) {
+	return p.verify(ctx, idToken, audienceIDToken)
+}
+
+// SessionCookie creates a session cookie from the id token. Like with
+// firebase, the lifetime must be between 5 minutes and 2 weeks.
+func (p *Provider) SessionCookie(ctx context.Context, idToken string, expiresIn time.Duration) (string, error) {
+	if expiresIn < minSessionTTL || expiresIn > maxSessionTTL {
+		return "", fmt.Errorf("memauth: session lifetime must be between %s and %s", minSessionTTL, maxSessionTTL)
+	}
+
+	t, err := p.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.RLock()
+	a, err := p.account(ctx, t.UID)
+	var u User
+	if err == nil {
+		u = copyUser(a.user)
+	}
+	p.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	return p.sign(u, audienceSession, t.AuthTime, time.Now().Add(expiresIn))
+}
+
+// Session creates a session cookie for the user of the tenant of the
+// request signed in another way than with the password, like a passkey.
+// The sign-in is now.
+func (p *Provider) Session(ctx context.Context, uid string, expiresIn time.Duration) (string, error) {
+	if expiresIn < minSessionTTL || expiresIn > maxSessionTTL {
+		return "", fmt.Errorf("memauth: session lifetime must be between %s and %s", minSessionTTL, maxSessionTTL)
+	}
+
+	p.mu.RLock()
+	a, err := p.account(ctx, uid)
+	var u User
+	if err == nil {
+		u = copyUser(a.user)
+	}
+	p.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return p.sign(u, audienceSession, now.Unix(), now.Add(expiresIn))
+}
+
+// VerifySession verifies the session cookie and checks it was not revoked.
+// It must belong to the tenant of the request.
+func (p *Provider) VerifySession(ctx context.Context, value string) (Token, error) {
+	t, err := p.verify(ctx, value, audienceSession)
+	if err != nil {
+		return Token{}, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	a, err := p.account(ctx, t.UID)
+	switch {
+	case err != nil:
+		return Token{}, err
+	case t.AuthTime < a.validAfter:
+		return Token{}, ErrRevoked
+	}
+	return t, nil
+}
+
+// RevokeSessions revokes the session cookies of the sign-ins of the user
+// until now. Like with firebase, the time is kept to the second, a sign-in
+// of the same second is not revoked.
+func (p *Provider) RevokeSessions(ctx context.Context, uid string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	a, err := p.account(ctx, uid)
+	if err != nil {
+		return err
+	}
+	a.validAfter = time.Now().Unix()
+	return nil
+}
+
+// account returns the account of the tenant of the request, the lock must
+// be held.
+func (p *Provider) account(ctx context.Context, uid string) (*account, error) {
+	a, exists := p.users[uid]
+	if !exists || a.user.TenantID != web.GetTenantID(ctx) {
+		return nil, ErrUserNotFound
+	}
+	return a, nil
+}
+
+// sign issues a token of the audience for the user.
+func (p *Provider) sign(u User, audience string, authTime int64, expires time.Time) (string, error) {
+	jti, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	c := accesstoken.Claims{
+		Issuer:    tenantIssuer(u.TenantID),
+		Subject:   u.UID,
+		Audience:  audience,
+		ID:        jti,
+		IssuedAt:  authTime,
+		ExpiresAt: expires.Unix(),
+		Email:     u.Email,
+		Roles:     u.Roles,
+	}
+	return accesstoken.Sign(p.key, keyID, c)
+}
+
+// verify checks the token of the audience was issued for the tenant of the
+// request.
+func (p *Provider) verify(ctx context.Context, value string, audience string) (Token, error) {
+	c, err := accesstoken.Parse(value, func(kid string) (crypto.PublicKey, error) {
+		if kid != keyID {
+			return nil, accesstoken.ErrUnknownKey
+		}
+		return p.key.Public(), nil
+	})
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	tenantID := web.GetTenantID(ctx)
+	if err := c.Validate(time.Now(), tenantIssuer(tenantID), audience, 0); err != nil {
+		return Token{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	t := Token{
+		UID:      c.Subject,
+		TenantID: tenantID,
+		Email:    c.Email,
+		Roles:    c.Roles,
+		Issuer:   c.Issuer,
+		Audience: c.Audience,
+		AuthTime: c.IssuedAt,
+		IssuedAt: c.IssuedAt,
+		Expires:  c.ExpiresAt,
+	}
+	return t, nil
+}
+
+// tenantIssuer returns the issuer of the tokens of the tenant.
+func tenantIssuer(tenantID string) string {
+	if tenantID == "" {
+		return issuer
+	}
+	return issuer + "/tenants/" + tenantID
+}
+
+// emailKey returns the key of the email within the tenant.
+func emailKey(tenantID string, email string) string {
+	return tenantID + "/" + strings.ToLower(email)
+}
+
+// copyUser returns a user that doesn't share the roles with the stored one.
+func copyUser(u User) User {
+	u.Roles = append([]string(nil), u.Roles...)
+	return u
+}
+
+// newID returns a random id, like the uids of firebase.
+func newID() (string, error) {
+	b := make([]byte, 21)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("memauth: generating id: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashPassword derives the hash of the password with PBKDF2-HMAC-SHA256.
+func hashPassword(password string, salt []byte) []byte {
+	prf := hmac.New(sha256.New, []byte(password))
+
+	// The hash fits in a single block of the derivation.
+	var block [4]byte
+	binary.BigEndian.PutUint32(block[:], 1)
+	prf.Write(salt)
+	prf.Write(block[:])
+	u := prf.Sum(nil)
+
+	out := make([]byte, len(u))
+	copy(out, u)
+	for i := 1; i < hashIterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range out {
+			out[j] ^= u[j]
+		}
+	}
+	return out[:hashSize]
+}
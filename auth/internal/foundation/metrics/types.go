@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"bufio"
+	"sort"
+	"sync"
+)
+
+// Counter is a value that only goes up, like the number of requests.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+// NewCounter constructs a counter registered on the default registry.
+func NewCounter(name string, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewCounter constructs a counter registered on the registry.
+func (r *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	c := Counter{
+		desc:   desc{n: name, help: help, kind: "counter", labels: labels},
+		values: make(map[string]float64),
+		labels: make(map[string][]string),
+	}
+	r.register(&c)
+	return &c
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v to the counter. Negative values are ignored.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+	k := c.key(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.labels[k]; !ok {
+		c.labels[k] = append([]string{}, values...)
+	}
+	c.values[k] += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w)
+	for _, k := range sortedKeys(c.labels) {
+		c.series(w, "", c.labels[k], nil, c.values[k])
+	}
+}
+
+// =============================================================================
+
+// Gauge is a value that goes up and down, like the requests in flight.
+type Gauge struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+// NewGauge constructs a gauge registered on the default registry.
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+// NewGauge constructs a gauge registered on the registry.
+func (r *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	g := Gauge{
+		desc:   desc{n: name, help: help, kind: "gauge", labels: labels},
+		values: make(map[string]float64),
+		labels: make(map[string][]string),
+	}
+	r.register(&g)
+	return &g
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.update(values, func(float64) float64 { return v })
+}
+
+// Add adds v to the gauge.
+func (g *Gauge) Add(v float64, values ...string) {
+	g.update(values, func(old float64) float64 { return old + v })
+}
+
+// Inc adds one to the gauge.
+func (g *Gauge) Inc(values ...string) {
+	g.Add(1, values...)
+}
+
+// Dec subtracts one from the gauge.
+func (g *Gauge) Dec(values ...string) {
+	g.Add(-1, values...)
+}
+
+func (g *Gauge) update(values []string, fn func(float64) float64) {
+	k := g.key(values)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.labels[k]; !ok {
+		g.labels[k] = append([]string{}, values...)
+	}
+	g.values[k] = fn(g.values[k])
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.header(w)
+	for _, k := range sortedKeys(g.labels) {
+		g.series(w, "", g.labels[k], nil, g.values[k])
+	}
+}
+
+// GaugeFunc is a gauge without labels whose value is read when collected.
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc constructs a gauge func registered on the default registry.
+func NewGaugeFunc(name string, help string, fn func() float64) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, fn)
+}
+
+// NewGaugeFunc constructs a gauge func registered on the registry.
+func (r *Registry) NewGaugeFunc(name string, help string, fn func() float64) *GaugeFunc {
+	g := GaugeFunc{
+		desc: desc{n: name, help: help, kind: "gauge"},
+		fn:   fn,
+	}
+	r.register(&g)
+	return &g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.header(w)
+	g.series(w, "", nil, nil, g.fn())
+}
+
+// =============================================================================
+
+// Histogram counts the observations in buckets, like the latency of the requests.
+type Histogram struct {
+	desc
+	buckets  []float64
+	mu       sync.Mutex
+	observed map[string]*histogramSeries
+	labels   map[string][]string
+}
+
+// histogramSeries holds the observations of a set of label values.
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram constructs a histogram registered on the default registry.
+// Nil buckets mean DefBuckets.
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram constructs a histogram registered on the registry. Nil
+// buckets mean DefBuckets.
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+
+	h := Histogram{
+		desc:     desc{n: name, help: help, kind: "histogram", labels: labels},
+		buckets:  b,
+		observed: make(map[string]*histogramSeries),
+		labels:   make(map[string][]string),
+	}
+	r.register(&h)
+	return &h
+}
+
+// Observe adds the observation v.
+func (h *Histogram) Observe(v float64, values ...string) {
+	k := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.observed[k]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.observed[k] = s
+		h.labels[k] = append([]string{}, values...)
+	}
+
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w)
+	for _, k := range sortedKeys(h.labels) {
+		s := h.observed[k]
+		values := h.labels[k]
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			h.series(w, "_bucket", values, []string{"le", formatFloat(upper)}, float64(cumulative))
+		}
+		h.series(w, "_bucket", values, []string{"le", "+Inf"}, float64(s.count))
+		h.series(w, "_sum", values, nil, s.sum)
+		h.series(w, "_count", values, nil, float64(s.count))
+	}
+}
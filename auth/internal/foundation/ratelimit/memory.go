@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// shards is the number of independently locked parts of the memory store.
+const shards = 64
+
+// Memory is an in-memory Store. The keys are spread over shards to reduce
+// lock contention and the buckets that are full again are evicted.
+type Memory struct {
+	shards [shards]shard
+}
+
+// shard is a part of the memory store.
+type shard struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+}
+
+// NewMemory constructs an empty in-memory store.
+func NewMemory() *Memory {
+	var m Memory
+	for i := range m.shards {
+		m.shards[i].buckets = make(map[string]bucket)
+	}
+	return &m
+}
+
+// Take tries to take a token from the bucket of the key at now.
+func (m *Memory) Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error) {
+	s := m.shard(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, res := s.buckets[key].take(l, now)
+	s.buckets[key] = b
+
+	return res, nil
+}
+
+// Evict removes the buckets that are full again at now, they hold the same
+// state as a missing bucket.
+func (m *Memory) Evict(now time.Time) int {
+	var evicted int
+	for i := range m.shards {
+		s := &m.shards[i]
+
+		s.mu.Lock()
+		for k, b := range s.buckets {
+			if !b.tat.After(now) {
+				delete(s.buckets, k)
+				evicted++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return evicted
+}
+
+// Run evicts the idle buckets every interval until the context is canceled.
+func (m *Memory) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			m.Evict(t)
+		}
+	}
+}
+
+// shard returns the shard of the key.
+func (m *Memory) shard(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &m.shards[h.Sum32()%shards]
+}
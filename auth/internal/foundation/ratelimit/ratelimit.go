@@ -0,0 +1,175 @@
+// Package ratelimit provides token bucket rate limiting with a pluggable
+// bucket store.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Limit represents a token bucket: Requests tokens refill every Per and at
+// most Requests can be spent at once. The zero value means unlimited.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses limits written as <requests>/<unit> where the unit is
+// s, sec, m, min, h or hour, e.g. 5/min.
+func ParseLimit(s string) (Limit, error) {
+	var l Limit
+	if err := l.Set(s); err != nil {
+		return Limit{}, err
+	}
+	return l, nil
+}
+
+// Set implements the conf.Setter interface so limits can be configured.
+func (l *Limit) Set(s string) error {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("limit %q must be written as <requests>/<unit>", s)
+	}
+
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n < 0 {
+		return fmt.Errorf("limit %q must have a positive number of requests", s)
+	}
+
+	var per time.Duration
+	switch parts[1] {
+	case "s", "sec":
+		per = time.Second
+	case "m", "min":
+		per = time.Minute
+	case "h", "hour":
+		per = time.Hour
+	default:
+		return fmt.Errorf("limit %q has an unknown unit", s)
+	}
+
+	*l = Limit{Requests: n, Per: per}
+	return nil
+}
+
+// String returns the limit in the format read by ParseLimit.
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "unlimited"
+	}
+	unit := "s"
+	switch l.Per {
+	case time.Minute:
+		unit = "min"
+	case time.Hour:
+		unit = "hour"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, unit)
+}
+
+// MarshalText implements encoding.TextMarshaler, the limit is written in
+// the format read by ParseLimit.
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *Limit) UnmarshalText(b []byte) error {
+	if string(b) == "unlimited" {
+		*l = Limit{}
+		return nil
+	}
+	return l.Set(string(b))
+}
+
+// Unlimited reports if the limit does not restrict anything.
+func (l Limit) Unlimited() bool {
+	return l.Requests == 0 || l.Per == 0
+}
+
+// interval returns the time needed to refill one token.
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// Var holds a limit that can be replaced while it is in use, e.g. when the
+// configuration is reloaded. It is safe for concurrent use.
+type Var struct {
+	v atomic.Value
+}
+
+// NewVar constructs a Var holding the limit.
+func NewVar(l Limit) *Var {
+	var v Var
+	v.v.Store(l)
+	return &v
+}
+
+// Load returns the current limit.
+func (v *Var) Load() Limit {
+	return v.v.Load().(Limit)
+}
+
+// Store replaces the limit.
+func (v *Var) Store(l Limit) {
+	v.v.Store(l)
+}
+
+// Result represents the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token, only set when denied.
+	RetryAfter time.Duration
+}
+
+// Store holds the buckets. Implementations must take the tokens atomically,
+// so a shared backend can replace the in-memory one.
+type Store interface {
+	// Take tries to take a token from the bucket of the key at now.
+	Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error)
+}
+
+// bucket represents the state of a token bucket as the time at which it
+// was full, the "theoretical arrival time" of the GCRA algorithm. This
+// keeps the state to a single value per key.
+type bucket struct {
+	tat time.Time
+}
+
+// take applies the limit at now and returns the new state of the bucket.
+func (b bucket) take(l Limit, now time.Time) (bucket, Result) {
+	interval := l.interval()
+	burst := time.Duration(l.Requests) * interval
+
+	tat := b.tat
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(interval)
+	allowAt := next.Add(-burst)
+	if now.Before(allowAt) {
+		return b, Result{
+			Allowed:    false,
+			Limit:      l.Requests,
+			Remaining:  0,
+			Reset:      tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}
+	}
+
+	return bucket{tat: next}, Result{
+		Allowed:   true,
+		Limit:     l.Requests,
+		Remaining: int((burst - next.Sub(now)) / interval),
+		Reset:     next.Sub(now),
+	}
+}
@@ -0,0 +1,54 @@
+package reload
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Handler serves the configuration: GET returns it with the secrets masked,
+// POST applies the JSON object of the request over it and returns the
+// changes. The requests must carry the token as a bearer token, without a
+// token the handler refuses all of them.
+func (s *Store) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			respond(w, map[string]string{"error": "unauthorized"}, http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			respond(w, Masked(s.Current()), http.StatusOK)
+
+		case http.MethodPost:
+			next, err := s.Decode(http.MaxBytesReader(w, r.Body, 1<<20))
+			if err != nil {
+				respond(w, map[string]string{"error": err.Error()}, http.StatusBadRequest)
+				return
+			}
+
+			changes, err := s.Apply(next)
+			if err != nil {
+				respond(w, map[string]string{"error": err.Error()}, http.StatusUnprocessableEntity)
+				return
+			}
+			if changes == nil {
+				changes = []Change{}
+			}
+			respond(w, map[string]interface{}{"changes": changes}, http.StatusOK)
+
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			respond(w, map[string]string{"error": "method not allowed"}, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func respond(w http.ResponseWriter, v interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
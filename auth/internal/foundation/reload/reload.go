@@ -0,0 +1,235 @@
+// Package reload holds the part of the configuration that can change while
+// the service runs. A new configuration is validated before it replaces the
+// current one, so a bad reload leaves the service as it was, then the
+// subscribers are told about it and the changes are logged.
+package reload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Validator is implemented by the configurations checking their values.
+// The subscribers can rely on a validated configuration.
+type Validator interface {
+	Validate() error
+}
+
+// Subscriber is told about the configuration once it changed.
+type Subscriber func(cfg interface{})
+
+// Change is the change of a field of the configuration. The values of the
+// fields tagged with conf:"mask" are masked.
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Store holds the current configuration, a struct value. It is safe for
+// concurrent use.
+type Store struct {
+	log *zap.SugaredLogger
+
+	mu      sync.Mutex
+	current interface{}
+	subs    []Subscriber
+}
+
+// New constructs a store holding the initial configuration.
+func New(initial interface{}, log *zap.SugaredLogger) (*Store, error) {
+	if reflect.TypeOf(initial).Kind() != reflect.Struct {
+		return nil, fmt.Errorf("reload: configuration must be a struct, got %T", initial)
+	}
+	if v, ok := initial.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, fmt.Errorf("reload: %w", err)
+		}
+	}
+
+	s := Store{
+		log:     log,
+		current: initial,
+	}
+	return &s, nil
+}
+
+// Current returns the current configuration.
+func (s *Store) Current() interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.current
+}
+
+// Subscribe registers a function called with every new configuration.
+func (s *Store) Subscribe(sub Subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subs = append(s.subs, sub)
+}
+
+// Apply replaces the configuration and returns the changes. An invalid
+// configuration is rejected and the current one stays in place. Nothing is
+// done when nothing changed.
+func (s *Store) Apply(next interface{}) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reflect.TypeOf(next) != reflect.TypeOf(s.current) {
+		return nil, fmt.Errorf("reload: configuration must be a %T, got %T", s.current, next)
+	}
+	if v, ok := next.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			s.log.Errorw("reload", "status", "configuration rejected", "ERROR", err)
+			return nil, fmt.Errorf("reload: %w", err)
+		}
+	}
+
+	changes := Diff(s.current, next)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	s.current = next
+	for _, sub := range s.subs {
+		sub(next)
+	}
+
+	s.log.Infow("reload", "status", "configuration changed", "changes", changes)
+	return changes, nil
+}
+
+// Decode reads a JSON object over a copy of the current configuration, so
+// the fields it doesn't mention keep their value.
+func (s *Store) Decode(r io.Reader) (interface{}, error) {
+	cur := s.Current()
+
+	// The copy goes through JSON, decoding over the current configuration
+	// would write in the arrays of its slices.
+	b, err := json.Marshal(cur)
+	if err != nil {
+		return nil, fmt.Errorf("reload: encoding configuration: %w", err)
+	}
+	v := reflect.New(reflect.TypeOf(cur))
+	if err := json.Unmarshal(b, v.Interface()); err != nil {
+		return nil, fmt.Errorf("reload: copying configuration: %w", err)
+	}
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v.Interface()); err != nil {
+		return nil, fmt.Errorf("reload: decoding configuration: %w", err)
+	}
+	return v.Elem().Interface(), nil
+}
+
+// Masked returns the configuration as a JSON object with the values of the
+// masked fields hidden.
+func Masked(cfg interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	walk(reflect.ValueOf(cfg), "", false, func(field string, v reflect.Value, masked bool) {
+		if masked {
+			out[field] = mask
+			return
+		}
+		out[field] = v.Interface()
+	})
+	return out
+}
+
+// Diff returns the fields whose value differs between the configurations
+// of the same type.
+func Diff(old interface{}, new interface{}) []Change {
+	values := make(map[string]reflect.Value)
+	walk(reflect.ValueOf(old), "", false, func(field string, v reflect.Value, masked bool) {
+		values[field] = v
+	})
+
+	var changes []Change
+	walk(reflect.ValueOf(new), "", false, func(field string, v reflect.Value, masked bool) {
+		ov := values[field]
+		if reflect.DeepEqual(ov.Interface(), v.Interface()) {
+			return
+		}
+
+		c := Change{Field: field, Old: mask, New: mask}
+		if !masked {
+			c.Old = format(ov)
+			c.New = format(v)
+		}
+		changes = append(changes, c)
+	})
+	return changes
+}
+
+// mask replaces the values of the masked fields.
+const mask = "xxxxxx"
+
+// walk calls fn with the leaf fields of the struct, named by their path of
+// JSON names. The fields of a masked struct are masked.
+func walk(v reflect.Value, prefix string, masked bool, fn func(field string, v reflect.Value, masked bool)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := prefix + f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+			name = prefix + tag
+		}
+		m := masked || hasMask(f.Tag.Get("conf"))
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && !isLeaf(fv) {
+			walk(fv, name+".", m, fn)
+			continue
+		}
+		fn(name, fv, m)
+	}
+}
+
+// isLeaf reports if the struct value is shown as a whole, like the values
+// with a text form.
+func isLeaf(v reflect.Value) bool {
+	switch v.Interface().(type) {
+	case fmt.Stringer, interface{ MarshalText() ([]byte, error) }:
+		return true
+	}
+	return false
+}
+
+func hasMask(tag string) bool {
+	for _, opt := range strings.Split(tag, ",") {
+		if strings.TrimSpace(opt) == "mask" {
+			return true
+		}
+	}
+	return false
+}
+
+// format returns the value as it is shown in the changes.
+func format(v reflect.Value) string {
+	switch x := v.Interface().(type) {
+	case string:
+		return x
+	case fmt.Stringer:
+		return x.String()
+	}
+
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(v.Interface()); err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return strings.TrimSpace(b.String())
+}
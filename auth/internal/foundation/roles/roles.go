@@ -0,0 +1,19 @@
+// Package roles holds the roles a principal can have. They are assigned by
+// the role use case and checked by the authorization of the routes.
+package roles
+
+// These are the roles a principal can have.
+const (
+	Admin     = "admin"
+	Organizer = "organizer"
+	Customer  = "customer"
+)
+
+// IsKnown reports if the given value is one of the known roles.
+func IsKnown(role string) bool {
+	switch role {
+	case Admin, Organizer, Customer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+// Package tenant resolves the tenant of the requests. Every tenant is an
+// Identity Platform tenant, a user pool of its own, e.g. the ticket shop of
+// a promoter, with its own password and session policies.
+//
+// The tenant is picked by a header, for the clients that can't choose the
+// host, then by the host of the request. The requests of the other hosts
+// go to the default tenant, or to the users of the project without one.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// ErrUnknown is returned when the request names a tenant that is not
+// registered.
+var ErrUnknown = errors.New("tenant: unknown tenant")
+
+// These are the bounds of the session lifetime. Identity Platform caps it
+// to 2 weeks, the sessions of signin last at least a day.
+const (
+	minSessionTTL = 24 * time.Hour
+	maxSessionTTL = 14 * 24 * time.Hour
+)
+
+// Policy holds the rules of a tenant. The zero values keep the defaults.
+type Policy struct {
+	// PasswordMinLength is the minimum length of the passwords, on top of
+	// the rules all the passwords follow.
+	PasswordMinLength int
+
+	// SessionTTL is the lifetime of the session cookies.
+	SessionTTL time.Duration
+}
+
+// Tenant is a registered tenant and the hosts it is served on.
+type Tenant struct {
+	ID     string
+	Hosts  []string
+	Policy Policy
+}
+
+// Parse parses a tenant in the form
+// "id=promoter-a-k2j3 hosts=shop.promoter-a.com,promoter-a.localhost
+// passwordMinLength=10 sessionTTL=24h". Only the id is required.
+func Parse(s string) (Tenant, error) {
+	var t Tenant
+	for _, field := range strings.Fields(s) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return Tenant{}, fmt.Errorf("tenant: invalid field %q", field)
+		}
+
+		var err error
+		switch kv[0] {
+		case "id":
+			t.ID = kv[1]
+		case "hosts":
+			for _, h := range strings.Split(kv[1], ",") {
+				if h = strings.TrimSpace(h); h != "" {
+					t.Hosts = append(t.Hosts, h)
+				}
+			}
+		case "passwordMinLength":
+			t.Policy.PasswordMinLength, err = strconv.Atoi(kv[1])
+		case "sessionTTL":
+			t.Policy.SessionTTL, err = time.ParseDuration(kv[1])
+		default:
+			return Tenant{}, fmt.Errorf("tenant: unknown field %q", kv[0])
+		}
+		if err != nil {
+			return Tenant{}, fmt.Errorf("tenant: field %s: %w", kv[0], err)
+		}
+	}
+
+	if err := t.validate(); err != nil {
+		return Tenant{}, err
+	}
+	return t, nil
+}
+
+// validate checks the tenant can be registered.
+func (t Tenant) validate() error {
+	switch {
+	case t.ID == "":
+		return errors.New("tenant: id is required")
+	case t.Policy.PasswordMinLength < 0:
+		return fmt.Errorf("tenant %s: password min length can't be negative", t.ID)
+	case t.Policy.SessionTTL != 0 && (t.Policy.SessionTTL < minSessionTTL || t.Policy.SessionTTL > maxSessionTTL):
+		return fmt.Errorf("tenant %s: session ttl must be between %s and %s", t.ID, minSessionTTL, maxSessionTTL)
+	}
+	return nil
+}
+
+// =============================================================================
+
+// Registry holds the registered tenants. It is read only once constructed.
+type Registry struct {
+	header    string
+	defaultID string
+	byID      map[string]Tenant
+	byHost    map[string]string
+}
+
+// NewRegistry constructs a registry of the tenants. The header names the
+// tenant of a request, an empty header leaves the choice to the host. The
+// default tenant, if any, serves the hosts of no tenant.
+func NewRegistry(tenants []Tenant, header string, defaultID string) (*Registry, error) {
+	r := Registry{
+		header:    header,
+		defaultID: defaultID,
+		byID:      make(map[string]Tenant),
+		byHost:    make(map[string]string),
+	}
+
+	for _, t := range tenants {
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		if _, exists := r.byID[t.ID]; exists {
+			return nil, fmt.Errorf("tenant %s: registered twice", t.ID)
+		}
+		r.byID[t.ID] = t
+
+		for _, h := range t.Hosts {
+			h = normalizeHost(h)
+			if other, exists := r.byHost[h]; exists {
+				return nil, fmt.Errorf("tenant %s: host %s already served by tenant %s", t.ID, h, other)
+			}
+			r.byHost[h] = t.ID
+		}
+	}
+
+	if _, exists := r.byID[defaultID]; defaultID != "" && !exists {
+		return nil, fmt.Errorf("tenant: default tenant %s is not registered", defaultID)
+	}
+
+	return &r, nil
+}
+
+// Resolve returns the id of the tenant of the request, empty for the users
+// of the project. A header naming an unknown tenant is an error.
+func (r *Registry) Resolve(req *http.Request) (string, error) {
+	if r.header != "" {
+		if id := req.Header.Get(r.header); id != "" {
+			if _, exists := r.byID[id]; !exists {
+				return "", ErrUnknown
+			}
+			return id, nil
+		}
+	}
+
+	if id, exists := r.byHost[normalizeHost(req.Host)]; exists {
+		return id, nil
+	}
+	return r.defaultID, nil
+}
+
+// Lookup returns the tenant of the id.
+func (r *Registry) Lookup(id string) (Tenant, bool) {
+	t, exists := r.byID[id]
+	return t, exists
+}
+
+// Policy returns the policy of the tenant of the request, the default one
+// without a tenant.
+func (r *Registry) Policy(ctx context.Context) Policy {
+	return r.byID[web.GetTenantID(ctx)].Policy
+}
+
+// normalizeHost drops the port and the case of the host.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
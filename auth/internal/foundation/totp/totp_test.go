@@ -0,0 +1,108 @@
+package totp_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/totp"
+)
+
+// rfcSecret is the base32 of the SHA1 seed of RFC 6238 appendix B,
+// "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// The codes are the last 6 of the 8 digits of appendix B.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		step := totp.Step(time.Unix(tt.unix, 0))
+
+		got, err := totp.Code(rfcSecret, step)
+		if err != nil {
+			t.Fatalf("computing code at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("got code %s at %d, want %s", got, tt.unix, tt.want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := totp.Step(now)
+
+	tests := []struct {
+		name   string
+		offset int64
+		skew   int64
+		valid  bool
+	}{
+		{"current step", 0, 0, true},
+		{"previous step without skew", -1, 0, false},
+		{"previous step", -1, 1, true},
+		{"next step", 1, 1, true},
+		{"two steps behind", -2, 1, false},
+		{"two steps ahead", 2, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := totp.Code(rfcSecret, step+tt.offset)
+			if err != nil {
+				t.Fatalf("computing code: %v", err)
+			}
+
+			got, err := totp.Validate(rfcSecret, code, now, tt.skew)
+			switch {
+			case tt.valid && err != nil:
+				t.Fatalf("validating: %v", err)
+			case tt.valid && got != step+tt.offset:
+				t.Fatalf("got step %d, want %d", got, step+tt.offset)
+			case !tt.valid && !errors.Is(err, totp.ErrInvalidCode):
+				t.Fatalf("got error %v, want %v", err, totp.ErrInvalidCode)
+			}
+		})
+	}
+}
+
+func TestValidateReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := totp.Code(rfcSecret, totp.Step(now))
+	if err != nil {
+		t.Fatalf("computing code: %v", err)
+	}
+
+	// The callers keep the last accepted step and reject the codes of the
+	// steps up to it, the same code seen later in the window is one of them.
+	last, err := totp.Validate(rfcSecret, code, now, 1)
+	if err != nil {
+		t.Fatalf("validating: %v", err)
+	}
+	step, err := totp.Validate(rfcSecret, code, now.Add(totp.Period), 1)
+	if err != nil {
+		t.Fatalf("validating again: %v", err)
+	}
+	if step > last {
+		t.Fatalf("got step %d after %d, want the replay caught", step, last)
+	}
+}
+
+func TestValidateMalformed(t *testing.T) {
+	for _, code := range []string{"", "12345", "1234567"} {
+		if _, err := totp.Validate(rfcSecret, code, time.Now(), 1); !errors.Is(err, totp.ErrInvalidCode) {
+			t.Errorf("got error %v for %q, want %v", err, code, totp.ErrInvalidCode)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package trace implements the W3C trace context propagation and the spans
+// of the service. Spans are batched and handed to an Exporter.
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// These are the headers of the W3C trace context.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// maxTracestate is the length above which a tracestate is dropped.
+const maxTracestate = 512
+
+// flagSampled is the trace flag set when the trace is recorded.
+const flagSampled = 0x01
+
+// ErrTraceparent is used when a traceparent header can't be parsed.
+var ErrTraceparent = errors.New("trace: invalid traceparent")
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the lowercase hex encoding of the id.
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid reports if the id is not all zeros.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID identifies a span inside a trace.
+type SpanID [8]byte
+
+// String returns the lowercase hex encoding of the id.
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid reports if the id is not all zeros.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext is the part of a span propagated to the other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+	State   string
+}
+
+// Sampled reports if the trace is recorded.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// IsValid reports if both ids are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent returns the value of the traceparent header.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent parses a traceparent header value. Versions above 00 are
+// accepted as long as they start with the fields of version 00.
+func ParseTraceparent(value string) (SpanContext, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 {
+		return SpanContext{}, ErrTraceparent
+	}
+
+	version, err := hexByte(value[0:2])
+	if err != nil || version == 0xff {
+		return SpanContext{}, ErrTraceparent
+	}
+	switch {
+	case version == 0 && len(value) != 55:
+		return SpanContext{}, ErrTraceparent
+	case version > 0 && len(value) > 55 && value[55] != '-':
+		return SpanContext{}, ErrTraceparent
+	case value[2] != '-' || value[35] != '-' || value[52] != '-':
+		return SpanContext{}, ErrTraceparent
+	}
+
+	var sc SpanContext
+	if err := decodeLower(sc.TraceID[:], value[3:35]); err != nil {
+		return SpanContext{}, ErrTraceparent
+	}
+	if err := decodeLower(sc.SpanID[:], value[36:52]); err != nil {
+		return SpanContext{}, ErrTraceparent
+	}
+	if sc.Flags, err = hexByte(value[53:55]); err != nil {
+		return SpanContext{}, ErrTraceparent
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, ErrTraceparent
+	}
+
+	return sc, nil
+}
+
+// Extract reads the span context propagated in the request headers. The
+// tracestate is only kept along a valid traceparent.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	state := strings.Join(h.Values(TracestateHeader), ",")
+	if len(state) <= maxTracestate {
+		sc.State = state
+	}
+	return sc, true
+}
+
+// Inject writes the span context in the headers.
+func Inject(h http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.State != "" {
+		h.Set(TracestateHeader, sc.State)
+	}
+}
+
+// hexByte decodes two lowercase hex digits.
+func hexByte(s string) (byte, error) {
+	var b [1]byte
+	if err := decodeLower(b[:], s); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// decodeLower decodes lowercase hex, the only case allowed by the spec.
+func decodeLower(dst []byte, s string) error {
+	if strings.ToLower(s) != s {
+		return ErrTraceparent
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// newTraceID returns a random trace id.
+func newTraceID() TraceID {
+	var t TraceID
+	for !t.IsValid() {
+		rand.Read(t[:])
+	}
+	return t
+}
+
+// newSpanID returns a random span id.
+func newSpanID() SpanID {
+	var s SpanID
+	for !s.IsValid() {
+		rand.Read(s[:])
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File exports the spans as JSON lines appended to a file.
+type File struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFile opens the file for appending, creating it when missing.
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("trace: opening file: %w", err)
+	}
+	return &File{f: f}, nil
+}
+
+// Export appends the spans.
+func (fl *File) Export(ctx context.Context, spans []SpanData) error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	enc := json.NewEncoder(fl.f)
+	for _, s := range spans {
+		if err := enc.Encode(s); err != nil {
+			return fmt.Errorf("trace: writing span: %w", err)
+		}
+	}
+	return nil
+}
+
+// Shutdown closes the file.
+func (fl *File) Shutdown(ctx context.Context) error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	return fl.f.Close()
+}
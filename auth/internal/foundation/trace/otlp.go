@@ -0,0 +1,202 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OTLP exports the spans to an OpenTelemetry collector with OTLP/HTTP, using
+// the JSON encoding.
+type OTLP struct {
+	endpoint string
+	service  string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewOTLP constructs an exporter posting to the traces endpoint of a
+// collector, e.g. http://localhost:4318/v1/traces. The client must not
+// inject the trace context, the exports are not part of the traces.
+func NewOTLP(endpoint string, service string, headers map[string]string, client *http.Client) *OTLP {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OTLP{
+		endpoint: endpoint,
+		service:  service,
+		headers:  headers,
+		client:   client,
+	}
+}
+
+// Export posts the spans.
+func (o *OTLP) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(o.request(spans))
+	if err != nil {
+		return fmt.Errorf("trace: encoding spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("trace: exporting spans: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("trace: exporting spans: collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown does nothing, the client is owned by the caller.
+func (o *OTLP) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// These types are the subset of the OTLP JSON encoding used by the exporter.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// request maps the spans to the OTLP request.
+func (o *OTLP) request(spans []SpanData) otlpRequest {
+	out := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		out[i] = otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentID,
+			Name:              s.Name,
+			Kind:              otlpKind(s.Kind),
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+			Status:            otlpStatus{Code: otlpStatusCode(s.Status), Message: s.Message},
+		}
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpKeyValue{{Key: "service.name", Value: otlpValue{StringValue: o.service}}},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/mroobert/go-tickets/auth"},
+				Spans: out,
+			}},
+		}},
+	}
+}
+
+func otlpKind(kind string) int {
+	switch kind {
+	case KindInternal:
+		return 1
+	case KindServer:
+		return 2
+	case KindClient:
+		return 3
+	}
+	return 0
+}
+
+func otlpStatusCode(status string) int {
+	switch status {
+	case StatusOK:
+		return 1
+	case StatusError:
+		return 2
+	}
+	return 0
+}
+
+func otlpAttributes(attrs map[string]string) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, len(keys))
+	for i, k := range keys {
+		kvs[i] = otlpKeyValue{Key: k, Value: otlpValue{StringValue: attrs[k]}}
+	}
+	return kvs
+}
+
+// ParseHeaders parses the headers of the exporter in the form
+// "key1=value1,key2=value2", the format of OTEL_EXPORTER_OTLP_HEADERS.
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("trace: invalid header %q", pair)
+		}
+		headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return headers, nil
+}
@@ -0,0 +1,133 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// These are the kinds of span.
+const (
+	KindInternal = "internal"
+	KindServer   = "server"
+	KindClient   = "client"
+)
+
+// These are the status codes of a span.
+const (
+	StatusUnset = "unset"
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// SpanData is the snapshot of an ended span handed to the exporters.
+type SpanData struct {
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`
+	TraceID    string            `json:"traceId"`
+	SpanID     string            `json:"spanId"`
+	ParentID   string            `json:"parentSpanId,omitempty"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Status     string            `json:"status"`
+	Message    string            `json:"message,omitempty"`
+}
+
+// Span is an operation of a trace. A nil or unsampled span records nothing,
+// so the callers never check before using it.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the context propagated to the children of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttribute adds an attribute to the span.
+func (s *Span) SetAttribute(key string, value string) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]string)
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetStatus sets the status of the span.
+func (s *Span) SetStatus(code string, message string) {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Status = code
+	s.data.Message = message
+}
+
+// End ends the span and queues it for the export. Only the first call counts.
+func (s *Span) End() {
+	if !s.recording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now().UTC()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.enqueue(data)
+}
+
+func (s *Span) recording() bool {
+	return s != nil && s.tracer != nil && s.sc.Sampled()
+}
+
+// =============================================================================
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// key is how the span is stored/retrieved.
+const key ctxKey = 1
+
+// ContextWithSpan returns a copy of the context holding the span.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, key, s)
+}
+
+// FromContext returns the span of the context, nil when there is none.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(key).(*Span)
+	return s
+}
+
+// Start starts a child of the span in the context, with the tracer of that
+// span. Without a span in the context the returned span records nothing.
+func Start(ctx context.Context, name string, kind string) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil || parent.tracer == nil {
+		return ctx, nil
+	}
+
+	s := parent.tracer.newSpan(name, kind, parent.sc, true)
+	return ContextWithSpan(ctx, s), s
+}
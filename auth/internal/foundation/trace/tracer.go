@@ -0,0 +1,203 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// Exporter sends the ended spans to a backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Config holds the settings of a tracer.
+type Config struct {
+	Service       string
+	SampleRatio   float64
+	BatchSize     int
+	QueueSize     int
+	FlushInterval time.Duration
+	OnError       func(err error)
+}
+
+// Tracer starts the spans and exports them in batches. The spans are dropped
+// when the queue is full, tracing must never slow the requests down.
+type Tracer struct {
+	cfg      Config
+	exporter Exporter
+	queue    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTracer constructs a tracer exporting through the exporter and starts
+// the goroutine that exports the batches. A nil exporter still propagates
+// the trace context but records nothing.
+func NewTracer(cfg Config, exporter Exporter) *Tracer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 4 * cfg.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+
+	t := Tracer{
+		cfg:      cfg,
+		exporter: exporter,
+		queue:    make(chan SpanData, cfg.QueueSize),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if exporter != nil {
+		go t.run()
+	}
+	return &t
+}
+
+// StartRemote starts the root span of the service for a request. The
+// remote span context, if valid, becomes the parent and decides the
+// sampling. Otherwise a new trace is started.
+func (t *Tracer) StartRemote(ctx context.Context, name string, remote SpanContext) (context.Context, *Span) {
+	s := t.newSpan(name, KindServer, remote, remote.IsValid())
+	return ContextWithSpan(ctx, s), s
+}
+
+// newSpan creates a span, a child of the parent when it has one.
+func (t *Tracer) newSpan(name string, kind string, parent SpanContext, hasParent bool) *Span {
+	sc := SpanContext{SpanID: newSpanID()}
+	if hasParent {
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.State = parent.State
+	} else {
+		sc.TraceID = newTraceID()
+		if t.sample() {
+			sc.Flags = flagSampled
+		}
+	}
+	if t.exporter == nil {
+		sc.Flags &^= flagSampled
+	}
+
+	s := Span{
+		tracer: t,
+		sc:     sc,
+		data: SpanData{
+			Name:    name,
+			Kind:    kind,
+			TraceID: sc.TraceID.String(),
+			SpanID:  sc.SpanID.String(),
+			Start:   time.Now().UTC(),
+			Status:  StatusUnset,
+		},
+	}
+	if hasParent {
+		s.data.ParentID = parent.SpanID.String()
+	}
+	return &s
+}
+
+// sample decides if a new trace is recorded.
+func (t *Tracer) sample() bool {
+	switch {
+	case t.cfg.SampleRatio >= 1:
+		return true
+	case t.cfg.SampleRatio <= 0:
+		return false
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil {
+		return false
+	}
+	return float64(n.Int64()) < t.cfg.SampleRatio*math.MaxInt64
+}
+
+// enqueue queues the span for the export, dropping it if the queue is full.
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case <-t.done:
+	case t.queue <- data:
+	default:
+	}
+}
+
+// run exports the queued spans in batches.
+func (t *Tracer) run() {
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.cfg.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.cfg.FlushInterval)
+		defer cancel()
+
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			t.cfg.OnError(err)
+		}
+		batch = make([]SpanData, 0, t.cfg.BatchSize)
+	}
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.cfg.BatchSize {
+				export()
+			}
+
+		case <-ticker.C:
+			export()
+
+		case ack := <-t.flush:
+			for drained := false; !drained; {
+				select {
+				case data := <-t.queue:
+					batch = append(batch, data)
+				default:
+					drained = true
+				}
+			}
+			export()
+			close(ack)
+
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Shutdown exports the queued spans and shuts the exporter down.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+
+	ack := make(chan struct{})
+	select {
+	case t.flush <- ack:
+		select {
+		case <-ack:
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+	}
+
+	t.stopOnce.Do(func() { close(t.done) })
+	return t.exporter.Shutdown(ctx)
+}
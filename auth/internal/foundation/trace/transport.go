@@ -0,0 +1,45 @@
+package trace
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Transport is a http.RoundTripper creating a client span for every request
+// and propagating its context to the server.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := Start(r.Context(), "HTTP "+r.Method, KindClient)
+	if span == nil {
+		return base.RoundTrip(r)
+	}
+	defer span.End()
+
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.host", r.URL.Host)
+
+	// The request must not be modified, the headers are set on a clone.
+	r = r.Clone(ctx)
+	Inject(r.Header, span.SpanContext())
+
+	resp, err := base.RoundTrip(r)
+	if err != nil {
+		span.SetStatus(StatusError, err.Error())
+		return nil, err
+	}
+
+	span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(StatusError, resp.Status)
+	}
+	return resp, nil
+}
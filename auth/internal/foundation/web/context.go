@@ -12,11 +12,22 @@ type ctxKey int
 // key is how request values are stored/retrieved.
 const key ctxKey = 1
 
-// Values represent state for each request.
+// Values represent state for each request. TraceID is the W3C trace id of
+// the request. TenantID is empty when the request is not for a tenant.
 type Values struct {
 	TraceID    string
+	TenantID   string
 	Now        time.Time
 	StatusCode int
+	Route      Route
+	ClientIP   string
+	UserAgent  string
+}
+
+// SetValues stores the values of a request in the context, the mux does it
+// for the handlers it calls.
+func SetValues(ctx context.Context, v *Values) context.Context {
+	return context.WithValue(ctx, key, v)
 }
 
 // GetValues returns the values from the context.
@@ -32,7 +43,7 @@ func GetValues(ctx context.Context) (*Values, error) {
 func GetTraceID(ctx context.Context) string {
 	v, ok := ctx.Value(key).(*Values)
 	if !ok {
-		return "00000000-0000-0000-0000-000000000000"
+		return "00000000000000000000000000000000"
 	}
 	return v.TraceID
 }
@@ -47,6 +58,35 @@ func SetTraceId(ctx context.Context, traceId string) error {
 	return nil
 }
 
+// GetTenantID returns the tenant id from the context, empty without a tenant.
+func GetTenantID(ctx context.Context) string {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok {
+		return ""
+	}
+	return v.TenantID
+}
+
+// SetTenantID sets the tenant id into the context.
+func SetTenantID(ctx context.Context, tenantID string) error {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok {
+		return errors.New("web value missing from context")
+	}
+	v.TenantID = tenantID
+	return nil
+}
+
+// GetClientIP returns the ip address of the client from the context, empty
+// outside a request.
+func GetClientIP(ctx context.Context) string {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok {
+		return ""
+	}
+	return v.ClientIP
+}
+
 // SetStatusCode sets the status code back into the context.
 func SetStatusCode(ctx context.Context, statusCode int) error {
 	v, ok := ctx.Value(key).(*Values)
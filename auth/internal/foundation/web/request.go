@@ -2,7 +2,10 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
 )
 
 // Decode reads the body of an HTTP request looking for a JSON document. The
@@ -18,3 +21,71 @@ func Decode(r *http.Request, val interface{}) error {
 
 	return nil
 }
+
+// ClientIP returns the ip address of the peer of the connection, the client
+// itself when there is no proxy in front of the service.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// TrustedProxies holds the networks of the proxies in front of the service.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses the networks of the proxies in CIDR notation.
+func ParseTrustedProxies(cidrs []string) (TrustedProxies, error) {
+	tp := make(TrustedProxies, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(c))
+		if err != nil {
+			return nil, fmt.Errorf("parsing trusted proxy: %w", err)
+		}
+		tp = append(tp, n)
+	}
+	return tp, nil
+}
+
+// ClientIP returns the ip address of the client. The X-Forwarded-For header
+// is only honoured from the trusted proxies: its addresses are walked from
+// the connection back while they are trusted proxies, the first one that
+// isn't is the client. A client can't pass for another one by sending the
+// header itself, its value is left of the address added by the proxy.
+func (tp TrustedProxies) ClientIP(r *http.Request) string {
+	ip := ClientIP(r)
+	if !tp.trusted(ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !tp.trusted(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// trusted reports if the ip address is the one of a trusted proxy.
+func (tp TrustedProxies) trusted(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range tp {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
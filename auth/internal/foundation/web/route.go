@@ -0,0 +1,89 @@
+package web
+
+import "time"
+
+// Route describes a registered route. Middlewares can read it from the
+// request Values to adapt to the route they serve. The preflight requests
+// answered by the AppMux have no pattern.
+type Route struct {
+	Method  string
+	Pattern string
+
+	// CSRFExempt turns off the CSRF protection for the route.
+	CSRFExempt bool
+
+	// Priority decides which routes are shed first under load.
+	Priority Priority
+
+	// Timeout is the deadline of the handler, zero for none.
+	Timeout time.Duration
+}
+
+// Priority orders the routes when the service sheds load. The zero value
+// is PriorityNormal.
+type Priority int
+
+// These are the priorities of the routes, the low ones are shed first.
+const (
+	PriorityLow      Priority = -1
+	PriorityNormal   Priority = 0
+	PriorityCritical Priority = 1
+)
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityCritical:
+		return "critical"
+	}
+	return "normal"
+}
+
+// RouteOption configures a route registered with AppMux.Handle. Middlewares
+// are options too, they are wrapped around the handler in the given order.
+type RouteOption interface {
+	apply(rc *routeConfig)
+}
+
+// routeConfig collects the options of a route.
+type routeConfig struct {
+	route Route
+	mw    []Middleware
+}
+
+// apply adds the middleware to the route.
+func (mw Middleware) apply(rc *routeConfig) {
+	rc.mw = append(rc.mw, mw)
+}
+
+// optionFunc adapts a function to a RouteOption.
+type optionFunc func(rc *routeConfig)
+
+func (f optionFunc) apply(rc *routeConfig) {
+	f(rc)
+}
+
+// WithoutCSRF exempts the route from the CSRF protection. Only use it for
+// routes that authenticate the request by other means than the cookies.
+func WithoutCSRF() RouteOption {
+	return optionFunc(func(rc *routeConfig) {
+		rc.route.CSRFExempt = true
+	})
+}
+
+// WithPriority sets the priority of the route under load.
+func WithPriority(p Priority) RouteOption {
+	return optionFunc(func(rc *routeConfig) {
+		rc.route.Priority = p
+	})
+}
+
+// WithTimeout sets the timeout of the route, overriding the default one of
+// the AppMux. The handler and the route middlewares run with the deadline.
+func WithTimeout(d time.Duration) RouteOption {
+	return optionFunc(func(rc *routeConfig) {
+		rc.route.Timeout = d
+	})
+}
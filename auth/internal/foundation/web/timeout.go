@@ -0,0 +1,133 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned when a route runs past its timeout. It wraps
+// context.DeadlineExceeded.
+var ErrTimeout = fmt.Errorf("route timeout: %w", context.DeadlineExceeded)
+
+// Budget returns the time left before the deadline of the request, false
+// when it has none. Adapters use it to skip the calls that can't complete.
+func Budget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// timeout runs the handler with a deadline d from now. When the deadline
+// passes first ErrTimeout is returned right away and the writes the handler
+// still makes are dropped. The handler runs on its own copy of the request
+// values so the late ones don't race with the error response.
+func timeout(d time.Duration, handler Handler) Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		v, err := GetValues(ctx)
+		if err != nil {
+			return NewShutdownError("web value missing from context")
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+
+		hv := *v
+		hctx := context.WithValue(ctx, key, &hv)
+		tw := timeoutWriter{w: w, h: make(http.Header)}
+
+		// The panics of the handler are recovered here, the ones of its
+		// goroutine can't reach the middlewares.
+		done := make(chan error, 1)
+		go func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					done <- fmt.Errorf("PANIC [%v] TRACE[%s]", rec, string(debug.Stack()))
+				}
+			}()
+			done <- handler(hctx, &tw, r.WithContext(hctx))
+		}()
+
+		select {
+		case err := <-done:
+			v.StatusCode = hv.StatusCode
+			return err
+
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+
+			// The response has started, it can only be cut short.
+			if tw.wroteHeader {
+				v.StatusCode = tw.code
+				return nil
+			}
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrTimeout
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// timeoutWriter passes the response of a handler through until its route
+// times out, then drops it. The handler gets its own header map, copied to
+// the response when the header is written.
+type timeoutWriter struct {
+	w http.ResponseWriter
+	h http.Header
+
+	mu          sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+	code        int
+}
+
+// Header returns the header map of the handler.
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+// WriteHeader writes the header unless the route timed out.
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeader(code)
+}
+
+// Write writes the body unless the route timed out.
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) writeHeader(code int) {
+	dst := tw.w.Header()
+	for k, vv := range tw.h {
+		dst[k] = vv
+	}
+
+	tw.wroteHeader = true
+	tw.code = code
+	tw.w.WriteHeader(code)
+}
@@ -1,14 +1,34 @@
 package web
 
 import (
-	"errors"
+	"fmt"
 	"strings"
 )
 
-func ExtractToken(header string) (string, error) {
+// These are the authorization schemes understood by the service.
+const (
+	SchemeJWT    = "jwt"
+	SchemeBearer = "bearer"
+	SchemeAPIKey = "apikey"
+)
+
+// ExtractToken splits the authorization header into its scheme and token.
+// Only the provided schemes are accepted, compared case insensitively. The
+// returned scheme is in lower case.
+func ExtractToken(header string, schemes ...string) (string, string, error) {
 	parts := strings.Split(header, " ")
-	if len(parts) != 2 || strings.ToLower(parts[0]) != "jwt" {
-		return "", errors.New("expected authorization header format: jwt <token>")
+	if len(parts) == 2 && parts[1] != "" {
+		scheme := strings.ToLower(parts[0])
+		for _, s := range schemes {
+			if scheme == strings.ToLower(s) {
+				return scheme, parts[1], nil
+			}
+		}
+	}
+
+	formats := make([]string, len(schemes))
+	for i, s := range schemes {
+		formats[i] = s + " <token>"
 	}
-	return parts[1], nil
+	return "", "", fmt.Errorf("expected authorization header format: %s", strings.Join(formats, " or "))
 }
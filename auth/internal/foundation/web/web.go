@@ -5,11 +5,12 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/dimfeld/httptreemux/v5"
-	"github.com/google/uuid"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/trace"
 )
 
 // A Handler is a type that handles a http request within our mini
@@ -21,58 +22,149 @@ type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) e
 type AppMux struct {
 	*httptreemux.ContextMux
 	shutdown chan os.Signal
+	tracer   *trace.Tracer
 	mw       []Middleware
+	timeout  time.Duration
+	proxies  TrustedProxies
 }
 
-// NewAppMux creates an AppMux that manages a set of routes for the application
-func NewAppMux(shutdown chan os.Signal, mw ...Middleware) *AppMux {
-	return &AppMux{
+// NewAppMux creates an AppMux that manages a set of routes for the application.
+// The tracer starts a span for every request, a nil tracer only propagates
+// the trace context.
+func NewAppMux(shutdown chan os.Signal, tracer *trace.Tracer, mw ...Middleware) *AppMux {
+	if tracer == nil {
+		tracer = trace.NewTracer(trace.Config{}, nil)
+	}
+
+	a := AppMux{
 		ContextMux: httptreemux.NewContextMux(),
 		shutdown:   shutdown,
+		tracer:     tracer,
 		mw:         mw,
 	}
+
+	// httptreemux rejects the OPTIONS requests of the paths without an
+	// OPTIONS route, so the preflight requests of every registered path are
+	// routed through the application's general middleware to let it (e.g.
+	// CORS) respond to them.
+	preflight := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return Respond(ctx, w, nil, http.StatusNoContent)
+	}
+	preflight = wrapMiddleware(a.mw, preflight)
+
+	a.ContextMux.OptionsHandler = func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		a.serve(preflight, Route{Method: http.MethodOptions})(w, r)
+	}
+
+	return &a
 }
 
 // SignalShutdown is used to gracefully shut down the web app when an integrity
-// issue is identified.
+// issue is identified. It never blocks, a shutdown already requested or a
+// mux without a shutdown channel makes it a no-op.
 func (a *AppMux) SignalShutdown() {
-	a.shutdown <- syscall.SIGTERM
+	if a.shutdown == nil {
+		return
+	}
+
+	select {
+	case a.shutdown <- syscall.SIGTERM:
+	default:
+	}
+}
+
+// SetTimeout sets the default timeout of the routes registered afterwards,
+// zero for none. It can be overridden by route with WithTimeout.
+func (a *AppMux) SetTimeout(d time.Duration) {
+	a.timeout = d
+}
+
+// SetTrustedProxies sets the proxies the client ip address is forwarded by,
+// none by default.
+func (a *AppMux) SetTrustedProxies(tp TrustedProxies) {
+	a.proxies = tp
 }
 
 // Handle sets a handler function for a given HTTP method and path pair
-// to the server mux.
-func (a *AppMux) Handle(method string, group string, path string, handler Handler, mw ...Middleware) {
+// to the server mux. The options are route specific middlewares and settings.
+func (a *AppMux) Handle(method string, group string, path string, handler Handler, opts ...RouteOption) {
+	finalPath := path
+	if group != "" {
+		finalPath = "/" + group + path
+	}
+
+	rc := routeConfig{
+		route: Route{
+			Method:  method,
+			Pattern: finalPath,
+			Timeout: a.timeout,
+		},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(&rc)
+		}
+	}
 
 	// First wrap handler specific middleware around this handler.
-	handler = wrapMiddleware(mw, handler)
+	handler = wrapMiddleware(rc.mw, handler)
+
+	// The deadline covers the route, the errors it causes go through the
+	// application's general middleware.
+	if rc.route.Timeout > 0 {
+		handler = timeout(rc.route.Timeout, handler)
+	}
 
 	// Add the application's general middleware to the handler chain.
 	handler = wrapMiddleware(a.mw, handler)
 
-	h := func(w http.ResponseWriter, r *http.Request) {
+	a.ContextMux.Handle(method, finalPath, a.serve(handler, rc.route))
+}
+
+// serve adapts the handler to the standard library, setting the request
+// values of the route in the context. The request joins the trace of the
+// caller, if any, and the response carries the context of its span.
+func (a *AppMux) serve(handler Handler, route Route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		// Pull the context from the request and
 		// use it as a separate parameter.
 		ctx := r.Context()
 
+		remote, _ := trace.Extract(r.Header)
+		name := route.Method + " " + route.Pattern
+		if route.Pattern == "" {
+			name = route.Method
+		}
+		ctx, span := a.tracer.StartRemote(ctx, name, remote)
+		trace.Inject(w.Header(), span.SpanContext())
+
 		// Set the context with the required values to
 		// process the request.
 		v := Values{
-			TraceID: uuid.New().String(),
-			Now:     time.Now().UTC(),
+			TraceID:   span.SpanContext().TraceID.String(),
+			Now:       time.Now().UTC(),
+			Route:     route,
+			ClientIP:  a.proxies.ClientIP(r),
+			UserAgent: r.UserAgent(),
 		}
-		ctx = context.WithValue(ctx, key, &v)
+		ctx = SetValues(ctx, &v)
 
 		// Call the wrapped handler functions.
-		if err := handler(ctx, w, r); err != nil {
+		err := handler(ctx, w, r)
+
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", route.Pattern)
+		span.SetAttribute("http.status_code", strconv.Itoa(v.StatusCode))
+		if v.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(trace.StatusError, http.StatusText(v.StatusCode))
+		}
+		span.End()
+
+		// Only the integrity errors stop the service, the others are what
+		// the middlewares couldn't deliver to the client.
+		if IsShutdown(err) {
 			a.SignalShutdown()
 			return
 		}
 	}
-
-	finalPath := path
-	if group != "" {
-		finalPath = "/" + group + path
-	}
-
-	a.ContextMux.Handle(method, finalPath, h)
 }
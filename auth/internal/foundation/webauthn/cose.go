@@ -0,0 +1,138 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/cbor"
+)
+
+// These are the COSE algorithms supported for credentials.
+const (
+	AlgES256 int64 = -7
+	AlgRS256 int64 = -257
+)
+
+// These are the COSE key parameters (RFC 8152 section 7 and 13).
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1 // EC2 curve, RSA modulus n
+	coseX   = -2 // EC2 x coordinate, RSA exponent e
+	coseY   = -3 // EC2 y coordinate
+
+	ktyEC2     = 2
+	ktyRSA     = 3
+	crvP256    = 1
+	minRSABits = 2048
+)
+
+// PublicKey is a credential public key with its COSE algorithm.
+type PublicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey parses a COSE encoded public key.
+func ParsePublicKey(cose []byte) (PublicKey, error) {
+	v, rest, err := cbor.Decode(cose)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("decoding cose key: %w", err)
+	}
+	if len(rest) != 0 {
+		return PublicKey{}, errors.New("trailing data after cose key")
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return PublicKey{}, errors.New("cose key must be a map")
+	}
+	return publicKeyFromMap(m)
+}
+
+func publicKeyFromMap(m map[interface{}]interface{}) (PublicKey, error) {
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return PublicKey{}, errors.New("invalid ES256 cose key")
+		}
+
+		pk := ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pk.Curve.IsOnCurve(pk.X, pk.Y) {
+			return PublicKey{}, errors.New("ES256 point is not on the curve")
+		}
+		return PublicKey{Algorithm: alg, Key: &pk}, nil
+
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseCrv)].([]byte)
+		e, _ := m[int64(coseX)].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return PublicKey{}, errors.New("invalid RS256 cose key")
+		}
+
+		exp := 0
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		pk := rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: exp,
+		}
+		if pk.N.BitLen() < minRSABits {
+			return PublicKey{}, fmt.Errorf("RS256 key must have at least %d bits", minRSABits)
+		}
+		return PublicKey{Algorithm: alg, Key: &pk}, nil
+	}
+
+	return PublicKey{}, fmt.Errorf("unsupported cose key kty[%d] alg[%d]", kty, alg)
+}
+
+// Verify checks the signature of the data with the public key.
+func (k PublicKey) Verify(data []byte, sig []byte) error {
+	return verify(k.Algorithm, k.Key, data, sig)
+}
+
+// verify checks the signature of the data for the given algorithm and key.
+func verify(alg int64, key crypto.PublicKey, data []byte, sig []byte) error {
+	digest := sha256.Sum256(data)
+
+	switch alg {
+	case AlgES256:
+		pk, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("ES256 requires an ecdsa key")
+		}
+		if !ecdsa.VerifyASN1(pk, digest[:], sig) {
+			return errors.New("invalid ES256 signature")
+		}
+		return nil
+
+	case AlgRS256:
+		pk, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 requires a rsa key")
+		}
+		if err := rsa.VerifyPKCS1v15(pk, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("invalid RS256 signature: %w", err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported algorithm %d", alg)
+}
@@ -0,0 +1,351 @@
+// Package webauthn verifies the registration and assertion ceremonies of the
+// Web Authentication specification (https://www.w3.org/TR/webauthn-2/) for
+// ES256 and RS256 credentials with "none" or "packed" attestation.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/cbor"
+)
+
+// These are the flags of the authenticator data.
+const (
+	FlagUserPresent            byte = 0x01
+	FlagUserVerified           byte = 0x04
+	FlagAttestedCredentialData byte = 0x40
+	FlagExtensionData          byte = 0x80
+)
+
+// These are the types of the client data for each ceremony.
+const (
+	TypeCreate = "webauthn.create"
+	TypeGet    = "webauthn.get"
+)
+
+// challengeSize is the size in bytes of a generated challenge.
+const challengeSize = 32
+
+// ErrSignCount is returned when the sign counter did not increase, which
+// signals a possibly cloned authenticator.
+var ErrSignCount = errors.New("webauthn: sign count did not increase")
+
+// Config holds the relying party settings used to verify the ceremonies.
+type Config struct {
+	RPID                    string
+	Origins                 []string
+	RequireUserVerification bool
+}
+
+// NewChallenge generates a new random challenge.
+func NewChallenge() ([]byte, error) {
+	b := make([]byte, challengeSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generating challenge: %w", err)
+	}
+	return b, nil
+}
+
+// ClientData is the data collected by the client for a ceremony.
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// ParseClientData parses the clientDataJSON of a ceremony.
+func ParseClientData(raw []byte) (ClientData, error) {
+	var cd ClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return ClientData{}, fmt.Errorf("decoding client data: %w", err)
+	}
+	return cd, nil
+}
+
+// ChallengeBytes returns the decoded challenge of the client data.
+func (cd ClientData) ChallengeBytes() ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil {
+		return nil, fmt.Errorf("decoding challenge: %w", err)
+	}
+	return b, nil
+}
+
+// AttestedCredential is the credential data created during registration.
+type AttestedCredential struct {
+	AAGUID []byte
+	ID     []byte
+	// PublicKey is the COSE encoded credential public key.
+	PublicKey []byte
+}
+
+// AuthenticatorData is the data signed by the authenticator.
+type AuthenticatorData struct {
+	RPIDHash   []byte
+	Flags      byte
+	SignCount  uint32
+	Credential *AttestedCredential
+}
+
+// ParseAuthenticatorData parses the binary authenticator data.
+func ParseAuthenticatorData(raw []byte) (AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return AuthenticatorData{}, errors.New("authenticator data too short")
+	}
+
+	ad := AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[37:]
+
+	if ad.Flags&FlagAttestedCredentialData != 0 {
+		if len(rest) < 18 {
+			return AuthenticatorData{}, errors.New("attested credential data too short")
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		if len(rest) < 18+idLen {
+			return AuthenticatorData{}, errors.New("credential id too short")
+		}
+
+		cred := AttestedCredential{
+			AAGUID: rest[:16],
+			ID:     rest[18 : 18+idLen],
+		}
+		keyStart := rest[18+idLen:]
+
+		// The public key is followed by the extensions, so the decoder
+		// tells where the key ends.
+		_, after, err := cbor.Decode(keyStart)
+		if err != nil {
+			return AuthenticatorData{}, fmt.Errorf("decoding credential public key: %w", err)
+		}
+		cred.PublicKey = keyStart[:len(keyStart)-len(after)]
+		ad.Credential = &cred
+		rest = after
+	}
+
+	if ad.Flags&FlagExtensionData != 0 {
+		_, after, err := cbor.Decode(rest)
+		if err != nil {
+			return AuthenticatorData{}, fmt.Errorf("decoding extensions: %w", err)
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return AuthenticatorData{}, errors.New("trailing data after authenticator data")
+	}
+
+	return ad, nil
+}
+
+// Registration is the result of a verified registration ceremony.
+type Registration struct {
+	CredentialID []byte
+	PublicKey    []byte
+	Algorithm    int64
+	SignCount    uint32
+	Format       string
+	UserVerified bool
+}
+
+// VerifyRegistration verifies the response of a registration ceremony
+// created with the given challenge.
+func (c Config) VerifyRegistration(challenge []byte, clientDataJSON []byte, attestationObject []byte) (Registration, error) {
+	if err := c.verifyClientData(TypeCreate, challenge, clientDataJSON); err != nil {
+		return Registration{}, err
+	}
+
+	v, rest, err := cbor.Decode(attestationObject)
+	if err != nil {
+		return Registration{}, fmt.Errorf("decoding attestation object: %w", err)
+	}
+	if len(rest) != 0 {
+		return Registration{}, errors.New("trailing data after attestation object")
+	}
+	obj, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return Registration{}, errors.New("attestation object must be a map")
+	}
+	format, _ := obj["fmt"].(string)
+	attStmt, _ := obj["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := obj["authData"].([]byte)
+	if attStmt == nil || rawAuthData == nil {
+		return Registration{}, errors.New("attestation object is missing fields")
+	}
+
+	ad, err := c.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Registration{}, err
+	}
+	if ad.Credential == nil {
+		return Registration{}, errors.New("attested credential data missing")
+	}
+
+	key, err := ParsePublicKey(ad.Credential.PublicKey)
+	if err != nil {
+		return Registration{}, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	if err := verifyAttestation(format, attStmt, key, signed); err != nil {
+		return Registration{}, err
+	}
+
+	return Registration{
+		CredentialID: append([]byte(nil), ad.Credential.ID...),
+		PublicKey:    append([]byte(nil), ad.Credential.PublicKey...),
+		Algorithm:    key.Algorithm,
+		SignCount:    ad.SignCount,
+		Format:       format,
+		UserVerified: ad.Flags&FlagUserVerified != 0,
+	}, nil
+}
+
+// Assertion is the result of a verified assertion ceremony.
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+// VerifyAssertion verifies the response of an assertion ceremony created with
+// the given challenge, for a credential with the given COSE public key and
+// last known sign count.
+func (c Config) VerifyAssertion(challenge []byte, publicKey []byte, signCount uint32, clientDataJSON []byte, authenticatorData []byte, signature []byte) (Assertion, error) {
+	if err := c.verifyClientData(TypeGet, challenge, clientDataJSON); err != nil {
+		return Assertion{}, err
+	}
+
+	ad, err := c.verifyAuthenticatorData(authenticatorData)
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authenticatorData...), clientDataHash[:]...)
+	if err := key.Verify(signed, signature); err != nil {
+		return Assertion{}, fmt.Errorf("webauthn: %w", err)
+	}
+
+	// Authenticators without a counter always report zero.
+	if (ad.SignCount != 0 || signCount != 0) && ad.SignCount <= signCount {
+		return Assertion{}, ErrSignCount
+	}
+
+	return Assertion{
+		SignCount:    ad.SignCount,
+		UserVerified: ad.Flags&FlagUserVerified != 0,
+	}, nil
+}
+
+// verifyClientData checks the type, challenge and origin of the client data.
+func (c Config) verifyClientData(typ string, challenge []byte, clientDataJSON []byte) error {
+	cd, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+	if cd.Type != typ {
+		return fmt.Errorf("webauthn: unexpected client data type %q", cd.Type)
+	}
+
+	got, err := cd.ChallengeBytes()
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return errors.New("webauthn: challenge mismatch")
+	}
+
+	for _, o := range c.Origins {
+		if cd.Origin == o {
+			return nil
+		}
+	}
+	return fmt.Errorf("webauthn: origin %q not allowed", cd.Origin)
+}
+
+// verifyAuthenticatorData checks the relying party and the flags of the authenticator data.
+func (c Config) verifyAuthenticatorData(raw []byte) (AuthenticatorData, error) {
+	ad, err := ParseAuthenticatorData(raw)
+	if err != nil {
+		return AuthenticatorData{}, fmt.Errorf("webauthn: %w", err)
+	}
+
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(ad.RPIDHash, rpIDHash[:]) {
+		return AuthenticatorData{}, errors.New("webauthn: relying party id mismatch")
+	}
+	if ad.Flags&FlagUserPresent == 0 {
+		return AuthenticatorData{}, errors.New("webauthn: user not present")
+	}
+	if c.RequireUserVerification && ad.Flags&FlagUserVerified == 0 {
+		return AuthenticatorData{}, errors.New("webauthn: user not verified")
+	}
+
+	return ad, nil
+}
+
+// verifyAttestation checks the attestation statement for the supported formats.
+// Attestation certificates are not validated against trust anchors, the
+// relying party does not restrict which authenticators can be used.
+func verifyAttestation(format string, attStmt map[interface{}]interface{}, key PublicKey, signed []byte) error {
+	switch format {
+	case "none":
+		if len(attStmt) != 0 {
+			return errors.New("webauthn: none attestation must have an empty statement")
+		}
+		return nil
+
+	case "packed":
+		alg, _ := attStmt["alg"].(int64)
+		sig, _ := attStmt["sig"].([]byte)
+		if sig == nil {
+			return errors.New("webauthn: packed attestation signature missing")
+		}
+
+		x5c, ok := attStmt["x5c"].([]interface{})
+		if !ok {
+			// Self attestation is signed with the credential key.
+			if alg != key.Algorithm {
+				return errors.New("webauthn: packed attestation algorithm mismatch")
+			}
+			if err := key.Verify(signed, sig); err != nil {
+				return fmt.Errorf("webauthn: packed self attestation: %w", err)
+			}
+			return nil
+		}
+
+		if len(x5c) == 0 {
+			return errors.New("webauthn: packed attestation certificate missing")
+		}
+		der, _ := x5c[0].([]byte)
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("webauthn: parsing attestation certificate: %w", err)
+		}
+		if err := verify(alg, cert.PublicKey, signed, sig); err != nil {
+			return fmt.Errorf("webauthn: packed attestation: %w", err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("webauthn: unsupported attestation format %q", format)
+}
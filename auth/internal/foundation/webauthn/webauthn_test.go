@@ -0,0 +1,275 @@
+package webauthn_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/webauthn"
+	"github.com/mroobert/go-tickets/auth/internal/testsupport/softauthn"
+)
+
+const (
+	rpID   = "localhost"
+	origin = "http://localhost:3000"
+)
+
+var cfg = webauthn.Config{
+	RPID:    rpID,
+	Origins: []string{origin},
+}
+
+func newAuthenticator(t *testing.T) *softauthn.Authenticator {
+	t.Helper()
+
+	a, err := softauthn.New(rpID, origin, []byte("uid-1"))
+	if err != nil {
+		t.Fatalf("creating authenticator: %v", err)
+	}
+	return a
+}
+
+func newChallenge(t *testing.T) []byte {
+	t.Helper()
+
+	c, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatalf("creating challenge: %v", err)
+	}
+	return c
+}
+
+// register registers the credential of the authenticator.
+func register(t *testing.T, a *softauthn.Authenticator) webauthn.Registration {
+	t.Helper()
+
+	challenge := newChallenge(t)
+	clientDataJSON, attestationObject, err := a.Register(challenge)
+	if err != nil {
+		t.Fatalf("answering registration: %v", err)
+	}
+
+	reg, err := cfg.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		t.Fatalf("verifying registration: %v", err)
+	}
+	return reg
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	for _, format := range []string{softauthn.FormatNone, softauthn.FormatPacked} {
+		t.Run(format, func(t *testing.T) {
+			a := newAuthenticator(t)
+			a.Format = format
+			a.UserVerified = true
+
+			reg := register(t, a)
+
+			if string(reg.CredentialID) != string(a.CredentialID()) {
+				t.Errorf("got credential id %x, want %x", reg.CredentialID, a.CredentialID())
+			}
+			if reg.Algorithm != webauthn.AlgES256 {
+				t.Errorf("got algorithm %d, want %d", reg.Algorithm, webauthn.AlgES256)
+			}
+			if reg.Format != format {
+				t.Errorf("got format %q, want %q", reg.Format, format)
+			}
+			if !reg.UserVerified {
+				t.Error("got user not verified, want verified")
+			}
+			if _, err := webauthn.ParsePublicKey(reg.PublicKey); err != nil {
+				t.Errorf("parsing public key: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(a *softauthn.Authenticator, challenge []byte) []byte
+		cfg    webauthn.Config
+		want   string
+	}{
+		{
+			name: "bad origin",
+			change: func(a *softauthn.Authenticator, challenge []byte) []byte {
+				a.Origin = "https://evil.example.com"
+				return challenge
+			},
+			want: "origin",
+		},
+		{
+			name: "other relying party",
+			change: func(a *softauthn.Authenticator, challenge []byte) []byte {
+				a.RPID = "evil.example.com"
+				return challenge
+			},
+			want: "relying party id mismatch",
+		},
+		{
+			name: "other challenge",
+			change: func(a *softauthn.Authenticator, challenge []byte) []byte {
+				other := append([]byte(nil), challenge...)
+				other[0] ^= 0xff
+				return other
+			},
+			want: "challenge mismatch",
+		},
+		{
+			name: "user not verified",
+			change: func(a *softauthn.Authenticator, challenge []byte) []byte {
+				return challenge
+			},
+			cfg: webauthn.Config{
+				RPID:                    rpID,
+				Origins:                 []string{origin},
+				RequireUserVerification: true,
+			},
+			want: "user not verified",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cfg
+			if tt.cfg.RPID != "" {
+				c = tt.cfg
+			}
+
+			a := newAuthenticator(t)
+			challenge := newChallenge(t)
+			clientDataJSON, attestationObject, err := a.Register(tt.change(a, challenge))
+			if err != nil {
+				t.Fatalf("answering registration: %v", err)
+			}
+
+			_, err = c.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationRejectsAssertion(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge := newChallenge(t)
+
+	// The client data of an assertion can't complete a registration.
+	resp, err := a.Assert(challenge)
+	if err != nil {
+		t.Fatalf("answering assertion: %v", err)
+	}
+	_, attestationObject, err := a.Register(challenge)
+	if err != nil {
+		t.Fatalf("answering registration: %v", err)
+	}
+
+	_, err = cfg.VerifyRegistration(challenge, resp.ClientDataJSON, attestationObject)
+	if err == nil || !strings.Contains(err.Error(), "client data type") {
+		t.Fatalf("got error %v, want a client data type error", err)
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	a := newAuthenticator(t)
+	reg := register(t, a)
+
+	signCount := reg.SignCount
+	for i := 0; i < 3; i++ {
+		challenge := newChallenge(t)
+		resp, err := a.Assert(challenge)
+		if err != nil {
+			t.Fatalf("answering assertion: %v", err)
+		}
+
+		res, err := cfg.VerifyAssertion(challenge, reg.PublicKey, signCount, resp.ClientDataJSON, resp.AuthenticatorData, resp.Signature)
+		if err != nil {
+			t.Fatalf("verifying assertion %d: %v", i, err)
+		}
+		if res.SignCount <= signCount {
+			t.Fatalf("got sign count %d, want more than %d", res.SignCount, signCount)
+		}
+		signCount = res.SignCount
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	a := newAuthenticator(t)
+	reg := register(t, a)
+
+	t.Run("bad origin", func(t *testing.T) {
+		a.Origin = "https://evil.example.com"
+		defer func() { a.Origin = origin }()
+
+		challenge := newChallenge(t)
+		resp, err := a.Assert(challenge)
+		if err != nil {
+			t.Fatalf("answering assertion: %v", err)
+		}
+
+		_, err = cfg.VerifyAssertion(challenge, reg.PublicKey, 0, resp.ClientDataJSON, resp.AuthenticatorData, resp.Signature)
+		if err == nil || !strings.Contains(err.Error(), "origin") {
+			t.Fatalf("got error %v, want an origin error", err)
+		}
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		challenge := newChallenge(t)
+		resp, err := a.Assert(challenge)
+		if err != nil {
+			t.Fatalf("answering assertion: %v", err)
+		}
+		resp.AuthenticatorData[len(resp.AuthenticatorData)-1] ^= 0xff
+
+		_, err = cfg.VerifyAssertion(challenge, reg.PublicKey, 0, resp.ClientDataJSON, resp.AuthenticatorData, resp.Signature)
+		if err == nil || !strings.Contains(err.Error(), "signature") {
+			t.Fatalf("got error %v, want a signature error", err)
+		}
+	})
+
+	t.Run("other credential", func(t *testing.T) {
+		other := newAuthenticator(t)
+		challenge := newChallenge(t)
+		resp, err := other.Assert(challenge)
+		if err != nil {
+			t.Fatalf("answering assertion: %v", err)
+		}
+
+		_, err = cfg.VerifyAssertion(challenge, reg.PublicKey, 0, resp.ClientDataJSON, resp.AuthenticatorData, resp.Signature)
+		if err == nil || !strings.Contains(err.Error(), "signature") {
+			t.Fatalf("got error %v, want a signature error", err)
+		}
+	})
+
+	t.Run("sign count regression", func(t *testing.T) {
+		// A clone of the authenticator answers with an older counter.
+		a.SignCount = 10
+		stored := uint32(20)
+
+		challenge := newChallenge(t)
+		resp, err := a.Assert(challenge)
+		if err != nil {
+			t.Fatalf("answering assertion: %v", err)
+		}
+
+		_, err = cfg.VerifyAssertion(challenge, reg.PublicKey, stored, resp.ClientDataJSON, resp.AuthenticatorData, resp.Signature)
+		if !errors.Is(err, webauthn.ErrSignCount) {
+			t.Fatalf("got error %v, want %v", err, webauthn.ErrSignCount)
+		}
+	})
+
+	t.Run("sign count repeated", func(t *testing.T) {
+		challenge := newChallenge(t)
+		resp, err := a.Assert(challenge)
+		if err != nil {
+			t.Fatalf("answering assertion: %v", err)
+		}
+
+		_, err = cfg.VerifyAssertion(challenge, reg.PublicKey, a.SignCount, resp.ClientDataJSON, resp.AuthenticatorData, resp.Signature)
+		if !errors.Is(err, webauthn.ErrSignCount) {
+			t.Fatalf("got error %v, want %v", err, webauthn.ErrSignCount)
+		}
+	})
+}
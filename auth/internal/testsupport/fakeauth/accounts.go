@@ -0,0 +1,550 @@
+package fakeauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// minPasswordLength is the minimum length of the passwords, like firebase.
+const minPasswordLength = 6
+
+// account is a stored user.
+type account struct {
+	uid              string
+	tenantID         string
+	email            string
+	displayName      string
+	photoURL         string
+	phoneNumber      string
+	emailVerified    bool
+	disabled         bool
+	salt             []byte
+	hash             []byte
+	customAttributes string
+	validSince       int64
+	createdAt        int64
+	lastLoginAt      int64
+}
+
+// setPassword replaces the password of the account.
+func (a *account) setPassword(password string) error {
+	if len(password) < minPasswordLength {
+		return newAPIError(http.StatusBadRequest, "WEAK_PASSWORD", "Password should be at least 6 characters")
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("generating salt: %w", err)
+	}
+	a.salt = salt
+	a.hash = hashPassword(password, salt)
+	return nil
+}
+
+// checkPassword reports if the password is the one of the account.
+func (a *account) checkPassword(password string) bool {
+	if a.hash == nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(hashPassword(password, a.salt), a.hash) == 1
+}
+
+// hashPassword hashes the salted password. The fake holds test passwords
+// only, a single round does.
+func hashPassword(password string, salt []byte) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(password))
+	return h.Sum(nil)
+}
+
+// userKey returns the key of the user within the tenant.
+func userKey(tenantID string, uid string) string {
+	return tenantID + "/" + uid
+}
+
+// findByEmail returns the account of the email in the tenant, the lock must
+// be held.
+func (s *Server) findByEmail(tenantID string, email string) *account {
+	for _, a := range s.users {
+		if a.tenantID == tenantID && a.email != "" && strings.EqualFold(a.email, email) {
+			return a
+		}
+	}
+	return nil
+}
+
+// findByPhone returns the account of the phone number in the tenant, the
+// lock must be held.
+func (s *Server) findByPhone(tenantID string, phone string) *account {
+	for _, a := range s.users {
+		if a.tenantID == tenantID && a.phoneNumber != "" && a.phoneNumber == phone {
+			return a
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+
+// createRequest is the body of POST /accounts.
+type createRequest struct {
+	LocalID       string `json:"localId"`
+	Email         string `json:"email"`
+	Password      string `json:"password"`
+	DisplayName   string `json:"displayName"`
+	PhotoURL      string `json:"photoUrl"`
+	PhoneNumber   string `json:"phoneNumber"`
+	EmailVerified bool   `json:"emailVerified"`
+	Disabled      bool   `json:"disabled"`
+}
+
+// createUser adds a user to the tenant.
+func (s *Server) createUser(tenantID string, body []byte) (interface{}, error) {
+	var req createRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+
+	a := account{
+		uid:           req.LocalID,
+		tenantID:      tenantID,
+		email:         strings.ToLower(req.Email),
+		displayName:   req.DisplayName,
+		photoURL:      req.PhotoURL,
+		phoneNumber:   req.PhoneNumber,
+		emailVerified: req.EmailVerified,
+		disabled:      req.Disabled,
+		createdAt:     nowMillis(),
+	}
+	if req.Password != "" {
+		if err := a.setPassword(req.Password); err != nil {
+			return nil, err
+		}
+	}
+	if a.uid == "" {
+		uid, err := newID(21)
+		if err != nil {
+			return nil, err
+		}
+		a.uid = uid
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[userKey(tenantID, a.uid)]; exists {
+		return nil, newAPIError(http.StatusBadRequest, "DUPLICATE_LOCAL_ID")
+	}
+	if err := s.checkClash(nil, &a); err != nil {
+		return nil, err
+	}
+	s.users[userKey(tenantID, a.uid)] = &a
+
+	resp := struct {
+		Kind    string `json:"kind"`
+		LocalID string `json:"localId"`
+	}{
+		Kind:    "identitytoolkit#SignupNewUserResponse",
+		LocalID: a.uid,
+	}
+	return resp, nil
+}
+
+// =============================================================================
+
+// lookupRequest is the body of POST /accounts:lookup.
+type lookupRequest struct {
+	LocalID     []string `json:"localId"`
+	Email       []string `json:"email"`
+	PhoneNumber []string `json:"phoneNumber"`
+}
+
+// providerInfo is a provider linked to a user.
+type providerInfo struct {
+	ProviderID  string `json:"providerId"`
+	RawID       string `json:"rawId"`
+	Email       string `json:"email,omitempty"`
+	PhoneNumber string `json:"phoneNumber,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	PhotoURL    string `json:"photoUrl,omitempty"`
+}
+
+// userResponse is a user as the api returns it.
+type userResponse struct {
+	LocalID          string         `json:"localId"`
+	Email            string         `json:"email,omitempty"`
+	DisplayName      string         `json:"displayName,omitempty"`
+	PhotoURL         string         `json:"photoUrl,omitempty"`
+	PhoneNumber      string         `json:"phoneNumber,omitempty"`
+	EmailVerified    bool           `json:"emailVerified,omitempty"`
+	Disabled         bool           `json:"disabled,omitempty"`
+	CustomAttributes string         `json:"customAttributes,omitempty"`
+	ProviderUserInfo []providerInfo `json:"providerUserInfo,omitempty"`
+	ValidSince       int64          `json:"validSince,string,omitempty"`
+	CreatedAt        int64          `json:"createdAt,string,omitempty"`
+	LastLoginAt      int64          `json:"lastLoginAt,string,omitempty"`
+	TenantID         string         `json:"tenantId,omitempty"`
+}
+
+// toUserResponse returns the user of the account.
+func toUserResponse(a *account) userResponse {
+	u := userResponse{
+		LocalID:          a.uid,
+		Email:            a.email,
+		DisplayName:      a.displayName,
+		PhotoURL:         a.photoURL,
+		PhoneNumber:      a.phoneNumber,
+		EmailVerified:    a.emailVerified,
+		Disabled:         a.disabled,
+		CustomAttributes: a.customAttributes,
+		ValidSince:       a.validSince,
+		CreatedAt:        a.createdAt,
+		LastLoginAt:      a.lastLoginAt,
+		TenantID:         a.tenantID,
+	}
+
+	if a.email != "" && a.hash != nil {
+		u.ProviderUserInfo = append(u.ProviderUserInfo, providerInfo{
+			ProviderID:  "password",
+			RawID:       a.email,
+			Email:       a.email,
+			DisplayName: a.displayName,
+			PhotoURL:    a.photoURL,
+		})
+	}
+	if a.phoneNumber != "" {
+		u.ProviderUserInfo = append(u.ProviderUserInfo, providerInfo{
+			ProviderID:  "phone",
+			RawID:       a.phoneNumber,
+			PhoneNumber: a.phoneNumber,
+		})
+	}
+
+	return u
+}
+
+// lookupUsers returns the users of the tenant matching any of the uids,
+// emails or phone numbers.
+func (s *Server) lookupUsers(tenantID string, body []byte) (interface{}, error) {
+	var req lookupRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var users []userResponse
+	seen := make(map[*account]bool)
+	add := func(a *account) {
+		if a != nil && !seen[a] {
+			seen[a] = true
+			users = append(users, toUserResponse(a))
+		}
+	}
+
+	for _, uid := range req.LocalID {
+		add(s.users[userKey(tenantID, uid)])
+	}
+	for _, email := range req.Email {
+		add(s.findByEmail(tenantID, email))
+	}
+	for _, phone := range req.PhoneNumber {
+		add(s.findByPhone(tenantID, phone))
+	}
+
+	resp := struct {
+		Kind  string         `json:"kind"`
+		Users []userResponse `json:"users,omitempty"`
+	}{
+		Kind:  "identitytoolkit#GetAccountInfoResponse",
+		Users: users,
+	}
+	return resp, nil
+}
+
+// =============================================================================
+
+// updateRequest is the body of POST /accounts:update. The fields left out
+// are kept.
+type updateRequest struct {
+	LocalID          string       `json:"localId"`
+	Email            *string      `json:"email"`
+	Password         *string      `json:"password"`
+	DisplayName      *string      `json:"displayName"`
+	PhotoURL         *string      `json:"photoUrl"`
+	PhoneNumber      *string      `json:"phoneNumber"`
+	EmailVerified    *bool        `json:"emailVerified"`
+	DisableUser      *bool        `json:"disableUser"`
+	CustomAttributes *string      `json:"customAttributes"`
+	ValidSince       *json.Number `json:"validSince"`
+	DeleteAttribute  []string     `json:"deleteAttribute"`
+	DeleteProvider   []string     `json:"deleteProvider"`
+}
+
+// updateUser updates the user of the tenant. Like firebase, a new password
+// revokes the sessions of the user.
+func (s *Server) updateUser(tenantID string, body []byte) (interface{}, error) {
+	var req updateRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.users[userKey(tenantID, req.LocalID)]
+	if !exists {
+		return nil, newAPIError(http.StatusBadRequest, "USER_NOT_FOUND")
+	}
+
+	// The changes are made on a copy, a failed update leaves the user as is.
+	a := *current
+
+	if req.Email != nil {
+		a.email = strings.ToLower(*req.Email)
+	}
+	if req.Password != nil {
+		if err := a.setPassword(*req.Password); err != nil {
+			return nil, err
+		}
+		a.validSince = time.Now().Unix()
+	}
+	if req.DisplayName != nil {
+		a.displayName = *req.DisplayName
+	}
+	if req.PhotoURL != nil {
+		a.photoURL = *req.PhotoURL
+	}
+	if req.PhoneNumber != nil {
+		a.phoneNumber = *req.PhoneNumber
+	}
+	if req.EmailVerified != nil {
+		a.emailVerified = *req.EmailVerified
+	}
+	if req.DisableUser != nil {
+		a.disabled = *req.DisableUser
+	}
+	if req.CustomAttributes != nil {
+		var claims map[string]interface{}
+		if err := json.Unmarshal([]byte(*req.CustomAttributes), &claims); err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "INVALID_CLAIMS")
+		}
+		a.customAttributes = *req.CustomAttributes
+	}
+	if req.ValidSince != nil {
+		validSince, err := req.ValidSince.Int64()
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, "INVALID_VALID_SINCE")
+		}
+		a.validSince = validSince
+	}
+
+	for _, attr := range req.DeleteAttribute {
+		switch attr {
+		case "DISPLAY_NAME":
+			a.displayName = ""
+		case "PHOTO_URL":
+			a.photoURL = ""
+		}
+	}
+	for _, provider := range req.DeleteProvider {
+		switch provider {
+		case "phone":
+			a.phoneNumber = ""
+		case "password":
+			a.salt, a.hash = nil, nil
+		}
+	}
+
+	if err := s.checkClash(current, &a); err != nil {
+		return nil, err
+	}
+	*current = a
+
+	resp := struct {
+		Kind    string `json:"kind"`
+		LocalID string `json:"localId"`
+	}{
+		Kind:    "identitytoolkit#SetAccountInfoResponse",
+		LocalID: a.uid,
+	}
+	return resp, nil
+}
+
+// checkClash checks no other user of the tenant holds the email or the
+// phone number of the account. The stored account, if any, is the one being
+// updated. The lock must be held.
+func (s *Server) checkClash(stored *account, a *account) error {
+	if other := s.findByEmail(a.tenantID, a.email); a.email != "" && other != nil && other != stored {
+		return newAPIError(http.StatusBadRequest, "EMAIL_EXISTS")
+	}
+	if other := s.findByPhone(a.tenantID, a.phoneNumber); a.phoneNumber != "" && other != nil && other != stored {
+		return newAPIError(http.StatusBadRequest, "PHONE_NUMBER_EXISTS")
+	}
+	return nil
+}
+
+// =============================================================================
+
+// deleteRequest is the body of POST /accounts:delete.
+type deleteRequest struct {
+	LocalID string `json:"localId"`
+}
+
+// deleteUser removes the user of the tenant.
+func (s *Server) deleteUser(tenantID string, body []byte) (interface{}, error) {
+	var req deleteRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := userKey(tenantID, req.LocalID)
+	if _, exists := s.users[key]; !exists {
+		return nil, newAPIError(http.StatusBadRequest, "USER_NOT_FOUND")
+	}
+	delete(s.users, key)
+
+	resp := struct {
+		Kind string `json:"kind"`
+	}{
+		Kind: "identitytoolkit#DeleteAccountResponse",
+	}
+	return resp, nil
+}
+
+// =============================================================================
+
+// signInRequest is the body of POST /accounts:signInWithPassword.
+type signInRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	TenantID string `json:"tenantId"`
+}
+
+// signInResponse is the response of the sign-ins of the client sdk.
+type signInResponse struct {
+	Kind         string `json:"kind"`
+	LocalID      string `json:"localId"`
+	Email        string `json:"email,omitempty"`
+	DisplayName  string `json:"displayName,omitempty"`
+	IDToken      string `json:"idToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    string `json:"expiresIn"`
+	Registered   bool   `json:"registered,omitempty"`
+	IsNewUser    bool   `json:"isNewUser,omitempty"`
+}
+
+// signInWithPassword signs the user of the tenant in with the password.
+func (s *Server) signInWithPassword(req signInRequest) (signInResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.findByEmail(req.TenantID, req.Email)
+	switch {
+	case a == nil:
+		return signInResponse{}, newAPIError(http.StatusBadRequest, "EMAIL_NOT_FOUND")
+	case !a.checkPassword(req.Password):
+		return signInResponse{}, newAPIError(http.StatusBadRequest, "INVALID_PASSWORD")
+	case a.disabled:
+		return signInResponse{}, newAPIError(http.StatusBadRequest, "USER_DISABLED")
+	}
+
+	resp, err := s.signedIn(a, "password", nil)
+	if err != nil {
+		return signInResponse{}, err
+	}
+	resp.Kind = "identitytoolkit#VerifyPasswordResponse"
+	resp.Registered = true
+	return resp, nil
+}
+
+// customTokenRequest is the body of POST /accounts:signInWithCustomToken.
+type customTokenRequest struct {
+	Token    string `json:"token"`
+	TenantID string `json:"tenantId"`
+}
+
+// signInWithCustomToken signs the user of the custom token in. Like the
+// emulator, the signature of the token is not checked and an unknown user
+// is created.
+func (s *Server) signInWithCustomToken(req customTokenRequest) (signInResponse, error) {
+	ct, err := parseCustomToken(req.Token)
+	if err != nil {
+		return signInResponse{}, err
+	}
+	if ct.TenantID != req.TenantID {
+		return signInResponse{}, newAPIError(http.StatusBadRequest, "TENANT_ID_MISMATCH")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, exists := s.users[userKey(req.TenantID, ct.UID)]
+	if !exists {
+		a = &account{
+			uid:       ct.UID,
+			tenantID:  req.TenantID,
+			createdAt: nowMillis(),
+		}
+		s.users[userKey(req.TenantID, ct.UID)] = a
+	}
+	if a.disabled {
+		return signInResponse{}, newAPIError(http.StatusBadRequest, "USER_DISABLED")
+	}
+
+	resp, err := s.signedIn(a, "custom", ct.Claims)
+	if err != nil {
+		return signInResponse{}, err
+	}
+	resp.Kind = "identitytoolkit#VerifyCustomTokenResponse"
+	resp.IsNewUser = !exists
+	return resp, nil
+}
+
+// signedIn records the sign-in of the account and issues its id token, the
+// lock must be held.
+func (s *Server) signedIn(a *account, provider string, devClaims map[string]interface{}) (signInResponse, error) {
+	now := time.Now()
+	a.lastLoginAt = nowMillis()
+
+	idToken, err := s.issueIDToken(a, provider, devClaims, now.Unix())
+	if err != nil {
+		return signInResponse{}, err
+	}
+
+	refresh, err := newID(32)
+	if err != nil {
+		return signInResponse{}, err
+	}
+
+	resp := signInResponse{
+		LocalID:      a.uid,
+		Email:        a.email,
+		DisplayName:  a.displayName,
+		IDToken:      idToken,
+		RefreshToken: refresh,
+		ExpiresIn:    fmt.Sprint(int64(idTokenTTL.Seconds())),
+	}
+	return resp, nil
+}
+
+// newID returns a random id of n bytes, like the uids of firebase.
+func newID(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating id: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,307 @@
+// Package fakeauth is a fake of the firebase auth emulator for the hermetic
+// integration tests. It speaks the subset of the identity toolkit api the
+// admin sdk uses, so the firebase adapters run against it unchanged through
+// FIREBASE_AUTH_EMULATOR_HOST:
+//
+//	srv, err := fakeauth.New("demo-test")
+//	...
+//	defer srv.Close()
+//	t.Setenv("FIREBASE_AUTH_EMULATOR_HOST", srv.Host())
+//	app, err := firebase.NewApp(ctx, &firebase.Config{ProjectID: "demo-test"})
+//
+// It also answers the password and custom token sign-ins of the client sdk,
+// which hand out the id tokens. Like the emulator, every tenant exists and
+// keeps its users apart. The users are kept in memory.
+//
+// Like with the emulator, the project client of the admin sdk checks the
+// session cookies against the users of the project, the cookies of the
+// tenant users don't verify through it.
+package fakeauth
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// These are the prefixes of the served apis.
+const (
+	toolkitPrefix  = "/identitytoolkit.googleapis.com/v1/"
+	emulatorPrefix = "/emulator/v1/projects/"
+)
+
+// maxBodySize caps the size of the request bodies.
+const maxBodySize = 1 << 20
+
+// Server is a running fake of the auth emulator. It is safe for concurrent
+// use.
+type Server struct {
+	srv       *httptest.Server
+	projectID string
+	key       *rsa.PrivateKey
+	cert      string
+
+	mu    sync.Mutex
+	users map[string]*account
+}
+
+// New starts a fake serving the users of the project.
+func New(projectID string) (*Server, error) {
+	key, cert, err := newSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	s := Server{
+		projectID: projectID,
+		key:       key,
+		cert:      cert,
+		users:     make(map[string]*account),
+	}
+	s.srv = httptest.NewServer(&s)
+
+	return &s, nil
+}
+
+// URL returns the base url of the fake, e.g. http://127.0.0.1:38411.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Host returns the host of the fake, the value of FIREBASE_AUTH_EMULATOR_HOST.
+func (s *Server) Host() string {
+	u, _ := url.Parse(s.srv.URL)
+	return u.Host
+}
+
+// Close shuts the fake down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Reset removes the users of all the tenants.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = make(map[string]*account)
+}
+
+// SignIn signs the user of the tenant in with the password, like the client
+// sdk does, and returns the id token.
+func (s *Server) SignIn(tenantID string, email string, password string) (string, error) {
+	resp, err := s.signInWithPassword(signInRequest{
+		Email:    email,
+		Password: password,
+		TenantID: tenantID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.IDToken, nil
+}
+
+// ServeHTTP routes the requests of the admin and the client sdk.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+
+	switch {
+	case r.Method == http.MethodGet && path == IDTokenCertPath,
+		r.Method == http.MethodGet && path == SessionCookieCertPath:
+		s.serveCerts(w)
+
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, emulatorPrefix):
+		s.serveReset(w, strings.TrimPrefix(path, emulatorPrefix))
+
+	case r.Method == http.MethodPost && strings.HasPrefix(path, toolkitPrefix):
+		s.serveToolkit(w, r, strings.TrimPrefix(path, toolkitPrefix))
+
+	default:
+		respondError(w, newAPIError(http.StatusNotFound, "NOT_FOUND"))
+	}
+}
+
+// serveReset clears the users of the project, like the emulator does on
+// DELETE /emulator/v1/projects/{project}/accounts.
+func (s *Server) serveReset(w http.ResponseWriter, path string) {
+	if path != s.projectID+"/accounts" {
+		respondError(w, newAPIError(http.StatusNotFound, "NOT_FOUND"))
+		return
+	}
+
+	s.Reset()
+	respond(w, struct{}{})
+}
+
+// serveToolkit routes the calls of the identity toolkit api, the path is
+// the one after /v1/.
+func (s *Server) serveToolkit(w http.ResponseWriter, r *http.Request, path string) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		respondError(w, newAPIError(http.StatusBadRequest, "INVALID_REQUEST_BODY"))
+		return
+	}
+
+	// The client sdk names the tenant in the body.
+	switch path {
+	case "accounts:signInWithPassword":
+		var req signInRequest
+		if err := decode(body, &req); err != nil {
+			respondError(w, err)
+			return
+		}
+		resp, err := s.signInWithPassword(req)
+		respondResult(w, resp, err)
+		return
+
+	case "accounts:signInWithCustomToken":
+		var req customTokenRequest
+		if err := decode(body, &req); err != nil {
+			respondError(w, err)
+			return
+		}
+		resp, err := s.signInWithCustomToken(req)
+		respondResult(w, resp, err)
+		return
+	}
+
+	// The admin sdk names the project and the tenant in the path:
+	// projects/{project}[/tenants/{tenant}]{method}.
+	projectID, tenantID, method, ok := splitResource(path)
+	if !ok {
+		respondError(w, newAPIError(http.StatusNotFound, "NOT_FOUND"))
+		return
+	}
+	if projectID != s.projectID {
+		respondError(w, newAPIError(http.StatusBadRequest, "PROJECT_NOT_FOUND"))
+		return
+	}
+
+	var (
+		resp   interface{}
+		apiErr error
+	)
+	switch method {
+	case "/accounts":
+		resp, apiErr = s.createUser(tenantID, body)
+	case "/accounts:lookup":
+		resp, apiErr = s.lookupUsers(tenantID, body)
+	case "/accounts:update":
+		resp, apiErr = s.updateUser(tenantID, body)
+	case "/accounts:delete":
+		resp, apiErr = s.deleteUser(tenantID, body)
+	case ":createSessionCookie":
+		resp, apiErr = s.createSessionCookie(body)
+	default:
+		apiErr = newAPIError(http.StatusNotFound, "NOT_FOUND")
+	}
+	respondResult(w, resp, apiErr)
+}
+
+// splitResource splits projects/{project}[/tenants/{tenant}]{method}.
+func splitResource(path string) (projectID string, tenantID string, method string, ok bool) {
+	rest := strings.TrimPrefix(path, "projects/")
+	if rest == path {
+		return "", "", "", false
+	}
+
+	i := strings.IndexAny(rest, "/:")
+	if i <= 0 {
+		return "", "", "", false
+	}
+	projectID, rest = rest[:i], rest[i:]
+
+	if strings.HasPrefix(rest, "/tenants/") {
+		rest = strings.TrimPrefix(rest, "/tenants/")
+		i = strings.IndexAny(rest, "/:")
+		if i <= 0 {
+			return "", "", "", false
+		}
+		tenantID, rest = rest[:i], rest[i:]
+	}
+
+	return projectID, tenantID, rest, true
+}
+
+// =============================================================================
+
+// apiError is an error of the identity toolkit api. The admin sdk maps the
+// code of the message to its own errors.
+type apiError struct {
+	status  int
+	message string
+}
+
+// newAPIError constructs an error with the code, and optionally a detail,
+// of the api, e.g. USER_NOT_FOUND.
+func newAPIError(status int, code string, detail ...string) *apiError {
+	msg := code
+	if len(detail) > 0 {
+		msg = fmt.Sprintf("%s : %s", code, strings.Join(detail, " "))
+	}
+	return &apiError{status: status, message: msg}
+}
+
+// Error implements the error interface.
+func (e *apiError) Error() string {
+	return "fakeauth: " + e.message
+}
+
+// decode decodes the json body of a request.
+func decode(body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return newAPIError(http.StatusBadRequest, "INVALID_JSON_PAYLOAD", err.Error())
+	}
+	return nil
+}
+
+// respondResult writes the result of a call, or its error.
+func respondResult(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respond(w, v)
+}
+
+// respond writes the json response of a successful call.
+func respond(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(v)
+}
+
+// respondError writes the error in the format of the identity toolkit api:
+// {"error": {"code": 400, "message": "USER_NOT_FOUND"}}.
+func respondError(w http.ResponseWriter, err error) {
+	e, ok := err.(*apiError)
+	if !ok {
+		e = newAPIError(http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	}
+
+	body := struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+	body.Error.Code = e.status
+	body.Error.Message = e.message
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// nowMillis returns the current time in milliseconds, the unit of the
+// timestamps of the users.
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
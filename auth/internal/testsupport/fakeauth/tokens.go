@@ -0,0 +1,302 @@
+package fakeauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// These are the paths of the certificates verifying the id tokens and the
+// session cookies, the same as the ones of google. The admin sdk skips the
+// signatures with an emulator, the other verifiers fetch the keys here.
+const (
+	IDTokenCertPath       = "/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
+	SessionCookieCertPath = "/identitytoolkit/v3/relyingparty/publicKeys"
+)
+
+// These are the settings of the issued tokens.
+const (
+	keyID                     = "fakeauth"
+	idTokenTTL                = time.Hour
+	minSessionTTL             = 5 * time.Minute
+	maxSessionTTL             = 14 * 24 * time.Hour
+	idTokenIssuerPrefix       = "https://securetoken.google.com/"
+	sessionCookieIssuerPrefix = "https://session.firebase.google.com/"
+	customTokenAudience       = "https://identitytoolkit.googleapis.com/google.identity.identitytoolkit.v1.IdentityToolkit"
+)
+
+// newSigningKey generates the key signing the tokens and its self-signed
+// certificate in pem.
+func newSigningKey() (*rsa.PrivateKey, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, "", fmt.Errorf("fakeauth: generating key: %w", err)
+	}
+
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fakeauth"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, "", fmt.Errorf("fakeauth: creating certificate: %w", err)
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return key, string(cert), nil
+}
+
+// serveCerts writes the certificates by key id, like google does.
+func (s *Server) serveCerts(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	respond(w, map[string]string{keyID: s.cert})
+}
+
+// =============================================================================
+
+// issueIDToken issues an id token of the account. The custom claims of the
+// user and the developer claims of a custom token are added to the token,
+// they can't override the claims of firebase.
+func (s *Server) issueIDToken(a *account, provider string, devClaims map[string]interface{}, authTime int64) (string, error) {
+	claims := make(map[string]interface{})
+
+	if a.customAttributes != "" {
+		if err := json.Unmarshal([]byte(a.customAttributes), &claims); err != nil {
+			return "", fmt.Errorf("decoding custom claims: %w", err)
+		}
+	}
+	for k, v := range devClaims {
+		claims[k] = v
+	}
+
+	identities := make(map[string]interface{})
+	if a.email != "" {
+		identities["email"] = []string{a.email}
+	}
+	if a.phoneNumber != "" {
+		identities["phone"] = []string{a.phoneNumber}
+	}
+	fb := map[string]interface{}{
+		"identities":       identities,
+		"sign_in_provider": provider,
+	}
+	if a.tenantID != "" {
+		fb["tenant"] = a.tenantID
+	}
+
+	now := time.Now()
+	claims["iss"] = idTokenIssuerPrefix + s.projectID
+	claims["aud"] = s.projectID
+	claims["auth_time"] = authTime
+	claims["user_id"] = a.uid
+	claims["sub"] = a.uid
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(idTokenTTL).Unix()
+	claims["firebase"] = fb
+	if a.email != "" {
+		claims["email"] = a.email
+		claims["email_verified"] = a.emailVerified
+	}
+	if a.displayName != "" {
+		claims["name"] = a.displayName
+	}
+	if a.phoneNumber != "" {
+		claims["phone_number"] = a.phoneNumber
+	}
+
+	return s.sign(claims)
+}
+
+// sessionRequest is the body of POST :createSessionCookie.
+type sessionRequest struct {
+	IDToken       string      `json:"idToken"`
+	ValidDuration json.Number `json:"validDuration"`
+}
+
+// createSessionCookie exchanges an id token for a session cookie. The
+// cookie carries the claims of the token, the tenant included.
+func (s *Server) createSessionCookie(body []byte) (interface{}, error) {
+	var req sessionRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+
+	seconds, err := req.ValidDuration.Int64()
+	ttl := time.Duration(seconds) * time.Second
+	if err != nil || ttl < minSessionTTL || ttl > maxSessionTTL {
+		return nil, newAPIError(http.StatusBadRequest, "INVALID_DURATION")
+	}
+
+	claims, err := s.verify(req.IDToken, idTokenIssuerPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var tenantID string
+	if fb, ok := claims["firebase"].(map[string]interface{}); ok {
+		tenantID, _ = fb["tenant"].(string)
+	}
+	uid, _ := claims["sub"].(string)
+	iat, _ := claims["iat"].(float64)
+
+	s.mu.Lock()
+	a, exists := s.users[userKey(tenantID, uid)]
+	var disabled bool
+	var validSince int64
+	if exists {
+		disabled, validSince = a.disabled, a.validSince
+	}
+	s.mu.Unlock()
+
+	switch {
+	case !exists:
+		return nil, newAPIError(http.StatusBadRequest, "USER_NOT_FOUND")
+	case disabled:
+		return nil, newAPIError(http.StatusBadRequest, "USER_DISABLED")
+	case int64(iat) < validSince:
+		return nil, newAPIError(http.StatusBadRequest, "TOKEN_EXPIRED")
+	}
+
+	now := time.Now()
+	claims["iss"] = sessionCookieIssuerPrefix + s.projectID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	cookie, err := s.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := struct {
+		SessionCookie string `json:"sessionCookie"`
+	}{
+		SessionCookie: cookie,
+	}
+	return resp, nil
+}
+
+// =============================================================================
+
+// customToken holds the claims of a custom token of the admin sdk.
+type customToken struct {
+	Audience string                 `json:"aud"`
+	Expires  int64                  `json:"exp"`
+	UID      string                 `json:"uid"`
+	TenantID string                 `json:"tenant_id"`
+	Claims   map[string]interface{} `json:"claims"`
+}
+
+// parseCustomToken decodes a custom token. With an emulator, the admin sdk
+// doesn't sign them.
+func parseCustomToken(token string) (customToken, error) {
+	invalid := newAPIError(http.StatusBadRequest, "INVALID_CUSTOM_TOKEN")
+
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return customToken{}, invalid
+	}
+
+	var ct customToken
+	if err := decodeSegment(segments[1], &ct); err != nil {
+		return customToken{}, invalid
+	}
+
+	switch {
+	case ct.Audience != customTokenAudience, ct.UID == "":
+		return customToken{}, invalid
+	case ct.Expires < time.Now().Unix():
+		return customToken{}, newAPIError(http.StatusBadRequest, "INVALID_CUSTOM_TOKEN", "TOKEN_EXPIRED")
+	}
+	return ct, nil
+}
+
+// jwtHeader is the header of the signed tokens.
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Type      string `json:"typ"`
+}
+
+// sign signs the claims into a RS256 jwt.
+func (s *Server) sign(claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: "RS256", KeyID: keyID, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing token: %w", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// verify checks the token was signed by the fake for the project, with the
+// issuer of the prefix, and is not expired. It returns the claims.
+func (s *Server) verify(token string, issuerPrefix string) (map[string]interface{}, error) {
+	invalid := newAPIError(http.StatusBadRequest, "INVALID_ID_TOKEN")
+
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return nil, invalid
+	}
+
+	var h jwtHeader
+	if err := decodeSegment(segments[0], &h); err != nil || h.Algorithm != "RS256" || h.KeyID != keyID {
+		return nil, invalid
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return nil, invalid
+	}
+	digest := sha256.Sum256([]byte(segments[0] + "." + segments[1]))
+	if err := rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, invalid
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(segments[1], &claims); err != nil {
+		return nil, invalid
+	}
+
+	exp, _ := claims["exp"].(float64)
+	switch {
+	case claims["iss"] != issuerPrefix+s.projectID, claims["aud"] != s.projectID:
+		return nil, invalid
+	case int64(exp) < time.Now().Unix():
+		return nil, newAPIError(http.StatusBadRequest, "TOKEN_EXPIRED")
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url segment of a jwt.
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,78 @@
+package softauthn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// These are the CBOR major types used by the encoder.
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorMap    = 5
+)
+
+// cborEntry is an entry of a map, the keys are ints or strings.
+type cborEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// cborMap is a map encoded in the order of its entries, which the callers
+// keep canonical like CTAP2 does.
+type cborMap []cborEntry
+
+// encode encodes the subset of CBOR the authenticator answers with: ints,
+// byte strings, text strings and maps. It panics on other values, they are
+// a bug of the package.
+func encode(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(majorNegInt, uint64(-1-v))
+		}
+		return head(majorUint, uint64(v))
+
+	case []byte:
+		return append(head(majorBytes, uint64(len(v))), v...)
+
+	case string:
+		return append(head(majorText, uint64(len(v))), v...)
+
+	case cborMap:
+		b := head(majorMap, uint64(len(v)))
+		for _, e := range v {
+			b = append(b, encode(e.key)...)
+			b = append(b, encode(e.value)...)
+		}
+		return b
+	}
+
+	panic(fmt.Sprintf("softauthn: unsupported cbor value %T", v))
+}
+
+// head encodes the major type and the argument in the shortest form.
+func head(major byte, arg uint64) []byte {
+	m := major << 5
+
+	switch {
+	case arg < 24:
+		return []byte{m | byte(arg)}
+	case arg <= 0xff:
+		return []byte{m | 24, byte(arg)}
+	case arg <= 0xffff:
+		b := []byte{m | 25, 0, 0}
+		binary.BigEndian.PutUint16(b[1:], uint16(arg))
+		return b
+	case arg <= 0xffffffff:
+		b := []byte{m | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(arg))
+		return b
+	}
+
+	b := []byte{m | 27, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(b[1:], arg)
+	return b
+}
@@ -0,0 +1,233 @@
+// Package softauthn is a software WebAuthn authenticator for the tests. It
+// creates an ES256 discoverable credential and answers the registration and
+// assertion ceremonies of a relying party like a platform authenticator:
+//
+//	a, err := softauthn.New("localhost", "http://localhost:3000", []byte(uid))
+//	...
+//	clientDataJSON, attestationObject, err := a.Register(challenge)
+//	...
+//	resp, err := a.Assert(challenge)
+//
+// The fields can be changed between the ceremonies to answer like a broken
+// or a cloned authenticator, e.g. from another origin or with an older sign
+// count.
+package softauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// These are the flags set in the authenticator data.
+const (
+	flagUserPresent            byte = 0x01
+	flagUserVerified           byte = 0x04
+	flagAttestedCredentialData byte = 0x40
+)
+
+// These are the COSE values of the ES256 credential key.
+const (
+	algES256 = -7
+	ktyEC2   = 2
+	crvP256  = 1
+)
+
+// These are the attestation formats.
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+// credentialIDSize is the size in bytes of a generated credential id.
+const credentialIDSize = 16
+
+// Authenticator holds one credential of a relying party. It is not safe
+// for concurrent use.
+type Authenticator struct {
+	// RPID is the relying party id hashed into the authenticator data.
+	RPID string
+	// Origin is the origin reported in the client data.
+	Origin string
+	// Format is the attestation format of the registrations, none by
+	// default or packed for a self attestation.
+	Format string
+	// UserVerified sets the user verified flag.
+	UserVerified bool
+	// SignCount is the counter of the signatures, incremented before each
+	// assertion.
+	SignCount uint32
+
+	credentialID []byte
+	userHandle   []byte
+	key          *ecdsa.PrivateKey
+}
+
+// New creates an authenticator with a new credential of the user handle
+// for the relying party.
+func New(rpID string, origin string, userHandle []byte) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("softauthn: generating key: %w", err)
+	}
+
+	id := make([]byte, credentialIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("softauthn: generating credential id: %w", err)
+	}
+
+	a := Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		Format:       FormatNone,
+		credentialID: id,
+		userHandle:   append([]byte(nil), userHandle...),
+		key:          key,
+	}
+	return &a, nil
+}
+
+// CredentialID returns the id of the credential.
+func (a *Authenticator) CredentialID() []byte {
+	return append([]byte(nil), a.credentialID...)
+}
+
+// Register answers a registration ceremony with the challenge and returns
+// the client data and the attestation object.
+func (a *Authenticator) Register(challenge []byte) ([]byte, []byte, error) {
+	clientDataJSON, err := a.clientData("webauthn.create", challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authData := a.authenticatorData(flagAttestedCredentialData)
+	authData = append(authData, a.attestedCredentialData()...)
+
+	var attStmt cborMap
+	switch a.Format {
+	case FormatNone:
+	case FormatPacked:
+		sig, err := a.sign(authData, clientDataJSON)
+		if err != nil {
+			return nil, nil, err
+		}
+		attStmt = cborMap{
+			{"alg", algES256},
+			{"sig", sig},
+		}
+	default:
+		return nil, nil, fmt.Errorf("softauthn: unsupported attestation format %q", a.Format)
+	}
+
+	attestationObject := encode(cborMap{
+		{"fmt", a.Format},
+		{"attStmt", attStmt},
+		{"authData", authData},
+	})
+	return clientDataJSON, attestationObject, nil
+}
+
+// Response is the answer of the authenticator to an assertion ceremony.
+type Response struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte
+}
+
+// Assert answers an assertion ceremony with the challenge. The sign count
+// is incremented first.
+func (a *Authenticator) Assert(challenge []byte) (Response, error) {
+	clientDataJSON, err := a.clientData("webauthn.get", challenge)
+	if err != nil {
+		return Response{}, err
+	}
+
+	a.SignCount++
+	authData := a.authenticatorData(0)
+
+	sig, err := a.sign(authData, clientDataJSON)
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp := Response{
+		CredentialID:      a.CredentialID(),
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authData,
+		Signature:         sig,
+		UserHandle:        append([]byte(nil), a.userHandle...),
+	}
+	return resp, nil
+}
+
+// clientData returns the client data the browser collects for a ceremony.
+func (a *Authenticator) clientData(typ string, challenge []byte) ([]byte, error) {
+	cd := struct {
+		Type        string `json:"type"`
+		Challenge   string `json:"challenge"`
+		Origin      string `json:"origin"`
+		CrossOrigin bool   `json:"crossOrigin"`
+	}{
+		Type:      typ,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    a.Origin,
+	}
+
+	b, err := json.Marshal(cd)
+	if err != nil {
+		return nil, fmt.Errorf("softauthn: encoding client data: %w", err)
+	}
+	return b, nil
+}
+
+// authenticatorData returns the rp id hash, the flags and the sign count.
+// The user is always present.
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	flags |= flagUserPresent
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := make([]byte, 37)
+	copy(data, rpIDHash[:])
+	data[32] = flags
+	binary.BigEndian.PutUint32(data[33:], a.SignCount)
+	return data
+}
+
+// attestedCredentialData returns the aaguid, which is zero, the credential
+// id and the COSE public key.
+func (a *Authenticator) attestedCredentialData() []byte {
+	data := make([]byte, 18, 18+len(a.credentialID))
+	binary.BigEndian.PutUint16(data[16:], uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+
+	coseKey := cborMap{
+		{1, ktyEC2},
+		{3, algES256},
+		{-1, crvP256},
+		{-2, a.key.X.FillBytes(make([]byte, 32))},
+		{-3, a.key.Y.FillBytes(make([]byte, 32))},
+	}
+	return append(data, encode(coseKey)...)
+}
+
+// sign signs the authenticator data followed by the hash of the client data.
+func (a *Authenticator) sign(authData []byte, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("softauthn: signing: %w", err)
+	}
+	return sig, nil
+}
@@ -0,0 +1,3 @@
+// Package admin contains all the components needed to
+// fulfill the user management use case used by support staff.
+package admin
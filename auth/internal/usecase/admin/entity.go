@@ -0,0 +1,33 @@
+package admin
+
+import "time"
+
+// user represents a domain entity.
+type user struct {
+	UID           string
+	Email         string
+	DisplayName   string
+	EmailVerified bool
+	Disabled      bool
+	Roles         []string
+	CreatedAt     time.Time
+	LastSignInAt  time.Time
+}
+
+// page represents a slice of users and the cursor to the next slice.
+type page struct {
+	Users      []user
+	NextCursor string
+}
+
+// importFailure represents a user that could not be imported.
+type importFailure struct {
+	Index  int
+	Reason string
+}
+
+// importResult represents the outcome of a bulk import.
+type importResult struct {
+	Imported int
+	Failed   []importFailure
+}
@@ -0,0 +1,6 @@
+package admin
+
+import "errors"
+
+// ErrNotFound is used when the user does not exist.
+var ErrNotFound = errors.New("user not found")
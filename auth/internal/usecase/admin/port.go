@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"context"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+)
+
+// (Port) Service defines how the interaction between the "core" and the "admin http handlers" has to be done.
+type Service interface {
+	// List returns a page of users matching the query.
+	List(context.Context, Query) (page, error)
+	// View returns the user with the given uid.
+	View(ctx context.Context, uid string) (user, error)
+	// SetDisabled disables or enables the user with the given uid.
+	SetDisabled(ctx context.Context, uid string, disabled bool) (user, error)
+	// ForcePasswordReset sends a password reset link and revokes the sessions of the user.
+	ForcePasswordReset(ctx context.Context, uid string) error
+	// RevokeSessions revokes all the sessions of the user with the given uid.
+	RevokeSessions(ctx context.Context, uid string) error
+	// Import creates the users in bulk.
+	Import(context.Context, Import) (importResult, error)
+}
+
+// (Port) AuthnProvider defines how the interaction between the "core" and the "authn provider" has to be done.
+type AuthnProvider interface {
+	// Users returns a page of users matching the query.
+	Users(context.Context, Query) (page, error)
+	// User returns the user with the given uid.
+	User(ctx context.Context, uid string) (user, error)
+	// SetDisabled disables or enables the user with the given uid.
+	SetDisabled(ctx context.Context, uid string, disabled bool) (user, error)
+	// PasswordResetLink generates the link used to reset the password of the given email.
+	PasswordResetLink(ctx context.Context, email string) (string, error)
+	// RevokeSessions revokes all the sessions of the user with the given uid.
+	RevokeSessions(ctx context.Context, uid string) error
+	// Import creates the users in bulk.
+	Import(context.Context, Import) (importResult, error)
+}
+
+// (Port) Notifier defines how the interaction between the "core" and the "notification system" has to be done.
+type Notifier interface {
+	// PasswordReset delivers the password reset link to the given email.
+	PasswordReset(ctx context.Context, email string, link string) error
+}
+
+// (Port) Auditor defines how the interaction between the "core" and the "audit log" has to be done.
+type Auditor interface {
+	// Record appends the event to the audit log.
+	Record(ctx context.Context, e audit.Event)
+}
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+)
+
+// Service represents "admin" core service.
+type service struct {
+	ap AuthnProvider
+	n  Notifier
+	a  Auditor
+}
+
+// NewService creates an "admin core service" with the necessary dependencies.
+func NewService(ap AuthnProvider, n Notifier, a Auditor) *service {
+	return &service{ap: ap, n: n, a: a}
+}
+
+// List returns a page of users matching the query.
+func (s *service) List(ctx context.Context, q Query) (page, error) {
+	p, err := s.ap.Users(ctx, q)
+	s.audit(ctx, "users.list", "", err, map[string]string{"search": q.Search, "cursor": q.Cursor})
+	if err != nil {
+		return page{}, fmt.Errorf("admin: %w", err)
+	}
+	return p, nil
+}
+
+// View returns the user with the given uid.
+func (s *service) View(ctx context.Context, uid string) (user, error) {
+	u, err := s.ap.User(ctx, uid)
+	s.audit(ctx, "users.view", uid, err, nil)
+	if err != nil {
+		return user{}, fmt.Errorf("admin: %w", err)
+	}
+	return u, nil
+}
+
+// SetDisabled disables or enables the user. Disabling a user also revokes its sessions.
+func (s *service) SetDisabled(ctx context.Context, uid string, disabled bool) (user, error) {
+	action := "users.enable"
+	if disabled {
+		action = "users.disable"
+	}
+
+	u, err := s.setDisabled(ctx, uid, disabled)
+	s.audit(ctx, action, uid, err, nil)
+	if err != nil {
+		return user{}, fmt.Errorf("admin: %w", err)
+	}
+	return u, nil
+}
+
+func (s *service) setDisabled(ctx context.Context, uid string, disabled bool) (user, error) {
+	u, err := s.ap.SetDisabled(ctx, uid, disabled)
+	if err != nil {
+		return user{}, err
+	}
+	if disabled {
+		if err := s.ap.RevokeSessions(ctx, uid); err != nil {
+			return user{}, err
+		}
+	}
+	return u, nil
+}
+
+// ForcePasswordReset sends a password reset link and revokes the sessions of
+// the user, so the user has to sign in again after choosing a new password.
+func (s *service) ForcePasswordReset(ctx context.Context, uid string) error {
+	err := s.forcePasswordReset(ctx, uid)
+	s.audit(ctx, "users.password_reset", uid, err, nil)
+	if err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
+	return nil
+}
+
+func (s *service) forcePasswordReset(ctx context.Context, uid string) error {
+	u, err := s.ap.User(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if u.Email == "" {
+		return fmt.Errorf("user %s has no email", uid)
+	}
+
+	link, err := s.ap.PasswordResetLink(ctx, u.Email)
+	if err != nil {
+		return err
+	}
+	if err := s.n.PasswordReset(ctx, u.Email, link); err != nil {
+		return err
+	}
+
+	return s.ap.RevokeSessions(ctx, uid)
+}
+
+// RevokeSessions revokes all the sessions of the user.
+func (s *service) RevokeSessions(ctx context.Context, uid string) error {
+	err := s.ap.RevokeSessions(ctx, uid)
+	s.audit(ctx, "users.revoke_sessions", uid, err, nil)
+	if err != nil {
+		return fmt.Errorf("admin: %w", err)
+	}
+	return nil
+}
+
+// Import creates the users in bulk.
+func (s *service) Import(ctx context.Context, imp Import) (importResult, error) {
+	res, err := s.ap.Import(ctx, imp)
+	s.audit(ctx, "users.import", "", err, map[string]string{
+		"users":    strconv.Itoa(len(imp.Users)),
+		"imported": strconv.Itoa(res.Imported),
+		"failed":   strconv.Itoa(len(res.Failed)),
+	})
+	if err != nil {
+		return importResult{}, fmt.Errorf("admin: %w", err)
+	}
+	return res, nil
+}
+
+// audit records every action performed by an admin.
+func (s *service) audit(ctx context.Context, action string, subject string, err error, details map[string]string) {
+	s.a.Record(ctx, audit.Event{
+		Action:  action,
+		Subject: subject,
+		Details: details,
+	}.Failed(err))
+}
@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mroobert/go-tickets/auth/internal/usecase/signup"
+)
+
+// These are the page size limits used when listing users.
+const (
+	defaultLimit = 50
+	maxLimit     = 1000
+)
+
+// maxImportUsers is the maximum number of users imported in a single call.
+const maxImportUsers = 1000
+
+// Query reprezents a "value object" inside domain used to list users.
+type Query struct {
+	Search string
+	Cursor string
+	Limit  int
+}
+
+// NewQuery creates a new Query that is in a valid state.
+func NewQuery(search string, cursor string, limit int) (Query, error) {
+	if limit < 0 || limit > maxLimit {
+		return Query{}, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	return Query{
+		Search: strings.TrimSpace(search),
+		Cursor: cursor,
+		Limit:  limit,
+	}, nil
+}
+
+// These are the supported password hash algorithms for import.
+const (
+	HashBcrypt         = "BCRYPT"
+	HashScrypt         = "SCRYPT"
+	HashStandardScrypt = "STANDARD_SCRYPT"
+	HashHMACSHA256     = "HMAC_SHA256"
+	HashHMACSHA512     = "HMAC_SHA512"
+	HashSHA256         = "SHA256"
+	HashSHA512         = "SHA512"
+	HashPBKDF2SHA256   = "PBKDF2_SHA256"
+)
+
+// Hash reprezents the configuration of the algorithm used to hash the imported passwords.
+type Hash struct {
+	Algorithm        string
+	Key              []byte
+	SaltSeparator    []byte
+	Rounds           int
+	MemoryCost       int
+	BlockSize        int
+	DerivedKeyLength int
+	Parallelization  int
+}
+
+// NewHash creates a new Hash that is in a valid state.
+func NewHash(h Hash) (Hash, error) {
+	h.Algorithm = strings.ToUpper(h.Algorithm)
+
+	switch h.Algorithm {
+	case "", HashBcrypt, HashStandardScrypt:
+	case HashScrypt, HashHMACSHA256, HashHMACSHA512:
+		if len(h.Key) == 0 {
+			return Hash{}, fmt.Errorf("hash algorithm %s requires a key", h.Algorithm)
+		}
+	case HashSHA256, HashSHA512, HashPBKDF2SHA256:
+		if h.Rounds < 1 {
+			return Hash{}, fmt.Errorf("hash algorithm %s requires rounds", h.Algorithm)
+		}
+	default:
+		return Hash{}, fmt.Errorf("unsupported hash algorithm %q", h.Algorithm)
+	}
+
+	return h, nil
+}
+
+// ImportUser reprezents a user to be imported with an already hashed password.
+type ImportUser struct {
+	UID           string
+	Email         string
+	DisplayName   string
+	EmailVerified bool
+	Disabled      bool
+	PasswordHash  []byte
+	PasswordSalt  []byte
+}
+
+// Import reprezents a "value object" inside domain used for bulk import.
+type Import struct {
+	Hash  Hash
+	Users []ImportUser
+}
+
+// NewImport creates a new Import that is in a valid state.
+func NewImport(h Hash, users []ImportUser) (Import, error) {
+	if len(users) == 0 {
+		return Import{}, fmt.Errorf("at least one user must be provided")
+	}
+	if len(users) > maxImportUsers {
+		return Import{}, fmt.Errorf("no more than %d users can be imported at once", maxImportUsers)
+	}
+
+	hashed := false
+	for i, u := range users {
+		if u.UID == "" {
+			return Import{}, fmt.Errorf("user[%d]: uid must be a non-empty string", i)
+		}
+		if u.Email != "" {
+			if err := signup.ValidateEmail(u.Email); err != nil {
+				return Import{}, fmt.Errorf("user[%d]: %w", i, err)
+			}
+		}
+		if len(u.PasswordHash) > 0 {
+			hashed = true
+		}
+	}
+	if hashed && h.Algorithm == "" {
+		return Import{}, fmt.Errorf("hash algorithm is required to import users with passwords")
+	}
+
+	return Import{
+		Hash:  h,
+		Users: users,
+	}, nil
+}
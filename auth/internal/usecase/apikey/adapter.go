@@ -0,0 +1,453 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	fbauthn "firebase.google.com/go/v4/auth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/memauth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// createRequestDto represents the create payload request contract.
+type createRequestDto struct {
+	Label         string   `json:"label"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+// keyResponseDto represents the api key response contract.
+type keyResponseDto struct {
+	ID        string   `json:"id"`
+	Label     string   `json:"label"`
+	Prefix    string   `json:"prefix"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt string   `json:"createdAt"`
+	ExpiresAt string   `json:"expiresAt"`
+	RevokedAt string   `json:"revokedAt,omitempty"`
+}
+
+// createResponseDto represents the create payload response contract.
+type createResponseDto struct {
+	keyResponseDto
+	Key string `json:"key"`
+}
+
+// keyToResponseDto transforms key domain struct into response (dto).
+func keyToResponseDto(k key) keyResponseDto {
+	dto := keyResponseDto{
+		ID:        k.ID,
+		Label:     k.Label,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt.Format(time.RFC3339),
+		ExpiresAt: k.ExpiresAt.Format(time.RFC3339),
+	}
+	if k.isRevoked() {
+		dto.RevokedAt = k.RevokedAt.Format(time.RFC3339)
+	}
+	return dto
+}
+
+// Handlers holds the http handlers of the api key use case.
+type Handlers struct {
+	Create web.Handler
+	List   web.Handler
+	Revoke web.Handler
+}
+
+// (Adapter) HttpHandlers transforms the "api key http requests" into "calls on api key core service".
+func HttpHandlers(s Service) Handlers {
+	return Handlers{
+		Create: createHandler(s),
+		List:   listHandler(s),
+		Revoke: revokeHandler(s),
+	}
+}
+
+func createHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		// decode payload
+		var reqDto createRequestDto
+		if err := web.Decode(r, &reqDto); err != nil {
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+		}
+		spec, err := NewSpec(reqDto.Label, reqDto.Scopes, time.Duration(reqDto.ExpiresInDays)*24*time.Hour)
+		if err != nil {
+			return webapp.NewRequestError(fmt.Errorf("invalid payload: %w", err), http.StatusBadRequest)
+		}
+
+		// business logic
+		c, err := s.Create(ctx, claims.UID, spec)
+		if err != nil {
+			return fmt.Errorf("unable to create api key: %w", err)
+		}
+
+		// send response
+		resp := createResponseDto{
+			keyResponseDto: keyToResponseDto(c.key),
+			Key:            c.Value,
+		}
+		return web.Respond(ctx, w, resp, http.StatusCreated)
+	}
+}
+
+func listHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		keys, err := s.List(ctx, claims.UID)
+		if err != nil {
+			return fmt.Errorf("unable to list api keys: %w", err)
+		}
+
+		resp := []keyResponseDto{}
+		for _, k := range keys {
+			resp = append(resp, keyToResponseDto(k))
+		}
+		return web.Respond(ctx, w, resp, http.StatusOK)
+	}
+}
+
+func revokeHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		if err := s.Revoke(ctx, claims.UID, web.Param(r, "id")); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return webapp.NewRequestError(err, http.StatusNotFound)
+			}
+			return fmt.Errorf("unable to revoke api key: %w", err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+// (Adapter) Firebase transforms an "apikey core service call" into a "call on firebase".
+type Firebase struct {
+	client *fbauthn.Client
+}
+
+// NewFirebase sets a firebase authentication client for apikey use case.
+func NewFirebase(client *fbauthn.Client) *Firebase {
+	return &Firebase{
+		client: client,
+	}
+}
+
+// Owner returns the email, the roles and the state of the user.
+func (fb Firebase) Owner(ctx context.Context, uid string) (owner, error) {
+	ctx, done := webapp.StartFirebase(ctx, "apikey.owner")
+	defer done()
+
+	u, err := webapp.FirebaseTenant(ctx, fb.client).GetUser(ctx, uid)
+	if err != nil {
+		return owner{}, fmt.Errorf("firebase getting user: %w", err)
+	}
+
+	o := owner{
+		Email:    u.Email,
+		Disabled: u.Disabled,
+	}
+
+	// Roles are stored by the role use case as a custom claim.
+	if roles, ok := u.CustomClaims["roles"].([]interface{}); ok {
+		for _, r := range roles {
+			if role, ok := r.(string); ok {
+				o.Roles = append(o.Roles, role)
+			}
+		}
+	}
+
+	return o, nil
+}
+
+// (Adapter) MemoryOwners transforms an "apikey core service call" into a "call on the in-memory authn provider".
+type MemoryOwners struct {
+	p *memauth.Provider
+}
+
+// NewMemoryOwners sets an in-memory authentication provider for apikey use case.
+func NewMemoryOwners(p *memauth.Provider) *MemoryOwners {
+	return &MemoryOwners{
+		p: p,
+	}
+}
+
+// Owner returns the email and the roles of the user. The users of the
+// in-memory provider can't be disabled.
+func (m MemoryOwners) Owner(ctx context.Context, uid string) (owner, error) {
+	u, err := m.p.GetUser(ctx, uid)
+	if err != nil {
+		return owner{}, fmt.Errorf("memory getting user: %w", err)
+	}
+
+	o := owner{
+		Email: u.Email,
+		Roles: u.Roles,
+	}
+	return o, nil
+}
+
+// (Adapter) Memory transforms a "store call" into an "in-memory map operation".
+// The keys are lost on restart and are not shared between instances, it is
+// meant for development.
+type Memory struct {
+	mu   sync.RWMutex
+	keys map[string]key
+}
+
+// NewMemory creates an empty in-memory api keys store.
+func NewMemory() *Memory {
+	return &Memory{
+		keys: make(map[string]key),
+	}
+}
+
+// Add stores a new key.
+func (m *Memory) Add(ctx context.Context, k key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sk := storeKey(k.TenantID, k.Prefix)
+	if _, ok := m.keys[sk]; ok {
+		return fmt.Errorf("key %s already exists", k.Prefix)
+	}
+	m.keys[sk] = copyKey(k)
+	return nil
+}
+
+// ByPrefix returns the key of the tenant of the request with the given prefix or ErrNotFound.
+func (m *Memory) ByPrefix(ctx context.Context, prefix string) (key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	k, ok := m.keys[storeKey(web.GetTenantID(ctx), prefix)]
+	if !ok {
+		return key{}, ErrNotFound
+	}
+	return copyKey(k), nil
+}
+
+// ByUser returns the keys of the user of the tenant of the request, the newest first.
+func (m *Memory) ByUser(ctx context.Context, uid string) ([]key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tenantID := web.GetTenantID(ctx)
+
+	var keys []key
+	for _, k := range m.keys {
+		if k.TenantID == tenantID && k.UID == uid {
+			keys = append(keys, copyKey(k))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// Update replaces a stored key or returns ErrNotFound.
+func (m *Memory) Update(ctx context.Context, k key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sk := storeKey(k.TenantID, k.Prefix)
+	if _, ok := m.keys[sk]; !ok {
+		return ErrNotFound
+	}
+	m.keys[sk] = copyKey(k)
+	return nil
+}
+
+// copyKey copies the slices so callers can't change the stored values.
+func copyKey(k key) key {
+	k.Scopes = append([]string(nil), k.Scopes...)
+	return k
+}
+
+// keyDoc represents the firestore document of a key.
+type keyDoc struct {
+	ID        string    `firestore:"id"`
+	TenantID  string    `firestore:"tenantId"`
+	UID       string    `firestore:"uid"`
+	Label     string    `firestore:"label"`
+	Scopes    []string  `firestore:"scopes"`
+	Hash      string    `firestore:"hash"`
+	CreatedAt time.Time `firestore:"createdAt"`
+	ExpiresAt time.Time `firestore:"expiresAt"`
+	RevokedAt time.Time `firestore:"revokedAt"`
+}
+
+// (Adapter) Firestore transforms a "store call" into a "firestore document operation".
+// The keys are the documents of the collection, by tenant and prefix.
+type Firestore struct {
+	collection *firestore.CollectionRef
+}
+
+// NewFirestore creates an api keys store on the collection.
+func NewFirestore(client *firestore.Client, collection string) *Firestore {
+	return &Firestore{
+		collection: client.Collection(collection),
+	}
+}
+
+// Add stores a new key.
+func (f *Firestore) Add(ctx context.Context, k key) error {
+	if !isDocID(k.Prefix) {
+		return fmt.Errorf("invalid key prefix %q", k.Prefix)
+	}
+
+	if _, err := f.collection.Doc(storeKey(k.TenantID, k.Prefix)).Create(ctx, toKeyDoc(k)); err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return fmt.Errorf("key %s already exists", k.Prefix)
+		}
+		return fmt.Errorf("writing key: %w", err)
+	}
+	return nil
+}
+
+// ByPrefix returns the key of the tenant of the request with the given prefix or ErrNotFound.
+func (f *Firestore) ByPrefix(ctx context.Context, prefix string) (key, error) {
+	// The prefix comes from the callers, it may not name a document.
+	if !isDocID(prefix) {
+		return key{}, ErrNotFound
+	}
+
+	snap, err := f.collection.Doc(storeKey(web.GetTenantID(ctx), prefix)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return key{}, ErrNotFound
+		}
+		return key{}, fmt.Errorf("reading key: %w", err)
+	}
+	return fromKeySnapshot(snap)
+}
+
+// ByUser returns the keys of the user of the tenant of the request, the newest first.
+func (f *Firestore) ByUser(ctx context.Context, uid string) ([]key, error) {
+	iter := f.collection.
+		Where("tenantId", "==", web.GetTenantID(ctx)).
+		Where("uid", "==", uid).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var keys []key
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading keys: %w", err)
+		}
+
+		k, err := fromKeySnapshot(snap)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	// Sorting here spares the composite index of the query.
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// Update replaces a stored key or returns ErrNotFound.
+func (f *Firestore) Update(ctx context.Context, k key) error {
+	if !isDocID(k.Prefix) {
+		return ErrNotFound
+	}
+
+	doc := toKeyDoc(k)
+	updates := []firestore.Update{
+		{Path: "id", Value: doc.ID},
+		{Path: "tenantId", Value: doc.TenantID},
+		{Path: "uid", Value: doc.UID},
+		{Path: "label", Value: doc.Label},
+		{Path: "scopes", Value: doc.Scopes},
+		{Path: "hash", Value: doc.Hash},
+		{Path: "createdAt", Value: doc.CreatedAt},
+		{Path: "expiresAt", Value: doc.ExpiresAt},
+		{Path: "revokedAt", Value: doc.RevokedAt},
+	}
+
+	// Update fails on a missing document, unlike Set.
+	if _, err := f.collection.Doc(storeKey(k.TenantID, k.Prefix)).Update(ctx, updates); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return ErrNotFound
+		}
+		return fmt.Errorf("writing key: %w", err)
+	}
+	return nil
+}
+
+// toKeyDoc maps the key to its document, the tenant and the prefix are the document id.
+func toKeyDoc(k key) keyDoc {
+	return keyDoc{
+		ID:        k.ID,
+		TenantID:  k.TenantID,
+		UID:       k.UID,
+		Label:     k.Label,
+		Scopes:    k.Scopes,
+		Hash:      k.Hash,
+		CreatedAt: k.CreatedAt,
+		ExpiresAt: k.ExpiresAt,
+		RevokedAt: k.RevokedAt,
+	}
+}
+
+// fromKeySnapshot maps the document back to the key.
+func fromKeySnapshot(snap *firestore.DocumentSnapshot) (key, error) {
+	var doc keyDoc
+	if err := snap.DataTo(&doc); err != nil {
+		return key{}, fmt.Errorf("decoding key: %w", err)
+	}
+
+	k := key{
+		ID:        doc.ID,
+		TenantID:  doc.TenantID,
+		UID:       doc.UID,
+		Label:     doc.Label,
+		Scopes:    doc.Scopes,
+		Prefix:    strings.TrimPrefix(snap.Ref.ID, storeKey(doc.TenantID, "")),
+		Hash:      doc.Hash,
+		CreatedAt: doc.CreatedAt,
+		ExpiresAt: doc.ExpiresAt,
+		RevokedAt: doc.RevokedAt,
+	}
+	return k, nil
+}
+
+// isDocID reports if the prefix can name a document.
+func isDocID(prefix string) bool {
+	return prefix != "" && !strings.Contains(prefix, "/")
+}
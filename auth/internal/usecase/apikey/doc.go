@@ -0,0 +1,3 @@
+// Package apikey contains all the components needed to
+// fulfill the personal api keys use case.
+package apikey
@@ -0,0 +1,47 @@
+package apikey
+
+import "time"
+
+// key represents a domain entity. Only the prefix and the hash of the
+// secret are stored, the full key is shown once when it is created.
+type key struct {
+	ID string
+	// TenantID is the tenant of the owner, empty for the project.
+	TenantID  string
+	UID       string
+	Label     string
+	Scopes    []string
+	Prefix    string
+	Hash      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt time.Time
+}
+
+// isRevoked reports if the key was revoked.
+func (k key) isRevoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// storeKey returns the storage key of an api key. The keys of every
+// tenant are apart, the ones of the project keep their prefix. The
+// separator is not a slash, the keys name firestore documents.
+func storeKey(tenantID string, prefix string) string {
+	if tenantID == "" {
+		return prefix
+	}
+	return tenantID + ":" + prefix
+}
+
+// created represents a key together with its secret value.
+type created struct {
+	key
+	Value string
+}
+
+// owner represents the user an api key acts for.
+type owner struct {
+	Email    string
+	Roles    []string
+	Disabled bool
+}
@@ -0,0 +1,12 @@
+package apikey
+
+import "errors"
+
+// Set of error variables for the api key use case.
+var (
+	ErrNotFound      = errors.New("api key not found")
+	ErrInvalidKey    = errors.New("invalid api key")
+	ErrExpired       = errors.New("api key expired")
+	ErrRevoked       = errors.New("api key revoked")
+	ErrOwnerDisabled = errors.New("api key owner disabled")
+)
@@ -0,0 +1,37 @@
+package apikey
+
+import (
+	"context"
+
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// (Port) Service defines how the interaction between the "core" and the "api key http handlers" has to be done.
+type Service interface {
+	// Create generates a new api key for the user.
+	Create(ctx context.Context, uid string, spec Spec) (created, error)
+	// List returns the api keys of the user.
+	List(ctx context.Context, uid string) ([]key, error)
+	// Revoke revokes the api key of the user.
+	Revoke(ctx context.Context, uid string, id string) error
+	// VerifyAPIKey verifies the api key and returns the claims of its owner.
+	VerifyAPIKey(ctx context.Context, value string) (auth.Claims, error)
+}
+
+// (Port) Store defines how the interaction between the "core" and the "api keys storage" has to be done.
+type Store interface {
+	// Add stores a new key.
+	Add(ctx context.Context, k key) error
+	// ByPrefix returns the key of the tenant of the request with the given prefix or ErrNotFound.
+	ByPrefix(ctx context.Context, prefix string) (key, error)
+	// ByUser returns the keys of the user of the tenant of the request.
+	ByUser(ctx context.Context, uid string) ([]key, error)
+	// Update replaces a stored key or returns ErrNotFound.
+	Update(ctx context.Context, k key) error
+}
+
+// (Port) AuthnProvider defines how the interaction between the "core" and the "authn provider" has to be done.
+type AuthnProvider interface {
+	// Owner returns the current state of the user with the given uid.
+	Owner(ctx context.Context, uid string) (owner, error)
+}
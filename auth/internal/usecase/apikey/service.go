@@ -0,0 +1,180 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// keyPrefix marks the values as go-tickets api keys, so they are easy to
+// recognize by secret scanners.
+const keyPrefix = "gtk"
+
+// Service represents "apikey" core service.
+type service struct {
+	store Store
+	ap    AuthnProvider
+}
+
+// NewService creates an "apikey core service" with the necessary dependencies.
+func NewService(store Store, ap AuthnProvider) *service {
+	return &service{
+		store: store,
+		ap:    ap,
+	}
+}
+
+// Create generates a new api key for the user. The returned value is the
+// only time the full key is available.
+func (s *service) Create(ctx context.Context, uid string, spec Spec) (created, error) {
+	id, err := random(6)
+	if err != nil {
+		return created{}, fmt.Errorf("apikey: %w", err)
+	}
+	secret, err := random(32)
+	if err != nil {
+		return created{}, fmt.Errorf("apikey: %w", err)
+	}
+
+	// The key looks like gtk_<id>_<secret>, the prefix gtk_<id> is
+	// stored in clear to find the key and to show it in listings.
+	prefix := keyPrefix + "_" + id
+	value := prefix + "_" + secret
+
+	now := time.Now().UTC()
+	k := key{
+		ID:        id,
+		TenantID:  web.GetTenantID(ctx),
+		UID:       uid,
+		Label:     spec.Label,
+		Scopes:    spec.Scopes,
+		Prefix:    prefix,
+		Hash:      hash(value),
+		CreatedAt: now,
+		ExpiresAt: now.Add(spec.ExpiresIn),
+	}
+	if err := s.store.Add(ctx, k); err != nil {
+		return created{}, fmt.Errorf("apikey: %w", err)
+	}
+
+	return created{key: k, Value: value}, nil
+}
+
+// List returns the api keys of the user.
+func (s *service) List(ctx context.Context, uid string) ([]key, error) {
+	keys, err := s.store.ByUser(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("apikey: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke revokes the api key of the user. Revoking a key twice is not an error.
+func (s *service) Revoke(ctx context.Context, uid string, id string) error {
+	k, err := s.byPrefix(ctx, keyPrefix+"_"+id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("apikey: %w", err)
+	}
+
+	// Keys of other users are reported as missing to not leak their existence.
+	if k.UID != uid {
+		return ErrNotFound
+	}
+	if k.isRevoked() {
+		return nil
+	}
+
+	k.RevokedAt = time.Now().UTC()
+	if err := s.store.Update(ctx, k); err != nil {
+		return fmt.Errorf("apikey: %w", err)
+	}
+	return nil
+}
+
+// VerifyAPIKey verifies the api key and returns the claims of its owner.
+// The roles are read from the authn provider so role changes and disabled
+// users apply to the keys right away.
+func (s *service) VerifyAPIKey(ctx context.Context, value string) (auth.Claims, error) {
+	parts := strings.Split(value, "_")
+	if len(parts) != 3 || parts[0] != keyPrefix {
+		return auth.Claims{}, ErrInvalidKey
+	}
+
+	k, err := s.byPrefix(ctx, parts[0]+"_"+parts[1])
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return auth.Claims{}, ErrInvalidKey
+		}
+		return auth.Claims{}, fmt.Errorf("apikey: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash(value)), []byte(k.Hash)) != 1 {
+		return auth.Claims{}, ErrInvalidKey
+	}
+	if k.isRevoked() {
+		return auth.Claims{}, ErrRevoked
+	}
+	if time.Now().After(k.ExpiresAt) {
+		return auth.Claims{}, ErrExpired
+	}
+
+	o, err := s.ap.Owner(ctx, k.UID)
+	if err != nil {
+		return auth.Claims{}, fmt.Errorf("apikey: %w", err)
+	}
+	if o.Disabled {
+		return auth.Claims{}, ErrOwnerDisabled
+	}
+
+	return auth.Claims{
+		UID:      k.UID,
+		Email:    o.Email,
+		Roles:    o.Roles,
+		APIKeyID: k.ID,
+		Scopes:   k.Scopes,
+	}, nil
+}
+
+// byPrefix returns the key of the tenant of the request with the given prefix.
+func (s *service) byPrefix(ctx context.Context, prefix string) (key, error) {
+	k, err := s.store.ByPrefix(ctx, prefix)
+	if err != nil {
+		return key{}, err
+	}
+
+	// The keys of another tenant are reported as missing, their owners
+	// are not the users of the tenant of the request.
+	if k.TenantID != web.GetTenantID(ctx) {
+		return key{}, ErrNotFound
+	}
+	return k, nil
+}
+
+// random returns n random bytes hex encoded, so the values never contain
+// the separator of the key parts.
+func random(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hash returns the hex encoded sha256 of the key. The keys have 256 bits
+// of entropy so a fast hash is enough.
+func hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
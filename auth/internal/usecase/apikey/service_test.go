@@ -0,0 +1,55 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// fakeOwners returns an enabled owner for every uid.
+type fakeOwners struct{}
+
+func (fakeOwners) Owner(ctx context.Context, uid string) (owner, error) {
+	return owner{Email: uid + "@example.com"}, nil
+}
+
+// tenantContext returns a context with the tenant of a request.
+func tenantContext(tenantID string) context.Context {
+	return web.SetValues(context.Background(), &web.Values{TenantID: tenantID})
+}
+
+func TestVerifyAPIKeyOtherTenant(t *testing.T) {
+	s := NewService(NewMemory(), fakeOwners{})
+	ctxA := tenantContext("tenant-a")
+
+	c, err := s.Create(ctxA, "uid-1", Spec{Label: "ci", ExpiresIn: time.Hour})
+	if err != nil {
+		t.Fatalf("creating key: %v", err)
+	}
+	if c.TenantID != "tenant-a" {
+		t.Fatalf("got key of tenant %q, want %q", c.TenantID, "tenant-a")
+	}
+
+	for _, ctx := range []context.Context{tenantContext("tenant-b"), context.Background()} {
+		if _, err := s.VerifyAPIKey(ctx, c.Value); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("got error %v in tenant %q, want %v", err, web.GetTenantID(ctx), ErrInvalidKey)
+		}
+		if err := s.Revoke(ctx, "uid-1", c.ID); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("got error %v in tenant %q, want %v", err, web.GetTenantID(ctx), ErrNotFound)
+		}
+		if keys, err := s.List(ctx, "uid-1"); err != nil || len(keys) != 0 {
+			t.Fatalf("got keys %v and error %v in tenant %q, want none", keys, err, web.GetTenantID(ctx))
+		}
+	}
+
+	claims, err := s.VerifyAPIKey(ctxA, c.Value)
+	if err != nil {
+		t.Fatalf("verifying key in the tenant: %v", err)
+	}
+	if claims.UID != "uid-1" || claims.APIKeyID != c.ID {
+		t.Fatalf("got claims %+v, want the ones of the key", claims)
+	}
+}
@@ -0,0 +1,65 @@
+package apikey
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// These are the limits of the key settings.
+const (
+	maxLabelLength   = 64
+	defaultExpiresIn = 90 * 24 * time.Hour
+	maxExpiresIn     = 365 * 24 * time.Hour
+)
+
+// Spec reprezents the settings of a new api key.
+type Spec struct {
+	Label     string
+	Scopes    []string
+	ExpiresIn time.Duration
+}
+
+// NewSpec creates a new Spec that is in a valid state. A zero expiresIn
+// falls back to the default expiry.
+func NewSpec(label string, scopes []string, expiresIn time.Duration) (Spec, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return Spec{}, fmt.Errorf("label must be a non-empty string")
+	}
+	if len(label) > maxLabelLength {
+		return Spec{}, fmt.Errorf("label must be at most %d characters long", maxLabelLength)
+	}
+
+	if len(scopes) == 0 {
+		return Spec{}, fmt.Errorf("at least one scope must be provided")
+	}
+	seen := make(map[string]bool)
+	var unique []string
+	for _, s := range scopes {
+		if !auth.IsScope(s) {
+			return Spec{}, fmt.Errorf("unknown scope %q", s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+
+	switch {
+	case expiresIn == 0:
+		expiresIn = defaultExpiresIn
+	case expiresIn < 0:
+		return Spec{}, fmt.Errorf("expiry must be in the future")
+	case expiresIn > maxExpiresIn:
+		return Spec{}, fmt.Errorf("expiry must be at most %d days", int(maxExpiresIn.Hours()/24))
+	}
+
+	return Spec{
+		Label:     label,
+		Scopes:    unique,
+		ExpiresIn: expiresIn,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package auditlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+)
+
+// queryResponseDto represents the query payload response contract.
+type queryResponseDto struct {
+	Events []audit.Event `json:"events"`
+}
+
+// verifyResponseDto represents the verify payload response contract.
+type verifyResponseDto struct {
+	Events int    `json:"events"`
+	Valid  bool   `json:"valid"`
+	Line   int    `json:"line,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Handlers holds the (Adapter) HttpHandlers of the auditlog use case.
+type Handlers struct {
+	Query  web.Handler
+	Verify web.Handler
+}
+
+// (Adapter) HttpHandlers transforms the "auditlog http requests" into "calls on auditlog core service".
+func HttpHandlers(s Service) Handlers {
+	return Handlers{
+		Query:  queryHandler(s),
+		Verify: verifyHandler(s),
+	}
+}
+
+func queryHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		qs := r.URL.Query()
+
+		var since, until time.Time
+		var limit int
+		var err error
+		if v := qs.Get("since"); v != "" {
+			if since, err = time.Parse(time.RFC3339, v); err != nil {
+				return webapp.NewRequestError(fmt.Errorf("invalid since: %w", err), http.StatusBadRequest)
+			}
+		}
+		if v := qs.Get("until"); v != "" {
+			if until, err = time.Parse(time.RFC3339, v); err != nil {
+				return webapp.NewRequestError(fmt.Errorf("invalid until: %w", err), http.StatusBadRequest)
+			}
+		}
+		if v := qs.Get("limit"); v != "" {
+			if limit, err = strconv.Atoi(v); err != nil {
+				return webapp.NewRequestError(fmt.Errorf("invalid limit: %w", err), http.StatusBadRequest)
+			}
+		}
+
+		f, err := NewFilter(qs.Get("actor"), qs.Get("subject"), qs.Get("action"), qs.Get("outcome"), since, until, limit)
+		if err != nil {
+			return webapp.NewRequestError(fmt.Errorf("invalid query: %w", err), http.StatusBadRequest)
+		}
+
+		events, err := s.Query(ctx, f)
+		if err != nil {
+			return toRequestError(err)
+		}
+		if events == nil {
+			events = []audit.Event{}
+		}
+
+		return web.Respond(ctx, w, queryResponseDto{Events: events}, http.StatusOK)
+	}
+}
+
+func verifyHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		v, err := s.Verify(ctx)
+		if err != nil {
+			return toRequestError(err)
+		}
+
+		resDto := verifyResponseDto{
+			Events: v.Events,
+			Valid:  v.Valid,
+			Line:   v.Line,
+			Reason: v.Reason,
+		}
+		return web.Respond(ctx, w, resDto, http.StatusOK)
+	}
+}
+
+// toRequestError maps the known domain errors to request errors.
+func toRequestError(err error) error {
+	switch {
+	case errors.Is(err, audit.ErrNotQueryable):
+		return webapp.NewRequestError(err, http.StatusNotImplemented)
+	case errors.Is(err, ErrProjectOnly):
+		return webapp.NewRequestError(err, http.StatusForbidden)
+	}
+	return fmt.Errorf("unable to read the audit log: %w", err)
+}
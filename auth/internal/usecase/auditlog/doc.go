@@ -0,0 +1,3 @@
+// Package auditlog contains all the components needed to
+// fulfill the audit log review use case.
+package auditlog
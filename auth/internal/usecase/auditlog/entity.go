@@ -0,0 +1,9 @@
+package auditlog
+
+// verification represents a domain entity.
+type verification struct {
+	Events int
+	Valid  bool
+	Line   int
+	Reason string
+}
@@ -0,0 +1,6 @@
+package auditlog
+
+import "errors"
+
+// ErrProjectOnly is used when a tenant asks for the audit log of all the tenants.
+var ErrProjectOnly = errors.New("only the admins of the project can verify the audit log")
@@ -0,0 +1,23 @@
+package auditlog
+
+import (
+	"context"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+)
+
+// (Port) Service defines how the interaction between the "core" and the "auditlog http handlers" has to be done.
+type Service interface {
+	// Query returns the events matching the filter, the newest first.
+	Query(context.Context, Filter) ([]audit.Event, error)
+	// Verify checks the hash chain of the audit log.
+	Verify(context.Context) (verification, error)
+}
+
+// (Port) Store defines how the interaction between the "core" and the "audit log" has to be done.
+type Store interface {
+	// Query returns the events matching the filter, the newest first.
+	Query(ctx context.Context, f audit.Filter) ([]audit.Event, error)
+	// Verify checks the hash chain and returns the number of events verified.
+	Verify(ctx context.Context) (int, error)
+}
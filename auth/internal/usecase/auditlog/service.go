@@ -0,0 +1,53 @@
+package auditlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// Service represents "auditlog" core service.
+type service struct {
+	store Store
+}
+
+// NewService creates an "auditlog core service" with the necessary dependencies.
+func NewService(store Store) *service {
+	return &service{store: store}
+}
+
+// Query returns the events matching the filter, the newest first. Only the
+// events of the tenant of the request are returned.
+func (s *service) Query(ctx context.Context, f Filter) ([]audit.Event, error) {
+	f.Tenant = web.GetTenantID(ctx)
+
+	events, err := s.store.Query(ctx, audit.Filter(f))
+	if err != nil {
+		return nil, fmt.Errorf("auditlog: %w", err)
+	}
+	return events, nil
+}
+
+// Verify checks the hash chain of the audit log. A broken chain is reported
+// in the verification, not as an error. The chain holds the events of all
+// the tenants, so only the admins of the project can verify it.
+func (s *service) Verify(ctx context.Context) (verification, error) {
+	if web.GetTenantID(ctx) != "" {
+		return verification{}, ErrProjectOnly
+	}
+
+	n, err := s.store.Verify(ctx)
+
+	var ce *audit.ChainError
+	switch {
+	case errors.As(err, &ce):
+		return verification{Events: n, Line: ce.Line, Reason: ce.Reason}, nil
+	case err != nil:
+		return verification{}, fmt.Errorf("auditlog: %w", err)
+	}
+
+	return verification{Events: n, Valid: true}, nil
+}
@@ -0,0 +1,56 @@
+package auditlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// fakeStore returns the result of Verify it holds.
+type fakeStore struct {
+	n   int
+	err error
+}
+
+func (s fakeStore) Query(ctx context.Context, f audit.Filter) ([]audit.Event, error) {
+	return nil, nil
+}
+
+func (s fakeStore) Verify(ctx context.Context) (int, error) {
+	return s.n, s.err
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+
+	v, err := NewService(fakeStore{n: 5}).Verify(ctx)
+	if err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+	if !v.Valid || v.Events != 5 {
+		t.Fatalf("got %+v, want 5 events verified", v)
+	}
+
+	// A broken chain is reported in the verification.
+	broken := fakeStore{n: 2, err: &audit.ChainError{Line: 3, Reason: "hash mismatch"}}
+	v, err = NewService(broken).Verify(ctx)
+	if err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+	want := verification{Events: 2, Line: 3, Reason: "hash mismatch"}
+	if v != want {
+		t.Fatalf("got %+v, want %+v", v, want)
+	}
+
+	if _, err := NewService(fakeStore{err: errors.New("disk")}).Verify(ctx); err == nil {
+		t.Fatal("got no error for a failing store")
+	}
+
+	tenant := web.SetValues(ctx, &web.Values{TenantID: "tenant-a"})
+	if _, err := NewService(fakeStore{n: 5}).Verify(tenant); !errors.Is(err, ErrProjectOnly) {
+		t.Fatalf("got error %v, want %v", err, ErrProjectOnly)
+	}
+}
@@ -0,0 +1,45 @@
+package auditlog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+)
+
+// These are the page size limits used when querying events.
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Filter reprezents a "value object" inside domain used to query events.
+type Filter audit.Filter
+
+// NewFilter creates a new Filter that is in a valid state.
+func NewFilter(actor string, subject string, action string, outcome string, since time.Time, until time.Time, limit int) (Filter, error) {
+	switch outcome {
+	case "", audit.OutcomeSuccess, audit.OutcomeFailure, audit.OutcomeDenied:
+	default:
+		return Filter{}, fmt.Errorf("outcome must be one of %s, %s or %s", audit.OutcomeSuccess, audit.OutcomeFailure, audit.OutcomeDenied)
+	}
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		return Filter{}, fmt.Errorf("since must be before until")
+	}
+	if limit < 0 || limit > maxLimit {
+		return Filter{}, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	return Filter{
+		Actor:   actor,
+		Subject: subject,
+		Action:  action,
+		Outcome: outcome,
+		Since:   since,
+		Until:   until,
+		Limit:   limit,
+	}, nil
+}
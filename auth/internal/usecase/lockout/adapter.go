@@ -0,0 +1,144 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+)
+
+// unlockRequestDto represents the unlock payload request contract.
+type unlockRequestDto struct {
+	UID string `json:"uid"`
+	IP  string `json:"ip"`
+}
+
+// (Adapter) HttpHandler transforms a "lockout http request" into a "call on lockout core service".
+func HttpHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		// decode payload
+		var reqDto unlockRequestDto
+		if err := web.Decode(r, &reqDto); err != nil {
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+		}
+		sub, err := NewSubject(reqDto.UID, reqDto.IP)
+		if err != nil {
+			return webapp.NewRequestError(fmt.Errorf("invalid payload: %w", err), http.StatusBadRequest)
+		}
+
+		// business logic
+		if err := s.Unlock(ctx, sub); err != nil {
+			return fmt.Errorf("unable to unlock: %w", err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+// (Adapter) Memory transforms a "store call" into an "in-memory map operation".
+// Records are evicted once their failures left the window and their lockout
+// expired.
+type Memory struct {
+	mu        sync.Mutex
+	records   map[string]memoryRecord
+	lastSweep time.Time
+}
+
+// memoryRecord is a record with the time it can be evicted.
+type memoryRecord struct {
+	record
+	expires time.Time
+}
+
+// sweepInterval is how often the expired records are evicted.
+const sweepInterval = time.Minute
+
+// NewMemory creates an empty in-memory counters store.
+func NewMemory() *Memory {
+	return &Memory{
+		records: make(map[string]memoryRecord),
+	}
+}
+
+// Get returns the record of the key, an empty one if there is none.
+func (m *Memory) Get(ctx context.Context, key string) (record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mr, ok := m.records[key]
+	if !ok || time.Now().After(mr.expires) {
+		return record{}, nil
+	}
+	return copyRecord(mr.record), nil
+}
+
+// AddFailure appends a failure at t, drops the failures older than the
+// window and returns the updated record.
+func (m *Memory) AddFailure(ctx context.Context, key string, t time.Time, window time.Duration) (record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(t)
+
+	mr := m.records[key]
+	var failures []time.Time
+	for _, f := range mr.Failures {
+		if t.Sub(f) < window {
+			failures = append(failures, f)
+		}
+	}
+	mr.Failures = append(failures, t)
+
+	mr.expires = t.Add(window)
+	if mr.LockedUntil.After(mr.expires) {
+		mr.expires = mr.LockedUntil
+	}
+	m.records[key] = mr
+
+	return copyRecord(mr.record), nil
+}
+
+// Lock clears the failures and locks the key until the given time.
+func (m *Memory) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records[key] = memoryRecord{
+		record:  record{LockedUntil: until},
+		expires: until,
+	}
+	return nil
+}
+
+// Delete removes the record of the key.
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, key)
+	return nil
+}
+
+// sweep evicts the expired records, at most once per sweepInterval.
+func (m *Memory) sweep(t time.Time) {
+	if t.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = t
+
+	for k, mr := range m.records {
+		if t.After(mr.expires) {
+			delete(m.records, k)
+		}
+	}
+}
+
+// copyRecord copies the failures so callers can't change the stored values.
+func copyRecord(r record) record {
+	r.Failures = append([]time.Time(nil), r.Failures...)
+	return r
+}
@@ -0,0 +1,3 @@
+// Package lockout contains all the components needed to
+// fulfill the brute-force protection use case.
+package lockout
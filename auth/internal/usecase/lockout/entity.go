@@ -0,0 +1,37 @@
+package lockout
+
+import "time"
+
+// These are the kinds of subjects the failures are tracked for.
+const (
+	kindUID = "uid"
+	kindIP  = "ip"
+)
+
+// record represents the failed attempts of a subject.
+type record struct {
+	Failures    []time.Time
+	LockedUntil time.Time
+}
+
+// isLocked reports if the subject is locked at t.
+func (r record) isLocked(t time.Time) bool {
+	return t.Before(r.LockedUntil)
+}
+
+// lastFailure returns the time of the last failure.
+func (r record) lastFailure() time.Time {
+	if len(r.Failures) == 0 {
+		return time.Time{}
+	}
+	return r.Failures[len(r.Failures)-1]
+}
+
+// key returns the storage key of a subject. The subjects of every tenant
+// are apart, an admin of a tenant can't unlock the users of another one.
+func key(tenantID string, kind string, value string) string {
+	if tenantID == "" {
+		return kind + ":" + value
+	}
+	return tenantID + "/" + kind + ":" + value
+}
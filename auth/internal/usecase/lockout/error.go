@@ -0,0 +1,40 @@
+package lockout
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Set of error variables for the lockout use case.
+var (
+	ErrThrottled = errors.New("too many failed attempts, try again later")
+	ErrLocked    = errors.New("temporarily locked after too many failed attempts")
+)
+
+// DeniedError is used when an attempt is not allowed. It wraps ErrThrottled
+// or ErrLocked and tells when the next attempt is allowed.
+type DeniedError struct {
+	Err   error
+	After time.Duration
+}
+
+// Error implements the error interface.
+func (de *DeniedError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", de.Err, de.After.Round(time.Second))
+}
+
+// Unwrap returns the wrapped error.
+func (de *DeniedError) Unwrap() error {
+	return de.Err
+}
+
+// RetryAfter returns how long the client has to wait.
+func (de *DeniedError) RetryAfter() time.Duration {
+	return de.After
+}
+
+// Locked reports if the attempt was denied by a lockout.
+func (de *DeniedError) Locked() bool {
+	return errors.Is(de.Err, ErrLocked)
+}
@@ -0,0 +1,34 @@
+package lockout
+
+import (
+	"context"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+)
+
+// (Port) Service defines how the interaction between the "core" and the "lockout http handler" has to be done.
+type Service interface {
+	// Unlock clears the failures and the lockout of the subject.
+	Unlock(ctx context.Context, s Subject) error
+}
+
+// (Port) Store defines how the interaction between the "core" and the "counters storage" has to be done.
+// The operations must be atomic since attempts are concurrent.
+type Store interface {
+	// Get returns the record of the key, an empty one if there is none.
+	Get(ctx context.Context, key string) (record, error)
+	// AddFailure appends a failure at t, drops the failures older than the
+	// window and returns the updated record.
+	AddFailure(ctx context.Context, key string, t time.Time, window time.Duration) (record, error)
+	// Lock clears the failures and locks the key until the given time.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Delete removes the record of the key.
+	Delete(ctx context.Context, key string) error
+}
+
+// (Port) Auditor defines how the interaction between the "core" and the "audit log" has to be done.
+type Auditor interface {
+	// Record appends the event to the audit log.
+	Record(ctx context.Context, e audit.Event)
+}
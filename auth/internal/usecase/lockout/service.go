@@ -0,0 +1,162 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// Service represents "lockout" core service.
+//
+// Firebase checks the passwords, so the service only sees the outcome of the
+// attempts made against its own endpoints. Tokens that fail verification
+// carry no trustworthy identity and only count against the client ip,
+// while failed second factors also count against the uid.
+type service struct {
+	store Store
+	user  Policy
+	ip    Policy
+	a     Auditor
+}
+
+// NewService creates a "lockout core service" with the policies for the
+// users and the client ip addresses.
+func NewService(store Store, user Policy, ip Policy, a Auditor) *service {
+	return &service{
+		store: store,
+		user:  user,
+		ip:    ip,
+		a:     a,
+	}
+}
+
+// Check returns a *DeniedError if an attempt for the uid or the ip is not
+// allowed yet. Empty values are ignored.
+func (s *service) Check(ctx context.Context, uid string, ip string) error {
+	now := time.Now()
+
+	var denied *DeniedError
+	for _, t := range s.targets(ctx, uid, ip) {
+		r, err := s.store.Get(ctx, t.key)
+		if err != nil {
+			return fmt.Errorf("lockout: %w", err)
+		}
+
+		d := t.policy.check(r, now)
+		if d != nil && (denied == nil || d.After > denied.After) {
+			denied = d
+		}
+	}
+
+	if denied != nil {
+		return denied
+	}
+	return nil
+}
+
+// Fail records a failed attempt for the uid and the ip, locking them out
+// once the policy allows no more attempts.
+func (s *service) Fail(ctx context.Context, uid string, ip string) error {
+	now := time.Now()
+
+	for _, t := range s.targets(ctx, uid, ip) {
+		r, err := s.store.AddFailure(ctx, t.key, now, t.policy.Window)
+		if err != nil {
+			return fmt.Errorf("lockout: %w", err)
+		}
+
+		if len(r.Failures) < t.policy.MaxAttempts {
+			continue
+		}
+
+		until := now.Add(t.policy.LockDuration)
+		err = s.store.Lock(ctx, t.key, until)
+		s.audit(ctx, "lockout.lock", t.key, err, map[string]string{
+			"failures": strconv.Itoa(len(r.Failures)),
+			"until":    until.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return fmt.Errorf("lockout: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Succeed clears the failures of the uid. The failures of the ip are kept,
+// otherwise a single valid account would reset the counter of an attacker.
+func (s *service) Succeed(ctx context.Context, uid string) error {
+	if uid == "" {
+		return nil
+	}
+	if err := s.store.Delete(ctx, key(web.GetTenantID(ctx), kindUID, uid)); err != nil {
+		return fmt.Errorf("lockout: %w", err)
+	}
+	return nil
+}
+
+// Unlock clears the failures and the lockout of the subject.
+func (s *service) Unlock(ctx context.Context, sub Subject) error {
+	for _, t := range s.targets(ctx, sub.UID, sub.IP) {
+		err := s.store.Delete(ctx, t.key)
+		s.audit(ctx, "lockout.unlock", t.key, err, nil)
+		if err != nil {
+			return fmt.Errorf("lockout: %w", err)
+		}
+	}
+	return nil
+}
+
+// target represents a storage key and the policy that applies to it.
+type target struct {
+	key    string
+	policy Policy
+}
+
+// targets returns the targets for the non-empty values, within the tenant
+// of the request.
+func (s *service) targets(ctx context.Context, uid string, ip string) []target {
+	tenantID := web.GetTenantID(ctx)
+
+	var ts []target
+	if uid != "" {
+		ts = append(ts, target{key: key(tenantID, kindUID, uid), policy: s.user})
+	}
+	if ip != "" {
+		ts = append(ts, target{key: key(tenantID, kindIP, ip), policy: s.ip})
+	}
+	return ts
+}
+
+// check returns the denial for the record at t, if any.
+func (p Policy) check(r record, t time.Time) *DeniedError {
+	if r.isLocked(t) {
+		return &DeniedError{Err: ErrLocked, After: r.LockedUntil.Sub(t)}
+	}
+
+	var failures int
+	for _, f := range r.Failures {
+		if t.Sub(f) < p.Window {
+			failures++
+		}
+	}
+
+	next := r.lastFailure().Add(p.delay(failures))
+	if t.Before(next) {
+		return &DeniedError{Err: ErrThrottled, After: next.Sub(t)}
+	}
+	return nil
+}
+
+// audit records the lockout actions. The actor is the admin that made them, if any.
+func (s *service) audit(ctx context.Context, action string, subject string, err error, details map[string]string) {
+	s.a.Record(ctx, audit.Event{
+		Action:  action,
+		Subject: subject,
+		Details: details,
+	}.Failed(err))
+}
@@ -0,0 +1,227 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// fakeAuditor keeps the recorded events, it is safe for concurrent use.
+type fakeAuditor struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (a *fakeAuditor) Record(ctx context.Context, e audit.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, e)
+}
+
+// actions returns the actions of the recorded events.
+func (a *fakeAuditor) actions() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var actions []string
+	for _, e := range a.events {
+		actions = append(actions, e.Action)
+	}
+	return actions
+}
+
+// testPolicy allows one free failure, delays the second one by a minute
+// and locks out on the third one.
+var testPolicy = Policy{
+	Window:       time.Hour,
+	FreeAttempts: 1,
+	BaseDelay:    time.Minute,
+	MaxDelay:     time.Hour,
+	MaxAttempts:  3,
+	LockDuration: 15 * time.Minute,
+}
+
+// checkDenied checks the error is a denial of the kind retrying after at
+// most the given duration.
+func checkDenied(t *testing.T, err error, kind error, after time.Duration) {
+	t.Helper()
+
+	var de *DeniedError
+	if !errors.As(err, &de) || !errors.Is(err, kind) {
+		t.Fatalf("got error %v, want %v", err, kind)
+	}
+	if de.RetryAfter() <= 0 || de.RetryAfter() > after {
+		t.Fatalf("got retry after %v, want at most %v", de.RetryAfter(), after)
+	}
+	if de.Locked() != errors.Is(kind, ErrLocked) {
+		t.Fatalf("got locked %t for %v", de.Locked(), kind)
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 8 * time.Second},
+		{7, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := p.delay(tt.failures); got != tt.want {
+			t.Errorf("got delay %v after %d failures, want %v", got, tt.failures, tt.want)
+		}
+	}
+}
+
+func TestPolicyCheckWindow(t *testing.T) {
+	p := testPolicy
+	p.Window = time.Minute
+	now := time.Now()
+
+	// The failures that left the window don't count, the one left is free.
+	r := record{Failures: []time.Time{now.Add(-2 * time.Minute), now.Add(-90 * time.Second), now.Add(-10 * time.Second)}}
+	if d := p.check(r, now); d != nil {
+		t.Fatalf("got denial %v, want the old failures ignored", d)
+	}
+
+	// Two failures in the window delay the next attempt.
+	r = record{Failures: []time.Time{now.Add(-50 * time.Second), now.Add(-10 * time.Second)}}
+	d := p.check(r, now)
+	if d == nil || !errors.Is(d, ErrThrottled) || d.After != 50*time.Second {
+		t.Fatalf("got denial %v, want to retry after %v", d, 50*time.Second)
+	}
+}
+
+func TestMemoryAddFailureWindow(t *testing.T) {
+	m := NewMemory()
+	now := time.Now()
+
+	for _, f := range []time.Time{now.Add(-2 * time.Minute), now.Add(-30 * time.Second), now} {
+		if _, err := m.AddFailure(context.Background(), "uid:uid-1", f, time.Minute); err != nil {
+			t.Fatalf("adding failure: %v", err)
+		}
+	}
+
+	r, err := m.Get(context.Background(), "uid:uid-1")
+	if err != nil {
+		t.Fatalf("reading record: %v", err)
+	}
+	if len(r.Failures) != 2 {
+		t.Fatalf("got %d failures, want the 2 in the window", len(r.Failures))
+	}
+}
+
+func TestLockoutAndUnlock(t *testing.T) {
+	a := &fakeAuditor{}
+	s := NewService(NewMemory(), testPolicy, testPolicy, a)
+	ctx := context.Background()
+
+	if err := s.Fail(ctx, "uid-1", "192.0.2.1"); err != nil {
+		t.Fatalf("failing: %v", err)
+	}
+	if err := s.Check(ctx, "uid-1", "192.0.2.1"); err != nil {
+		t.Fatalf("got error %v after a free failure, want none", err)
+	}
+
+	if err := s.Fail(ctx, "uid-1", "192.0.2.1"); err != nil {
+		t.Fatalf("failing: %v", err)
+	}
+	checkDenied(t, s.Check(ctx, "uid-1", ""), ErrThrottled, testPolicy.BaseDelay)
+
+	if err := s.Fail(ctx, "uid-1", "192.0.2.1"); err != nil {
+		t.Fatalf("failing: %v", err)
+	}
+	checkDenied(t, s.Check(ctx, "uid-1", ""), ErrLocked, testPolicy.LockDuration)
+	checkDenied(t, s.Check(ctx, "", "192.0.2.1"), ErrLocked, testPolicy.LockDuration)
+
+	// The subjects of another tenant are apart.
+	other := web.SetValues(ctx, &web.Values{TenantID: "tenant-a"})
+	if err := s.Check(other, "uid-1", "192.0.2.1"); err != nil {
+		t.Fatalf("got error %v in another tenant, want none", err)
+	}
+
+	if err := s.Unlock(ctx, Subject{UID: "uid-1", IP: "192.0.2.1"}); err != nil {
+		t.Fatalf("unlocking: %v", err)
+	}
+	if err := s.Check(ctx, "uid-1", "192.0.2.1"); err != nil {
+		t.Fatalf("got error %v after the unlock, want none", err)
+	}
+
+	want := []string{"lockout.lock", "lockout.lock", "lockout.unlock", "lockout.unlock"}
+	if got := a.actions(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got actions %v, want %v", got, want)
+	}
+}
+
+func TestSucceedKeepsIPFailures(t *testing.T) {
+	s := NewService(NewMemory(), testPolicy, testPolicy, &fakeAuditor{})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := s.Fail(ctx, "uid-1", "192.0.2.1"); err != nil {
+			t.Fatalf("failing: %v", err)
+		}
+	}
+	if err := s.Succeed(ctx, "uid-1"); err != nil {
+		t.Fatalf("succeeding: %v", err)
+	}
+
+	if err := s.Check(ctx, "uid-1", ""); err != nil {
+		t.Fatalf("got error %v for the uid, want its failures cleared", err)
+	}
+	checkDenied(t, s.Check(ctx, "", "192.0.2.1"), ErrThrottled, testPolicy.BaseDelay)
+}
+
+func TestConcurrentFailures(t *testing.T) {
+	p := testPolicy
+	p.MaxAttempts = 1000
+
+	store := NewMemory()
+	s := NewService(store, p, p, &fakeAuditor{})
+	ctx := context.Background()
+
+	const workers = 20
+	const failures = 10
+
+	t.Run("group", func(t *testing.T) {
+		for i := 0; i < workers; i++ {
+			t.Run("worker", func(t *testing.T) {
+				t.Parallel()
+
+				for j := 0; j < failures; j++ {
+					if err := s.Fail(ctx, "", "192.0.2.1"); err != nil {
+						t.Errorf("failing: %v", err)
+					}
+					s.Check(ctx, "uid-1", "192.0.2.1")
+					if err := s.Unlock(ctx, Subject{UID: "uid-1"}); err != nil {
+						t.Errorf("unlocking: %v", err)
+					}
+				}
+			})
+		}
+	})
+
+	// Every failure is counted, none is lost to a concurrent one.
+	r, err := store.Get(ctx, key("", kindIP, "192.0.2.1"))
+	if err != nil {
+		t.Fatalf("reading record: %v", err)
+	}
+	if len(r.Failures) != workers*failures {
+		t.Fatalf("got %d failures, want %d", len(r.Failures), workers*failures)
+	}
+}
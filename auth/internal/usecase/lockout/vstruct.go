@@ -0,0 +1,82 @@
+package lockout
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Policy reprezents how failures of a kind of subject are handled.
+type Policy struct {
+	// Window is the sliding window in which failures are counted.
+	Window time.Duration
+	// FreeAttempts is the number of failures allowed without delay. Past
+	// it, the delay before the next attempt doubles from BaseDelay up to
+	// MaxDelay.
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// MaxAttempts is the number of failures that locks the subject out
+	// for LockDuration.
+	MaxAttempts  int
+	LockDuration time.Duration
+}
+
+// NewPolicy creates a new Policy that is in a valid state.
+func NewPolicy(window time.Duration, freeAttempts int, baseDelay time.Duration, maxDelay time.Duration, maxAttempts int, lockDuration time.Duration) (Policy, error) {
+	switch {
+	case window <= 0:
+		return Policy{}, fmt.Errorf("window must be positive")
+	case freeAttempts < 0:
+		return Policy{}, fmt.Errorf("free attempts can't be negative")
+	case baseDelay <= 0 || maxDelay < baseDelay:
+		return Policy{}, fmt.Errorf("delays must be positive and max delay at least base delay")
+	case maxAttempts <= freeAttempts:
+		return Policy{}, fmt.Errorf("max attempts must be greater than free attempts")
+	case lockDuration <= 0:
+		return Policy{}, fmt.Errorf("lock duration must be positive")
+	}
+
+	return Policy{
+		Window:       window,
+		FreeAttempts: freeAttempts,
+		BaseDelay:    baseDelay,
+		MaxDelay:     maxDelay,
+		MaxAttempts:  maxAttempts,
+		LockDuration: lockDuration,
+	}, nil
+}
+
+// delay returns how long to wait after the given number of failures.
+func (p Policy) delay(failures int) time.Duration {
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		d *= 2
+		if d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return d
+}
+
+// Subject reprezents the uid and/or ip address an unlock applies to.
+type Subject struct {
+	UID string
+	IP  string
+}
+
+// NewSubject creates a new Subject that is in a valid state.
+func NewSubject(uid string, ip string) (Subject, error) {
+	if uid == "" && ip == "" {
+		return Subject{}, fmt.Errorf("uid or ip must be provided")
+	}
+	if ip != "" && net.ParseIP(ip) == nil {
+		return Subject{}, fmt.Errorf("ip must be a valid ip address")
+	}
+
+	return Subject{UID: uid, IP: ip}, nil
+}
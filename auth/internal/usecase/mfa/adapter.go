@@ -0,0 +1,284 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// enrollResponseDto represents the enroll payload response contract.
+type enrollResponseDto struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// confirmRequestDto represents the confirm payload request contract.
+type confirmRequestDto struct {
+	Code string `json:"code"`
+}
+
+// confirmResponseDto represents the confirm payload response contract.
+type confirmResponseDto struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// Handlers holds the http handlers of the mfa use case.
+type Handlers struct {
+	Enroll  web.Handler
+	Confirm web.Handler
+}
+
+// (Adapter) HttpHandlers transforms the "mfa http requests" into "calls on mfa core service".
+func HttpHandlers(s Service) Handlers {
+	return Handlers{
+		Enroll:  enrollHandler(s),
+		Confirm: confirmHandler(s),
+	}
+}
+
+func enrollHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		account := claims.Email
+		if account == "" {
+			account = claims.UID
+		}
+
+		o, err := s.Enroll(ctx, claims.UID, account)
+		if err != nil {
+			if errors.Is(err, ErrAlreadyEnrolled) {
+				return webapp.NewRequestError(err, http.StatusConflict)
+			}
+			return fmt.Errorf("unable to enroll: %w", err)
+		}
+
+		resp := enrollResponseDto{
+			Secret: o.Secret,
+			URI:    o.URI,
+		}
+		return web.Respond(ctx, w, resp, http.StatusCreated)
+	}
+}
+
+func confirmHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		// decode payload
+		var reqDto confirmRequestDto
+		if err := web.Decode(r, &reqDto); err != nil {
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+		}
+		code, err := NewCode(reqDto.Code)
+		if err != nil {
+			return webapp.NewRequestError(fmt.Errorf("invalid payload: %w", err), http.StatusBadRequest)
+		}
+
+		// business logic
+		codes, err := s.Confirm(ctx, claims.UID, code)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidCode):
+				return webapp.NewRequestError(err, http.StatusUnauthorized)
+			case errors.Is(err, ErrNotEnrolled):
+				return webapp.NewRequestError(err, http.StatusNotFound)
+			case errors.Is(err, ErrAlreadyEnrolled):
+				return webapp.NewRequestError(err, http.StatusConflict)
+			}
+			return fmt.Errorf("unable to confirm enrolment: %w", err)
+		}
+
+		// send response
+		resp := confirmResponseDto{
+			RecoveryCodes: codes,
+		}
+		return web.Respond(ctx, w, resp, http.StatusOK)
+	}
+}
+
+// (Adapter) Memory transforms a "store call" into an "in-memory map operation".
+// The enrolments are lost on restart and are not shared between instances,
+// it is meant for development.
+type Memory struct {
+	mu         sync.RWMutex
+	enrolments map[string]enrolment
+}
+
+// NewMemory creates an empty in-memory enrolments store.
+func NewMemory() *Memory {
+	return &Memory{
+		enrolments: make(map[string]enrolment),
+	}
+}
+
+// Get returns the enrolment of the user of the tenant of the request or ErrNotEnrolled.
+func (m *Memory) Get(ctx context.Context, uid string) (enrolment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, ok := m.enrolments[storeKey(web.GetTenantID(ctx), uid)]
+	if !ok {
+		return enrolment{}, ErrNotEnrolled
+	}
+	return copyEnrolment(e), nil
+}
+
+// Update runs fn on the enrolment of the user of the tenant of the request,
+// a zero one with the uid and the tenant when there is none, and stores the
+// result unless fn fails.
+func (m *Memory) Update(ctx context.Context, uid string, fn func(e *enrolment) error) error {
+	if uid == "" {
+		return fmt.Errorf("uid must be a non-empty string")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenantID := web.GetTenantID(ctx)
+	key := storeKey(tenantID, uid)
+
+	e, ok := m.enrolments[key]
+	if !ok {
+		e = enrolment{UID: uid, TenantID: tenantID}
+	}
+	e = copyEnrolment(e)
+	if err := fn(&e); err != nil {
+		return err
+	}
+
+	m.enrolments[key] = copyEnrolment(e)
+	return nil
+}
+
+// copyEnrolment copies the slices so callers can't change the stored values.
+func copyEnrolment(e enrolment) enrolment {
+	e.Secret = append([]byte(nil), e.Secret...)
+	e.RecoveryCodes = append([]string(nil), e.RecoveryCodes...)
+	return e
+}
+
+// enrolmentDoc represents the firestore document of an enrolment.
+type enrolmentDoc struct {
+	TenantID      string   `firestore:"tenantId"`
+	Secret        []byte   `firestore:"secret"`
+	Confirmed     bool     `firestore:"confirmed"`
+	RecoveryCodes []string `firestore:"recoveryCodes"`
+	LastStep      int64    `firestore:"lastStep"`
+}
+
+// (Adapter) Firestore transforms a "store call" into a "firestore document operation".
+// The enrolments are the documents of the collection, by tenant and uid.
+type Firestore struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+// NewFirestore creates an enrolments store on the collection.
+func NewFirestore(client *firestore.Client, collection string) *Firestore {
+	return &Firestore{
+		client:     client,
+		collection: client.Collection(collection),
+	}
+}
+
+// Get returns the enrolment of the user of the tenant of the request or ErrNotEnrolled.
+func (f *Firestore) Get(ctx context.Context, uid string) (enrolment, error) {
+	if err := checkDocID(uid); err != nil {
+		return enrolment{}, err
+	}
+
+	snap, err := f.collection.Doc(storeKey(web.GetTenantID(ctx), uid)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return enrolment{}, ErrNotEnrolled
+		}
+		return enrolment{}, fmt.Errorf("reading enrolment: %w", err)
+	}
+
+	return fromEnrolmentSnapshot(uid, snap)
+}
+
+// Update runs fn on the enrolment of the user of the tenant of the request,
+// a zero one with the uid and the tenant when there is none, and stores the
+// result unless fn fails. The read and the write are one transaction, of
+// two concurrent updates the second one runs again on the result of the
+// first, so a code is accepted once even by concurrent instances.
+func (f *Firestore) Update(ctx context.Context, uid string, fn func(e *enrolment) error) error {
+	if err := checkDocID(uid); err != nil {
+		return err
+	}
+
+	tenantID := web.GetTenantID(ctx)
+	ref := f.collection.Doc(storeKey(tenantID, uid))
+
+	return f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		e := enrolment{UID: uid, TenantID: tenantID}
+
+		snap, err := tx.Get(ref)
+		switch {
+		case err == nil:
+			if e, err = fromEnrolmentSnapshot(uid, snap); err != nil {
+				return err
+			}
+		case status.Code(err) != codes.NotFound:
+			return fmt.Errorf("reading enrolment: %w", err)
+		}
+
+		if err := fn(&e); err != nil {
+			return err
+		}
+
+		doc := enrolmentDoc{
+			TenantID:      e.TenantID,
+			Secret:        e.Secret,
+			Confirmed:     e.Confirmed,
+			RecoveryCodes: e.RecoveryCodes,
+			LastStep:      e.LastStep,
+		}
+		return tx.Set(ref, doc)
+	})
+}
+
+// fromEnrolmentSnapshot maps the document back to the enrolment.
+func fromEnrolmentSnapshot(uid string, snap *firestore.DocumentSnapshot) (enrolment, error) {
+	var doc enrolmentDoc
+	if err := snap.DataTo(&doc); err != nil {
+		return enrolment{}, fmt.Errorf("decoding enrolment: %w", err)
+	}
+
+	e := enrolment{
+		UID:           uid,
+		TenantID:      doc.TenantID,
+		Secret:        doc.Secret,
+		Confirmed:     doc.Confirmed,
+		RecoveryCodes: doc.RecoveryCodes,
+		LastStep:      doc.LastStep,
+	}
+	return e, nil
+}
+
+// checkDocID checks the uid can name a document. A colon would let the
+// uid of a user of the project name the document of a tenant user.
+func checkDocID(uid string) error {
+	if uid == "" || strings.ContainsAny(uid, "/:") {
+		return fmt.Errorf("uid must be a non-empty string without slashes or colons")
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+// Package mfa contains all the components needed to
+// fulfill the TOTP multi-factor authentication use case.
+package mfa
@@ -0,0 +1,31 @@
+package mfa
+
+// enrolment represents a domain entity.
+type enrolment struct {
+	UID string
+	// TenantID is the tenant of the user, empty for the project.
+	TenantID string
+	// Secret is the TOTP secret sealed with the encryption key.
+	Secret    []byte
+	Confirmed bool
+	// RecoveryCodes holds the hashes of the unused recovery codes.
+	RecoveryCodes []string
+	// LastStep is the time step of the last accepted code, used to reject replays.
+	LastStep int64
+}
+
+// storeKey returns the storage key of an enrolment. The enrolments of
+// every tenant are apart, the ones of the project keep the uid. The
+// separator is not a slash, the keys name firestore documents.
+func storeKey(tenantID string, uid string) string {
+	if tenantID == "" {
+		return uid
+	}
+	return tenantID + ":" + uid
+}
+
+// offer represents what the user needs to configure an authenticator app.
+type offer struct {
+	Secret string
+	URI    string
+}
@@ -0,0 +1,14 @@
+package mfa
+
+import "errors"
+
+var (
+	// ErrNotEnrolled is used when the user has no TOTP enrolment.
+	ErrNotEnrolled = errors.New("user not enrolled")
+
+	// ErrAlreadyEnrolled is used when the user already confirmed a TOTP enrolment.
+	ErrAlreadyEnrolled = errors.New("user already enrolled")
+
+	// ErrInvalidCode is used when the code is not valid for the user.
+	ErrInvalidCode = errors.New("invalid code")
+)
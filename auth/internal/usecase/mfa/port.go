@@ -0,0 +1,23 @@
+package mfa
+
+import (
+	"context"
+)
+
+// (Port) Service defines how the interaction between the "core" and the "mfa http handlers" has to be done.
+type Service interface {
+	// Enroll generates a new TOTP secret for the user.
+	Enroll(ctx context.Context, uid string, account string) (offer, error)
+	// Confirm activates the enrolment with a first code and returns the recovery codes.
+	Confirm(ctx context.Context, uid string, code Code) ([]string, error)
+}
+
+// (Port) Store defines how the interaction between the "core" and the "enrolments storage" has to be done.
+type Store interface {
+	// Get returns the enrolment of the user of the tenant of the request or ErrNotEnrolled.
+	Get(ctx context.Context, uid string) (enrolment, error)
+	// Update runs fn on the enrolment of the user of the tenant of the request,
+	// a zero one with the uid and the tenant when there is none, and stores
+	// the result unless fn fails. The read and the write are atomic.
+	Update(ctx context.Context, uid string, fn func(e *enrolment) error) error
+}
@@ -0,0 +1,229 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/secretbox"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/totp"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// recoveryCodes is the number of recovery codes generated on confirmation.
+const recoveryCodes = 10
+
+// skew is the number of adjacent time steps accepted to allow for clock drift.
+const skew = 1
+
+// Service represents "mfa" core service.
+type service struct {
+	store  Store
+	box    *secretbox.Box
+	issuer string
+}
+
+// NewService creates a "mfa core service" with the necessary dependencies.
+func NewService(store Store, box *secretbox.Box, issuer string) *service {
+	return &service{store: store, box: box, issuer: issuer}
+}
+
+// Enroll generates a new TOTP secret for the user. The enrolment stays
+// inactive until it is confirmed with a first code.
+func (s *service) Enroll(ctx context.Context, uid string, account string) (offer, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return offer{}, fmt.Errorf("mfa: %w", err)
+	}
+	sealed, err := s.box.Seal([]byte(secret))
+	if err != nil {
+		return offer{}, fmt.Errorf("mfa: %w", err)
+	}
+
+	err = s.update(ctx, uid, func(e *enrolment) error {
+		if e.Confirmed {
+			return ErrAlreadyEnrolled
+		}
+		*e = enrolment{UID: uid, TenantID: e.TenantID, Secret: sealed}
+		return nil
+	})
+	if err != nil {
+		return offer{}, err
+	}
+
+	return offer{
+		Secret: secret,
+		URI:    totp.URI(s.issuer, account, secret),
+	}, nil
+}
+
+// Confirm activates the enrolment with a first code and returns the recovery
+// codes. The recovery codes are only returned once, just their hashes are kept.
+func (s *service) Confirm(ctx context.Context, uid string, code Code) ([]string, error) {
+	codes := make([]string, recoveryCodes)
+	hashes := make([]string, recoveryCodes)
+	for i := range codes {
+		c, err := newRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("mfa: %w", err)
+		}
+		codes[i], hashes[i] = c, hashRecoveryCode(c)
+	}
+
+	err := s.update(ctx, uid, func(e *enrolment) error {
+		switch {
+		case len(e.Secret) == 0:
+			return ErrNotEnrolled
+		case e.Confirmed:
+			return ErrAlreadyEnrolled
+		}
+
+		step, err := s.validate(*e, code)
+		if err != nil {
+			return err
+		}
+
+		e.RecoveryCodes = hashes
+		e.Confirmed = true
+		e.LastStep = step
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Enrolled reports if the user has a confirmed enrolment.
+func (s *service) Enrolled(ctx context.Context, uid string) (bool, error) {
+	e, err := s.get(ctx, uid)
+	if err != nil {
+		if errors.Is(err, ErrNotEnrolled) {
+			return false, nil
+		}
+		return false, fmt.Errorf("mfa: %w", err)
+	}
+	return e.Confirmed, nil
+}
+
+// Verify reports if the code is valid for an enrolled user. The code is either
+// a TOTP code, which can't be reused, or a recovery code, which is consumed.
+// The check and the write are atomic in the store, so of two requests with
+// the same code, even on different instances, the second one is invalid.
+func (s *service) Verify(ctx context.Context, uid string, code string) (bool, error) {
+	c, err := NewCode(code)
+	if err != nil {
+		return false, nil
+	}
+
+	err = s.update(ctx, uid, func(e *enrolment) error {
+		if !e.Confirmed {
+			return ErrNotEnrolled
+		}
+
+		step, err := s.validate(*e, c)
+		switch {
+		case err == nil:
+			if step <= e.LastStep {
+				return ErrInvalidCode
+			}
+			e.LastStep = step
+			return nil
+
+		case !errors.Is(err, ErrInvalidCode):
+			return err
+		}
+
+		hash := hashRecoveryCode(string(c))
+		for i, rc := range e.RecoveryCodes {
+			if subtle.ConstantTimeCompare([]byte(rc), []byte(hash)) == 1 {
+				e.RecoveryCodes = append(e.RecoveryCodes[:i], e.RecoveryCodes[i+1:]...)
+				return nil
+			}
+		}
+		return ErrInvalidCode
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// get returns the enrolment of the user of the tenant of the request.
+func (s *service) get(ctx context.Context, uid string) (enrolment, error) {
+	e, err := s.store.Get(ctx, uid)
+	if err != nil {
+		return enrolment{}, err
+	}
+
+	// The enrolments of another tenant are reported as missing, their
+	// users are not the ones of the tenant of the request.
+	if e.TenantID != web.GetTenantID(ctx) {
+		return enrolment{}, ErrNotEnrolled
+	}
+	return e, nil
+}
+
+// update applies fn to the enrolment of the user of the tenant of the
+// request. The errors of the use case are returned as they are.
+func (s *service) update(ctx context.Context, uid string, fn func(e *enrolment) error) error {
+	tenantID := web.GetTenantID(ctx)
+
+	err := s.store.Update(ctx, uid, func(e *enrolment) error {
+		// The enrolments of another tenant are reported as missing, their
+		// users are not the ones of the tenant of the request.
+		if e.TenantID != tenantID {
+			return ErrNotEnrolled
+		}
+		return fn(e)
+	})
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNotEnrolled), errors.Is(err, ErrAlreadyEnrolled), errors.Is(err, ErrInvalidCode):
+		return err
+	}
+	return fmt.Errorf("mfa: %w", err)
+}
+
+// validate checks a TOTP code against the sealed secret of the enrolment.
+func (s *service) validate(e enrolment, code Code) (int64, error) {
+	secret, err := s.box.Open(e.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("opening secret: %w", err)
+	}
+
+	step, err := totp.Validate(string(secret), string(code), time.Now(), skew)
+	if err != nil {
+		return 0, ErrInvalidCode
+	}
+	return step, nil
+}
+
+// newRecoveryCode generates a random code formatted as xxxxx-xxxxx.
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+// hashRecoveryCode returns the hash under which a recovery code is stored.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,155 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/secretbox"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/totp"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+const testUID = "uid-1"
+
+// newTestService constructs the service on a memory store.
+func newTestService(t *testing.T) *service {
+	t.Helper()
+
+	key, err := secretbox.NewKey()
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	box, err := secretbox.New(key)
+	if err != nil {
+		t.Fatalf("constructing box: %v", err)
+	}
+	return NewService(NewMemory(), box, "go-tickets")
+}
+
+// enroll enrolls the test user and returns the secret, the step of the
+// confirmation code and the recovery codes.
+func enroll(t *testing.T, ctx context.Context, s *service) (string, int64, []string) {
+	t.Helper()
+
+	o, err := s.Enroll(ctx, testUID, "ana@example.com")
+	if err != nil {
+		t.Fatalf("enrolling: %v", err)
+	}
+
+	step := totp.Step(time.Now())
+	code, err := totp.Code(o.Secret, step)
+	if err != nil {
+		t.Fatalf("computing code: %v", err)
+	}
+	codes, err := s.Confirm(ctx, testUID, Code(code))
+	if err != nil {
+		t.Fatalf("confirming: %v", err)
+	}
+	return o.Secret, step, codes
+}
+
+func TestVerifyReplay(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	secret, step, _ := enroll(t, ctx, s)
+
+	// The code of the confirmation can't be used again.
+	confirmed, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatalf("computing code: %v", err)
+	}
+	if ok, err := s.Verify(ctx, testUID, confirmed); err != nil || ok {
+		t.Fatalf("got %t and error %v for the confirmation code, want it rejected", ok, err)
+	}
+
+	next, err := totp.Code(secret, step+1)
+	if err != nil {
+		t.Fatalf("computing code: %v", err)
+	}
+	if ok, err := s.Verify(ctx, testUID, next); err != nil || !ok {
+		t.Fatalf("got %t and error %v for the next code, want it accepted", ok, err)
+	}
+	if ok, err := s.Verify(ctx, testUID, next); err != nil || ok {
+		t.Fatalf("got %t and error %v for the replayed code, want it rejected", ok, err)
+	}
+}
+
+func TestVerifyRecoveryCode(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	_, _, codes := enroll(t, ctx, s)
+
+	if len(codes) != recoveryCodes {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodes)
+	}
+
+	if ok, err := s.Verify(ctx, testUID, codes[0]); err != nil || !ok {
+		t.Fatalf("got %t and error %v, want the recovery code accepted", ok, err)
+	}
+	if ok, err := s.Verify(ctx, testUID, codes[0]); err != nil || ok {
+		t.Fatalf("got %t and error %v, want the used recovery code rejected", ok, err)
+	}
+
+	e, err := s.store.Get(ctx, testUID)
+	if err != nil {
+		t.Fatalf("reading enrolment: %v", err)
+	}
+	if len(e.RecoveryCodes) != recoveryCodes-1 {
+		t.Fatalf("got %d recovery codes left, want %d", len(e.RecoveryCodes), recoveryCodes-1)
+	}
+
+	// The other codes are still valid, in any case.
+	if ok, err := s.Verify(ctx, testUID, " "+codes[1]+" "); err != nil || !ok {
+		t.Fatalf("got %t and error %v, want the next recovery code accepted", ok, err)
+	}
+}
+
+func TestVerifyRecoveryCodeConcurrent(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	_, _, codes := enroll(t, ctx, s)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ok, err := s.Verify(ctx, testUID, codes[0])
+			if err != nil {
+				t.Errorf("verifying: %v", err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 1 {
+		t.Fatalf("got the recovery code accepted %d times, want once", accepted)
+	}
+}
+
+func TestVerifyOtherTenant(t *testing.T) {
+	s := newTestService(t)
+	_, _, codes := enroll(t, web.SetValues(context.Background(), &web.Values{TenantID: "tenant-a"}), s)
+
+	ctx := web.SetValues(context.Background(), &web.Values{TenantID: "tenant-b"})
+	if ok, err := s.Enrolled(ctx, testUID); err != nil || ok {
+		t.Fatalf("got %t and error %v, want the user not enrolled in another tenant", ok, err)
+	}
+	if _, err := s.Verify(ctx, testUID, codes[0]); !errors.Is(err, ErrNotEnrolled) {
+		t.Fatalf("got error %v, want %v", err, ErrNotEnrolled)
+	}
+}
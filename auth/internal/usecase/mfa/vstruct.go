@@ -0,0 +1,19 @@
+package mfa
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Code reprezents a "value object" inside domain. It is either a TOTP code
+// or a recovery code.
+type Code string
+
+// NewCode creates a new Code that is in a valid state.
+func NewCode(code string) (Code, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", fmt.Errorf("code must be a non-empty string")
+	}
+	return Code(code), nil
+}
@@ -0,0 +1,690 @@
+package passkey
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	fbauthn "firebase.google.com/go/v4/auth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/memauth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/webauthn"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rpDto, userDto and the other dtos mirror the WebAuthn JSON contract
+// where binary values are base64url encoded.
+type rpDto struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userDto struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credParamDto struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type credDescriptorDto struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type authenticatorSelectionDto struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// registrationOptionsDto represents the PublicKeyCredentialCreationOptions response contract.
+type registrationOptionsDto struct {
+	Challenge              string                    `json:"challenge"`
+	RP                     rpDto                     `json:"rp"`
+	User                   userDto                   `json:"user"`
+	PubKeyCredParams       []credParamDto            `json:"pubKeyCredParams"`
+	Timeout                int64                     `json:"timeout"`
+	Attestation            string                    `json:"attestation"`
+	ExcludeCredentials     []credDescriptorDto       `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelectionDto `json:"authenticatorSelection"`
+}
+
+// registrationOptionsToDto transforms registration options domain struct into response (dto).
+func registrationOptionsToDto(o registrationOptions) registrationOptionsDto {
+	dto := registrationOptionsDto{
+		Challenge: encode(o.Challenge),
+		RP: rpDto{
+			ID:   o.RPID,
+			Name: o.RPName,
+		},
+		User: userDto{
+			ID:          encode([]byte(o.UID)),
+			Name:        o.Name,
+			DisplayName: o.DisplayName,
+		},
+		Timeout:            o.Timeout.Milliseconds(),
+		Attestation:        "none",
+		ExcludeCredentials: []credDescriptorDto{},
+		AuthenticatorSelection: authenticatorSelectionDto{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+	}
+	for _, alg := range o.Algorithms {
+		dto.PubKeyCredParams = append(dto.PubKeyCredParams, credParamDto{Type: "public-key", Alg: alg})
+	}
+	for _, id := range o.Exclude {
+		dto.ExcludeCredentials = append(dto.ExcludeCredentials, credDescriptorDto{Type: "public-key", ID: encode(id)})
+	}
+	return dto
+}
+
+// signInOptionsDto represents the PublicKeyCredentialRequestOptions response contract.
+type signInOptionsDto struct {
+	Challenge        string `json:"challenge"`
+	RPID             string `json:"rpId"`
+	Timeout          int64  `json:"timeout"`
+	UserVerification string `json:"userVerification"`
+}
+
+// signInOptionsToDto transforms sign-in options domain struct into response (dto).
+func signInOptionsToDto(o signInOptions) signInOptionsDto {
+	return signInOptionsDto{
+		Challenge:        encode(o.Challenge),
+		RPID:             o.RPID,
+		Timeout:          o.Timeout.Milliseconds(),
+		UserVerification: "required",
+	}
+}
+
+// attestationRequestDto represents the registration payload request contract.
+type attestationRequestDto struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+	ClientExtensionResults  json.RawMessage `json:"clientExtensionResults"`
+	AuthenticatorAttachment string          `json:"authenticatorAttachment"`
+}
+
+// dtoToAttestation transforms registration payload (dto) into attestation domain struct.
+func dtoToAttestation(dto attestationRequestDto) (Attestation, error) {
+	clientData, err := decode(dto.Response.ClientDataJSON)
+	if err != nil {
+		return Attestation{}, fmt.Errorf("clientDataJSON: %w", err)
+	}
+	attObj, err := decode(dto.Response.AttestationObject)
+	if err != nil {
+		return Attestation{}, fmt.Errorf("attestationObject: %w", err)
+	}
+	return NewAttestation(clientData, attObj)
+}
+
+// assertionRequestDto represents the sign-in payload request contract.
+type assertionRequestDto struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+	ClientExtensionResults  json.RawMessage `json:"clientExtensionResults"`
+	AuthenticatorAttachment string          `json:"authenticatorAttachment"`
+}
+
+// dtoToAssertion transforms sign-in payload (dto) into assertion domain struct.
+func dtoToAssertion(dto assertionRequestDto) (Assertion, error) {
+	fields := []struct {
+		name string
+		in   string
+		out  *[]byte
+	}{
+		{"rawId", dto.RawID, new([]byte)},
+		{"clientDataJSON", dto.Response.ClientDataJSON, new([]byte)},
+		{"authenticatorData", dto.Response.AuthenticatorData, new([]byte)},
+		{"signature", dto.Response.Signature, new([]byte)},
+		{"userHandle", dto.Response.UserHandle, new([]byte)},
+	}
+	for _, f := range fields {
+		b, err := decode(f.in)
+		if err != nil {
+			return Assertion{}, fmt.Errorf("%s: %w", f.name, err)
+		}
+		*f.out = b
+	}
+
+	return NewAssertion(*fields[0].out, *fields[1].out, *fields[2].out, *fields[3].out, *fields[4].out)
+}
+
+// credentialResponseDto represents the registered credential response contract.
+type credentialResponseDto struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// encode returns the base64url encoding used by WebAuthn.
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode accepts base64url values with or without padding.
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Handlers holds the http handlers of the passkey use case.
+type Handlers struct {
+	BeginRegistration  web.Handler
+	FinishRegistration web.Handler
+	BeginSignIn        web.Handler
+	FinishSignIn       web.Handler
+}
+
+// (Adapter) HttpHandlers transforms the "passkey http requests" into "calls on passkey core service".
+func HttpHandlers(s Service, cookies auth.Cookies) Handlers {
+	return Handlers{
+		BeginRegistration:  beginRegistrationHandler(s),
+		FinishRegistration: finishRegistrationHandler(s),
+		BeginSignIn:        beginSignInHandler(s),
+		FinishSignIn:       finishSignInHandler(s, cookies),
+	}
+}
+
+func beginRegistrationHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		name := claims.Email
+		if name == "" {
+			name = claims.UID
+		}
+
+		opts, err := s.BeginRegistration(ctx, claims.UID, name, name)
+		if err != nil {
+			return fmt.Errorf("unable to begin registration: %w", err)
+		}
+
+		return web.Respond(ctx, w, registrationOptionsToDto(opts), http.StatusOK)
+	}
+}
+
+func finishRegistrationHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		// decode payload
+		var reqDto attestationRequestDto
+		if err := web.Decode(r, &reqDto); err != nil {
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+		}
+		att, err := dtoToAttestation(reqDto)
+		if err != nil {
+			return webapp.NewRequestError(fmt.Errorf("invalid payload: %w", err), http.StatusBadRequest)
+		}
+
+		// business logic
+		cred, err := s.FinishRegistration(ctx, claims.UID, att)
+		if err != nil {
+			return toRequestError(err, "unable to finish registration")
+		}
+
+		// send response
+		resp := credentialResponseDto{
+			ID:        encode(cred.ID),
+			CreatedAt: cred.CreatedAt.Format(time.RFC3339),
+		}
+		return web.Respond(ctx, w, resp, http.StatusCreated)
+	}
+}
+
+func beginSignInHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		opts, err := s.BeginSignIn(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to begin sign in: %w", err)
+		}
+
+		return web.Respond(ctx, w, signInOptionsToDto(opts), http.StatusOK)
+	}
+}
+
+func finishSignInHandler(s Service, cookies auth.Cookies) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		// decode payload
+		var reqDto assertionRequestDto
+		if err := web.Decode(r, &reqDto); err != nil {
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+		}
+		a, err := dtoToAssertion(reqDto)
+		if err != nil {
+			return webapp.NewRequestError(fmt.Errorf("invalid payload: %w", err), http.StatusBadRequest)
+		}
+
+		// business logic
+		ses, err := s.FinishSignIn(ctx, a)
+		if err != nil {
+			return toRequestError(err, "unable to sign in")
+		}
+
+		// Generate session cookie
+		cookies.SetSession(w, ses.Value, ses.ExpiresIn)
+
+		status := struct {
+			Status string
+		}{
+			Status: "Success",
+		}
+
+		return web.Respond(ctx, w, status, http.StatusOK)
+	}
+}
+
+// toRequestError maps the known domain errors to request errors.
+func toRequestError(err error, msg string) error {
+	switch {
+	case errors.Is(err, ErrCeremonyNotFound),
+		errors.Is(err, ErrCredentialNotFound),
+		errors.Is(err, ErrVerification):
+		return webapp.NewRequestError(err, http.StatusUnauthorized)
+	case errors.Is(err, ErrDuplicate):
+		return webapp.NewRequestError(err, http.StatusConflict)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// (Adapter) Firebase transforms a "passkey core service call" into a "call on firebase".
+type Firebase struct {
+	sessions *webapp.FirebaseSessions
+}
+
+// NewFirebase sets a firebase authentication client for passkey use case. The
+// web api key is needed to exchange custom tokens for id tokens.
+func NewFirebase(client *fbauthn.Client, apiKey string) *Firebase {
+	return &Firebase{
+		sessions: webapp.NewFirebaseSessions(client, apiKey),
+	}
+}
+
+// Session creates the firebase session cookie of the user.
+func (fb Firebase) Session(ctx context.Context, uid string, expiresIn time.Duration) (session, error) {
+	ctx, done := webapp.StartFirebase(ctx, "passkey.session")
+	defer done()
+
+	value, err := fb.sessions.Mint(ctx, uid, expiresIn)
+	if err != nil {
+		return session{}, err
+	}
+	return session{Value: value, ExpiresIn: expiresIn}, nil
+}
+
+// (Adapter) Memory transforms a "passkey core service call" into a "call on the in-memory authn provider".
+type Memory struct {
+	p *memauth.Provider
+}
+
+// NewMemory sets an in-memory authentication provider for passkey use case.
+func NewMemory(p *memauth.Provider) *Memory {
+	return &Memory{
+		p: p,
+	}
+}
+
+// Session creates the in-memory session cookie of the user.
+func (m Memory) Session(ctx context.Context, uid string, expiresIn time.Duration) (session, error) {
+	value, err := m.p.Session(ctx, uid, expiresIn)
+	if err != nil {
+		return session{}, fmt.Errorf("failed to create a session cookie in memory: %w", err)
+	}
+	return session{Value: value, ExpiresIn: expiresIn}, nil
+}
+
+// (Adapter) MemoryCredentials transforms a "credential store call" into an "in-memory map operation".
+// The credentials are lost on restart and are not shared between instances,
+// it is meant for development.
+type MemoryCredentials struct {
+	mu          sync.RWMutex
+	credentials map[string]credential
+}
+
+// NewMemoryCredentials creates an empty in-memory credentials store.
+func NewMemoryCredentials() *MemoryCredentials {
+	return &MemoryCredentials{
+		credentials: make(map[string]credential),
+	}
+}
+
+// Add stores a new credential or returns ErrDuplicate.
+func (m *MemoryCredentials) Add(ctx context.Context, c credential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := storeKey(c.TenantID, string(c.ID))
+	if _, ok := m.credentials[key]; ok {
+		return ErrDuplicate
+	}
+	m.credentials[key] = c
+	return nil
+}
+
+// ByID returns the credential of the tenant of the request or ErrCredentialNotFound.
+func (m *MemoryCredentials) ByID(ctx context.Context, id []byte) (credential, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.credentials[storeKey(web.GetTenantID(ctx), string(id))]
+	if !ok {
+		return credential{}, ErrCredentialNotFound
+	}
+	return c, nil
+}
+
+// ByUser returns the credentials of the user of the tenant of the request.
+func (m *MemoryCredentials) ByUser(ctx context.Context, uid string) ([]credential, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tenantID := web.GetTenantID(ctx)
+
+	var creds []credential
+	for _, c := range m.credentials {
+		if c.TenantID == tenantID && c.UID == uid {
+			creds = append(creds, c)
+		}
+	}
+	return creds, nil
+}
+
+// UpdateSignCount stores the last sign count of the credential.
+func (m *MemoryCredentials) UpdateSignCount(ctx context.Context, id []byte, signCount uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := storeKey(web.GetTenantID(ctx), string(id))
+	c, ok := m.credentials[key]
+	if !ok {
+		return ErrCredentialNotFound
+	}
+	c.SignCount = signCount
+	m.credentials[key] = c
+	return nil
+}
+
+// (Adapter) MemoryCeremonies transforms a "ceremony store call" into an "in-memory map operation".
+// A ceremony begun on an instance can't be finished on another one, it is
+// meant for development.
+type MemoryCeremonies struct {
+	mu         sync.Mutex
+	ceremonies map[string]ceremony
+}
+
+// NewMemoryCeremonies creates an empty in-memory ceremonies store.
+func NewMemoryCeremonies() *MemoryCeremonies {
+	return &MemoryCeremonies{
+		ceremonies: make(map[string]ceremony),
+	}
+}
+
+// Save stores a pending ceremony. Expired ceremonies are purged on the way.
+func (m *MemoryCeremonies) Save(ctx context.Context, c ceremony) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for k, old := range m.ceremonies {
+		if old.isExpired() {
+			delete(m.ceremonies, k)
+		}
+	}
+	m.ceremonies[string(c.Challenge)] = c
+	return nil
+}
+
+// Take returns and removes the ceremony or returns ErrCeremonyNotFound.
+func (m *MemoryCeremonies) Take(ctx context.Context, challenge []byte) (ceremony, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.ceremonies[string(challenge)]
+	if !ok {
+		return ceremony{}, ErrCeremonyNotFound
+	}
+	delete(m.ceremonies, string(challenge))
+	return c, nil
+}
+
+// credentialDoc represents the firestore document of a credential.
+type credentialDoc struct {
+	TenantID  string    `firestore:"tenantId"`
+	UID       string    `firestore:"uid"`
+	PublicKey []byte    `firestore:"publicKey"`
+	Algorithm int64     `firestore:"algorithm"`
+	SignCount int64     `firestore:"signCount"`
+	CreatedAt time.Time `firestore:"createdAt"`
+}
+
+// (Adapter) FirestoreCredentials transforms a "credential store call" into a "firestore document operation".
+// The credentials are the documents of the collection, by tenant and base64url id.
+type FirestoreCredentials struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+// NewFirestoreCredentials creates a credentials store on the collection.
+func NewFirestoreCredentials(client *firestore.Client, collection string) *FirestoreCredentials {
+	return &FirestoreCredentials{
+		client:     client,
+		collection: client.Collection(collection),
+	}
+}
+
+// Add stores a new credential or returns ErrDuplicate.
+func (f *FirestoreCredentials) Add(ctx context.Context, c credential) error {
+	doc := credentialDoc{
+		TenantID:  c.TenantID,
+		UID:       c.UID,
+		PublicKey: c.PublicKey,
+		Algorithm: c.Algorithm,
+		SignCount: int64(c.SignCount),
+		CreatedAt: c.CreatedAt,
+	}
+	if _, err := f.doc(c.TenantID, c.ID).Create(ctx, doc); err != nil {
+		if status.Code(err) == codes.AlreadyExists {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("writing credential: %w", err)
+	}
+	return nil
+}
+
+// ByID returns the credential of the tenant of the request or ErrCredentialNotFound.
+func (f *FirestoreCredentials) ByID(ctx context.Context, id []byte) (credential, error) {
+	snap, err := f.doc(web.GetTenantID(ctx), id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return credential{}, ErrCredentialNotFound
+		}
+		return credential{}, fmt.Errorf("reading credential: %w", err)
+	}
+	return fromCredentialSnapshot(snap)
+}
+
+// ByUser returns the credentials of the user of the tenant of the request.
+func (f *FirestoreCredentials) ByUser(ctx context.Context, uid string) ([]credential, error) {
+	iter := f.collection.
+		Where("tenantId", "==", web.GetTenantID(ctx)).
+		Where("uid", "==", uid).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var creds []credential
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading credentials: %w", err)
+		}
+
+		c, err := fromCredentialSnapshot(snap)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, c)
+	}
+	return creds, nil
+}
+
+// UpdateSignCount stores the last sign count of the credential. The count
+// only moves forward, so of two concurrent sign-ins with the same count, as
+// from a cloned authenticator, the second one fails.
+func (f *FirestoreCredentials) UpdateSignCount(ctx context.Context, id []byte, signCount uint32) error {
+	ref := f.doc(web.GetTenantID(ctx), id)
+
+	return f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrCredentialNotFound
+			}
+			return fmt.Errorf("reading credential: %w", err)
+		}
+
+		stored, err := snap.DataAt("signCount")
+		if err != nil {
+			return fmt.Errorf("reading sign count: %w", err)
+		}
+		// Authenticators without a counter always report zero.
+		if n, _ := stored.(int64); signCount != 0 && int64(signCount) <= n {
+			return fmt.Errorf("%w: %v", ErrVerification, webauthn.ErrSignCount)
+		}
+
+		return tx.Update(ref, []firestore.Update{{Path: "signCount", Value: int64(signCount)}})
+	})
+}
+
+// doc returns the reference of the document of the credential.
+func (f *FirestoreCredentials) doc(tenantID string, id []byte) *firestore.DocumentRef {
+	return f.collection.Doc(storeKey(tenantID, encode(id)))
+}
+
+// fromCredentialSnapshot maps the document back to the credential.
+func fromCredentialSnapshot(snap *firestore.DocumentSnapshot) (credential, error) {
+	var doc credentialDoc
+	if err := snap.DataTo(&doc); err != nil {
+		return credential{}, fmt.Errorf("decoding credential: %w", err)
+	}
+
+	id, err := decode(strings.TrimPrefix(snap.Ref.ID, storeKey(doc.TenantID, "")))
+	if err != nil {
+		return credential{}, fmt.Errorf("decoding credential id: %w", err)
+	}
+
+	c := credential{
+		ID:        id,
+		TenantID:  doc.TenantID,
+		UID:       doc.UID,
+		PublicKey: doc.PublicKey,
+		Algorithm: doc.Algorithm,
+		SignCount: uint32(doc.SignCount),
+		CreatedAt: doc.CreatedAt,
+	}
+	return c, nil
+}
+
+// ceremonyDoc represents the firestore document of a ceremony. A ttl
+// policy on expiresAt purges the ceremonies never finished.
+type ceremonyDoc struct {
+	Kind      string    `firestore:"kind"`
+	UID       string    `firestore:"uid"`
+	ExpiresAt time.Time `firestore:"expiresAt"`
+}
+
+// (Adapter) FirestoreCeremonies transforms a "ceremony store call" into a "firestore document operation".
+// The ceremonies are the documents of the collection, by base64url challenge.
+type FirestoreCeremonies struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+// NewFirestoreCeremonies creates a ceremonies store on the collection.
+func NewFirestoreCeremonies(client *firestore.Client, collection string) *FirestoreCeremonies {
+	return &FirestoreCeremonies{
+		client:     client,
+		collection: client.Collection(collection),
+	}
+}
+
+// Save stores a pending ceremony.
+func (f *FirestoreCeremonies) Save(ctx context.Context, c ceremony) error {
+	doc := ceremonyDoc{
+		Kind:      c.Kind,
+		UID:       c.UID,
+		ExpiresAt: c.ExpiresAt,
+	}
+	if _, err := f.collection.Doc(encode(c.Challenge)).Create(ctx, doc); err != nil {
+		return fmt.Errorf("writing ceremony: %w", err)
+	}
+	return nil
+}
+
+// Take returns and removes the ceremony or returns ErrCeremonyNotFound. The
+// read and the delete are one transaction, a challenge is taken once even
+// by concurrent instances.
+func (f *FirestoreCeremonies) Take(ctx context.Context, challenge []byte) (ceremony, error) {
+	ref := f.collection.Doc(encode(challenge))
+
+	var c ceremony
+	err := f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrCeremonyNotFound
+			}
+			return fmt.Errorf("reading ceremony: %w", err)
+		}
+
+		var doc ceremonyDoc
+		if err := snap.DataTo(&doc); err != nil {
+			return fmt.Errorf("decoding ceremony: %w", err)
+		}
+		c = ceremony{
+			Kind:      doc.Kind,
+			Challenge: append([]byte(nil), challenge...),
+			UID:       doc.UID,
+			ExpiresAt: doc.ExpiresAt,
+		}
+
+		return tx.Delete(ref)
+	})
+	if err != nil {
+		return ceremony{}, err
+	}
+	return c, nil
+}
@@ -0,0 +1,3 @@
+// Package passkey contains all the components needed to
+// fulfill the passkey (WebAuthn) registration and sign-in use case.
+package passkey
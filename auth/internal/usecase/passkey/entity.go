@@ -0,0 +1,70 @@
+package passkey
+
+import "time"
+
+// These are the kinds of ceremonies.
+const (
+	ceremonyRegistration = "registration"
+	ceremonySignIn       = "signin"
+)
+
+// credential represents a domain entity.
+type credential struct {
+	ID []byte
+	// TenantID is the tenant of the owner, empty for the project.
+	TenantID  string
+	UID       string
+	PublicKey []byte
+	Algorithm int64
+	SignCount uint32
+	CreatedAt time.Time
+}
+
+// storeKey returns the storage key of a credential. The credentials of
+// every tenant are apart, the ones of the project keep their id. The
+// separator is not a slash, the keys name firestore documents.
+func storeKey(tenantID string, id string) string {
+	if tenantID == "" {
+		return id
+	}
+	return tenantID + ":" + id
+}
+
+// ceremony represents a pending registration or sign-in.
+type ceremony struct {
+	Kind      string
+	Challenge []byte
+	// UID is only known for registrations.
+	UID       string
+	ExpiresAt time.Time
+}
+
+func (c ceremony) isExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// registrationOptions represents what the client needs to create a credential.
+type registrationOptions struct {
+	Challenge   []byte
+	RPID        string
+	RPName      string
+	UID         string
+	Name        string
+	DisplayName string
+	Algorithms  []int64
+	Exclude     [][]byte
+	Timeout     time.Duration
+}
+
+// signInOptions represents what the client needs to get an assertion.
+type signInOptions struct {
+	Challenge []byte
+	RPID      string
+	Timeout   time.Duration
+}
+
+// session represents the session issued after a successful sign-in.
+type session struct {
+	Value     string
+	ExpiresIn time.Duration
+}
@@ -0,0 +1,17 @@
+package passkey
+
+import "errors"
+
+var (
+	// ErrCeremonyNotFound is used when the challenge of a ceremony is unknown or expired.
+	ErrCeremonyNotFound = errors.New("ceremony not found")
+
+	// ErrCredentialNotFound is used when the credential is not registered.
+	ErrCredentialNotFound = errors.New("credential not found")
+
+	// ErrDuplicate is used when the credential is already registered.
+	ErrDuplicate = errors.New("credential already registered")
+
+	// ErrVerification is used when the response of the authenticator is not valid.
+	ErrVerification = errors.New("passkey verification failed")
+)
@@ -0,0 +1,59 @@
+package passkey
+
+import (
+	"context"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+)
+
+// (Port) Service defines how the interaction between the "core" and the "passkey http handlers" has to be done.
+type Service interface {
+	// BeginRegistration creates the challenge used to register a new credential.
+	BeginRegistration(ctx context.Context, uid string, name string, displayName string) (registrationOptions, error)
+	// FinishRegistration verifies the attestation and stores the credential.
+	FinishRegistration(ctx context.Context, uid string, a Attestation) (credential, error)
+	// BeginSignIn creates the challenge used to sign in with a credential.
+	BeginSignIn(ctx context.Context) (signInOptions, error)
+	// FinishSignIn verifies the assertion and returns the session cookie.
+	FinishSignIn(ctx context.Context, a Assertion) (session, error)
+}
+
+// (Port) CredentialStore defines how the interaction between the "core" and the "credentials storage" has to be done.
+type CredentialStore interface {
+	// Add stores a new credential or returns ErrDuplicate.
+	Add(ctx context.Context, c credential) error
+	// ByID returns the credential of the tenant of the request or ErrCredentialNotFound.
+	ByID(ctx context.Context, id []byte) (credential, error)
+	// ByUser returns the credentials of the user of the tenant of the request.
+	ByUser(ctx context.Context, uid string) ([]credential, error)
+	// UpdateSignCount stores the last sign count of the credential.
+	UpdateSignCount(ctx context.Context, id []byte, signCount uint32) error
+}
+
+// (Port) CeremonyStore defines how the interaction between the "core" and the "ceremonies storage" has to be done.
+type CeremonyStore interface {
+	// Save stores a pending ceremony.
+	Save(ctx context.Context, c ceremony) error
+	// Take returns and removes the ceremony or returns ErrCeremonyNotFound.
+	Take(ctx context.Context, challenge []byte) (ceremony, error)
+}
+
+// (Port) AuthnProvider defines how the interaction between the "core" and the "authn provider" has to be done.
+type AuthnProvider interface {
+	// Session creates a new session cookie for the user with the given expiry duration.
+	Session(ctx context.Context, uid string, expiresIn time.Duration) (session, error)
+}
+
+// (Port) Policies defines how the interaction between the "core" and the "tenant policies" has to be done.
+type Policies interface {
+	// Policy returns the policy of the tenant of the request.
+	Policy(ctx context.Context) tenant.Policy
+}
+
+// (Port) Auditor defines how the interaction between the "core" and the "audit log" has to be done.
+type Auditor interface {
+	// Record appends the event to the audit log.
+	Record(ctx context.Context, e audit.Event)
+}
@@ -0,0 +1,248 @@
+package passkey
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/webauthn"
+)
+
+// ceremonyTimeout is how long the client has to answer a challenge.
+const ceremonyTimeout = 5 * time.Minute
+
+// sessionExpiresIn matches the expiration of the sessions issued by signin,
+// the tenants can have their own.
+const sessionExpiresIn = time.Hour * 24 * 2
+
+// Config holds the relying party settings.
+type Config struct {
+	RPID    string
+	RPName  string
+	Origins []string
+}
+
+// Service represents "passkey" core service.
+type service struct {
+	cfg         Config
+	wa          webauthn.Config
+	credentials CredentialStore
+	ceremonies  CeremonyStore
+	ap          AuthnProvider
+	pol         Policies
+	a           Auditor
+}
+
+// NewService creates a "passkey core service" with the necessary dependencies.
+func NewService(cfg Config, credentials CredentialStore, ceremonies CeremonyStore, ap AuthnProvider, pol Policies, a Auditor) *service {
+	return &service{
+		cfg: cfg,
+		// A passkey stands for the password and the second factor, the
+		// user presence alone would let anyone holding the device in.
+		wa: webauthn.Config{
+			RPID:                    cfg.RPID,
+			Origins:                 cfg.Origins,
+			RequireUserVerification: true,
+		},
+		credentials: credentials,
+		ceremonies:  ceremonies,
+		ap:          ap,
+		pol:         pol,
+		a:           a,
+	}
+}
+
+// BeginRegistration creates the challenge used to register a new credential.
+func (s *service) BeginRegistration(ctx context.Context, uid string, name string, displayName string) (registrationOptions, error) {
+	existing, err := s.credentials.ByUser(ctx, uid)
+	if err != nil {
+		return registrationOptions{}, fmt.Errorf("passkey: %w", err)
+	}
+
+	c, err := s.newCeremony(ctx, ceremonyRegistration, uid)
+	if err != nil {
+		return registrationOptions{}, err
+	}
+
+	opts := registrationOptions{
+		Challenge:   c.Challenge,
+		RPID:        s.cfg.RPID,
+		RPName:      s.cfg.RPName,
+		UID:         uid,
+		Name:        name,
+		DisplayName: displayName,
+		Algorithms:  []int64{webauthn.AlgES256, webauthn.AlgRS256},
+		Timeout:     ceremonyTimeout,
+	}
+	for _, cred := range existing {
+		opts.Exclude = append(opts.Exclude, cred.ID)
+	}
+
+	return opts, nil
+}
+
+// FinishRegistration verifies the attestation and stores the credential.
+func (s *service) FinishRegistration(ctx context.Context, uid string, a Attestation) (credential, error) {
+	cred, err := s.finishRegistration(ctx, uid, a)
+	s.audit(ctx, "passkeys.register", uid, cred.ID, err)
+
+	return cred, err
+}
+
+func (s *service) finishRegistration(ctx context.Context, uid string, a Attestation) (credential, error) {
+	c, err := s.takeCeremony(ctx, ceremonyRegistration, a.ClientDataJSON)
+	if err != nil {
+		return credential{}, err
+	}
+	if c.UID != uid {
+		return credential{}, ErrCeremonyNotFound
+	}
+
+	reg, err := s.wa.VerifyRegistration(c.Challenge, a.ClientDataJSON, a.AttestationObject)
+	if err != nil {
+		return credential{}, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+
+	cred := credential{
+		ID:        reg.CredentialID,
+		TenantID:  web.GetTenantID(ctx),
+		UID:       uid,
+		PublicKey: reg.PublicKey,
+		Algorithm: reg.Algorithm,
+		SignCount: reg.SignCount,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.credentials.Add(ctx, cred); err != nil {
+		return credential{}, fmt.Errorf("passkey: %w", err)
+	}
+
+	return cred, nil
+}
+
+// BeginSignIn creates the challenge used to sign in with a discoverable credential.
+func (s *service) BeginSignIn(ctx context.Context) (signInOptions, error) {
+	c, err := s.newCeremony(ctx, ceremonySignIn, "")
+	if err != nil {
+		return signInOptions{}, err
+	}
+
+	return signInOptions{
+		Challenge: c.Challenge,
+		RPID:      s.cfg.RPID,
+		Timeout:   ceremonyTimeout,
+	}, nil
+}
+
+// FinishSignIn verifies the assertion and returns the same session cookie
+// issued by signin, minted for the owner of the credential.
+func (s *service) FinishSignIn(ctx context.Context, a Assertion) (session, error) {
+	c, err := s.takeCeremony(ctx, ceremonySignIn, a.ClientDataJSON)
+	if err != nil {
+		return session{}, err
+	}
+
+	cred, err := s.credentials.ByID(ctx, a.CredentialID)
+	// The credentials of another tenant are reported as missing, their
+	// users are not the ones of the tenant of the request.
+	if err == nil && cred.TenantID != web.GetTenantID(ctx) {
+		err = ErrCredentialNotFound
+	}
+	if err != nil {
+		err = fmt.Errorf("passkey: %w", err)
+		s.audit(ctx, "signin.passkey", "", a.CredentialID, err)
+		return session{}, err
+	}
+
+	ses, err := s.finishSignIn(ctx, c, cred, a)
+	s.audit(ctx, "signin.passkey", cred.UID, cred.ID, err)
+
+	return ses, err
+}
+
+func (s *service) finishSignIn(ctx context.Context, c ceremony, cred credential, a Assertion) (session, error) {
+	if len(a.UserHandle) > 0 && !bytes.Equal(a.UserHandle, []byte(cred.UID)) {
+		return session{}, fmt.Errorf("%w: user handle mismatch", ErrVerification)
+	}
+
+	res, err := s.wa.VerifyAssertion(c.Challenge, cred.PublicKey, cred.SignCount, a.ClientDataJSON, a.AuthenticatorData, a.Signature)
+	if err != nil {
+		return session{}, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+
+	if err := s.credentials.UpdateSignCount(ctx, cred.ID, res.SignCount); err != nil {
+		return session{}, fmt.Errorf("passkey: %w", err)
+	}
+
+	expiresIn := sessionExpiresIn
+	if ttl := s.pol.Policy(ctx).SessionTTL; ttl > 0 {
+		expiresIn = ttl
+	}
+	ses, err := s.ap.Session(ctx, cred.UID, expiresIn)
+	if err != nil {
+		return session{}, fmt.Errorf("passkey: %w", err)
+	}
+	return ses, nil
+}
+
+// audit records the ceremony with the credential used.
+func (s *service) audit(ctx context.Context, action string, uid string, credentialID []byte, err error) {
+	e := audit.Event{
+		Action:  action,
+		Subject: uid,
+	}
+	if len(credentialID) > 0 {
+		e.Details = map[string]string{"credential": base64.RawURLEncoding.EncodeToString(credentialID)}
+	}
+
+	s.a.Record(ctx, e.Failed(err))
+}
+
+// newCeremony stores a new ceremony with a random challenge.
+func (s *service) newCeremony(ctx context.Context, kind string, uid string) (ceremony, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return ceremony{}, fmt.Errorf("passkey: %w", err)
+	}
+
+	c := ceremony{
+		Kind:      kind,
+		Challenge: challenge,
+		UID:       uid,
+		ExpiresAt: time.Now().Add(ceremonyTimeout),
+	}
+	if err := s.ceremonies.Save(ctx, c); err != nil {
+		return ceremony{}, fmt.Errorf("passkey: %w", err)
+	}
+
+	return c, nil
+}
+
+// takeCeremony finds the pending ceremony through the challenge echoed in the client data.
+func (s *service) takeCeremony(ctx context.Context, kind string, clientDataJSON []byte) (ceremony, error) {
+	cd, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return ceremony{}, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	challenge, err := cd.ChallengeBytes()
+	if err != nil {
+		return ceremony{}, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+
+	c, err := s.ceremonies.Take(ctx, challenge)
+	if err != nil {
+		if errors.Is(err, ErrCeremonyNotFound) {
+			return ceremony{}, err
+		}
+		return ceremony{}, fmt.Errorf("passkey: %w", err)
+	}
+	if c.Kind != kind || c.isExpired() {
+		return ceremony{}, ErrCeremonyNotFound
+	}
+
+	return c, nil
+}
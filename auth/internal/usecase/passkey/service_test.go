@@ -0,0 +1,366 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/testsupport/softauthn"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+	testUID    = "uid-1"
+)
+
+// fakeProvider issues the sessions of the passkey sign-ins.
+type fakeProvider struct{}
+
+func (fakeProvider) Session(ctx context.Context, uid string, expiresIn time.Duration) (session, error) {
+	return session{Value: "session-" + uid, ExpiresIn: expiresIn}, nil
+}
+
+// fakePolicies returns the policy of the project.
+type fakePolicies struct{}
+
+func (fakePolicies) Policy(ctx context.Context) tenant.Policy {
+	return tenant.Policy{}
+}
+
+// fakeAuditor keeps the recorded events.
+type fakeAuditor struct {
+	events []audit.Event
+}
+
+func (a *fakeAuditor) Record(ctx context.Context, e audit.Event) {
+	a.events = append(a.events, e)
+}
+
+// tenantContext returns a context with the tenant of a request.
+func tenantContext(tenantID string) context.Context {
+	return web.SetValues(context.Background(), &web.Values{TenantID: tenantID})
+}
+
+// newTestService constructs the service on memory stores and a software
+// authenticator of the test user.
+func newTestService(t *testing.T) (*service, *MemoryCredentials, *softauthn.Authenticator) {
+	t.Helper()
+
+	creds := NewMemoryCredentials()
+	s := NewService(
+		Config{RPID: testRPID, RPName: "go-tickets", Origins: []string{testOrigin}},
+		creds,
+		NewMemoryCeremonies(),
+		fakeProvider{},
+		fakePolicies{},
+		&fakeAuditor{},
+	)
+
+	a, err := softauthn.New(testRPID, testOrigin, []byte(testUID))
+	if err != nil {
+		t.Fatalf("creating authenticator: %v", err)
+	}
+	a.UserVerified = true
+	return s, creds, a
+}
+
+// registerPasskey registers the credential of the authenticator.
+func registerPasskey(t *testing.T, s *service, a *softauthn.Authenticator) credential {
+	t.Helper()
+	return registerPasskeyIn(t, context.Background(), s, a)
+}
+
+// registerPasskeyIn registers the credential of the authenticator in the
+// tenant of the context.
+func registerPasskeyIn(t *testing.T, ctx context.Context, s *service, a *softauthn.Authenticator) credential {
+	t.Helper()
+
+	opts, err := s.BeginRegistration(ctx, testUID, "ana@example.com", "Ana")
+	if err != nil {
+		t.Fatalf("beginning registration: %v", err)
+	}
+	clientDataJSON, attestationObject, err := a.Register(opts.Challenge)
+	if err != nil {
+		t.Fatalf("answering registration: %v", err)
+	}
+
+	cred, err := s.FinishRegistration(ctx, testUID, Attestation{ClientDataJSON: clientDataJSON, AttestationObject: attestationObject})
+	if err != nil {
+		t.Fatalf("finishing registration: %v", err)
+	}
+	return cred
+}
+
+// assertPasskey answers a new sign-in challenge with the authenticator.
+func assertPasskey(t *testing.T, s *service, a *softauthn.Authenticator) Assertion {
+	t.Helper()
+
+	opts, err := s.BeginSignIn(context.Background())
+	if err != nil {
+		t.Fatalf("beginning sign-in: %v", err)
+	}
+	resp, err := a.Assert(opts.Challenge)
+	if err != nil {
+		t.Fatalf("answering assertion: %v", err)
+	}
+
+	return Assertion{
+		CredentialID:      resp.CredentialID,
+		ClientDataJSON:    resp.ClientDataJSON,
+		AuthenticatorData: resp.AuthenticatorData,
+		Signature:         resp.Signature,
+		UserHandle:        resp.UserHandle,
+	}
+}
+
+func TestRegisterAndSignIn(t *testing.T) {
+	s, creds, a := newTestService(t)
+	ctx := context.Background()
+
+	cred := registerPasskey(t, s, a)
+	if cred.UID != testUID {
+		t.Fatalf("got credential of %q, want %q", cred.UID, testUID)
+	}
+
+	// The registered credential is excluded from the next registration.
+	opts, err := s.BeginRegistration(ctx, testUID, "ana@example.com", "Ana")
+	if err != nil {
+		t.Fatalf("beginning registration: %v", err)
+	}
+	if len(opts.Exclude) != 1 || string(opts.Exclude[0]) != string(cred.ID) {
+		t.Fatalf("got excluded %x, want the registered credential", opts.Exclude)
+	}
+
+	ses, err := s.FinishSignIn(ctx, assertPasskey(t, s, a))
+	if err != nil {
+		t.Fatalf("finishing sign-in: %v", err)
+	}
+	if ses.Value != "session-"+testUID || ses.ExpiresIn != sessionExpiresIn {
+		t.Fatalf("got session %+v, want the one of %q", ses, testUID)
+	}
+
+	stored, err := creds.ByID(ctx, cred.ID)
+	if err != nil {
+		t.Fatalf("reading credential: %v", err)
+	}
+	if stored.SignCount != a.SignCount {
+		t.Fatalf("got sign count %d, want %d", stored.SignCount, a.SignCount)
+	}
+}
+
+func TestFinishRegistrationRejects(t *testing.T) {
+	t.Run("bad origin", func(t *testing.T) {
+		s, _, a := newTestService(t)
+		a.Origin = "https://evil.example.com"
+
+		opts, err := s.BeginRegistration(context.Background(), testUID, "ana@example.com", "Ana")
+		if err != nil {
+			t.Fatalf("beginning registration: %v", err)
+		}
+		clientDataJSON, attestationObject, err := a.Register(opts.Challenge)
+		if err != nil {
+			t.Fatalf("answering registration: %v", err)
+		}
+
+		_, err = s.FinishRegistration(context.Background(), testUID, Attestation{ClientDataJSON: clientDataJSON, AttestationObject: attestationObject})
+		if !errors.Is(err, ErrVerification) {
+			t.Fatalf("got error %v, want %v", err, ErrVerification)
+		}
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		s, _, a := newTestService(t)
+
+		opts, err := s.BeginRegistration(context.Background(), testUID, "ana@example.com", "Ana")
+		if err != nil {
+			t.Fatalf("beginning registration: %v", err)
+		}
+		clientDataJSON, attestationObject, err := a.Register(opts.Challenge)
+		if err != nil {
+			t.Fatalf("answering registration: %v", err)
+		}
+
+		_, err = s.FinishRegistration(context.Background(), "uid-2", Attestation{ClientDataJSON: clientDataJSON, AttestationObject: attestationObject})
+		if !errors.Is(err, ErrCeremonyNotFound) {
+			t.Fatalf("got error %v, want %v", err, ErrCeremonyNotFound)
+		}
+	})
+
+	t.Run("user not verified", func(t *testing.T) {
+		s, _, a := newTestService(t)
+		a.UserVerified = false
+
+		opts, err := s.BeginRegistration(context.Background(), testUID, "ana@example.com", "Ana")
+		if err != nil {
+			t.Fatalf("beginning registration: %v", err)
+		}
+		clientDataJSON, attestationObject, err := a.Register(opts.Challenge)
+		if err != nil {
+			t.Fatalf("answering registration: %v", err)
+		}
+
+		_, err = s.FinishRegistration(context.Background(), testUID, Attestation{ClientDataJSON: clientDataJSON, AttestationObject: attestationObject})
+		if !errors.Is(err, ErrVerification) {
+			t.Fatalf("got error %v, want %v", err, ErrVerification)
+		}
+	})
+
+	t.Run("replayed challenge", func(t *testing.T) {
+		s, _, a := newTestService(t)
+
+		opts, err := s.BeginRegistration(context.Background(), testUID, "ana@example.com", "Ana")
+		if err != nil {
+			t.Fatalf("beginning registration: %v", err)
+		}
+		clientDataJSON, attestationObject, err := a.Register(opts.Challenge)
+		if err != nil {
+			t.Fatalf("answering registration: %v", err)
+		}
+		att := Attestation{ClientDataJSON: clientDataJSON, AttestationObject: attestationObject}
+
+		if _, err := s.FinishRegistration(context.Background(), testUID, att); err != nil {
+			t.Fatalf("finishing registration: %v", err)
+		}
+		_, err = s.FinishRegistration(context.Background(), testUID, att)
+		if !errors.Is(err, ErrCeremonyNotFound) {
+			t.Fatalf("got error %v, want %v", err, ErrCeremonyNotFound)
+		}
+	})
+}
+
+func TestFinishSignInRejects(t *testing.T) {
+	t.Run("replayed challenge", func(t *testing.T) {
+		s, _, a := newTestService(t)
+		registerPasskey(t, s, a)
+
+		as := assertPasskey(t, s, a)
+		if _, err := s.FinishSignIn(context.Background(), as); err != nil {
+			t.Fatalf("finishing sign-in: %v", err)
+		}
+
+		_, err := s.FinishSignIn(context.Background(), as)
+		if !errors.Is(err, ErrCeremonyNotFound) {
+			t.Fatalf("got error %v, want %v", err, ErrCeremonyNotFound)
+		}
+	})
+
+	t.Run("registration challenge", func(t *testing.T) {
+		s, _, a := newTestService(t)
+		registerPasskey(t, s, a)
+
+		opts, err := s.BeginRegistration(context.Background(), testUID, "ana@example.com", "Ana")
+		if err != nil {
+			t.Fatalf("beginning registration: %v", err)
+		}
+		resp, err := a.Assert(opts.Challenge)
+		if err != nil {
+			t.Fatalf("answering assertion: %v", err)
+		}
+
+		_, err = s.FinishSignIn(context.Background(), Assertion{
+			CredentialID:      resp.CredentialID,
+			ClientDataJSON:    resp.ClientDataJSON,
+			AuthenticatorData: resp.AuthenticatorData,
+			Signature:         resp.Signature,
+		})
+		if !errors.Is(err, ErrCeremonyNotFound) {
+			t.Fatalf("got error %v, want %v", err, ErrCeremonyNotFound)
+		}
+	})
+
+	t.Run("bad origin", func(t *testing.T) {
+		s, _, a := newTestService(t)
+		registerPasskey(t, s, a)
+		a.Origin = "https://evil.example.com"
+
+		_, err := s.FinishSignIn(context.Background(), assertPasskey(t, s, a))
+		if !errors.Is(err, ErrVerification) {
+			t.Fatalf("got error %v, want %v", err, ErrVerification)
+		}
+	})
+
+	t.Run("sign count regression", func(t *testing.T) {
+		s, creds, a := newTestService(t)
+		cred := registerPasskey(t, s, a)
+
+		if _, err := s.FinishSignIn(context.Background(), assertPasskey(t, s, a)); err != nil {
+			t.Fatalf("finishing sign-in: %v", err)
+		}
+		signCount := a.SignCount
+
+		// A clone of the authenticator still counts from the registration.
+		a.SignCount = 0
+		_, err := s.FinishSignIn(context.Background(), assertPasskey(t, s, a))
+		if !errors.Is(err, ErrVerification) {
+			t.Fatalf("got error %v, want %v", err, ErrVerification)
+		}
+
+		stored, err := creds.ByID(context.Background(), cred.ID)
+		if err != nil {
+			t.Fatalf("reading credential: %v", err)
+		}
+		if stored.SignCount != signCount {
+			t.Fatalf("got sign count %d, want %d", stored.SignCount, signCount)
+		}
+	})
+
+	t.Run("user not verified", func(t *testing.T) {
+		s, _, a := newTestService(t)
+		registerPasskey(t, s, a)
+		a.UserVerified = false
+
+		_, err := s.FinishSignIn(context.Background(), assertPasskey(t, s, a))
+		if !errors.Is(err, ErrVerification) {
+			t.Fatalf("got error %v, want %v", err, ErrVerification)
+		}
+	})
+
+	t.Run("unknown credential", func(t *testing.T) {
+		s, _, a := newTestService(t)
+
+		_, err := s.FinishSignIn(context.Background(), assertPasskey(t, s, a))
+		if !errors.Is(err, ErrCredentialNotFound) {
+			t.Fatalf("got error %v, want %v", err, ErrCredentialNotFound)
+		}
+	})
+
+	t.Run("other user handle", func(t *testing.T) {
+		s, _, a := newTestService(t)
+		registerPasskey(t, s, a)
+
+		as := assertPasskey(t, s, a)
+		as.UserHandle = []byte("uid-2")
+
+		_, err := s.FinishSignIn(context.Background(), as)
+		if !errors.Is(err, ErrVerification) {
+			t.Fatalf("got error %v, want %v", err, ErrVerification)
+		}
+	})
+
+	t.Run("other tenant", func(t *testing.T) {
+		s, creds, a := newTestService(t)
+		cred := registerPasskeyIn(t, tenantContext("tenant-a"), s, a)
+		if cred.TenantID != "tenant-a" {
+			t.Fatalf("got credential of tenant %q, want %q", cred.TenantID, "tenant-a")
+		}
+
+		for _, ctx := range []context.Context{tenantContext("tenant-b"), context.Background()} {
+			_, err := s.FinishSignIn(ctx, assertPasskey(t, s, a))
+			if !errors.Is(err, ErrCredentialNotFound) {
+				t.Fatalf("got error %v in tenant %q, want %v", err, web.GetTenantID(ctx), ErrCredentialNotFound)
+			}
+		}
+		if got, err := creds.ByUser(tenantContext("tenant-b"), testUID); err != nil || len(got) != 0 {
+			t.Fatalf("got credentials %v and error %v in another tenant, want none", got, err)
+		}
+
+		if _, err := s.FinishSignIn(tenantContext("tenant-a"), assertPasskey(t, s, a)); err != nil {
+			t.Fatalf("finishing sign-in in the tenant: %v", err)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package passkey
+
+import (
+	"fmt"
+)
+
+// Attestation reprezents the response of the authenticator to a registration.
+type Attestation struct {
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// NewAttestation creates a new Attestation that is in a valid state.
+func NewAttestation(clientDataJSON []byte, attestationObject []byte) (Attestation, error) {
+	if len(clientDataJSON) == 0 {
+		return Attestation{}, fmt.Errorf("clientDataJSON must be provided")
+	}
+	if len(attestationObject) == 0 {
+		return Attestation{}, fmt.Errorf("attestationObject must be provided")
+	}
+
+	return Attestation{
+		ClientDataJSON:    clientDataJSON,
+		AttestationObject: attestationObject,
+	}, nil
+}
+
+// Assertion reprezents the response of the authenticator to a sign-in.
+type Assertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte
+}
+
+// NewAssertion creates a new Assertion that is in a valid state.
+func NewAssertion(credentialID []byte, clientDataJSON []byte, authenticatorData []byte, signature []byte, userHandle []byte) (Assertion, error) {
+	if len(credentialID) == 0 {
+		return Assertion{}, fmt.Errorf("rawId must be provided")
+	}
+	if len(clientDataJSON) == 0 {
+		return Assertion{}, fmt.Errorf("clientDataJSON must be provided")
+	}
+	if len(authenticatorData) == 0 {
+		return Assertion{}, fmt.Errorf("authenticatorData must be provided")
+	}
+	if len(signature) == 0 {
+		return Assertion{}, fmt.Errorf("signature must be provided")
+	}
+
+	return Assertion{
+		CredentialID:      credentialID,
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authenticatorData,
+		Signature:         signature,
+		UserHandle:        userHandle,
+	}, nil
+}
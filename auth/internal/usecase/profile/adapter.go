@@ -0,0 +1,240 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	fbauthn "firebase.google.com/go/v4/auth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/mail"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+	"go.uber.org/zap"
+)
+
+// updateRequestDto represents the payload request contract.
+type updateRequestDto struct {
+	DisplayName *string `json:"displayName"`
+	Email       *string `json:"email"`
+	Password    *string `json:"password"`
+}
+
+// dtoToChanges transforms update payload (dto) into changes domain struct.
+func dtoToChanges(dto updateRequestDto) (Changes, error) {
+	ch, err := NewChanges(dto.DisplayName, dto.Email, dto.Password)
+	if err != nil {
+		return Changes{}, err
+	}
+	return ch, nil
+}
+
+// updateResponseDto represents the payload response contract.
+type updateResponseDto struct {
+	Email           string `json:"email"`
+	DisplayName     string `json:"displayName"`
+	EmailVerified   bool   `json:"emailVerified"`
+	SessionsRevoked bool   `json:"sessionsRevoked"`
+}
+
+// resultToUpdateResponseDto transforms result domain struct into update response (dto).
+func resultToUpdateResponseDto(r result) updateResponseDto {
+	dto := updateResponseDto{
+		Email:           r.User.Email,
+		DisplayName:     r.User.DisplayName,
+		EmailVerified:   r.User.EmailVerified,
+		SessionsRevoked: r.SessionsRevoked,
+	}
+	return dto
+}
+
+// (Adapter) HttpHandler transforms a "profile http request" into a "call on profile core service".
+func HttpHandler(s Service, cookies auth.Cookies) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+		caller, err := NewCaller(claims.UID, claims.AuthTime)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		// decode payload
+		var reqDto updateRequestDto
+		if err := web.Decode(r, &reqDto); err != nil {
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+		}
+
+		// business logic
+		ch, err := dtoToChanges(reqDto)
+		if err != nil {
+			return webapp.NewRequestError(fmt.Errorf("invalid payload: %w", err), http.StatusBadRequest)
+		}
+		res, err := s.Update(ctx, caller, ch)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrWeakPassword):
+				return webapp.NewRequestError(err, http.StatusBadRequest)
+			case errors.Is(err, ErrRecentSignInRequired):
+				return webapp.NewRequestError(err, http.StatusUnauthorized)
+			case errors.Is(err, ErrDuplicate):
+				return webapp.NewRequestError(err, http.StatusConflict)
+			case errors.Is(err, ErrNotFound):
+				return webapp.NewRequestError(err, http.StatusNotFound)
+			}
+			return fmt.Errorf("unable to update profile: %w", err)
+		}
+
+		// The session of this request was revoked together with the other
+		// ones, it is replaced.
+		if res.SessionsRevoked {
+			cookies.SetSession(w, res.Session.Value, res.Session.ExpiresIn)
+		}
+
+		// send response
+		resp := resultToUpdateResponseDto(res)
+		return web.Respond(ctx, w, resp, http.StatusOK)
+	}
+}
+
+// (Adapter) Firebase transforms a "profile core service call" into a "call on firebase".
+type Firebase struct {
+	client   *fbauthn.Client
+	sessions *webapp.FirebaseSessions
+}
+
+// NewFirebase sets a firebase authentication client for profile use case. The
+// web api key is needed to exchange custom tokens for id tokens.
+func NewFirebase(client *fbauthn.Client, apiKey string) *Firebase {
+	return &Firebase{
+		client:   client,
+		sessions: webapp.NewFirebaseSessions(client, apiKey),
+	}
+}
+
+// Update applies the changes on the firebase user. A new email is marked as not verified.
+func (fb Firebase) Update(ctx context.Context, uid string, c Changes) (user, error) {
+	ctx, done := webapp.StartFirebase(ctx, "profile.update")
+	defer done()
+
+	fbUser := toFirebaseUserToUpdate(c)
+	u, err := webapp.FirebaseTenant(ctx, fb.client).UpdateUser(ctx, uid, fbUser)
+	if err != nil {
+		switch {
+		case fbauthn.IsUserNotFound(err):
+			return user{}, ErrNotFound
+		case fbauthn.IsEmailAlreadyExists(err):
+			return user{}, ErrDuplicate
+		}
+		return user{}, fmt.Errorf("firebase updating user: %w", err)
+	}
+
+	return user{
+		UID:           u.UID,
+		Email:         u.Email,
+		DisplayName:   u.DisplayName,
+		EmailVerified: u.EmailVerified,
+	}, nil
+}
+
+// EmailVerificationLink generates the firebase link used to verify the given email.
+func (fb Firebase) EmailVerificationLink(ctx context.Context, email string) (string, error) {
+	ctx, done := webapp.StartFirebase(ctx, "profile.email_verification_link")
+	defer done()
+
+	link, err := webapp.FirebaseTenant(ctx, fb.client).EmailVerificationLink(ctx, email)
+	if err != nil {
+		return "", fmt.Errorf("firebase generating email verification link: %w", err)
+	}
+	return link, nil
+}
+
+// RevokeSessions revokes all the firebase refresh tokens of the user, which
+// invalidates all the session cookies created before this moment.
+func (fb Firebase) RevokeSessions(ctx context.Context, uid string) error {
+	ctx, done := webapp.StartFirebase(ctx, "profile.revoke_sessions")
+	defer done()
+
+	if err := webapp.FirebaseTenant(ctx, fb.client).RevokeRefreshTokens(ctx, uid); err != nil {
+		if fbauthn.IsUserNotFound(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("firebase revoking refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// Session creates the firebase session cookie of the user. It is created
+// after the revocation, so it stays valid.
+func (fb Firebase) Session(ctx context.Context, uid string, expiresIn time.Duration) (session, error) {
+	ctx, done := webapp.StartFirebase(ctx, "profile.session")
+	defer done()
+
+	value, err := fb.sessions.Mint(ctx, uid, expiresIn)
+	if err != nil {
+		return session{}, err
+	}
+	return session{Value: value, ExpiresIn: expiresIn}, nil
+}
+
+func toFirebaseUserToUpdate(c Changes) *fbauthn.UserToUpdate {
+	u := &fbauthn.UserToUpdate{}
+	if c.DisplayName != "" {
+		u.DisplayName(c.DisplayName)
+	}
+	if c.Email != "" {
+		u.Email(c.Email)
+		u.EmailVerified(false)
+	}
+	if c.Password != "" {
+		u.Password(c.Password)
+	}
+
+	return u
+}
+
+// (Adapter) Mailer transforms a "notification" into a "mail".
+type Mailer struct {
+	sender *mail.Sender
+}
+
+// NewMailer sets the sender used to deliver the notifications.
+func NewMailer(sender *mail.Sender) *Mailer {
+	return &Mailer{
+		sender: sender,
+	}
+}
+
+// EmailVerification mails the verification link to the new email.
+func (m Mailer) EmailVerification(ctx context.Context, email string, link string) error {
+	body := "The email of your go-tickets account was changed to this address.\n\n" +
+		"Verify it with this link:\n\n" + link + "\n"
+
+	if err := m.sender.Send(ctx, email, "Verify your go-tickets email", body); err != nil {
+		return fmt.Errorf("mailing email verification: %w", err)
+	}
+	return nil
+}
+
+// (Adapter) LogNotifier transforms a "notification" into a "log entry".
+// The link is a credential, it is not logged, so nothing is delivered: it
+// is meant for development without a mail relay.
+type LogNotifier struct {
+	log *zap.SugaredLogger
+}
+
+// NewLogNotifier sets the logger used to deliver the notifications.
+func NewLogNotifier(log *zap.SugaredLogger) *LogNotifier {
+	return &LogNotifier{
+		log: log,
+	}
+}
+
+// EmailVerification writes to the logs that a verification link was issued.
+func (n LogNotifier) EmailVerification(ctx context.Context, email string, link string) error {
+	n.log.Infow("email verification", "traceid", web.GetTraceID(ctx), "status", "link issued, not delivered without a mail relay")
+	return nil
+}
@@ -0,0 +1,108 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// serveUpdate calls the handler with the body for the signed in user.
+func serveUpdate(t *testing.T, claims auth.Claims, body string) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+
+	s, _, _ := newTestService(tenant.Policy{PasswordMinLength: 12})
+	h := HttpHandler(s, auth.Cookies{Secure: true})
+
+	ctx := auth.SetClaims(context.Background(), claims)
+	r := httptest.NewRequest(http.MethodPatch, "/api/profile", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	return w, h(ctx, w, r.WithContext(ctx))
+}
+
+func TestHttpHandlerReplacesSessionCookie(t *testing.T) {
+	claims := auth.Claims{UID: "uid-1", AuthTime: time.Now().Unix()}
+
+	w, err := serveUpdate(t, claims, `{"password":"A-new-passw0rd"}`)
+	if err != nil {
+		t.Fatalf("handling: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var resp updateResponseDto
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if !resp.SessionsRevoked {
+		t.Error("got sessions not revoked, want revoked")
+	}
+
+	// The caller stays signed in with a new session.
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want the session one", len(cookies))
+	}
+	c := cookies[0]
+	if c.Name != auth.SessionCookieName || c.Value != "session-uid-1" || c.MaxAge != int(sessionExpiresIn.Seconds()) {
+		t.Errorf("got cookie %s=%s max-age %d, want the new session", c.Name, c.Value, c.MaxAge)
+	}
+	if !c.Secure || !c.HttpOnly {
+		t.Errorf("got cookie secure %t http only %t, want both", c.Secure, c.HttpOnly)
+	}
+}
+
+func TestHttpHandlerKeepsSessionCookie(t *testing.T) {
+	claims := auth.Claims{UID: "uid-1", AuthTime: time.Now().Add(-time.Hour).Unix()}
+
+	w, err := serveUpdate(t, claims, `{"displayName":"Ana Maria"}`)
+	if err != nil {
+		t.Fatalf("handling: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("got cookies %v, want none", cookies)
+	}
+}
+
+func TestHttpHandlerErrors(t *testing.T) {
+	recent := time.Now().Unix()
+
+	tests := []struct {
+		name   string
+		claims auth.Claims
+		body   string
+		want   int
+	}{
+		{"invalid payload", auth.Claims{UID: "uid-1", AuthTime: recent}, `{"email":"not-an-email"}`, http.StatusBadRequest},
+		{"password too short for the tenant", auth.Claims{UID: "uid-1", AuthTime: recent}, `{"password":"B-passw0rd"}`, http.StatusBadRequest},
+		{"old session", auth.Claims{UID: "uid-1", AuthTime: recent - 3600}, `{"password":"A-new-passw0rd"}`, http.StatusUnauthorized},
+		{"email of another user", auth.Claims{UID: "uid-1", AuthTime: recent}, `{"email":"bob@example.com"}`, http.StatusConflict},
+		{"unknown user", auth.Claims{UID: "uid-3", AuthTime: recent}, `{"displayName":"Eve"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := serveUpdate(t, tt.claims, tt.body)
+
+			re := webapp.GetRequestError(err)
+			if re == nil || re.Status != tt.want {
+				t.Fatalf("got error %v, want a request error with status %d", err, tt.want)
+			}
+			if cookies := w.Result().Cookies(); len(cookies) != 0 {
+				t.Errorf("got cookies %v, want none", cookies)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package profile contains all the components needed to
+// fulfill the profile management use case.
+package profile
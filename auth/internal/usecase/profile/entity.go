@@ -0,0 +1,26 @@
+package profile
+
+import "time"
+
+// user represents a domain entity.
+type user struct {
+	UID           string
+	Email         string
+	DisplayName   string
+	EmailVerified bool
+}
+
+// session represents the session issued to the caller after the other
+// sessions were revoked.
+type session struct {
+	Value     string
+	ExpiresIn time.Duration
+}
+
+// result represents the outcome of a profile update. The session is only
+// issued when the sessions were revoked.
+type result struct {
+	User            user
+	SessionsRevoked bool
+	Session         session
+}
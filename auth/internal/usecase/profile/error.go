@@ -0,0 +1,18 @@
+package profile
+
+import "errors"
+
+var (
+	// ErrNotFound is used when the user does not exist.
+	ErrNotFound = errors.New("user not found")
+
+	// ErrDuplicate is used when the new email is already used by another user.
+	ErrDuplicate = errors.New("email already in use")
+
+	// ErrWeakPassword is used when the new password is shorter than the policy of the tenant allows.
+	ErrWeakPassword = errors.New("password is too short for the tenant")
+
+	// ErrRecentSignInRequired is used when a sensitive change is requested
+	// with a session that was not created recently.
+	ErrRecentSignInRequired = errors.New("recent sign-in required")
+)
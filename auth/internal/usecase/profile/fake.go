@@ -0,0 +1,100 @@
+package profile
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// (Adapter) Fake is an in-memory authn provider used to exercise
+// the profile core service and its handler without depending on firebase.
+type Fake struct {
+	mu      sync.Mutex
+	users   map[string]user
+	revoked map[string]int
+}
+
+// NewFake creates an empty Fake.
+func NewFake() *Fake {
+	return &Fake{
+		users:   make(map[string]user),
+		revoked: make(map[string]int),
+	}
+}
+
+// Add stores a user with a verified email.
+func (f *Fake) Add(uid string, email string, displayName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.users[uid] = user{
+		UID:           uid,
+		Email:         email,
+		DisplayName:   displayName,
+		EmailVerified: true,
+	}
+}
+
+// Update applies the changes on the stored user.
+func (f *Fake) Update(ctx context.Context, uid string, c Changes) (user, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	u, ok := f.users[uid]
+	if !ok {
+		return user{}, ErrNotFound
+	}
+
+	if c.Email != "" {
+		for id, other := range f.users {
+			if id != uid && strings.EqualFold(other.Email, c.Email) {
+				return user{}, ErrDuplicate
+			}
+		}
+		u.Email = c.Email
+		u.EmailVerified = false
+	}
+	if c.DisplayName != "" {
+		u.DisplayName = c.DisplayName
+	}
+
+	f.users[uid] = u
+	return u, nil
+}
+
+// EmailVerificationLink returns a fake verification link.
+func (f *Fake) EmailVerificationLink(ctx context.Context, email string) (string, error) {
+	return "https://example.com/verify?email=" + email, nil
+}
+
+// RevokeSessions records the revocation for the given user.
+func (f *Fake) RevokeSessions(ctx context.Context, uid string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.users[uid]; !ok {
+		return ErrNotFound
+	}
+	f.revoked[uid]++
+	return nil
+}
+
+// Session returns a fake session of the user.
+func (f *Fake) Session(ctx context.Context, uid string, expiresIn time.Duration) (session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.users[uid]; !ok {
+		return session{}, ErrNotFound
+	}
+	return session{Value: "session-" + uid, ExpiresIn: expiresIn}, nil
+}
+
+// Revocations returns how many times the sessions of the user were revoked.
+func (f *Fake) Revocations(uid string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.revoked[uid]
+}
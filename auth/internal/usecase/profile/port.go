@@ -0,0 +1,38 @@
+package profile
+
+import (
+	"context"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+)
+
+// (Port) Service defines how the interaction between the "core" and the "profile http handler" has to be done.
+type Service interface {
+	// Update applies the changes on the profile of the caller.
+	Update(context.Context, Caller, Changes) (result, error)
+}
+
+// (Port) AuthnProvider defines how the interaction between the "core" and the "authn provider" has to be done.
+type AuthnProvider interface {
+	// Update applies the changes on the user with the given uid.
+	Update(ctx context.Context, uid string, c Changes) (user, error)
+	// EmailVerificationLink generates the link used to verify the given email.
+	EmailVerificationLink(ctx context.Context, email string) (string, error)
+	// RevokeSessions revokes all the sessions of the user with the given uid.
+	RevokeSessions(ctx context.Context, uid string) error
+	// Session creates a new session cookie for the user with the given expiry duration.
+	Session(ctx context.Context, uid string, expiresIn time.Duration) (session, error)
+}
+
+// (Port) Notifier defines how the interaction between the "core" and the "notification system" has to be done.
+type Notifier interface {
+	// EmailVerification delivers the verification link to the given email.
+	EmailVerification(ctx context.Context, email string, link string) error
+}
+
+// (Port) Policies defines how the interaction between the "core" and the "tenant policies" has to be done.
+type Policies interface {
+	// Policy returns the policy of the tenant of the request.
+	Policy(ctx context.Context) tenant.Policy
+}
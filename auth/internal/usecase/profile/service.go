@@ -0,0 +1,73 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// sessionExpiresIn matches the expiration of the sessions issued by signin,
+// the tenants can have their own.
+const sessionExpiresIn = time.Hour * 24 * 2
+
+// Service represents "profile" core service.
+type service struct {
+	ap  AuthnProvider
+	n   Notifier
+	pol Policies
+}
+
+// NewService creates a "profile core service" with the necessary dependencies.
+func NewService(ap AuthnProvider, n Notifier, pol Policies) *service {
+	return &service{ap: ap, n: n, pol: pol}
+}
+
+// Update applies the changes on the profile of the caller. Email and password
+// changes require a recent sign-in and revoke the other sessions of the user,
+// a new email must also be verified again. A new password follows the policy
+// of the tenant of the request, like on signup.
+func (s *service) Update(ctx context.Context, c Caller, ch Changes) (result, error) {
+	if min := s.pol.Policy(ctx).PasswordMinLength; ch.Password != "" && utf8.RuneCountInString(ch.Password) < min {
+		return result{}, fmt.Errorf("profile: %w: at least %d characters", ErrWeakPassword, min)
+	}
+	if ch.sensitive() && !c.signedInRecently() {
+		return result{}, ErrRecentSignInRequired
+	}
+
+	u, err := s.ap.Update(ctx, c.UID, ch)
+	if err != nil {
+		return result{}, fmt.Errorf("profile: %w", err)
+	}
+
+	if ch.Email != "" {
+		link, err := s.ap.EmailVerificationLink(ctx, u.Email)
+		if err != nil {
+			return result{}, fmt.Errorf("profile: %w", err)
+		}
+		if err := s.n.EmailVerification(ctx, u.Email, link); err != nil {
+			return result{}, fmt.Errorf("profile: %w", err)
+		}
+	}
+
+	if !ch.sensitive() {
+		return result{User: u}, nil
+	}
+
+	// The provider revokes the sessions at user level, so the caller gets a
+	// new session in place of the one used for this request.
+	if err := s.ap.RevokeSessions(ctx, c.UID); err != nil {
+		return result{}, fmt.Errorf("profile: %w", err)
+	}
+
+	expiresIn := sessionExpiresIn
+	if ttl := s.pol.Policy(ctx).SessionTTL; ttl > 0 {
+		expiresIn = ttl
+	}
+	ses, err := s.ap.Session(ctx, c.UID, expiresIn)
+	if err != nil {
+		return result{}, fmt.Errorf("profile: %w", err)
+	}
+
+	return result{User: u, SessionsRevoked: true, Session: ses}, nil
+}
@@ -0,0 +1,167 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+)
+
+// fakeNotifier keeps the delivered verification links by email.
+type fakeNotifier struct {
+	links map[string]string
+}
+
+func (n *fakeNotifier) EmailVerification(ctx context.Context, email string, link string) error {
+	if n.links == nil {
+		n.links = make(map[string]string)
+	}
+	n.links[email] = link
+	return nil
+}
+
+// fakePolicies returns the same policy for every request.
+type fakePolicies struct {
+	policy tenant.Policy
+}
+
+func (p fakePolicies) Policy(ctx context.Context) tenant.Policy {
+	return p.policy
+}
+
+// newTestService constructs the service on a fake provider holding two users.
+func newTestService(policy tenant.Policy) (*service, *Fake, *fakeNotifier) {
+	f := NewFake()
+	f.Add("uid-1", "ana@example.com", "Ana")
+	f.Add("uid-2", "bob@example.com", "Bob")
+
+	n := &fakeNotifier{}
+	return NewService(f, n, fakePolicies{policy: policy}), f, n
+}
+
+// recentCaller returns a caller that signed in now.
+func recentCaller(uid string) Caller {
+	return Caller{UID: uid, AuthTime: time.Now().Unix()}
+}
+
+func TestUpdateDisplayName(t *testing.T) {
+	s, f, n := newTestService(tenant.Policy{})
+
+	// A display name is not sensitive, an old session can change it.
+	old := Caller{UID: "uid-1", AuthTime: time.Now().Add(-time.Hour).Unix()}
+	res, err := s.Update(context.Background(), old, Changes{DisplayName: "Ana Maria"})
+	if err != nil {
+		t.Fatalf("updating: %v", err)
+	}
+
+	if res.User.DisplayName != "Ana Maria" {
+		t.Errorf("got display name %q, want %q", res.User.DisplayName, "Ana Maria")
+	}
+	if res.SessionsRevoked || res.Session != (session{}) {
+		t.Errorf("got sessions revoked with %+v, want them kept", res.Session)
+	}
+	if f.Revocations("uid-1") != 0 {
+		t.Errorf("got %d revocations, want none", f.Revocations("uid-1"))
+	}
+	if len(n.links) != 0 {
+		t.Errorf("got links %v, want none", n.links)
+	}
+}
+
+func TestUpdateEmail(t *testing.T) {
+	s, f, n := newTestService(tenant.Policy{})
+
+	res, err := s.Update(context.Background(), recentCaller("uid-1"), Changes{Email: "ana@example.org"})
+	if err != nil {
+		t.Fatalf("updating: %v", err)
+	}
+
+	if res.User.Email != "ana@example.org" || res.User.EmailVerified {
+		t.Errorf("got %+v, want the new email not verified", res.User)
+	}
+	if n.links["ana@example.org"] == "" {
+		t.Error("got no verification link for the new email")
+	}
+
+	// The other sessions are revoked, the caller gets a new one.
+	if !res.SessionsRevoked || f.Revocations("uid-1") != 1 {
+		t.Errorf("got %d revocations, want the sessions revoked once", f.Revocations("uid-1"))
+	}
+	want := session{Value: "session-uid-1", ExpiresIn: sessionExpiresIn}
+	if res.Session != want {
+		t.Errorf("got session %+v, want %+v", res.Session, want)
+	}
+}
+
+func TestUpdatePasswordSessionTTL(t *testing.T) {
+	s, _, _ := newTestService(tenant.Policy{SessionTTL: 12 * time.Hour})
+
+	res, err := s.Update(context.Background(), recentCaller("uid-1"), Changes{Password: "A-new-passw0rd"})
+	if err != nil {
+		t.Fatalf("updating: %v", err)
+	}
+
+	if res.Session.ExpiresIn != 12*time.Hour {
+		t.Errorf("got session for %v, want the %v of the tenant", res.Session.ExpiresIn, 12*time.Hour)
+	}
+}
+
+func TestUpdatePasswordTenantMinLength(t *testing.T) {
+	s, f, _ := newTestService(tenant.Policy{PasswordMinLength: 12})
+
+	_, err := s.Update(context.Background(), recentCaller("uid-1"), Changes{Password: "B-passw0rd"})
+	if !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("got error %v, want %v", err, ErrWeakPassword)
+	}
+	if f.Revocations("uid-1") != 0 {
+		t.Errorf("got %d revocations, want none", f.Revocations("uid-1"))
+	}
+
+	if _, err := s.Update(context.Background(), recentCaller("uid-1"), Changes{Password: "A-long-passw0rd"}); err != nil {
+		t.Fatalf("updating: %v", err)
+	}
+}
+
+func TestUpdateRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  Caller
+		changes Changes
+		want    error
+	}{
+		{
+			name:    "old session",
+			caller:  Caller{UID: "uid-1", AuthTime: time.Now().Add(-time.Hour).Unix()},
+			changes: Changes{Password: "A-new-passw0rd"},
+			want:    ErrRecentSignInRequired,
+		},
+		{
+			name:    "email of another user",
+			caller:  recentCaller("uid-1"),
+			changes: Changes{Email: "BOB@example.com"},
+			want:    ErrDuplicate,
+		},
+		{
+			name:    "unknown user",
+			caller:  recentCaller("uid-3"),
+			changes: Changes{DisplayName: "Eve"},
+			want:    ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, f, _ := newTestService(tenant.Policy{})
+
+			_, err := s.Update(context.Background(), tt.caller, tt.changes)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if f.Revocations(tt.caller.UID) != 0 {
+				t.Errorf("got %d revocations, want none", f.Revocations(tt.caller.UID))
+			}
+		})
+	}
+}
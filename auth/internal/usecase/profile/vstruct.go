@@ -0,0 +1,77 @@
+package profile
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/usecase/signup"
+)
+
+// Caller reprezents the signed in user that requests the changes.
+type Caller struct {
+	UID      string
+	AuthTime int64
+}
+
+// NewCaller creates a new Caller that is in a valid state.
+func NewCaller(uid string, authTime int64) (Caller, error) {
+	if uid == "" {
+		return Caller{}, fmt.Errorf("uid must be a non-empty string")
+	}
+
+	return Caller{
+		UID:      uid,
+		AuthTime: authTime,
+	}, nil
+}
+
+// signedInRecently reports if the sign-in happened in the last 5 minutes.
+func (c Caller) signedInRecently() bool {
+	signInTime := time.Now().Unix() - c.AuthTime
+	return signInTime <= 5*60
+}
+
+// Changes reprezents a "value object" inside domain. Empty fields are left unchanged.
+type Changes struct {
+	DisplayName string
+	Email       string
+	Password    string
+}
+
+// NewChanges creates new Changes that are in a valid state. A nil value means
+// the field is not changed, the rules are the same as the ones used on signup.
+func NewChanges(displayName *string, email *string, password *string) (Changes, error) {
+	var c Changes
+
+	if displayName != nil {
+		if err := signup.ValidateDisplayName(*displayName); err != nil {
+			return Changes{}, err
+		}
+		c.DisplayName = *displayName
+	}
+
+	if email != nil {
+		if err := signup.ValidateEmail(*email); err != nil {
+			return Changes{}, err
+		}
+		c.Email = *email
+	}
+
+	if password != nil {
+		if err := signup.ValidatePassword(*password); err != nil {
+			return Changes{}, err
+		}
+		c.Password = *password
+	}
+
+	if c == (Changes{}) {
+		return Changes{}, fmt.Errorf("at least one of displayName, email or password must be provided")
+	}
+
+	return c, nil
+}
+
+// sensitive reports if the changes affect the credentials of the user.
+func (c Changes) sensitive() bool {
+	return c.Email != "" || c.Password != ""
+}
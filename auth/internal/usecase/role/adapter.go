@@ -0,0 +1,142 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	fbauthn "firebase.google.com/go/v4/auth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/memauth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+)
+
+// assignRequestDto represents the payload request contract.
+type assignRequestDto struct {
+	Roles []string `json:"roles"`
+}
+
+// assignResponseDto represents the payload response contract.
+type assignResponseDto struct {
+	UID   string   `json:"uid"`
+	Roles []string `json:"roles"`
+}
+
+// userToAssignResponseDto transforms user domain struct into assign response (dto).
+func userToAssignResponseDto(u user) assignResponseDto {
+	dto := assignResponseDto{
+		UID:   u.UID,
+		Roles: u.Roles,
+	}
+	return dto
+}
+
+// (Adapter) HttpHandler transforms a "role http request" into a "call on role core service".
+func HttpHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		uid := web.Param(r, "uid")
+
+		// decode payload
+		var reqDto assignRequestDto
+		if err := web.Decode(r, &reqDto); err != nil {
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+		}
+
+		// business logic
+		roles, err := NewRoles(reqDto.Roles)
+		if err != nil {
+			return webapp.NewRequestError(fmt.Errorf("invalid payload: %w", err), http.StatusBadRequest)
+		}
+		u, err := s.Assign(ctx, uid, roles)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return webapp.NewRequestError(err, http.StatusNotFound)
+			}
+			return fmt.Errorf("unable to assign roles: %w", err)
+		}
+
+		// send response
+		resp := userToAssignResponseDto(u)
+		return web.Respond(ctx, w, resp, http.StatusOK)
+	}
+}
+
+// (Adapter) Firebase transforms a "role core service call" into a "call on firebase".
+type Firebase struct {
+	client *fbauthn.Client
+}
+
+// NewFirebase sets a firebase authentication client for role use case.
+func NewFirebase(client *fbauthn.Client) *Firebase {
+	return &Firebase{
+		client: client,
+	}
+}
+
+// SetRoles stores the roles as a custom claim, keeping the other custom claims of the user.
+func (fb Firebase) SetRoles(ctx context.Context, uid string, roles Roles) error {
+	ctx, done := webapp.StartFirebase(ctx, "role.set_roles")
+	defer done()
+
+	u, err := webapp.FirebaseTenant(ctx, fb.client).GetUser(ctx, uid)
+	if err != nil {
+		if fbauthn.IsUserNotFound(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("firebase getting user: %w", err)
+	}
+
+	claims := make(map[string]interface{})
+	for k, v := range u.CustomClaims {
+		claims[k] = v
+	}
+	claims["roles"] = []string(roles)
+
+	if err := webapp.FirebaseTenant(ctx, fb.client).SetCustomUserClaims(ctx, uid, claims); err != nil {
+		return fmt.Errorf("firebase setting custom claims: %w", err)
+	}
+	return nil
+}
+
+// RevokeSessions revokes all the firebase refresh tokens of the user.
+func (fb Firebase) RevokeSessions(ctx context.Context, uid string) error {
+	ctx, done := webapp.StartFirebase(ctx, "role.revoke_sessions")
+	defer done()
+
+	if err := webapp.FirebaseTenant(ctx, fb.client).RevokeRefreshTokens(ctx, uid); err != nil {
+		return fmt.Errorf("firebase revoking refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// (Adapter) Memory transforms a "role core service call" into a "call on the in-memory authn provider".
+type Memory struct {
+	p *memauth.Provider
+}
+
+// NewMemory sets an in-memory authentication provider for role use case.
+func NewMemory(p *memauth.Provider) *Memory {
+	return &Memory{
+		p: p,
+	}
+}
+
+// SetRoles replaces the roles of the user, the next sessions carry them.
+func (m Memory) SetRoles(ctx context.Context, uid string, roles Roles) error {
+	if err := m.p.SetRoles(ctx, uid, roles); err != nil {
+		if errors.Is(err, memauth.ErrUserNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("memory setting roles: %w", err)
+	}
+	return nil
+}
+
+// RevokeSessions revokes the sessions of the user.
+func (m Memory) RevokeSessions(ctx context.Context, uid string) error {
+	if err := m.p.RevokeSessions(ctx, uid); err != nil {
+		return fmt.Errorf("memory revoking sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+// Package role contains all the components needed to
+// fulfill the role assignment use case.
+package role
@@ -0,0 +1,7 @@
+package role
+
+// user represents a domain entity.
+type user struct {
+	UID   string
+	Roles []string
+}
@@ -0,0 +1,27 @@
+package role
+
+import (
+	"context"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+)
+
+// (Port) Service defines how the interaction between the "core" and the "role http handler" has to be done.
+type Service interface {
+	// Assign replaces the roles of the user with the given uid.
+	Assign(ctx context.Context, uid string, roles Roles) (user, error)
+}
+
+// (Port) AuthnProvider defines how the interaction between the "core" and the "authn provider" has to be done.
+type AuthnProvider interface {
+	// SetRoles stores the roles on the user with the given uid.
+	SetRoles(ctx context.Context, uid string, roles Roles) error
+	// RevokeSessions revokes all the sessions of the user with the given uid.
+	RevokeSessions(ctx context.Context, uid string) error
+}
+
+// (Port) Auditor defines how the interaction between the "core" and the "audit log" has to be done.
+type Auditor interface {
+	// Record appends the event to the audit log.
+	Record(ctx context.Context, e audit.Event)
+}
@@ -0,0 +1,44 @@
+package role
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+)
+
+// Service represents "role" core service.
+type service struct {
+	ap AuthnProvider
+	a  Auditor
+}
+
+// NewService creates a "role core service" with the necessary dependencies.
+func NewService(ap AuthnProvider, a Auditor) *service {
+	return &service{ap: ap, a: a}
+}
+
+// Assign replaces the roles of the user. The sessions of the user are revoked
+// so the next sign-in issues a session carrying the new roles.
+func (s *service) Assign(ctx context.Context, uid string, roles Roles) (user, error) {
+	err := s.assign(ctx, uid, roles)
+	s.a.Record(ctx, audit.Event{
+		Action:  "roles.assign",
+		Subject: uid,
+		Details: map[string]string{"roles": strings.Join(roles, ",")},
+	}.Failed(err))
+	if err != nil {
+		return user{}, fmt.Errorf("role: %w", err)
+	}
+
+	return user{UID: uid, Roles: roles}, nil
+}
+
+func (s *service) assign(ctx context.Context, uid string, roles Roles) error {
+	if err := s.ap.SetRoles(ctx, uid, roles); err != nil {
+		return err
+	}
+
+	return s.ap.RevokeSessions(ctx, uid)
+}
@@ -0,0 +1,28 @@
+package role
+
+import (
+	"fmt"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/roles"
+)
+
+// Roles reprezents a "value object" inside domain.
+type Roles []string
+
+// NewRoles creates new Roles that are in a valid state. Duplicates are removed.
+func NewRoles(names []string) (Roles, error) {
+	seen := make(map[string]bool)
+	rs := Roles{}
+	for _, r := range names {
+		if !roles.IsKnown(r) {
+			return nil, fmt.Errorf("unknown role %q", r)
+		}
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		rs = append(rs, r)
+	}
+
+	return rs, nil
+}
@@ -2,40 +2,92 @@ package signin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"cloud.google.com/go/firestore"
 	fbauthn "firebase.google.com/go/v4/auth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/memauth"
 	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // (Adapter) HttpHandler transforms a "signin http request" into a "call on signin core service".
-func HttpHandler(s signInService) web.Handler {
+func HttpHandler(s signInService, cookies auth.Cookies) web.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 		// Expecting: jwt <token>
 		authStr := r.Header.Get("authorization")
 
 		// Parse the authorization header.
-		token, err := web.ExtractToken(authStr)
+		_, token, err := web.ExtractToken(authStr, web.SchemeJWT)
 		if err != nil {
-			return fmt.Errorf("unable to sign in: %w", err)
+			return webapp.NewRequestError(fmt.Errorf("unable to sign in: %w", err), http.StatusUnauthorized)
+		}
+
+		out, err := s.SignIn(ctx, token, web.GetClientIP(ctx))
+		if err != nil {
+			return toRequestError(w, err)
+		}
+
+		// The session cookie is only issued once the second factor is verified.
+		if out.MFARequired {
+			status := struct {
+				Status    string
+				Challenge string
+				ExpiresIn int
+			}{
+				Status:    "MFA required",
+				Challenge: out.Challenge.ID,
+				ExpiresIn: int(time.Until(out.Challenge.ExpiresAt).Seconds()),
+			}
+
+			return web.Respond(ctx, w, status, http.StatusOK)
 		}
 
 		// Generate session cookie
-		scookie, err := s.SignIn(token)
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session",
-			Value:    scookie.Value,
-			MaxAge:   int(scookie.ExpiresIn.Seconds()),
-			HttpOnly: true,
-			Secure:   false,
-			SameSite: http.SameSiteLaxMode,
-		})
+		cookies.SetSession(w, out.Session.Value, out.Session.ExpiresIn)
+
+		status := struct {
+			Status string
+		}{
+			Status: "Success",
+		}
+
+		return web.Respond(ctx, w, status, http.StatusOK)
+	}
+}
+
+// mfaRequestDto represents the second factor payload request contract.
+type mfaRequestDto struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"`
+}
+
+// (Adapter) MFAHttpHandler transforms a "signin second factor http request" into a "call on signin core service".
+func MFAHttpHandler(s signInService, cookies auth.Cookies) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var reqDto mfaRequestDto
+		if err := web.Decode(r, &reqDto); err != nil {
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+		}
+
+		ses, err := s.VerifyChallenge(ctx, reqDto.Challenge, reqDto.Code, web.GetClientIP(ctx))
 		if err != nil {
-			return err
+			return toRequestError(w, err)
 		}
 
+		// Generate session cookie
+		cookies.SetSession(w, ses.Value, ses.ExpiresIn)
+
 		status := struct {
 			Status string
 		}{
@@ -46,6 +98,52 @@ func HttpHandler(s signInService) web.Handler {
 	}
 }
 
+// (Adapter) SignOutHttpHandler transforms a "signout http request" into a "call on signin core service".
+func SignOutHttpHandler(s signInService, cookies auth.Cookies) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		if err := s.SignOut(ctx, claims.UID); err != nil {
+			return fmt.Errorf("unable to sign out: %w", err)
+		}
+
+		cookies.ClearSession(w)
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+// denial is implemented by the errors of the brute-force protection.
+type denial interface {
+	RetryAfter() time.Duration
+	Locked() bool
+}
+
+// toRequestError maps the known domain errors to request errors. Denied
+// attempts tell the client when to retry.
+func toRequestError(w http.ResponseWriter, err error) error {
+	var d denial
+	if errors.As(err, &d) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter().Seconds()))))
+		if d.Locked() {
+			return webapp.NewRequestError(err, http.StatusLocked)
+		}
+		return webapp.NewRequestError(err, http.StatusTooManyRequests)
+	}
+
+	switch {
+	case errors.Is(err, ErrInvalidToken),
+		errors.Is(err, ErrRecentSignInRequired),
+		errors.Is(err, ErrChallengeNotFound),
+		errors.Is(err, ErrInvalidCode):
+		return webapp.NewRequestError(err, http.StatusUnauthorized)
+	}
+	return fmt.Errorf("unable to sign in: %w", err)
+}
+
 // (Adapter) Firebase transforms a "core service call" into a "call on firebase authn provider".
 type Firebase struct {
 	client *fbauthn.Client
@@ -59,8 +157,11 @@ func NewFirebase(client *fbauthn.Client) *Firebase {
 }
 
 // VerifyToken verifies the signature and payload of the provided firebase token.
-func (fb Firebase) VerifyToken(tkn string) (token, error) {
-	decoded, err := fb.client.VerifyIDToken(context.Background(), tkn)
+func (fb Firebase) VerifyToken(ctx context.Context, tkn string) (token, error) {
+	ctx, done := webapp.StartFirebase(ctx, "signin.verify_token")
+	defer done()
+
+	decoded, err := webapp.FirebaseTenant(ctx, fb.client).VerifyIDToken(ctx, tkn)
 	if err != nil {
 		return token{}, fmt.Errorf("failed to verify the token: %w", err)
 	}
@@ -70,10 +171,14 @@ func (fb Firebase) VerifyToken(tkn string) (token, error) {
 }
 
 // SessionCookie creates a new firebase session cookie from the given token and expiry duration.
-func (fb Firebase) SessionCookie(tkn string, expiresIn time.Duration) (Session, error) {
+func (fb Firebase) SessionCookie(ctx context.Context, tkn string, expiresIn time.Duration) (Session, error) {
+	ctx, done := webapp.StartFirebase(ctx, "signin.session_cookie")
+	defer done()
+
 	// Create the session cookie. This will also verify the ID token in the process.
-	// The session cookie will have the same claims as the ID token.
-	value, err := fb.client.SessionCookie(context.Background(), tkn, expiresIn)
+	// The session cookie will have the same claims as the ID token, the tenant
+	// included, so the project creates the cookies of all the tenants.
+	value, err := fb.client.SessionCookie(ctx, tkn, expiresIn)
 	if err != nil {
 		return Session{}, fmt.Errorf("failed to create a session cookie on firebase: %w", err)
 	}
@@ -85,6 +190,17 @@ func (fb Firebase) SessionCookie(tkn string, expiresIn time.Duration) (Session,
 	return session, nil
 }
 
+// RevokeSessions revokes the refresh tokens of the user, which invalidates its session cookies.
+func (fb Firebase) RevokeSessions(ctx context.Context, uid string) error {
+	ctx, done := webapp.StartFirebase(ctx, "signin.revoke_sessions")
+	defer done()
+
+	if err := webapp.FirebaseTenant(ctx, fb.client).RevokeRefreshTokens(ctx, uid); err != nil {
+		return fmt.Errorf("failed to revoke the sessions on firebase: %w", err)
+	}
+	return nil
+}
+
 func toToken(fbToken *fbauthn.Token) token {
 	t := token{
 		AuthTime: fbToken.AuthTime,
@@ -98,3 +214,219 @@ func toToken(fbToken *fbauthn.Token) token {
 
 	return t
 }
+
+// (Adapter) Memory transforms a "core service call" into a "call on the in-memory authn provider".
+type Memory struct {
+	p *memauth.Provider
+}
+
+// NewMemory sets an in-memory authentication provider for signin use case.
+func NewMemory(p *memauth.Provider) *Memory {
+	return &Memory{
+		p: p,
+	}
+}
+
+// VerifyToken verifies the signature and payload of the provided token.
+func (m Memory) VerifyToken(ctx context.Context, tkn string) (token, error) {
+	decoded, err := m.p.VerifyIDToken(ctx, tkn)
+	if err != nil {
+		return token{}, fmt.Errorf("failed to verify the token: %w", err)
+	}
+
+	t := token{
+		AuthTime: decoded.AuthTime,
+		Issuer:   decoded.Issuer,
+		Audience: decoded.Audience,
+		Expires:  decoded.Expires,
+		IssuedAt: decoded.IssuedAt,
+		Subject:  decoded.UID,
+		UID:      decoded.UID,
+	}
+	return t, nil
+}
+
+// SessionCookie creates a new session cookie from the given token and expiry duration.
+func (m Memory) SessionCookie(ctx context.Context, tkn string, expiresIn time.Duration) (Session, error) {
+	value, err := m.p.SessionCookie(ctx, tkn, expiresIn)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to create a session cookie in memory: %w", err)
+	}
+
+	session, err := NewSession(value, expiresIn)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to create a session cookie: %w", err)
+	}
+	return session, nil
+}
+
+// RevokeSessions revokes the sessions of the user.
+func (m Memory) RevokeSessions(ctx context.Context, uid string) error {
+	if err := m.p.RevokeSessions(ctx, uid); err != nil {
+		return fmt.Errorf("failed to revoke the sessions in memory: %w", err)
+	}
+	return nil
+}
+
+// passwordRequestDto represents the password sign-in payload request contract.
+type passwordRequestDto struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// (Adapter) PasswordHttpHandler exchanges an email and a password for an id token of the
+// in-memory provider, the job of the client sdk with firebase. The id token then goes to
+// the sign-in route like a firebase one.
+func PasswordHttpHandler(p *memauth.Provider) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var reqDto passwordRequestDto
+		if err := web.Decode(r, &reqDto); err != nil {
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+		}
+
+		idToken, err := p.SignInWithPassword(ctx, reqDto.Email, reqDto.Password)
+		if err != nil {
+			if errors.Is(err, memauth.ErrInvalidCredentials) {
+				return webapp.NewRequestError(err, http.StatusUnauthorized)
+			}
+			return fmt.Errorf("unable to sign in: %w", err)
+		}
+
+		resp := struct {
+			IDToken string `json:"idToken"`
+		}{
+			IDToken: idToken,
+		}
+
+		return web.Respond(ctx, w, resp, http.StatusOK)
+	}
+}
+
+// (Adapter) MemoryChallenges transforms a "challenge store call" into an "in-memory map operation".
+// A challenge issued on an instance can't be answered on another one, it is
+// meant for development.
+type MemoryChallenges struct {
+	mu         sync.Mutex
+	challenges map[string]challenge
+}
+
+// NewMemoryChallenges creates an empty in-memory challenges store.
+func NewMemoryChallenges() *MemoryChallenges {
+	return &MemoryChallenges{
+		challenges: make(map[string]challenge),
+	}
+}
+
+// Save creates or replaces the challenge. Expired challenges are purged on the way.
+func (m *MemoryChallenges) Save(ctx context.Context, c challenge) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, old := range m.challenges {
+		if old.isExpired() {
+			delete(m.challenges, id)
+		}
+	}
+	m.challenges[c.ID] = c
+	return nil
+}
+
+// Take returns and removes the challenge or returns ErrChallengeNotFound.
+func (m *MemoryChallenges) Take(ctx context.Context, id string) (challenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.challenges[id]
+	if !ok {
+		return challenge{}, ErrChallengeNotFound
+	}
+	delete(m.challenges, id)
+	return c, nil
+}
+
+// challengeDoc represents the firestore document of a challenge. A ttl
+// policy on expiresAt purges the challenges never answered.
+type challengeDoc struct {
+	UID       string    `firestore:"uid"`
+	Token     string    `firestore:"token"`
+	Attempts  int       `firestore:"attempts"`
+	ExpiresAt time.Time `firestore:"expiresAt"`
+}
+
+// (Adapter) FirestoreChallenges transforms a "challenge store call" into a "firestore document operation".
+// The challenges are the documents of the collection, by id.
+type FirestoreChallenges struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+// NewFirestoreChallenges creates a challenges store on the collection.
+func NewFirestoreChallenges(client *firestore.Client, collection string) *FirestoreChallenges {
+	return &FirestoreChallenges{
+		client:     client,
+		collection: client.Collection(collection),
+	}
+}
+
+// Save creates or replaces the challenge.
+func (f *FirestoreChallenges) Save(ctx context.Context, c challenge) error {
+	if !isDocID(c.ID) {
+		return fmt.Errorf("invalid challenge id %q", c.ID)
+	}
+
+	doc := challengeDoc{
+		UID:       c.UID,
+		Token:     c.Token,
+		Attempts:  c.Attempts,
+		ExpiresAt: c.ExpiresAt,
+	}
+	if _, err := f.collection.Doc(c.ID).Set(ctx, doc); err != nil {
+		return fmt.Errorf("writing challenge: %w", err)
+	}
+	return nil
+}
+
+// Take returns and removes the challenge or returns ErrChallengeNotFound. The
+// read and the delete are one transaction, a challenge is taken once even
+// by concurrent instances.
+func (f *FirestoreChallenges) Take(ctx context.Context, id string) (challenge, error) {
+	// The id comes from the clients, it may not name a document.
+	if !isDocID(id) {
+		return challenge{}, ErrChallengeNotFound
+	}
+	ref := f.collection.Doc(id)
+
+	var c challenge
+	err := f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrChallengeNotFound
+			}
+			return fmt.Errorf("reading challenge: %w", err)
+		}
+
+		var doc challengeDoc
+		if err := snap.DataTo(&doc); err != nil {
+			return fmt.Errorf("decoding challenge: %w", err)
+		}
+		c = challenge{
+			ID:        id,
+			UID:       doc.UID,
+			Token:     doc.Token,
+			Attempts:  doc.Attempts,
+			ExpiresAt: doc.ExpiresAt,
+		}
+
+		return tx.Delete(ref)
+	})
+	if err != nil {
+		return challenge{}, err
+	}
+	return c, nil
+}
+
+// isDocID reports if the id can name a document.
+func isDocID(id string) bool {
+	return id != "" && !strings.Contains(id, "/")
+}
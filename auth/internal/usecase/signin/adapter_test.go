@@ -0,0 +1,233 @@
+package signin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/lockout"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// serveSignIn calls the sign-in handler with the authorization header.
+func serveSignIn(env testEnv, authorization string) (*httptest.ResponseRecorder, error) {
+	h := HttpHandler(env.s, auth.Cookies{Secure: true})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/signin", nil)
+	r.Header.Set("Authorization", authorization)
+	w := httptest.NewRecorder()
+
+	return w, h(env.ctx, w, r)
+}
+
+func TestHttpHandler(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	uid, idToken := env.signUp(t, "ana@example.com")
+
+	w, err := serveSignIn(env, "jwt "+idToken)
+	if err != nil {
+		t.Fatalf("handling: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want the session one", len(cookies))
+	}
+	c := cookies[0]
+	if c.Name != auth.SessionCookieName || !c.Secure || !c.HttpOnly {
+		t.Fatalf("got cookie %s secure %t http only %t, want the session one", c.Name, c.Secure, c.HttpOnly)
+	}
+
+	tkn, err := env.p.VerifySession(env.ctx, c.Value)
+	if err != nil {
+		t.Fatalf("verifying session: %v", err)
+	}
+	if tkn.UID != uid {
+		t.Fatalf("got session of %s, want the one of %s", tkn.UID, uid)
+	}
+}
+
+func TestHttpHandlerSecondFactor(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	uid, idToken := env.signUp(t, "ana@example.com")
+	env.sf.enrolled[uid] = true
+
+	w, err := serveSignIn(env, "jwt "+idToken)
+	if err != nil {
+		t.Fatalf("handling: %v", err)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		t.Fatalf("got cookies %v, want none before the second factor", cookies)
+	}
+
+	var resp struct {
+		Status    string
+		Challenge string
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Status != "MFA required" || resp.Challenge == "" {
+		t.Fatalf("got response %+v, want a challenge", resp)
+	}
+
+	h := MFAHttpHandler(env.s, auth.Cookies{Secure: true})
+	body := `{"challenge":"` + resp.Challenge + `","code":"` + testCode + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/signin/mfa", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	if err := h(env.ctx, w, r); err != nil {
+		t.Fatalf("handling second factor: %v", err)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != auth.SessionCookieName {
+		t.Fatalf("got cookies %v, want the session one", cookies)
+	}
+}
+
+func TestHttpHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		deny          error
+		want          int
+		retryAfter    string
+	}{
+		{"missing token", "", nil, http.StatusUnauthorized, ""},
+		{"wrong scheme", "Bearer abc", nil, http.StatusUnauthorized, ""},
+		{"invalid token", "jwt abc", nil, http.StatusUnauthorized, ""},
+		{"throttled", "jwt abc", errDenied{}, http.StatusTooManyRequests, "2"},
+		{"locked", "jwt abc", errDenied{locked: true}, http.StatusLocked, "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, tenant.Policy{})
+			env.g.deny = tt.deny
+
+			w, err := serveSignIn(env, tt.authorization)
+
+			re := webapp.GetRequestError(err)
+			if re == nil || re.Status != tt.want {
+				t.Fatalf("got error %v, want a request error with status %d", err, tt.want)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("got retry after %q, want %q", got, tt.retryAfter)
+			}
+			if cookies := w.Result().Cookies(); len(cookies) != 0 {
+				t.Errorf("got cookies %v, want none", cookies)
+			}
+		})
+	}
+}
+
+func TestHttpHandlerLockout(t *testing.T) {
+	tests := []struct {
+		name         string
+		freeAttempts int
+		want         int
+		retryAfter   string
+	}{
+		{"throttled", 1, http.StatusTooManyRequests, "60"},
+		{"locked", 2, http.StatusLocked, "900"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := lockout.NewPolicy(time.Hour, tt.freeAttempts, time.Minute, time.Hour, 3, 15*time.Minute)
+			if err != nil {
+				t.Fatalf("constructing policy: %v", err)
+			}
+			env := newTestEnv(t, tenant.Policy{})
+			g := lockout.NewService(lockout.NewMemory(), p, p, env.a)
+			env.s = NewService(NewMemory(env.p), env.sf, NewMemoryChallenges(), g, fakePolicies{}, env.a)
+			env.ctx = web.SetValues(env.ctx, &web.Values{ClientIP: "192.0.2.1"})
+
+			// The invalid tokens count against the client ip.
+			for i := 0; i < tt.freeAttempts+1; i++ {
+				_, err := serveSignIn(env, "jwt abc")
+				if re := webapp.GetRequestError(err); re == nil || re.Status != http.StatusUnauthorized {
+					t.Fatalf("got error %v on attempt %d, want a request error with status %d", err, i+1, http.StatusUnauthorized)
+				}
+			}
+
+			w, err := serveSignIn(env, "jwt abc")
+			if re := webapp.GetRequestError(err); re == nil || re.Status != tt.want {
+				t.Fatalf("got error %v, want a request error with status %d", err, tt.want)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Fatalf("got retry after %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestSignOutHttpHandler(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	uid, _ := env.signUp(t, "ana@example.com")
+	h := SignOutHttpHandler(env.s, auth.Cookies{Secure: true})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/signout", nil)
+	if err := h(env.ctx, httptest.NewRecorder(), r); webapp.GetRequestError(err) == nil {
+		t.Fatalf("got error %v, want a request error without claims", err)
+	}
+
+	ctx := auth.SetClaims(env.ctx, auth.Claims{UID: uid})
+	w := httptest.NewRecorder()
+	if err := h(ctx, w, r.WithContext(ctx)); err != nil {
+		t.Fatalf("handling: %v", err)
+	}
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != auth.SessionCookieName || cookies[0].MaxAge >= 0 {
+		t.Fatalf("got cookies %v, want the session one cleared", cookies)
+	}
+}
+
+func TestPasswordHttpHandler(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	uid, _ := env.signUp(t, "ana@example.com")
+	h := PasswordHttpHandler(env.p)
+
+	body := `{"email":"ana@example.com","password":"` + testPassword + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/signin/password", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	if err := h(env.ctx, w, r); err != nil {
+		t.Fatalf("handling: %v", err)
+	}
+
+	var resp struct {
+		IDToken string `json:"idToken"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	// The id token goes to the sign-in route like a firebase one.
+	tkn, err := NewMemory(env.p).VerifyToken(env.ctx, resp.IDToken)
+	if err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	if tkn.UID != uid || tkn.isOld() {
+		t.Fatalf("got token of %s at %d, want a recent one of %s", tkn.UID, tkn.AuthTime, uid)
+	}
+
+	body = `{"email":"ana@example.com","password":"A-wrong-passw0rd"}`
+	r = httptest.NewRequest(http.MethodPost, "/api/signin/password", strings.NewReader(body))
+	err = h(env.ctx, httptest.NewRecorder(), r)
+	if re := webapp.GetRequestError(err); re == nil || re.Status != http.StatusUnauthorized {
+		t.Fatalf("got error %v, want a request error with status %d", err, http.StatusUnauthorized)
+	}
+}
@@ -17,3 +17,26 @@ func (t token) isOld() bool {
 	signInTime := time.Now().Unix() - t.AuthTime
 	return signInTime > 5*60
 }
+
+// challenge represents a sign-in waiting for the second factor.
+type challenge struct {
+	ID  string
+	UID string
+	// Token is the verified token used to create the session once the
+	// second factor is verified.
+	Token     string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+func (c challenge) isExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// outcome represents the result of a sign-in. When MFARequired is set
+// the session is only created after the challenge is verified.
+type outcome struct {
+	Session     Session
+	MFARequired bool
+	Challenge   challenge
+}
@@ -0,0 +1,17 @@
+package signin
+
+import "errors"
+
+var (
+	// ErrInvalidToken is used when the token can't be verified.
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrRecentSignInRequired is used when the token was issued for an old sign-in.
+	ErrRecentSignInRequired = errors.New("recent sign-in required")
+
+	// ErrChallengeNotFound is used when the challenge does not exist or expired.
+	ErrChallengeNotFound = errors.New("challenge not found")
+
+	// ErrInvalidCode is used when the second factor code is not valid.
+	ErrInvalidCode = errors.New("invalid code")
+)
@@ -0,0 +1,172 @@
+package signin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	firebase "firebase.google.com/go/v4"
+	fbauthn "firebase.google.com/go/v4/auth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/roles"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/testsupport/fakeauth"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+const (
+	testProjectID = "demo-test"
+	testPassword  = "A-passw0rd"
+	sessionTTL    = 48 * time.Hour
+)
+
+// newTestClient starts a fake of the auth emulator and returns it with the
+// admin client talking to it.
+func newTestClient(t *testing.T) (*fakeauth.Server, *fbauthn.Client) {
+	t.Helper()
+
+	srv, err := fakeauth.New(testProjectID)
+	if err != nil {
+		t.Fatalf("starting fake: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	t.Setenv("FIREBASE_AUTH_EMULATOR_HOST", srv.Host())
+
+	app, err := firebase.NewApp(context.Background(), &firebase.Config{ProjectID: testProjectID})
+	if err != nil {
+		t.Fatalf("creating app: %v", err)
+	}
+	client, err := app.Auth(context.Background())
+	if err != nil {
+		t.Fatalf("creating auth client: %v", err)
+	}
+	return srv, client
+}
+
+// tenantContext returns the context of a request for the tenant, the one of
+// the project without a tenant.
+func tenantContext(tenantID string) context.Context {
+	return web.SetValues(context.Background(), &web.Values{TenantID: tenantID})
+}
+
+// createUser creates the user in the tenant and sets its custom claims.
+func createUser(t *testing.T, client *fbauthn.Client, tenantID string, email string, claims map[string]interface{}) string {
+	t.Helper()
+	ctx := tenantContext(tenantID)
+	users := webapp.FirebaseTenant(ctx, client)
+
+	u, err := users.CreateUser(ctx, (&fbauthn.UserToCreate{}).Email(email).Password(testPassword))
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	if claims != nil {
+		if err := users.SetCustomUserClaims(ctx, u.UID, claims); err != nil {
+			t.Fatalf("setting claims: %v", err)
+		}
+	}
+	return u.UID
+}
+
+// nextSecond waits for the next second. The revocations are second
+// granular, a session of the same second outlives them.
+func nextSecond() {
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+}
+
+func TestFirebaseSession(t *testing.T) {
+	srv, client := newTestClient(t)
+	fb := NewFirebase(client)
+	verifier := auth.NewFirebase(client)
+	ctx := tenantContext("")
+
+	uid := createUser(t, client, "", "ana@example.com", map[string]interface{}{"roles": []string{"organizer"}})
+
+	idToken, err := srv.SignIn("", "ana@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("signing in: %v", err)
+	}
+
+	tkn, err := fb.VerifyToken(ctx, idToken)
+	if err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	if tkn.UID != uid || tkn.AuthTime == 0 {
+		t.Fatalf("got token of %s at %d, want the one of %s", tkn.UID, tkn.AuthTime, uid)
+	}
+
+	ses, err := fb.SessionCookie(ctx, idToken, sessionTTL)
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	if ses.ExpiresIn != sessionTTL {
+		t.Fatalf("got session for %v, want %v", ses.ExpiresIn, sessionTTL)
+	}
+
+	// The session carries the custom claims of the user.
+	claims, err := verifier.VerifySession(ctx, ses.Value)
+	if err != nil {
+		t.Fatalf("verifying session: %v", err)
+	}
+	if claims.UID != uid || claims.Email != "ana@example.com" || !claims.Authorized(roles.Organizer) {
+		t.Fatalf("got claims %+v, want the ones of %s with its role", claims, uid)
+	}
+
+	nextSecond()
+	if err := fb.RevokeSessions(ctx, uid); err != nil {
+		t.Fatalf("revoking sessions: %v", err)
+	}
+	if _, err := verifier.VerifySession(ctx, ses.Value); err == nil {
+		t.Fatal("got the revoked session verified")
+	}
+}
+
+// Like with the emulator, the sdk looks the users up in the project to
+// verify any session, so the sessions of the tenants are only created here.
+func TestFirebaseTenantIsolation(t *testing.T) {
+	srv, client := newTestClient(t)
+	fb := NewFirebase(client)
+	ctxA := tenantContext("tenant-a")
+
+	uid := createUser(t, client, "tenant-a", "ana@example.com", map[string]interface{}{"roles": []string{"organizer"}})
+
+	idToken, err := srv.SignIn("tenant-a", "ana@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("signing in: %v", err)
+	}
+	if _, err := srv.SignIn("tenant-b", "ana@example.com", testPassword); err == nil {
+		t.Fatal("got a sign-in in the tenant without the user")
+	}
+
+	// The token of a tenant is only accepted by its tenant.
+	tkn, err := fb.VerifyToken(ctxA, idToken)
+	if err != nil {
+		t.Fatalf("verifying token: %v", err)
+	}
+	if tkn.UID != uid {
+		t.Fatalf("got token of %s, want the one of %s", tkn.UID, uid)
+	}
+	if _, err := fb.VerifyToken(tenantContext("tenant-b"), idToken); err == nil {
+		t.Fatal("got the token of tenant-a verified for tenant-b")
+	}
+
+	// The project creates the sessions of all the tenants.
+	if _, err := fb.SessionCookie(ctxA, idToken, sessionTTL); err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+
+	// The revocation is recorded on the user of the tenant only.
+	nextSecond()
+	if err := fb.RevokeSessions(ctxA, uid); err != nil {
+		t.Fatalf("revoking sessions: %v", err)
+	}
+	u, err := webapp.FirebaseTenant(ctxA, client).GetUser(ctxA, uid)
+	if err != nil {
+		t.Fatalf("reading user: %v", err)
+	}
+	if u.TokensValidAfterMillis <= tkn.AuthTime*1000 {
+		t.Fatalf("got tokens valid after %d, want after the sign-in at %d", u.TokensValidAfterMillis, tkn.AuthTime*1000)
+	}
+	if err := fb.RevokeSessions(tenantContext("tenant-b"), uid); err == nil {
+		t.Fatal("got the sessions of the tenant-a user revoked from tenant-b")
+	}
+}
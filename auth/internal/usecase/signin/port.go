@@ -1,19 +1,67 @@
 package signin
 
 import (
+	"context"
 	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
 )
 
 // (Port) Service defines how the interaction between the "core" and the "signin http handler" has to be done.
 type signInService interface {
-	// Signin returns the session cookie.
-	SignIn(tkn string) (Session, error)
+	// Signin returns the session cookie or the challenge for the second factor.
+	SignIn(ctx context.Context, tkn string, clientIP string) (outcome, error)
+	// VerifyChallenge verifies the second factor code and returns the session cookie.
+	VerifyChallenge(ctx context.Context, challengeID string, code string, clientIP string) (Session, error)
+	// SignOut revokes the sessions of the user.
+	SignOut(ctx context.Context, uid string) error
 }
 
 // (Port) AuthnProvider defines how the interaction between the "core" and the "authn provider" has to be done.
 type authnProvider interface {
 	// VerifyToken verifies the signature and payload of the provided token.
-	VerifyToken(tkn string) (token, error)
+	VerifyToken(ctx context.Context, tkn string) (token, error)
 	// SessionCookie creates a new session cookie from the given token and expiry duration.
-	SessionCookie(tkn string, expiresIn time.Duration) (Session, error)
+	SessionCookie(ctx context.Context, tkn string, expiresIn time.Duration) (Session, error)
+	// RevokeSessions revokes all the sessions of the user with the given uid.
+	RevokeSessions(ctx context.Context, uid string) error
+}
+
+// (Port) SecondFactor defines how the interaction between the "core" and the "mfa provider" has to be done.
+type secondFactor interface {
+	// Enrolled reports if the user must provide a second factor.
+	Enrolled(ctx context.Context, uid string) (bool, error)
+	// Verify reports if the code is valid for the user.
+	Verify(ctx context.Context, uid string, code string) (bool, error)
+}
+
+// (Port) ChallengeStore defines how the interaction between the "core" and the "challenges storage" has to be done.
+type ChallengeStore interface {
+	// Save creates or replaces the challenge.
+	Save(ctx context.Context, c challenge) error
+	// Take returns and removes the challenge or returns ErrChallengeNotFound.
+	Take(ctx context.Context, id string) (challenge, error)
+}
+
+// (Port) Guard defines how the interaction between the "core" and the "brute-force protection" has to be done.
+type guard interface {
+	// Check returns an error if an attempt for the uid or the ip is not allowed yet.
+	Check(ctx context.Context, uid string, ip string) error
+	// Fail records a failed attempt for the uid and the ip.
+	Fail(ctx context.Context, uid string, ip string) error
+	// Succeed clears the failed attempts of the uid.
+	Succeed(ctx context.Context, uid string) error
+}
+
+// (Port) Policies defines how the interaction between the "core" and the "tenant policies" has to be done.
+type policies interface {
+	// Policy returns the policy of the tenant of the request.
+	Policy(ctx context.Context) tenant.Policy
+}
+
+// (Port) Auditor defines how the interaction between the "core" and the "audit log" has to be done.
+type auditor interface {
+	// Record appends the event to the audit log.
+	Record(ctx context.Context, e audit.Event)
 }
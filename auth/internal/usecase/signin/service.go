@@ -1,42 +1,243 @@
 package signin
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/metrics"
+)
+
+// signInFailures counts the failed sign-in attempts by reason.
+var signInFailures = metrics.NewCounter("auth_signin_failures_total",
+	"Number of failed sign-in attempts by reason.", "reason")
+
+// These are the settings of the second factor challenge.
+const (
+	challengeTTL         = 5 * time.Minute
+	challengeMaxAttempts = 5
 )
 
 // Service represents "signin" core service.
 type service struct {
-	p authnProvider
+	p   authnProvider
+	sf  secondFactor
+	cs  ChallengeStore
+	g   guard
+	pol policies
+	a   auditor
 }
 
 // NewService creates a "signin" core service with the necessary dependencies.
-func NewService(p authnProvider) *service {
-	return &service{p: p}
+func NewService(p authnProvider, sf secondFactor, cs ChallengeStore, g guard, pol policies, a auditor) *service {
+	return &service{p: p, sf: sf, cs: cs, g: g, pol: pol, a: a}
+}
+
+// SignIn returns the session cookie. Users enrolled in multi-factor
+// authentication get a short-lived challenge instead.
+func (s *service) SignIn(ctx context.Context, token string, clientIP string) (outcome, error) {
+	out, uid, err := s.signIn(ctx, token, clientIP)
+
+	action := "signin"
+	if out.MFARequired {
+		action = "signin.challenge"
+	}
+	s.audit(ctx, action, uid, err)
+
+	return out, err
 }
 
-// SignIn returns the session cookie.
-func (s *service) SignIn(token string) (Session, error) {
-	decoded, err := s.p.VerifyToken(token)
+func (s *service) signIn(ctx context.Context, token string, clientIP string) (outcome, string, error) {
+	if err := s.g.Check(ctx, "", clientIP); err != nil {
+		return outcome{}, "", err
+	}
+
+	decoded, err := s.p.VerifyToken(ctx, token)
 	if err != nil {
-		//return v1Web.NewRequestError(auth.ErrInvalidToken, http.StatusUnauthorized)
-		return Session{}, err
+		if gErr := s.g.Fail(ctx, "", clientIP); gErr != nil {
+			return outcome{}, "", gErr
+		}
+		return outcome{}, "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	// A user locked out by failed second factors stays locked out
+	// even with a valid token.
+	if err := s.g.Check(ctx, decoded.UID, ""); err != nil {
+		return outcome{}, decoded.UID, err
 	}
 
 	// Return error if the sign-in is older than 5 minutes.
 	if decoded.isOld() {
-		return Session{}, fmt.Errorf("recent sign-in required")
+		return outcome{}, decoded.UID, ErrRecentSignInRequired
+	}
+
+	enrolled, err := s.sf.Enrolled(ctx, decoded.UID)
+	if err != nil {
+		return outcome{}, decoded.UID, fmt.Errorf("checking second factor: %w", err)
+	}
+	if enrolled {
+		c, err := s.newChallenge(ctx, decoded.UID, token)
+		if err != nil {
+			return outcome{}, decoded.UID, err
+		}
+		return outcome{MFARequired: true, Challenge: c}, decoded.UID, nil
+	}
+
+	ses, err := s.session(ctx, token)
+	if err != nil {
+		return outcome{}, decoded.UID, err
+	}
+	return outcome{Session: ses}, decoded.UID, nil
+}
+
+// VerifyChallenge verifies the second factor code and returns the session cookie.
+// The challenge is taken for the attempt, so of two concurrent attempts only
+// one gets it, and it is put back after a wrong code.
+func (s *service) VerifyChallenge(ctx context.Context, challengeID string, code string, clientIP string) (Session, error) {
+	c, err := s.cs.Take(ctx, challengeID)
+	if err != nil {
+		s.audit(ctx, "signin.mfa", "", err)
+		return Session{}, err
+	}
+
+	ses, err := s.verifyChallenge(ctx, c, code, clientIP)
+	s.audit(ctx, "signin.mfa", c.UID, err)
+
+	return ses, err
+}
+
+func (s *service) verifyChallenge(ctx context.Context, c challenge, code string, clientIP string) (Session, error) {
+	if c.isExpired() || c.Attempts >= challengeMaxAttempts {
+		return Session{}, ErrChallengeNotFound
+	}
+
+	// The attempts stopped before the code is checked don't count.
+	if err := s.g.Check(ctx, c.UID, clientIP); err != nil {
+		return Session{}, s.putBack(ctx, c, err)
 	}
 
-	// Set session expiration to 2 days.
+	ok, err := s.sf.Verify(ctx, c.UID, code)
+	if err != nil {
+		return Session{}, s.putBack(ctx, c, fmt.Errorf("verifying second factor: %w", err))
+	}
+	if !ok {
+		// The challenge is dropped with the last attempt.
+		c.Attempts++
+		if c.Attempts < challengeMaxAttempts {
+			if err := s.cs.Save(ctx, c); err != nil {
+				return Session{}, fmt.Errorf("saving challenge: %w", err)
+			}
+		}
+		if err := s.g.Fail(ctx, c.UID, clientIP); err != nil {
+			return Session{}, err
+		}
+		return Session{}, ErrInvalidCode
+	}
+
+	if err := s.g.Succeed(ctx, c.UID); err != nil {
+		return Session{}, err
+	}
+
+	return s.session(ctx, c.Token)
+}
+
+// putBack stores the taken challenge again, so the user can still answer
+// it, and returns the error of the attempt.
+func (s *service) putBack(ctx context.Context, c challenge, err error) error {
+	if serr := s.cs.Save(ctx, c); serr != nil {
+		return fmt.Errorf("saving challenge: %w", serr)
+	}
+	return err
+}
+
+// SignOut revokes the sessions of the user. Firebase revokes the refresh
+// tokens of the user, so the user is signed out of every device.
+func (s *service) SignOut(ctx context.Context, uid string) error {
+	err := s.p.RevokeSessions(ctx, uid)
+	s.audit(ctx, "signout", uid, err)
+	if err != nil {
+		return fmt.Errorf("signout: %w", err)
+	}
+	return nil
+}
+
+// audit records the attempt and counts the failures. Attempts stopped by
+// the brute-force protection are recorded as denied.
+func (s *service) audit(ctx context.Context, action string, uid string, err error) {
+	e := audit.Event{
+		Action:  action,
+		Subject: uid,
+	}.Failed(err)
+
+	var d denial
+	if errors.As(err, &d) {
+		e.Outcome = audit.OutcomeDenied
+	}
+
+	s.a.Record(ctx, e)
+	if err != nil && action != "signout" {
+		signInFailures.Inc(failureReason(err))
+	}
+}
+
+// failureReason maps the error of a sign-in attempt to a bounded set of reasons.
+func failureReason(err error) string {
+	var d denial
+	switch {
+	case errors.As(err, &d) && d.Locked():
+		return "locked"
+	case errors.As(err, &d):
+		return "throttled"
+	case errors.Is(err, ErrInvalidToken):
+		return "invalid_token"
+	case errors.Is(err, ErrRecentSignInRequired):
+		return "recent_signin_required"
+	case errors.Is(err, ErrChallengeNotFound):
+		return "challenge_not_found"
+	case errors.Is(err, ErrInvalidCode):
+		return "invalid_code"
+	}
+	return "error"
+}
+
+// session creates the session cookie for the given token.
+func (s *service) session(ctx context.Context, token string) (Session, error) {
+	// Set session expiration to 2 days, unless the tenant has its own.
 	expiresIn := time.Hour * 24 * 2
+	if ttl := s.pol.Policy(ctx).SessionTTL; ttl > 0 {
+		expiresIn = ttl
+	}
 
 	// Create the session cookie. This will also verify the ID token in the process.
 	// The session cookie will have the same claims as the ID token.
-	ses, err := s.p.SessionCookie(token, expiresIn)
+	ses, err := s.p.SessionCookie(ctx, token, expiresIn)
 	if err != nil {
 		return Session{}, fmt.Errorf("failed to create a session cookie: %w", err)
 	}
 
 	return ses, nil
 }
+
+// newChallenge stores a challenge for the second factor of the user.
+func (s *service) newChallenge(ctx context.Context, uid string, token string) (challenge, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return challenge{}, fmt.Errorf("generating challenge: %w", err)
+	}
+
+	c := challenge{
+		ID:        base64.RawURLEncoding.EncodeToString(b),
+		UID:       uid,
+		Token:     token,
+		ExpiresAt: time.Now().Add(challengeTTL),
+	}
+	if err := s.cs.Save(ctx, c); err != nil {
+		return challenge{}, fmt.Errorf("saving challenge: %w", err)
+	}
+
+	return c, nil
+}
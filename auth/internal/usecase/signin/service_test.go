@@ -0,0 +1,293 @@
+package signin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/memauth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+)
+
+const testCode = "123456"
+
+// fakeSecondFactor enrolls the users of the set, their code is testCode.
+type fakeSecondFactor struct {
+	enrolled map[string]bool
+}
+
+func (f fakeSecondFactor) Enrolled(ctx context.Context, uid string) (bool, error) {
+	return f.enrolled[uid], nil
+}
+
+func (f fakeSecondFactor) Verify(ctx context.Context, uid string, code string) (bool, error) {
+	return code == testCode, nil
+}
+
+// errDenied is the error of the fake guard.
+type errDenied struct {
+	locked bool
+}
+
+func (e errDenied) Error() string             { return "denied" }
+func (e errDenied) RetryAfter() time.Duration { return 1500 * time.Millisecond }
+func (e errDenied) Locked() bool              { return e.locked }
+
+// fakeGuard denies the attempts once deny is set and counts the failures.
+type fakeGuard struct {
+	deny     error
+	failures int
+}
+
+func (g *fakeGuard) Check(ctx context.Context, uid string, ip string) error {
+	return g.deny
+}
+
+func (g *fakeGuard) Fail(ctx context.Context, uid string, ip string) error {
+	g.failures++
+	return nil
+}
+
+func (g *fakeGuard) Succeed(ctx context.Context, uid string) error {
+	g.failures = 0
+	return nil
+}
+
+// fakePolicies returns the same policy for every request.
+type fakePolicies struct {
+	policy tenant.Policy
+}
+
+func (p fakePolicies) Policy(ctx context.Context) tenant.Policy {
+	return p.policy
+}
+
+// fakeAuditor keeps the recorded events.
+type fakeAuditor struct {
+	events []audit.Event
+}
+
+func (a *fakeAuditor) Record(ctx context.Context, e audit.Event) {
+	a.events = append(a.events, e)
+}
+
+// last returns the last recorded event.
+func (a *fakeAuditor) last() audit.Event {
+	if len(a.events) == 0 {
+		return audit.Event{}
+	}
+	return a.events[len(a.events)-1]
+}
+
+// testEnv holds the service and its dependencies.
+type testEnv struct {
+	s   *service
+	p   *memauth.Provider
+	sf  fakeSecondFactor
+	g   *fakeGuard
+	a   *fakeAuditor
+	ctx context.Context
+}
+
+// newTestEnv constructs the service on the in-memory provider.
+func newTestEnv(t *testing.T, policy tenant.Policy) testEnv {
+	t.Helper()
+
+	p, err := memauth.New()
+	if err != nil {
+		t.Fatalf("constructing provider: %v", err)
+	}
+
+	env := testEnv{
+		p:   p,
+		sf:  fakeSecondFactor{enrolled: make(map[string]bool)},
+		g:   &fakeGuard{},
+		a:   &fakeAuditor{},
+		ctx: context.Background(),
+	}
+	env.s = NewService(NewMemory(p), env.sf, NewMemoryChallenges(), env.g, fakePolicies{policy: policy}, env.a)
+	return env
+}
+
+// signUp creates the user and returns its uid and an id token.
+func (env testEnv) signUp(t *testing.T, email string) (string, string) {
+	t.Helper()
+
+	u, err := env.p.CreateUser(env.ctx, email, testPassword, "")
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	idToken, err := env.p.SignInWithPassword(env.ctx, email, testPassword)
+	if err != nil {
+		t.Fatalf("signing in with password: %v", err)
+	}
+	return u.UID, idToken
+}
+
+func TestSignIn(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	uid, idToken := env.signUp(t, "ana@example.com")
+
+	out, err := env.s.SignIn(env.ctx, idToken, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("signing in: %v", err)
+	}
+	if out.MFARequired {
+		t.Fatal("got a challenge, want a session")
+	}
+	if out.Session.ExpiresIn != 48*time.Hour {
+		t.Errorf("got session for %v, want the default %v", out.Session.ExpiresIn, 48*time.Hour)
+	}
+
+	tkn, err := env.p.VerifySession(env.ctx, out.Session.Value)
+	if err != nil {
+		t.Fatalf("verifying session: %v", err)
+	}
+	if tkn.UID != uid {
+		t.Fatalf("got session of %s, want the one of %s", tkn.UID, uid)
+	}
+
+	if e := env.a.last(); e.Action != "signin" || e.Subject != uid || e.Outcome != audit.OutcomeSuccess {
+		t.Fatalf("got event %+v, want the successful sign-in of %s", e, uid)
+	}
+}
+
+func TestSignInTenantSessionTTL(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{SessionTTL: 24 * time.Hour})
+	_, idToken := env.signUp(t, "ana@example.com")
+
+	out, err := env.s.SignIn(env.ctx, idToken, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("signing in: %v", err)
+	}
+	if out.Session.ExpiresIn != 24*time.Hour {
+		t.Fatalf("got session for %v, want the one of the tenant", out.Session.ExpiresIn)
+	}
+}
+
+func TestSignInInvalidToken(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	_, idToken := env.signUp(t, "ana@example.com")
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		token string
+	}{
+		{"malformed", env.ctx, "not-a-token"},
+		{"other tenant", tenantContext("tenant-a"), idToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := env.g.failures
+
+			_, err := env.s.SignIn(tt.ctx, tt.token, "192.0.2.1")
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("got error %v, want %v", err, ErrInvalidToken)
+			}
+			if env.g.failures != failures+1 {
+				t.Fatalf("got %d failures, want %d", env.g.failures, failures+1)
+			}
+			if e := env.a.last(); e.Outcome != audit.OutcomeFailure {
+				t.Fatalf("got outcome %q, want %q", e.Outcome, audit.OutcomeFailure)
+			}
+		})
+	}
+}
+
+func TestSignInDenied(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	_, idToken := env.signUp(t, "ana@example.com")
+	env.g.deny = errDenied{locked: true}
+
+	_, err := env.s.SignIn(env.ctx, idToken, "192.0.2.1")
+	var d denial
+	if !errors.As(err, &d) || !d.Locked() {
+		t.Fatalf("got error %v, want the lockout", err)
+	}
+	if e := env.a.last(); e.Outcome != audit.OutcomeDenied {
+		t.Fatalf("got outcome %q, want %q", e.Outcome, audit.OutcomeDenied)
+	}
+}
+
+func TestSignInSecondFactor(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	uid, idToken := env.signUp(t, "ana@example.com")
+	env.sf.enrolled[uid] = true
+
+	out, err := env.s.SignIn(env.ctx, idToken, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("signing in: %v", err)
+	}
+	if !out.MFARequired || out.Challenge.ID == "" || out.Session.Value != "" {
+		t.Fatalf("got outcome %+v, want a challenge only", out)
+	}
+
+	if _, err := env.s.VerifyChallenge(env.ctx, out.Challenge.ID, "000000", "192.0.2.1"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidCode)
+	}
+	if env.g.failures != 1 {
+		t.Fatalf("got %d failures, want 1", env.g.failures)
+	}
+
+	ses, err := env.s.VerifyChallenge(env.ctx, out.Challenge.ID, testCode, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("verifying challenge: %v", err)
+	}
+	if _, err := env.p.VerifySession(env.ctx, ses.Value); err != nil {
+		t.Fatalf("verifying session: %v", err)
+	}
+	if env.g.failures != 0 {
+		t.Fatalf("got %d failures, want them cleared", env.g.failures)
+	}
+
+	// A challenge is used once.
+	if _, err := env.s.VerifyChallenge(env.ctx, out.Challenge.ID, testCode, "192.0.2.1"); !errors.Is(err, ErrChallengeNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrChallengeNotFound)
+	}
+}
+
+func TestSignInChallengeAttempts(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	uid, idToken := env.signUp(t, "ana@example.com")
+	env.sf.enrolled[uid] = true
+
+	out, err := env.s.SignIn(env.ctx, idToken, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("signing in: %v", err)
+	}
+	for i := 0; i < challengeMaxAttempts; i++ {
+		if _, err := env.s.VerifyChallenge(env.ctx, out.Challenge.ID, "000000", "192.0.2.1"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("got error %v on attempt %d, want %v", err, i+1, ErrInvalidCode)
+		}
+	}
+
+	// The right code comes too late.
+	if _, err := env.s.VerifyChallenge(env.ctx, out.Challenge.ID, testCode, "192.0.2.1"); !errors.Is(err, ErrChallengeNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrChallengeNotFound)
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	env := newTestEnv(t, tenant.Policy{})
+	uid, idToken := env.signUp(t, "ana@example.com")
+
+	out, err := env.s.SignIn(env.ctx, idToken, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("signing in: %v", err)
+	}
+
+	nextSecond()
+	if err := env.s.SignOut(env.ctx, uid); err != nil {
+		t.Fatalf("signing out: %v", err)
+	}
+	if _, err := env.p.VerifySession(env.ctx, out.Session.Value); !errors.Is(err, memauth.ErrRevoked) {
+		t.Fatalf("got error %v, want %v", err, memauth.ErrRevoked)
+	}
+	if e := env.a.last(); e.Action != "signout" || e.Subject != uid {
+		t.Fatalf("got event %+v, want the sign-out of %s", e, uid)
+	}
+}
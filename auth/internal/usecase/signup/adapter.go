@@ -2,12 +2,15 @@ package signup
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	fbauthn "firebase.google.com/go/v4/auth"
 	fberrors "firebase.google.com/go/v4/errorutils"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/memauth"
 	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
 )
 
 // signUpRequestDto represents the payload request contract.
@@ -17,7 +20,7 @@ type signUpRequestDto struct {
 	DisplayName string `json:"displayName"`
 }
 
-// dtoToUser transforms signup payload (dto) into user domain struct.
+// dtoToSignUpUser transforms signup payload (dto) into the SignUpUser value object.
 func dtoToSignUpUser(dto signUpRequestDto) (SignUpUser, error) {
 	su, err := NewSignUpUser(dto.Email, dto.Password, dto.DisplayName)
 	if err != nil {
@@ -47,16 +50,22 @@ func HttpHandler(s Service) web.Handler {
 		// decode payload
 		var reqDto signUpRequestDto
 		if err := web.Decode(r, &reqDto); err != nil {
-			return fmt.Errorf("unable to decode payload: %w", err)
+			return webapp.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
 		}
 
 		// business logic
 		su, err := dtoToSignUpUser(reqDto)
 		if err != nil {
-			return fmt.Errorf("invalid payload: %w", err)
+			return webapp.NewRequestError(fmt.Errorf("invalid payload: %w", err), http.StatusBadRequest)
 		}
 		usr, err := s.SignUp(ctx, su)
 		if err != nil {
+			switch {
+			case errors.Is(err, ErrWeakPassword):
+				return webapp.NewRequestError(err, http.StatusBadRequest)
+			case errors.Is(err, ErrDuplicate):
+				return webapp.NewRequestError(err, http.StatusConflict)
+			}
 			return fmt.Errorf("unable to signup %w", err)
 		}
 
@@ -80,8 +89,11 @@ func NewFirebase(client *fbauthn.Client) *Firebase {
 
 // Create adds a new user in firebase with the specified properties.
 func (fb Firebase) Create(ctx context.Context, su SignUpUser) (user, error) {
+	ctx, done := webapp.StartFirebase(ctx, "signup.create")
+	defer done()
+
 	fbUser := ToFirebaseUser(su)
-	u, err := fb.client.CreateUser(ctx, &fbUser)
+	u, err := webapp.FirebaseTenant(ctx, fb.client).CreateUser(ctx, &fbUser)
 	if err != nil {
 		if fberrors.IsAlreadyExists(err) {
 			return user{}, ErrDuplicate
@@ -95,3 +107,32 @@ func (fb Firebase) Create(ctx context.Context, su SignUpUser) (user, error) {
 		DisplayName: u.DisplayName,
 	}, nil
 }
+
+// (Adapter) Memory transforms a "signup core service call" into a "call on the in-memory authn provider".
+type Memory struct {
+	p *memauth.Provider
+}
+
+// NewMemory sets an in-memory authentication provider for signup use case.
+func NewMemory(p *memauth.Provider) *Memory {
+	return &Memory{
+		p: p,
+	}
+}
+
+// Create adds a new user in the in-memory provider with the specified properties.
+func (m Memory) Create(ctx context.Context, su SignUpUser) (user, error) {
+	u, err := m.p.CreateUser(ctx, su.Email, su.Password, su.DisplayName)
+	if err != nil {
+		if errors.Is(err, memauth.ErrEmailExists) {
+			return user{}, ErrDuplicate
+		}
+		return user{}, fmt.Errorf("memory creating user: %w", err)
+	}
+
+	return user{
+		UID:         u.UID,
+		Email:       u.Email,
+		DisplayName: u.DisplayName,
+	}, nil
+}
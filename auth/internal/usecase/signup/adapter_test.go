@@ -0,0 +1,74 @@
+package signup
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+)
+
+// serveSignUp calls the handler of the service with the body.
+func serveSignUp(s *service, body string) (*httptest.ResponseRecorder, error) {
+	h := HttpHandler(s)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/signup", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	return w, h(context.Background(), w, r)
+}
+
+func TestHttpHandler(t *testing.T) {
+	s, _, _ := newTestService(t, tenant.Policy{})
+
+	w, err := serveSignUp(s, `{"email":"ana@example.com","password":"A-passw0rd","displayName":"Ana"}`)
+	if err != nil {
+		t.Fatalf("handling: %v", err)
+	}
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusCreated)
+	}
+
+	var resp signUpResponseDto
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Email != "ana@example.com" || resp.DisplayName != "Ana" {
+		t.Fatalf("got response %+v, want the signed up user", resp)
+	}
+}
+
+func TestHttpHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"malformed payload", `{"email":`, http.StatusBadRequest},
+		{"unknown field", `{"email":"bob@example.com","password":"A-passw0rd","displayName":"Bob","admin":true}`, http.StatusBadRequest},
+		{"invalid email", `{"email":"not-an-email","password":"A-passw0rd","displayName":"Bob"}`, http.StatusBadRequest},
+		{"invalid password", `{"email":"bob@example.com","password":"password","displayName":"Bob"}`, http.StatusBadRequest},
+		{"password too short for the tenant", `{"email":"bob@example.com","password":"B-passw0rd","displayName":"Bob"}`, http.StatusBadRequest},
+		{"duplicate", `{"email":"ana@example.com","password":"A-long-passw0rd","displayName":"Ana"}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService(t, tenant.Policy{PasswordMinLength: 12})
+			if _, err := serveSignUp(s, `{"email":"ana@example.com","password":"A-long-passw0rd","displayName":"Ana"}`); err != nil {
+				t.Fatalf("signing up: %v", err)
+			}
+
+			_, err := serveSignUp(s, tt.body)
+
+			re := webapp.GetRequestError(err)
+			if re == nil || re.Status != tt.want {
+				t.Fatalf("got error %v, want a request error with status %d", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,5 +2,10 @@ package signup
 
 import "errors"
 
-// ErrDuplicate is used when a user already exists.
-var ErrDuplicate = errors.New("user already exists")
+var (
+	// ErrDuplicate is used when a user already exists.
+	ErrDuplicate = errors.New("user already exists")
+
+	// ErrWeakPassword is used when the password is shorter than the policy of the tenant allows.
+	ErrWeakPassword = errors.New("password is too short for the tenant")
+)
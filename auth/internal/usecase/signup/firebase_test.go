@@ -0,0 +1,109 @@
+package signup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	firebase "firebase.google.com/go/v4"
+	fbauthn "firebase.google.com/go/v4/auth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/testsupport/fakeauth"
+)
+
+const testProjectID = "demo-test"
+
+// newTestClient starts a fake of the auth emulator and returns the admin
+// client talking to it.
+func newTestClient(t *testing.T) *fbauthn.Client {
+	t.Helper()
+
+	srv, err := fakeauth.New(testProjectID)
+	if err != nil {
+		t.Fatalf("starting fake: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	t.Setenv("FIREBASE_AUTH_EMULATOR_HOST", srv.Host())
+
+	app, err := firebase.NewApp(context.Background(), &firebase.Config{ProjectID: testProjectID})
+	if err != nil {
+		t.Fatalf("creating app: %v", err)
+	}
+	client, err := app.Auth(context.Background())
+	if err != nil {
+		t.Fatalf("creating auth client: %v", err)
+	}
+	return client
+}
+
+// tenantContext returns the context of a request for the tenant.
+func tenantContext(tenantID string) context.Context {
+	return web.SetValues(context.Background(), &web.Values{TenantID: tenantID})
+}
+
+func TestFirebaseCreate(t *testing.T) {
+	client := newTestClient(t)
+	fb := NewFirebase(client)
+	ctx := context.Background()
+
+	u, err := fb.Create(ctx, SignUpUser{Email: "ana@example.com", Password: "A-passw0rd", DisplayName: "Ana"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	if u.UID == "" || u.Email != "ana@example.com" || u.DisplayName != "Ana" {
+		t.Fatalf("got user %+v, want the created one", u)
+	}
+
+	stored, err := client.GetUserByEmail(ctx, "ana@example.com")
+	if err != nil {
+		t.Fatalf("reading user: %v", err)
+	}
+	if stored.UID != u.UID || stored.DisplayName != "Ana" {
+		t.Fatalf("got stored user %s %q, want %s %q", stored.UID, stored.DisplayName, u.UID, "Ana")
+	}
+
+	_, err = fb.Create(ctx, SignUpUser{Email: "ana@example.com", Password: "A-passw0rd", DisplayName: "Ana"})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("got error %v, want %v", err, ErrDuplicate)
+	}
+}
+
+func TestFirebaseCreateTenants(t *testing.T) {
+	client := newTestClient(t)
+	fb := NewFirebase(client)
+
+	project, err := fb.Create(context.Background(), SignUpUser{Email: "ana@example.com", Password: "A-passw0rd", DisplayName: "Ana"})
+	if err != nil {
+		t.Fatalf("creating project user: %v", err)
+	}
+
+	// The tenants keep their users apart, the same email signs up in each.
+	tenantA, err := fb.Create(tenantContext("tenant-a"), SignUpUser{Email: "ana@example.com", Password: "A-passw0rd", DisplayName: "Ana A"})
+	if err != nil {
+		t.Fatalf("creating tenant user: %v", err)
+	}
+	if tenantA.UID == project.UID {
+		t.Fatalf("got the uid %s of the project user, want a new one", tenantA.UID)
+	}
+
+	_, err = fb.Create(tenantContext("tenant-a"), SignUpUser{Email: "ana@example.com", Password: "A-passw0rd", DisplayName: "Ana A"})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("got error %v, want %v", err, ErrDuplicate)
+	}
+
+	tc, err := client.TenantManager.AuthForTenant("tenant-a")
+	if err != nil {
+		t.Fatalf("creating tenant client: %v", err)
+	}
+	stored, err := tc.GetUserByEmail(context.Background(), "ana@example.com")
+	if err != nil {
+		t.Fatalf("reading tenant user: %v", err)
+	}
+	if stored.UID != tenantA.UID || stored.TenantID != "tenant-a" {
+		t.Fatalf("got tenant user %s of %q, want %s of %q", stored.UID, stored.TenantID, tenantA.UID, "tenant-a")
+	}
+
+	if _, err := client.GetUser(context.Background(), tenantA.UID); !fbauthn.IsUserNotFound(err) {
+		t.Fatalf("got error %v, want the tenant user missing from the project", err)
+	}
+}
@@ -2,6 +2,9 @@ package signup
 
 import (
 	"context"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
 )
 
 // (Port) Service defines how the interaction between the "core" and the "signup http handler" has to be done.
@@ -15,3 +18,15 @@ type AuthnProvider interface {
 	// Create inserts a new user into the authentication provider.
 	Create(context.Context, SignUpUser) (user, error)
 }
+
+// (Port) Policies defines how the interaction between the "core" and the "tenant policies" has to be done.
+type Policies interface {
+	// Policy returns the policy of the tenant of the request.
+	Policy(ctx context.Context) tenant.Policy
+}
+
+// (Port) Auditor defines how the interaction between the "core" and the "audit log" has to be done.
+type Auditor interface {
+	// Record appends the event to the audit log.
+	Record(ctx context.Context, e audit.Event)
+}
@@ -3,21 +3,43 @@ package signup
 import (
 	"context"
 	"fmt"
+	"unicode/utf8"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/metrics"
 )
 
+// signUps counts the sign-ups by outcome.
+var signUps = metrics.NewCounter("auth_signups_total", "Number of sign-ups by outcome.", "outcome")
+
 // Service represents "signup" core service.
 type service struct {
-	ap AuthnProvider
+	ap  AuthnProvider
+	pol Policies
+	a   Auditor
 }
 
 // NewService creates a "signup core service" with the necessary dependencies.
-func NewService(ap AuthnProvider) *service {
-	return &service{ap: ap}
+func NewService(ap AuthnProvider, pol Policies, a Auditor) *service {
+	return &service{ap: ap, pol: pol, a: a}
 }
 
-// SignUp creates a new user.
+// SignUp creates a new user. On top of the rules of every password, the
+// tenant of the request can require longer ones.
 func (s *service) SignUp(ctx context.Context, su SignUpUser) (user, error) {
+	if min := s.pol.Policy(ctx).PasswordMinLength; utf8.RuneCountInString(su.Password) < min {
+		return user{}, fmt.Errorf("signup: %w: at least %d characters", ErrWeakPassword, min)
+	}
+
 	u, err := s.ap.Create(ctx, su)
+	e := audit.Event{
+		Action:  "signup",
+		Subject: u.UID,
+		Details: map[string]string{"email": su.Email},
+	}.Failed(err)
+
+	s.a.Record(ctx, e)
+	signUps.Inc(e.Outcome)
 	if err != nil {
 		return user{}, fmt.Errorf("signup: %w", err)
 	}
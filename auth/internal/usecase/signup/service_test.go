@@ -0,0 +1,104 @@
+package signup
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/audit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/memauth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+)
+
+// fakePolicies returns the same policy for every request.
+type fakePolicies struct {
+	policy tenant.Policy
+}
+
+func (p fakePolicies) Policy(ctx context.Context) tenant.Policy {
+	return p.policy
+}
+
+// fakeAuditor keeps the recorded events.
+type fakeAuditor struct {
+	events []audit.Event
+}
+
+func (a *fakeAuditor) Record(ctx context.Context, e audit.Event) {
+	a.events = append(a.events, e)
+}
+
+// newTestService constructs the service on the in-memory provider.
+func newTestService(t *testing.T, policy tenant.Policy) (*service, *memauth.Provider, *fakeAuditor) {
+	t.Helper()
+
+	p, err := memauth.New()
+	if err != nil {
+		t.Fatalf("constructing provider: %v", err)
+	}
+	a := &fakeAuditor{}
+	return NewService(NewMemory(p), fakePolicies{policy: policy}, a), p, a
+}
+
+func TestSignUp(t *testing.T) {
+	s, p, a := newTestService(t, tenant.Policy{})
+	ctx := context.Background()
+
+	u, err := s.SignUp(ctx, SignUpUser{Email: "ana@example.com", Password: "A-passw0rd", DisplayName: "Ana"})
+	if err != nil {
+		t.Fatalf("signing up: %v", err)
+	}
+	if u.UID == "" || u.Email != "ana@example.com" || u.DisplayName != "Ana" {
+		t.Fatalf("got user %+v, want the signed up one", u)
+	}
+
+	// The user signs in with the password of the sign-up.
+	if _, err := p.SignInWithPassword(ctx, "ana@example.com", "A-passw0rd"); err != nil {
+		t.Fatalf("signing in: %v", err)
+	}
+
+	if len(a.events) != 1 || a.events[0].Subject != u.UID || a.events[0].Outcome != audit.OutcomeSuccess {
+		t.Fatalf("got events %+v, want the successful sign-up of %s", a.events, u.UID)
+	}
+}
+
+func TestSignUpDuplicate(t *testing.T) {
+	s, _, a := newTestService(t, tenant.Policy{})
+	ctx := context.Background()
+
+	if _, err := s.SignUp(ctx, SignUpUser{Email: "ana@example.com", Password: "A-passw0rd", DisplayName: "Ana"}); err != nil {
+		t.Fatalf("signing up: %v", err)
+	}
+
+	// The emails are compared regardless of their case.
+	_, err := s.SignUp(ctx, SignUpUser{Email: "ANA@example.com", Password: "A-passw0rd", DisplayName: "Ana"})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("got error %v, want %v", err, ErrDuplicate)
+	}
+	if last := a.events[len(a.events)-1]; last.Outcome != audit.OutcomeFailure {
+		t.Fatalf("got outcome %q, want %q", last.Outcome, audit.OutcomeFailure)
+	}
+
+	// Another tenant has its own users.
+	if _, err := s.SignUp(tenantContext("tenant-a"), SignUpUser{Email: "ana@example.com", Password: "A-passw0rd", DisplayName: "Ana"}); err != nil {
+		t.Fatalf("signing up in the tenant: %v", err)
+	}
+}
+
+func TestSignUpTenantPasswordLength(t *testing.T) {
+	s, _, a := newTestService(t, tenant.Policy{PasswordMinLength: 12})
+
+	_, err := s.SignUp(context.Background(), SignUpUser{Email: "ana@example.com", Password: "A-passw0rd", DisplayName: "Ana"})
+	if !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("got error %v, want %v", err, ErrWeakPassword)
+	}
+
+	// The short password never reaches the provider.
+	if len(a.events) != 0 {
+		t.Fatalf("got events %+v, want none", a.events)
+	}
+
+	if _, err := s.SignUp(context.Background(), SignUpUser{Email: "ana@example.com", Password: "A-longer-passw0rd", DisplayName: "Ana"}); err != nil {
+		t.Fatalf("signing up: %v", err)
+	}
+}
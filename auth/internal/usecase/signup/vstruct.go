@@ -17,40 +17,62 @@ type SignUpUser struct {
 
 // NewSignUpUser creates a new SignUpUser that is in a valid state.
 func NewSignUpUser(email string, password string, displayName string) (SignUpUser, error) {
+	if err := ValidateEmail(email); err != nil {
+		return SignUpUser{}, err
+	}
+	if err := ValidatePassword(password); err != nil {
+		return SignUpUser{}, err
+	}
+	if err := ValidateDisplayName(displayName); err != nil {
+		return SignUpUser{}, err
+	}
+
+	return SignUpUser{
+		Email:       email,
+		Password:    password,
+		DisplayName: displayName,
+	}, nil
+}
+
+// ValidateEmail checks that the email is a valid address.
+func ValidateEmail(email string) error {
 	if email == "" {
-		return SignUpUser{}, fmt.Errorf("email must be a non-empty string")
+		return fmt.Errorf("email must be a non-empty string")
 	}
 	_, err := mail.ParseAddress(email)
 	if err != nil {
-		return SignUpUser{}, err
+		return err
 	}
+	return nil
+}
 
+// ValidatePassword checks that the password respects the password policy.
+func ValidatePassword(password string) error {
 	if password == "" {
-		return SignUpUser{}, fmt.Errorf("password must be a non-empty string")
+		return fmt.Errorf("password must be a non-empty string")
 	}
 	sixOrMore, number, upper, special := parsePassword(password)
 	if !(sixOrMore) {
-		return SignUpUser{}, fmt.Errorf("password must be 6 or more characters long")
+		return fmt.Errorf("password must be 6 or more characters long")
 	}
 	if !(number) {
-		return SignUpUser{}, fmt.Errorf("password must contain a number")
+		return fmt.Errorf("password must contain a number")
 	}
 	if !(upper) {
-		return SignUpUser{}, fmt.Errorf("password must contain an upper letter")
+		return fmt.Errorf("password must contain an upper letter")
 	}
 	if !(special) {
-		return SignUpUser{}, fmt.Errorf("password must contain a special character")
+		return fmt.Errorf("password must contain a special character")
 	}
+	return nil
+}
 
+// ValidateDisplayName checks that the display name is not empty.
+func ValidateDisplayName(displayName string) error {
 	if displayName == "" {
-		return SignUpUser{}, fmt.Errorf("display name must be a non-empty string")
+		return fmt.Errorf("display name must be a non-empty string")
 	}
-
-	return SignUpUser{
-		Email:       email,
-		Password:    password,
-		DisplayName: displayName,
-	}, nil
+	return nil
 }
 
 // parsePassword takes the incoming password and validates it.
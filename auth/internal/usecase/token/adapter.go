@@ -0,0 +1,71 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// issueResponseDto represents the issue payload response contract (RFC 6749 naming).
+type issueResponseDto struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// accessTokenToDto transforms access token domain struct into issue response (dto).
+func accessTokenToDto(t accessToken) issueResponseDto {
+	return issueResponseDto{
+		AccessToken: t.Value,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(t.ExpiresIn.Seconds()),
+	}
+}
+
+// Handlers holds the http handlers of the token use case.
+type Handlers struct {
+	Issue web.Handler
+	JWKS  web.Handler
+}
+
+// (Adapter) HttpHandlers transforms the "token http requests" into "calls on token core service".
+func HttpHandlers(s Service) Handlers {
+	return Handlers{
+		Issue: issueHandler(s),
+		JWKS:  jwksHandler(s),
+	}
+}
+
+func issueHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		claims, err := auth.GetClaims(ctx)
+		if err != nil {
+			return webapp.NewRequestError(err, http.StatusUnauthorized)
+		}
+
+		t, err := s.Issue(ctx, claims)
+		if err != nil {
+			return fmt.Errorf("unable to issue token: %w", err)
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		return web.Respond(ctx, w, accessTokenToDto(t), http.StatusOK)
+	}
+}
+
+func jwksHandler(s Service) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		set, err := s.JWKS(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to get key set: %w", err)
+		}
+
+		// Verifiers refresh on unknown keys, so a short cache is enough.
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		return web.Respond(ctx, w, set, http.StatusOK)
+	}
+}
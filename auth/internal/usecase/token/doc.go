@@ -0,0 +1,3 @@
+// Package token contains all the components needed to
+// fulfill the access token issuing use case.
+package token
@@ -0,0 +1,9 @@
+package token
+
+import "time"
+
+// accessToken represents a domain entity.
+type accessToken struct {
+	Value     string
+	ExpiresIn time.Duration
+}
@@ -0,0 +1,24 @@
+package token
+
+import (
+	"context"
+
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+	"github.com/mroobert/go-tickets/auth/pkg/accesstoken"
+)
+
+// (Port) Service defines how the interaction between the "core" and the "token http handlers" has to be done.
+type Service interface {
+	// Issue mints an access token for the authenticated principal.
+	Issue(ctx context.Context, claims auth.Claims) (accessToken, error)
+	// JWKS returns the public keys used to verify the access tokens.
+	JWKS(ctx context.Context) (accesstoken.JWKS, error)
+}
+
+// (Port) Keyring defines how the interaction between the "core" and the "signing keys" has to be done.
+type Keyring interface {
+	// Sign signs the claims with the active key.
+	Sign(c accesstoken.Claims) (string, error)
+	// JWKS returns the public keys that are still valid for verification.
+	JWKS() (accesstoken.JWKS, error)
+}
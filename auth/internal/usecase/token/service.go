@@ -0,0 +1,72 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+	"github.com/mroobert/go-tickets/auth/pkg/accesstoken"
+)
+
+// Config holds the settings of the issued tokens.
+type Config struct {
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+}
+
+// Service represents "token" core service.
+type service struct {
+	cfg     Config
+	keyring Keyring
+}
+
+// NewService creates a "token core service" with the necessary dependencies.
+func NewService(cfg Config, keyring Keyring) *service {
+	return &service{
+		cfg:     cfg,
+		keyring: keyring,
+	}
+}
+
+// Issue mints a short-lived access token carrying the uid and the roles of
+// the principal, and the tenant the roles are granted in.
+func (s *service) Issue(ctx context.Context, claims auth.Claims) (accessToken, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return accessToken{}, fmt.Errorf("token: %w", err)
+	}
+
+	now := time.Now()
+	c := accesstoken.Claims{
+		Issuer:    s.cfg.Issuer,
+		Subject:   claims.UID,
+		Audience:  s.cfg.Audience,
+		ID:        hex.EncodeToString(id),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.cfg.TTL).Unix(),
+		Email:     claims.Email,
+		Roles:     claims.Roles,
+		Tenant:    web.GetTenantID(ctx),
+	}
+
+	value, err := s.keyring.Sign(c)
+	if err != nil {
+		return accessToken{}, fmt.Errorf("token: %w", err)
+	}
+
+	return accessToken{Value: value, ExpiresIn: s.cfg.TTL}, nil
+}
+
+// JWKS returns the public keys used to verify the access tokens.
+func (s *service) JWKS(ctx context.Context) (accesstoken.JWKS, error) {
+	set, err := s.keyring.JWKS()
+	if err != nil {
+		return accesstoken.JWKS{}, fmt.Errorf("token: %w", err)
+	}
+	return set, nil
+}
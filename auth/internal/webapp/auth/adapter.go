@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	fbauthn "firebase.google.com/go/v4/auth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/memauth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+)
+
+// (Adapter) Firebase transforms a "session verification" into a "call on firebase authn provider".
+type Firebase struct {
+	client *fbauthn.Client
+}
+
+// NewFirebase sets a firebase authentication client for session verification.
+func NewFirebase(client *fbauthn.Client) *Firebase {
+	return &Firebase{
+		client: client,
+	}
+}
+
+// VerifySession verifies the firebase session cookie and checks that it was not revoked.
+// The cookie must belong to the tenant of the request.
+func (fb Firebase) VerifySession(ctx context.Context, value string) (Claims, error) {
+	ctx, done := webapp.StartFirebase(ctx, "auth.verify_session")
+	defer done()
+
+	tenantID := web.GetTenantID(ctx)
+	if tenantID == "" {
+		decoded, err := fb.client.VerifySessionCookieAndCheckRevoked(ctx, value)
+		if err != nil {
+			return Claims{}, fmt.Errorf("failed to verify the session cookie: %w", err)
+		}
+		return toClaims(decoded), nil
+	}
+
+	// The project verifies the cookies of all the tenants, the revocation is
+	// checked against the users of the tenant.
+	decoded, err := fb.client.VerifySessionCookie(ctx, value)
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to verify the session cookie: %w", err)
+	}
+	if decoded.Firebase.Tenant != tenantID {
+		return Claims{}, fmt.Errorf("session cookie of tenant %q used for tenant %q", decoded.Firebase.Tenant, tenantID)
+	}
+
+	u, err := webapp.FirebaseTenant(ctx, fb.client).GetUser(ctx, decoded.UID)
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to check the session cookie: %w", err)
+	}
+	switch {
+	case u.Disabled:
+		return Claims{}, errors.New("user is disabled")
+	case decoded.AuthTime*1000 < u.TokensValidAfterMillis:
+		return Claims{}, errors.New("session cookie has been revoked")
+	}
+
+	return toClaims(decoded), nil
+}
+
+// probeUID is a uid that no user has, looked up to probe firebase.
+const probeUID = "health-probe"
+
+// Ping reports if firebase auth is reachable. A lookup answered with "user
+// not found" proves the round trip works.
+func (fb Firebase) Ping(ctx context.Context) error {
+	ctx, done := webapp.StartFirebase(ctx, "auth.ping")
+	defer done()
+
+	_, err := fb.client.GetUser(ctx, probeUID)
+	if err != nil && !fbauthn.IsUserNotFound(err) {
+		return fmt.Errorf("firebase auth unreachable: %w", err)
+	}
+	return nil
+}
+
+func toClaims(fbToken *fbauthn.Token) Claims {
+	c := Claims{
+		UID:      fbToken.UID,
+		AuthTime: fbToken.AuthTime,
+	}
+	if email, ok := fbToken.Claims["email"].(string); ok {
+		c.Email = email
+	}
+
+	// Roles are stored by the role use case as a custom claim.
+	if roles, ok := fbToken.Claims["roles"].([]interface{}); ok {
+		for _, r := range roles {
+			if role, ok := r.(string); ok {
+				c.Roles = append(c.Roles, role)
+			}
+		}
+	}
+
+	return c
+}
+
+// (Adapter) Memory transforms a "session verification" into a "call on the in-memory authn provider".
+type Memory struct {
+	p *memauth.Provider
+}
+
+// NewMemory sets an in-memory authentication provider for session verification.
+func NewMemory(p *memauth.Provider) *Memory {
+	return &Memory{
+		p: p,
+	}
+}
+
+// VerifySession verifies the session cookie and checks that it was not revoked.
+func (m Memory) VerifySession(ctx context.Context, value string) (Claims, error) {
+	decoded, err := m.p.VerifySession(ctx, value)
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to verify the session cookie: %w", err)
+	}
+
+	c := Claims{
+		UID:      decoded.UID,
+		Email:    decoded.Email,
+		AuthTime: decoded.AuthTime,
+		Roles:    decoded.Roles,
+	}
+	return c, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// These are the scopes an API key can be restricted to.
+const (
+	ScopeTokenIssue = "token:issue"
+)
+
+// IsScope reports if the given value is one of the known scopes.
+func IsScope(scope string) bool {
+	switch scope {
+	case ScopeTokenIssue:
+		return true
+	}
+	return false
+}
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// key is how claims are stored/retrieved.
+const key ctxKey = 1
+
+// Claims represents the authenticated principal of a request.
+type Claims struct {
+	UID      string
+	Email    string
+	AuthTime int64
+	Roles    []string
+
+	// APIKeyID and Scopes are only set when the principal
+	// authenticated with an API key.
+	APIKeyID string
+	Scopes   []string
+}
+
+// Authorized returns true if the claims has at least one of the provided roles.
+func (c Claims) Authorized(roles ...string) bool {
+	for _, has := range c.Roles {
+		for _, want := range roles {
+			if has == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Permits returns true if the claims allow the scope. Sessions are not
+// restricted by scopes, only API keys are.
+func (c Claims) Permits(scope string) bool {
+	if c.APIKeyID == "" {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// SetClaims stores the claims in the context.
+func SetClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, key, claims)
+}
+
+// GetClaims returns the claims from the context.
+func GetClaims(ctx context.Context) (Claims, error) {
+	v, ok := ctx.Value(key).(Claims)
+	if !ok {
+		return Claims{}, errors.New("claims missing from context")
+	}
+	return v, nil
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/csrf"
+)
+
+// SessionCookieName is the name of the cookie holding the session.
+const SessionCookieName = "session"
+
+// Cookies writes the cookies of the service. Secure must be set whenever
+// the clients reach the service over https, directly or through a TLS
+// terminating proxy, so the browsers never send the cookies in plain text.
+type Cookies struct {
+	Secure bool
+}
+
+// SetSession writes the session cookie to the response.
+func (c Cookies) SetSession(w http.ResponseWriter, value string, expiresIn time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    value,
+		MaxAge:   int(expiresIn.Seconds()),
+		HttpOnly: true,
+		Secure:   c.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSession asks the client to remove the session cookie.
+func (c Cookies) ClearSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// SetCSRF writes the signed csrf cookie to the response.
+func (c Cookies) SetCSRF(w http.ResponseWriter, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrf.CookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   c.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
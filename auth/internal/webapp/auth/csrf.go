@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/csrf"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// csrfResponseDto represents the csrf token response contract.
+type csrfResponseDto struct {
+	Token  string `json:"token"`
+	Header string `json:"header"`
+}
+
+// CSRFHandler issues a csrf token to the SPA. The signed cookie is set on
+// the response and the token has to be sent back in the header.
+func CSRFHandler(p *csrf.Protector, cookies Cookies) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		token, value, err := p.Issue()
+		if err != nil {
+			return fmt.Errorf("unable to issue csrf token: %w", err)
+		}
+
+		cookies.SetCSRF(w, value)
+		w.Header().Set("Cache-Control", "no-store")
+
+		resp := csrfResponseDto{
+			Token:  token,
+			Header: csrf.HeaderName,
+		}
+		return web.Respond(ctx, w, resp, http.StatusOK)
+	}
+}
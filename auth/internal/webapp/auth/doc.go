@@ -0,0 +1,3 @@
+// Package auth provides support for authenticating requests based on
+// the session cookie issued by the signin use case.
+package auth
@@ -0,0 +1,15 @@
+package auth
+
+import "context"
+
+// (Port) SessionVerifier defines how the interaction between the "authentication middleware" and the "authn provider" has to be done.
+type SessionVerifier interface {
+	// VerifySession verifies the session cookie value and returns the claims it carries.
+	VerifySession(ctx context.Context, value string) (Claims, error)
+}
+
+// (Port) APIKeyVerifier defines how the interaction between the "api key middleware" and the "api keys provider" has to be done.
+type APIKeyVerifier interface {
+	// VerifyAPIKey verifies the api key and returns the claims of its owner.
+	VerifyAPIKey(ctx context.Context, key string) (Claims, error)
+}
@@ -0,0 +1,164 @@
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	fbauthn "firebase.google.com/go/v4/auth"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/metrics"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/trace"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// firebaseDuration is the latency of the calls made to firebase.
+var firebaseDuration = metrics.NewHistogram("firebase_request_duration_seconds",
+	"Latency of the calls made to firebase.", nil, "op")
+
+// StartFirebase starts the span of a firebase call and returns the function
+// ending it and recording its latency. The call runs on the deadline of the
+// route, so it fails fast once the budget is spent. It is meant to be used at the top of
+// the firebase adapters:
+//
+//	ctx, done := webapp.StartFirebase(ctx, "signin.verify_token")
+//	defer done()
+func StartFirebase(ctx context.Context, op string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := trace.Start(ctx, "firebase "+op, trace.KindClient)
+	if budget, ok := web.Budget(ctx); ok {
+		span.SetAttribute("deadline.budget_ms", strconv.FormatInt(budget.Milliseconds(), 10))
+	}
+
+	done := func() {
+		span.End()
+		firebaseDuration.Observe(time.Since(start).Seconds(), op)
+	}
+	return ctx, done
+}
+
+// FirebaseUsers is the part of the firebase auth api scoped to a tenant, it
+// is implemented by the clients of the project and of its tenants.
+type FirebaseUsers interface {
+	CreateUser(ctx context.Context, user *fbauthn.UserToCreate) (*fbauthn.UserRecord, error)
+	GetUser(ctx context.Context, uid string) (*fbauthn.UserRecord, error)
+	GetUserByEmail(ctx context.Context, email string) (*fbauthn.UserRecord, error)
+	Users(ctx context.Context, nextPageToken string) *fbauthn.UserIterator
+	UpdateUser(ctx context.Context, uid string, user *fbauthn.UserToUpdate) (*fbauthn.UserRecord, error)
+	ImportUsers(ctx context.Context, users []*fbauthn.UserToImport, opts ...fbauthn.UserImportOption) (*fbauthn.UserImportResult, error)
+	SetCustomUserClaims(ctx context.Context, uid string, customClaims map[string]interface{}) error
+	RevokeRefreshTokens(ctx context.Context, uid string) error
+	PasswordResetLink(ctx context.Context, email string) (string, error)
+	EmailVerificationLink(ctx context.Context, email string) (string, error)
+	VerifyIDToken(ctx context.Context, idToken string) (*fbauthn.Token, error)
+	CustomToken(ctx context.Context, uid string) (string, error)
+}
+
+// FirebaseTenant returns the client of the users of the tenant of the
+// request, the client of the project without a tenant. The tenant clients
+// verify the tenant of the tokens they get.
+func FirebaseTenant(ctx context.Context, client *fbauthn.Client) FirebaseUsers {
+	id := web.GetTenantID(ctx)
+	if id == "" {
+		return client
+	}
+
+	// The tenant client is a view of the project client, it costs nothing
+	// and only fails on an empty id.
+	tc, _ := client.TenantManager.AuthForTenant(id)
+	return tc
+}
+
+// FirebaseSessions mints the session cookies of the users without their
+// credentials, e.g. after a passkey sign-in. It is safe for concurrent use.
+type FirebaseSessions struct {
+	client *fbauthn.Client
+	apiKey string
+	http   *http.Client
+}
+
+// NewFirebaseSessions constructs the minter. The web api key is needed to
+// exchange the custom tokens for id tokens.
+func NewFirebaseSessions(client *fbauthn.Client, apiKey string) *FirebaseSessions {
+	return &FirebaseSessions{
+		client: client,
+		apiKey: apiKey,
+		http: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &trace.Transport{},
+		},
+	}
+}
+
+// Mint mints a custom token for the user of the tenant of the request,
+// exchanges it for an id token and creates the session cookie from it.
+func (fs *FirebaseSessions) Mint(ctx context.Context, uid string, expiresIn time.Duration) (string, error) {
+	customToken, err := FirebaseTenant(ctx, fs.client).CustomToken(ctx, uid)
+	if err != nil {
+		return "", fmt.Errorf("firebase minting custom token: %w", err)
+	}
+
+	idToken, err := fs.signInWithCustomToken(ctx, customToken)
+	if err != nil {
+		return "", err
+	}
+
+	// The cookie carries the tenant of the id token.
+	value, err := fs.client.SessionCookie(ctx, idToken, expiresIn)
+	if err != nil {
+		return "", fmt.Errorf("failed to create a session cookie on firebase: %w", err)
+	}
+	return value, nil
+}
+
+// signInWithCustomToken exchanges a custom token for an id token through the
+// identity toolkit api, or the emulator when one is configured.
+func (fs *FirebaseSessions) signInWithCustomToken(ctx context.Context, customToken string) (string, error) {
+	base := "https://identitytoolkit.googleapis.com"
+	if host := os.Getenv("FIREBASE_AUTH_EMULATOR_HOST"); host != "" {
+		base = "http://" + host + "/identitytoolkit.googleapis.com"
+	}
+	url := base + "/v1/accounts:signInWithCustomToken?key=" + fs.apiKey
+
+	// The custom token of a tenant user is only accepted by its tenant.
+	body, err := json.Marshal(struct {
+		Token             string `json:"token"`
+		TenantID          string `json:"tenantId,omitempty"`
+		ReturnSecureToken bool   `json:"returnSecureToken"`
+	}{
+		Token:             customToken,
+		TenantID:          web.GetTenantID(ctx),
+		ReturnSecureToken: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := fs.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("firebase exchanging custom token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("firebase exchanging custom token: status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		IDToken string `json:"idToken"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("firebase decoding custom token exchange: %w", err)
+	}
+	return result.IDToken, nil
+}
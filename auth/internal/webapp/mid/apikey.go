@@ -0,0 +1,52 @@
+package mid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// APIKey validates the api key of the request and stores the claims of its
+// owner into the context, the same way Authenticate does for sessions.
+// Requests without an authorization header are passed on untouched so
+// that Authenticate can follow it in the chain for the routes open to both.
+func APIKey(v auth.APIKeyVerifier) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// Expecting: ApiKey <key>
+			authStr := r.Header.Get("authorization")
+			if authStr == "" {
+				return handler(ctx, w, r)
+			}
+
+			_, key, err := web.ExtractToken(authStr, web.SchemeAPIKey)
+			if err != nil {
+				return webapp.NewCodedRequestError(err, http.StatusUnauthorized, CodeUnauthenticated)
+			}
+
+			claims, err := v.VerifyAPIKey(ctx, key)
+			if err != nil {
+				return webapp.NewCodedRequestError(fmt.Errorf("unable to authenticate: %w", err), http.StatusUnauthorized, CodeUnauthenticated)
+			}
+
+			// Add claims to the context so they can be retrieved later.
+			ctx = auth.SetClaims(ctx, claims)
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
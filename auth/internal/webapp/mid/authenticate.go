@@ -0,0 +1,51 @@
+package mid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// Authenticate validates the session cookie of the request and stores
+// the claims of the authenticated principal into the context. Requests
+// already authenticated by a previous middleware (e.g. APIKey) are passed on.
+func Authenticate(v auth.SessionVerifier) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			if _, err := auth.GetClaims(ctx); err == nil {
+				return handler(ctx, w, r)
+			}
+
+			// Expecting: session=<cookie value>
+			cookie, err := r.Cookie(auth.SessionCookieName)
+			if err != nil {
+				return webapp.NewCodedRequestError(errors.New("session cookie missing"), http.StatusUnauthorized, CodeUnauthenticated)
+			}
+
+			claims, err := v.VerifySession(ctx, cookie.Value)
+			if err != nil {
+				return webapp.NewCodedRequestError(fmt.Errorf("unable to authenticate: %w", err), http.StatusUnauthorized, CodeUnauthenticated)
+			}
+
+			// Add claims to the context so they can be retrieved later.
+			ctx = auth.SetClaims(ctx, claims)
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
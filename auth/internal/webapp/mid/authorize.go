@@ -0,0 +1,93 @@
+package mid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// These are the codes returned to the client when authorization fails.
+const (
+	CodeUnauthenticated = "unauthenticated"
+	CodeMissingRole     = "missing_role"
+	CodeMissingScope    = "missing_scope"
+)
+
+// Authorize validates that an authenticated principal has at least one
+// role from a specified list. This method constructs the actual function
+// that is used.
+func Authorize(roles ...string) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// If the context is missing the claims, the route was
+			// registered without the authentication middleware.
+			claims, err := auth.GetClaims(ctx)
+			if err != nil {
+				return webapp.NewCodedRequestError(
+					fmt.Errorf("you are not authorized for that action: %w", err),
+					http.StatusUnauthorized,
+					CodeUnauthenticated,
+				)
+			}
+
+			if !claims.Authorized(roles...) {
+				return webapp.NewCodedRequestError(
+					fmt.Errorf("you are not authorized for that action, claims[%v] roles[%v]", claims.Roles, roles),
+					http.StatusForbidden,
+					CodeMissingRole,
+				)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// RequireScope validates that the api key of an authenticated principal
+// allows the scope. Principals authenticated with a session are not
+// restricted by scopes.
+func RequireScope(scope string) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			claims, err := auth.GetClaims(ctx)
+			if err != nil {
+				return webapp.NewCodedRequestError(
+					fmt.Errorf("you are not authorized for that action: %w", err),
+					http.StatusUnauthorized,
+					CodeUnauthenticated,
+				)
+			}
+
+			if !claims.Permits(scope) {
+				return webapp.NewCodedRequestError(
+					fmt.Errorf("you are not authorized for that action, scopes[%v] scope[%s]", claims.Scopes, scope),
+					http.StatusForbidden,
+					CodeMissingScope,
+				)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
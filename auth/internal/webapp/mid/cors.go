@@ -0,0 +1,224 @@
+package mid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// CORSConfig holds the cross-origin settings. The allowed origins are exact
+// values like https://shop.example.com or patterns with a single wildcard
+// for the subdomains like https://*.example.com.
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+	MaxAge         time.Duration
+}
+
+// Validate checks that the config can be used with credentials, which
+// forbids a wildcard origin.
+func (c CORSConfig) Validate() error {
+	if err := ValidateOrigins(c.AllowedOrigins); err != nil {
+		return err
+	}
+	if len(c.AllowedMethods) == 0 {
+		return errors.New("at least one method must be allowed")
+	}
+	return nil
+}
+
+// ValidateOrigins checks that the allowed origins can be used with
+// credentials.
+func ValidateOrigins(origins []string) error {
+	for _, o := range origins {
+		switch {
+		case o == "*" || o == "null":
+			return fmt.Errorf("origin %q is not allowed with credentials", o)
+		case strings.Count(o, "*") > 1:
+			return fmt.Errorf("origin %q must have at most one wildcard", o)
+		case strings.Contains(o, "*") && !strings.Contains(o, "://*."):
+			return fmt.Errorf("origin %q must use the wildcard for the subdomains only", o)
+		}
+	}
+	return nil
+}
+
+// allowsOrigin reports if the origin matches one of the allowed origins.
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range c.AllowedOrigins {
+		o = strings.ToLower(o)
+
+		i := strings.Index(o, "*")
+		if i < 0 {
+			if o == origin {
+				return true
+			}
+			continue
+		}
+
+		prefix, suffix := o[:i], o[i+1:]
+		if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+			continue
+		}
+		if isSubdomain(origin[len(prefix) : len(origin)-len(suffix)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubdomain reports if s only holds host name labels.
+func isSubdomain(s string) bool {
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return !strings.HasPrefix(s, ".") && !strings.HasSuffix(s, ".")
+}
+
+// CORSPolicy holds the config applied by the CORS middleware. It can be
+// replaced while the service runs and is safe for concurrent use.
+type CORSPolicy struct {
+	v atomic.Value
+}
+
+// corsRules is a CORS config prepared for the requests.
+type corsRules struct {
+	CORSConfig
+	methods        string
+	exposed        string
+	maxAge         string
+	allowedHeaders map[string]bool
+}
+
+// NewCORSPolicy validates the config and constructs a policy applying it.
+func NewCORSPolicy(cfg CORSConfig) (*CORSPolicy, error) {
+	var p CORSPolicy
+	if err := p.Update(cfg); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Update replaces the config of the policy. An invalid config is rejected
+// and the current one stays in place.
+func (p *CORSPolicy) Update(cfg CORSConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	rules := corsRules{
+		CORSConfig:     cfg,
+		methods:        strings.Join(cfg.AllowedMethods, ", "),
+		exposed:        strings.Join(cfg.ExposedHeaders, ", "),
+		maxAge:         strconv.Itoa(int(cfg.MaxAge.Seconds())),
+		allowedHeaders: make(map[string]bool),
+	}
+	for _, h := range cfg.AllowedHeaders {
+		rules.allowedHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+
+	p.v.Store(&rules)
+	return nil
+}
+
+// Config returns the current config of the policy.
+func (p *CORSPolicy) Config() CORSConfig {
+	return p.rules().CORSConfig
+}
+
+func (p *CORSPolicy) rules() *corsRules {
+	return p.v.Load().(*corsRules)
+}
+
+// CORS adds the cross-origin headers for the allowed origins and answers
+// the preflight requests. Credentials are allowed, so the origin is echoed
+// back instead of a wildcard.
+func CORS(p *CORSPolicy) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			cfg := p.rules()
+
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return handler(ctx, w, r)
+			}
+
+			// The response depends on the origin, caches must know it.
+			w.Header().Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			// Without the headers the browser blocks the response.
+			if !cfg.allowsOrigin(origin) {
+				return handler(ctx, w, r)
+			}
+
+			if !preflight {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				if cfg.exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", cfg.exposed)
+				}
+				return handler(ctx, w, r)
+			}
+
+			// A preflight for a method or headers that are not allowed is
+			// answered without the headers, so the browser blocks the request.
+			if !allowsMethod(cfg.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+				return web.Respond(ctx, w, nil, http.StatusNoContent)
+			}
+			for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+				h = strings.TrimSpace(h)
+				if h != "" && !cfg.allowedHeaders[http.CanonicalHeaderKey(h)] {
+					return web.Respond(ctx, w, nil, http.StatusNoContent)
+				}
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Methods", cfg.methods)
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", cfg.maxAge)
+			}
+
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// allowsMethod reports if the method is one of the allowed methods.
+func allowsMethod(allowed []string, method string) bool {
+	for _, m := range allowed {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
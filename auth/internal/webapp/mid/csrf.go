@@ -0,0 +1,62 @@
+package mid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/csrf"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// CodeCSRF is returned to the client when the CSRF protection rejects a request.
+const CodeCSRF = "csrf_failed"
+
+// CSRF protects the state-changing requests authenticated by the session
+// cookie. The origin of the request must be allowed and the token of the
+// csrf cookie must be submitted in the X-CSRF-Token header. Safe methods,
+// requests without a session cookie and exempt routes are passed on.
+func CSRF(p *csrf.Protector) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				return handler(ctx, w, r)
+			}
+
+			if v, err := web.GetValues(ctx); err == nil && v.Route.CSRFExempt {
+				return handler(ctx, w, r)
+			}
+
+			// Without the session cookie the browser adds nothing the
+			// attacker could abuse.
+			if _, err := r.Cookie(auth.SessionCookieName); err != nil {
+				return handler(ctx, w, r)
+			}
+
+			if err := p.CheckOrigin(r); err != nil {
+				return webapp.NewCodedRequestError(err, http.StatusForbidden, CodeCSRF)
+			}
+
+			cookie, err := r.Cookie(csrf.CookieName)
+			if err != nil {
+				return webapp.NewCodedRequestError(fmt.Errorf("%w: cookie missing", csrf.ErrToken), http.StatusForbidden, CodeCSRF)
+			}
+			if err := p.Verify(cookie.Value, r.Header.Get(csrf.HeaderName)); err != nil {
+				return webapp.NewCodedRequestError(err, http.StatusForbidden, CodeCSRF)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
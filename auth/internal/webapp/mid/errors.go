@@ -2,6 +2,7 @@ package mid
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
@@ -9,9 +10,16 @@ import (
 	"go.uber.org/zap"
 )
 
+// These are the codes returned to the client when a request runs out of time.
+const (
+	CodeTimeout         = "timeout"
+	CodeUpstreamTimeout = "upstream_timeout"
+)
+
 // Errors handles errors coming out of the call chain. It detects normal
 // application errors which are used to respond to the client in a uniform way.
-// Unexpected errors (status >= 500) are logged.
+// Unexpected errors (status >= 500) are logged. A route running past its
+// timeout gets a 503, one whose upstream call failed on the deadline a 504.
 func Errors(log *zap.SugaredLogger) func(handler web.Handler) web.Handler {
 
 	// This is the actual middleware function to be executed.
@@ -31,7 +39,7 @@ func Errors(log *zap.SugaredLogger) func(handler web.Handler) web.Handler {
 			if err := handler(ctx, w, r); err != nil {
 
 				// Log the error.
-				log.Errorw("ERROR", "traceid", v.TraceID, "Handler", err)
+				log.Errorw("ERROR", "traceid", v.TraceID, "tenant", v.TenantID, "Handler", err)
 
 				// Build out the error response.
 				var er webapp.ErrorResponse
@@ -41,9 +49,24 @@ func Errors(log *zap.SugaredLogger) func(handler web.Handler) web.Handler {
 					reqErr := webapp.GetRequestError(err)
 					er = webapp.ErrorResponse{
 						Error: reqErr.Error(),
+						Code:  reqErr.Code,
 					}
 					status = reqErr.Status
 
+				case errors.Is(err, web.ErrTimeout):
+					er = webapp.ErrorResponse{
+						Error: "request timed out",
+						Code:  CodeTimeout,
+					}
+					status = http.StatusServiceUnavailable
+
+				case errors.Is(err, context.DeadlineExceeded):
+					er = webapp.ErrorResponse{
+						Error: "upstream request timed out",
+						Code:  CodeUpstreamTimeout,
+					}
+					status = http.StatusGatewayTimeout
+
 				default:
 					er = webapp.ErrorResponse{
 						Error: http.StatusText(http.StatusInternalServerError),
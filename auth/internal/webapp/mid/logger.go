@@ -10,7 +10,8 @@ import (
 )
 
 // Logger writes some information about the request to the logs in the
-// format: TraceID : (200) GET /foo -> IP ADDR (latency)
+// format: TraceID : (200) GET /foo -> IP ADDR (latency). The tenant is
+// known once the request went through the chain.
 func Logger(log *zap.SugaredLogger) web.Middleware {
 
 	// This is the actual middleware function to be executed.
@@ -33,7 +34,7 @@ func Logger(log *zap.SugaredLogger) web.Middleware {
 			err = handler(ctx, w, r)
 
 			log.Infow("request completed", "traceid", v.TraceID, "method", r.Method, "path", r.URL.Path,
-				"remoteaddr", r.RemoteAddr, "statuscode", v.StatusCode, "since", time.Since(v.Now))
+				"remoteaddr", r.RemoteAddr, "tenant", v.TenantID, "statuscode", v.StatusCode, "since", time.Since(v.Now))
 
 			// Return the error so it can be handled further up the chain.
 			return err
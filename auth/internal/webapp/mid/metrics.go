@@ -0,0 +1,66 @@
+package mid
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/metrics"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+)
+
+// The request metrics are labeled with the route pattern, not the path, so
+// the path parameters don't create a series per value.
+var (
+	requestsTotal = metrics.NewCounter("http_requests_total",
+		"Number of requests handled.", "method", "route", "status")
+
+	requestDuration = metrics.NewHistogram("http_request_duration_seconds",
+		"Latency of the requests.", nil, "method", "route")
+
+	requestsInFlight = metrics.NewGauge("http_requests_in_flight",
+		"Number of requests being handled.", "method", "route")
+)
+
+// Metrics records the count, the latency and the requests in flight per
+// route. It must run outside mid.Errors to see the status of the response.
+func Metrics() web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			// The preflight requests of all the paths share a series.
+			route := v.Route.Pattern
+			if route == "" {
+				route = "preflight"
+			}
+
+			requestsInFlight.Inc(r.Method, route)
+			defer requestsInFlight.Dec(r.Method, route)
+
+			// Call the next handler.
+			err = handler(ctx, w, r)
+
+			requestsTotal.Inc(r.Method, route, strconv.Itoa(v.StatusCode))
+			requestDuration.Observe(time.Since(v.Now).Seconds(), r.Method, route)
+
+			// Return the error so it can be handled further up the chain.
+			return err
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,93 @@
+package mid
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/ratelimit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
+)
+
+// CodeRateLimited is returned to the client when a rate limit is exceeded.
+const CodeRateLimited = "rate_limited"
+
+// RateLimitKey returns the key of the bucket a request is counted in.
+type RateLimitKey func(ctx context.Context, r *http.Request) string
+
+// ByIP counts the requests per client ip address, the one forwarded by the
+// trusted proxies.
+func ByIP(ctx context.Context, r *http.Request) string {
+	return "ip:" + web.GetClientIP(ctx)
+}
+
+// ByPrincipal counts the requests per authenticated principal, falling back
+// to the client ip address for anonymous requests.
+func ByPrincipal(ctx context.Context, r *http.Request) string {
+	if claims, err := auth.GetClaims(ctx); err == nil {
+		return "uid:" + claims.UID
+	}
+	return ByIP(ctx, r)
+}
+
+// PerRoute counts the requests of each route separately.
+func PerRoute(key RateLimitKey) RateLimitKey {
+	return func(ctx context.Context, r *http.Request) string {
+		route := r.URL.Path
+		if v, err := web.GetValues(ctx); err == nil {
+			route = v.Route.Pattern
+		}
+		return r.Method + " " + route + " " + key(ctx, r)
+	}
+}
+
+// RateLimit limits the requests with a token bucket per key. The X-RateLimit
+// headers describe the bucket and denied requests get a 429 with Retry-After.
+// The limiter fails open, an unavailable store must not take the service down.
+// The limit is read at every request, so it can be replaced while in use.
+func RateLimit(store ratelimit.Store, lv *ratelimit.Var, key RateLimitKey) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// An unlimited bucket has nothing to count.
+			l := lv.Load()
+			if l.Unlimited() {
+				return handler(ctx, w, r)
+			}
+
+			res, err := store.Take(ctx, key(ctx, r), l, time.Now())
+			if err != nil {
+				return handler(ctx, w, r)
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("X-RateLimit-Reset", seconds(res.Reset))
+
+			if !res.Allowed {
+				w.Header().Set("Retry-After", seconds(res.RetryAfter))
+				return webapp.NewCodedRequestError(errors.New("rate limit exceeded"), http.StatusTooManyRequests, CodeRateLimited)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// seconds formats the duration as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
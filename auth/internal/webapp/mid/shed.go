@@ -0,0 +1,82 @@
+package mid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/loadshed"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/metrics"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+)
+
+// CodeOverloaded is returned to the client when its request is shed.
+const CodeOverloaded = "overloaded"
+
+// requestsShed counts the requests shed by priority.
+var requestsShed = metrics.NewCounter("http_requests_shed_total",
+	"Number of requests shed because of overload.", "priority")
+
+// Shed rejects the requests above the adaptive concurrency limit with a 503
+// and Retry-After. The low priority routes are shed first. Requests ending
+// with a deadline exceeded count as an overload, and only the requests
+// served with a 2xx set the baseline latency.
+func Shed(l *loadshed.Limiter) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			route := v.Route.Method + " " + v.Route.Pattern
+			release, ok := l.Acquire(route, shedPriority(v.Route.Priority))
+			if !ok {
+				requestsShed.Inc(v.Route.Priority.String())
+				w.Header().Set("Retry-After", seconds(l.RetryAfter(route)))
+				return webapp.NewCodedRequestError(errors.New("service overloaded"), http.StatusServiceUnavailable, CodeOverloaded)
+			}
+
+			err = handler(ctx, w, r)
+			release(shedOutcome(err, v.StatusCode))
+
+			return err
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// shedPriority maps the priority of the route to the limiter one.
+func shedPriority(p web.Priority) int {
+	switch p {
+	case web.PriorityLow:
+		return loadshed.PriorityLow
+	case web.PriorityCritical:
+		return loadshed.PriorityCritical
+	}
+	return loadshed.PriorityNormal
+}
+
+// shedOutcome maps the result of the handler to the limiter outcome. The
+// errors are answered later in the chain, so a returned error is never a
+// success whatever the status code is.
+func shedOutcome(err error, statusCode int) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return loadshed.OutcomeFailed
+	case err != nil, statusCode < 200, statusCode > 299:
+		return loadshed.OutcomeError
+	}
+	return loadshed.OutcomeSuccess
+}
@@ -0,0 +1,46 @@
+package mid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/trace"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/webapp"
+)
+
+// CodeUnknownTenant is returned to the client when the request names a
+// tenant that is not registered.
+const CodeUnknownTenant = "unknown_tenant"
+
+// Tenant resolves the tenant of the request and stores its id into the
+// values, the adapters then talk to the users of the tenant.
+func Tenant(reg *tenant.Registry) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			id, err := reg.Resolve(r)
+			if err != nil {
+				return webapp.NewCodedRequestError(err, http.StatusNotFound, CodeUnknownTenant)
+			}
+
+			if id != "" {
+				if err := web.SetTenantID(ctx, id); err != nil {
+					return web.NewShutdownError("web value missing from context")
+				}
+				trace.FromContext(ctx).SetAttribute("tenant.id", id)
+			}
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,63 @@
+package mux_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/internal/foundation/health"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/loadshed"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/mux"
+)
+
+func TestDebugMuxClientCerts(t *testing.T) {
+	shed, err := loadshed.New(loadshed.Config{
+		InitialLimit:   1,
+		MinLimit:       1,
+		MaxLimit:       1,
+		Tolerance:      2,
+		Backoff:        0.5,
+		BaselineWindow: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("constructing limiter: %v", err)
+	}
+	config := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := mux.DebugMux(health.New("test", time.Second), shed, config, true)
+
+	// The verified chains are only set by the handshake against the CAs.
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{}}}
+
+	tests := []struct {
+		path string
+		tls  *tls.ConnectionState
+		want int
+	}{
+		{"/debug/liveness", nil, http.StatusOK},
+		{"/debug/readiness", nil, http.StatusOK},
+		{"/metrics", nil, http.StatusOK},
+		{"/debug/loadshed", nil, http.StatusOK},
+		{"/debug/pprof/", nil, http.StatusForbidden},
+		{"/debug/pprof/cmdline", &tls.ConnectionState{}, http.StatusForbidden},
+		{"/debug/vars", nil, http.StatusForbidden},
+		{"/debug/config", nil, http.StatusForbidden},
+		{"/debug/pprof/cmdline", verified, http.StatusOK},
+		{"/debug/config", verified, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.TLS = tt.tls
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -2,35 +2,156 @@
 package mux
 
 import (
+	"encoding/json"
 	"expvar"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"time"
 
+	"github.com/mroobert/go-tickets/auth/internal/foundation/certs"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/csrf"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/health"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/loadshed"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/metrics"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/ratelimit"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/roles"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/tenant"
+	"github.com/mroobert/go-tickets/auth/internal/foundation/trace"
 	"github.com/mroobert/go-tickets/auth/internal/foundation/web"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/admin"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/apikey"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/auditlog"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/mfa"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/passkey"
+	"github.com/mroobert/go-tickets/auth/internal/usecase/token"
+	"github.com/mroobert/go-tickets/auth/internal/webapp/auth"
 	"github.com/mroobert/go-tickets/auth/internal/webapp/mid"
 	"go.uber.org/zap"
 )
 
+// RateLimits holds the limits applied to the api routes. They can be
+// replaced while the service runs.
+type RateLimits struct {
+	IP        *ratelimit.Var
+	Principal *ratelimit.Var
+	SignUp    *ratelimit.Var
+	SignIn    *ratelimit.Var
+}
+
+// Timeouts holds the deadlines of the api routes. The slow one is for the
+// routes walking many users or events.
+type Timeouts struct {
+	Default time.Duration
+	Slow    time.Duration
+}
+
 // APIMuxConfig contains all the mandatory systems required by handlers.
 type APIMuxConfig struct {
-	SignUpHandler web.Handler
-	SignInHandler web.Handler
-	Log           *zap.SugaredLogger
-	Shutdown      chan os.Signal
+	SignUpHandler    web.Handler
+	SignInHandler    web.Handler
+	SignInMFAHandler web.Handler
+	SignInPassword   web.Handler
+	SignOutHandler   web.Handler
+	MFAHandlers      mfa.Handlers
+	PasskeyHandlers  passkey.Handlers
+	TokenHandlers    token.Handlers
+	APIKeyHandlers   apikey.Handlers
+	ProfileHandler   web.Handler
+	RoleHandler      web.Handler
+	AdminHandlers    admin.Handlers
+	AuditLogHandlers auditlog.Handlers
+	LockoutHandler   web.Handler
+	SessionVerifier  auth.SessionVerifier
+	APIKeyVerifier   auth.APIKeyVerifier
+	CORS             *mid.CORSPolicy
+	Tenants          *tenant.Registry
+	CSRF             *csrf.Protector
+	Cookies          auth.Cookies
+	RateLimitStore   ratelimit.Store
+	RateLimits       RateLimits
+	Timeouts         Timeouts
+	TrustedProxies   web.TrustedProxies
+	Shed             *loadshed.Limiter
+	Tracer           *trace.Tracer
+	Log              *zap.SugaredLogger
+	Shutdown         chan os.Signal
 }
 
 // APIMux constructs a mux with all application routes defined.
 func APIMux(cfg APIMuxConfig) *web.AppMux {
-	mux := web.NewAppMux(cfg.Shutdown,
+	mux := web.NewAppMux(cfg.Shutdown, cfg.Tracer,
 		mid.Logger(cfg.Log),
+		mid.Metrics(),
 		mid.Errors(cfg.Log),
 		mid.Panics(),
+		mid.CORS(cfg.CORS),
+		mid.Tenant(cfg.Tenants),
+		mid.Shed(cfg.Shed),
+		mid.RateLimit(cfg.RateLimitStore, cfg.RateLimits.IP, mid.ByIP),
+		mid.CSRF(cfg.CSRF),
 	)
 
+	mux.SetTimeout(cfg.Timeouts.Default)
+	mux.SetTrustedProxies(cfg.TrustedProxies)
+	slow := web.WithTimeout(cfg.Timeouts.Slow)
+
+	// Under load the routes other services depend on and the sign-in are
+	// kept, the admin routes are shed first.
+	critical := web.WithPriority(web.PriorityCritical)
+	low := web.WithPriority(web.PriorityLow)
+
+	mux.Handle(http.MethodGet, "", "/.well-known/jwks.json", cfg.TokenHandlers.JWKS, critical)
+
+	signUpLimit := mid.RateLimit(cfg.RateLimitStore, cfg.RateLimits.SignUp, mid.PerRoute(mid.ByIP))
+	signInLimit := mid.RateLimit(cfg.RateLimitStore, cfg.RateLimits.SignIn, mid.PerRoute(mid.ByIP))
+
 	const group = "api"
-	mux.Handle(http.MethodPost, group, "/signup", cfg.SignUpHandler)
-	mux.Handle(http.MethodPost, group, "/signin", cfg.SignInHandler)
+	mux.Handle(http.MethodGet, group, "/csrf", auth.CSRFHandler(cfg.CSRF, cfg.Cookies))
+	mux.Handle(http.MethodPost, group, "/signup", cfg.SignUpHandler, signUpLimit)
+
+	// The sign-in routes are authenticated by their own credentials, a
+	// stale session cookie must not get in the way.
+	mux.Handle(http.MethodPost, group, "/signin", cfg.SignInHandler, signInLimit, critical, web.WithoutCSRF())
+	mux.Handle(http.MethodPost, group, "/signin/mfa", cfg.SignInMFAHandler, signInLimit, critical, web.WithoutCSRF())
+
+	// Without the client sdk of firebase, the memory provider exchanges the
+	// passwords for id tokens itself.
+	if cfg.SignInPassword != nil {
+		mux.Handle(http.MethodPost, group, "/signin/password", cfg.SignInPassword, signInLimit, critical, web.WithoutCSRF())
+	}
+	mux.Handle(http.MethodPost, group, "/passkeys/signin/begin", cfg.PasskeyHandlers.BeginSignIn, signInLimit, critical, web.WithoutCSRF())
+	mux.Handle(http.MethodPost, group, "/passkeys/signin/finish", cfg.PasskeyHandlers.FinishSignIn, signInLimit, critical, web.WithoutCSRF())
+
+	// The principal limit follows the authentication so it counts per user.
+	authen := mid.Authenticate(cfg.SessionVerifier)
+	user := mid.RateLimit(cfg.RateLimitStore, cfg.RateLimits.Principal, mid.ByPrincipal)
+	mux.Handle(http.MethodPost, group, "/signout", cfg.SignOutHandler, authen, user)
+	mux.Handle(http.MethodPatch, group, "/me", cfg.ProfileHandler, authen, user)
+	mux.Handle(http.MethodGet, group, "/me/apikeys", cfg.APIKeyHandlers.List, authen, user)
+	mux.Handle(http.MethodPost, group, "/me/apikeys", cfg.APIKeyHandlers.Create, authen, user)
+	mux.Handle(http.MethodDelete, group, "/me/apikeys/:id", cfg.APIKeyHandlers.Revoke, authen, user)
+
+	// Machine clients exchange their api key for an access token.
+	apiKey := mid.APIKey(cfg.APIKeyVerifier)
+	mux.Handle(http.MethodPost, group, "/token", cfg.TokenHandlers.Issue, apiKey, authen, user, mid.RequireScope(auth.ScopeTokenIssue), critical)
+	mux.Handle(http.MethodPost, group, "/mfa/totp/enroll", cfg.MFAHandlers.Enroll, authen, user)
+	mux.Handle(http.MethodPost, group, "/mfa/totp/confirm", cfg.MFAHandlers.Confirm, authen, user)
+	mux.Handle(http.MethodPost, group, "/passkeys/register/begin", cfg.PasskeyHandlers.BeginRegistration, authen, user)
+	mux.Handle(http.MethodPost, group, "/passkeys/register/finish", cfg.PasskeyHandlers.FinishRegistration, authen, user)
+
+	admin := mid.Authorize(roles.Admin)
+	mux.Handle(http.MethodPut, group, "/users/:uid/roles", cfg.RoleHandler, authen, user, admin)
+	mux.Handle(http.MethodGet, group, "/users", cfg.AdminHandlers.List, authen, user, admin, low, slow)
+	mux.Handle(http.MethodPost, group, "/users/import", cfg.AdminHandlers.Import, authen, user, admin, low, slow)
+	mux.Handle(http.MethodGet, group, "/users/:uid", cfg.AdminHandlers.View, authen, user, admin)
+	mux.Handle(http.MethodPost, group, "/users/:uid/disable", cfg.AdminHandlers.Disable, authen, user, admin)
+	mux.Handle(http.MethodPost, group, "/users/:uid/enable", cfg.AdminHandlers.Enable, authen, user, admin)
+	mux.Handle(http.MethodPost, group, "/users/:uid/password-reset", cfg.AdminHandlers.PasswordReset, authen, user, admin)
+	mux.Handle(http.MethodPost, group, "/users/:uid/revoke-sessions", cfg.AdminHandlers.RevokeSessions, authen, user, admin)
+	mux.Handle(http.MethodPost, group, "/lockouts/unlock", cfg.LockoutHandler, authen, user, admin)
+	mux.Handle(http.MethodGet, group, "/audit/events", cfg.AuditLogHandlers.Query, authen, user, admin, low, slow)
+	mux.Handle(http.MethodGet, group, "/audit/verify", cfg.AuditLogHandlers.Verify, authen, user, admin, low, slow)
 
 	return mux
 }
@@ -52,3 +173,40 @@ func DebugStandardLibraryMux() *http.ServeMux {
 
 	return mux
 }
+
+// DebugMux registers all the debug standard library routes and then custom
+// debug application routes for the service. With clientCerts set, the
+// standard library routes and the configuration require a verified client
+// certificate, while the probes and the metrics stay open to the
+// orchestrator and the scraper.
+func DebugMux(checker *health.Checker, shed *loadshed.Limiter, config http.Handler, clientCerts bool) http.Handler {
+	private := func(h http.Handler) http.Handler { return h }
+	if clientCerts {
+		private = certs.RequireVerified
+	}
+
+	mux := http.NewServeMux()
+
+	// Register the standard library routes, they expose the internals.
+	std := private(DebugStandardLibraryMux())
+	mux.Handle("/debug/pprof/", std)
+	mux.Handle("/debug/vars", std)
+
+	// Register the metrics in the prometheus text format.
+	mux.Handle("/metrics", metrics.Handler())
+
+	// Register debug check endpoints.
+	mux.HandleFunc("/debug/liveness", checker.Liveness)
+	mux.HandleFunc("/debug/readiness", checker.Readiness)
+
+	// Register the state of the load shedding.
+	mux.HandleFunc("/debug/loadshed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(shed.State())
+	})
+
+	// Register the runtime configuration, protected by its own token.
+	mux.Handle("/debug/config", private(config))
+
+	return mux
+}
@@ -6,6 +6,7 @@ import "errors"
 // ErrorResponse is the form used for API responses from failures in the API.
 type ErrorResponse struct {
 	Error  string            `json:"error"`
+	Code   string            `json:"code,omitempty"`
 	Fields map[string]string `json:"fields,omitempty"`
 }
 
@@ -14,12 +15,19 @@ type ErrorResponse struct {
 type RequestError struct {
 	Err    error
 	Status int
+	Code   string
 }
 
 // NewRequestError wraps a provided error with an HTTP status code. This
 // function should be used when handlers encounter expected errors.
 func NewRequestError(err error, status int) error {
-	return &RequestError{err, status}
+	return &RequestError{Err: err, Status: status}
+}
+
+// NewCodedRequestError wraps a provided error with an HTTP status code and
+// a machine readable code the clients can rely on.
+func NewCodedRequestError(err error, status int, code string) error {
+	return &RequestError{Err: err, Status: status, Code: code}
 }
 
 // Error implements the error interface. It uses the default message of the
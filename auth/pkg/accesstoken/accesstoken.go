@@ -0,0 +1,214 @@
+// Package accesstoken signs and verifies the internal access tokens issued by
+// the auth service. Sibling services import it to authenticate requests
+// without calling Firebase: the public keys are fetched from the JWKS
+// endpoint published by the auth service.
+package accesstoken
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// These are the supported signing algorithms.
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// Set of errors returned while verifying a token.
+var (
+	ErrMalformed  = errors.New("accesstoken: malformed token")
+	ErrSignature  = errors.New("accesstoken: invalid signature")
+	ErrUnknownKey = errors.New("accesstoken: unknown signing key")
+	ErrExpired    = errors.New("accesstoken: token expired")
+	ErrClaims     = errors.New("accesstoken: invalid claims")
+)
+
+// Claims represents the payload of an access token. The roles are granted
+// within the tenant, an empty tenant is the project of the auth service.
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  string   `json:"aud"`
+	ID        string   `json:"jti,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+	Email     string   `json:"email,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Tenant    string   `json:"tenant,omitempty"`
+}
+
+// HasRole returns true if the claims has at least one of the provided roles.
+func (c Claims) HasRole(roles ...string) bool {
+	for _, has := range c.Roles {
+		for _, want := range roles {
+			if has == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasTenantRole returns true if the claims were issued for the tenant and
+// has at least one of the provided roles. An admin of a tenant is not an
+// admin of the others.
+func (c Claims) HasTenantRole(tenant string, roles ...string) bool {
+	return c.Tenant == tenant && c.HasRole(roles...)
+}
+
+// header represents the JOSE header of a token.
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Algorithm returns the signing algorithm for the key or an error if the key
+// type is not supported.
+func Algorithm(key crypto.PublicKey) (string, error) {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return RS256, nil
+	case ed25519.PublicKey:
+		return EdDSA, nil
+	}
+	return "", fmt.Errorf("accesstoken: unsupported key type %T", key)
+}
+
+// Sign creates a signed token for the claims. The key must be an RSA or an
+// Ed25519 private key and kid identifies it in the published key set.
+func Sign(key crypto.Signer, kid string, c Claims) (string, error) {
+	alg, err := Algorithm(key.Public())
+	if err != nil {
+		return "", err
+	}
+
+	h, err := json.Marshal(header{Alg: alg, Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	signed := encode(h) + "." + encode(p)
+
+	var sig []byte
+	switch alg {
+	case RS256:
+		sum := sha256.Sum256([]byte(signed))
+		sig, err = key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	case EdDSA:
+		sig, err = key.Sign(rand.Reader, []byte(signed), crypto.Hash(0))
+	}
+	if err != nil {
+		return "", fmt.Errorf("accesstoken: signing: %w", err)
+	}
+
+	return signed + "." + encode(sig), nil
+}
+
+// KeyFunc returns the public key identified by kid.
+type KeyFunc func(kid string) (crypto.PublicKey, error)
+
+// Parse checks the signature of the token with the key returned by keyFn and
+// returns its claims. The time based claims are not validated, see Validate.
+func Parse(token string, keyFn KeyFunc) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrMalformed
+	}
+
+	rawHeader, err := decode(parts[0])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	var h header
+	if err := json.Unmarshal(rawHeader, &h); err != nil {
+		return Claims{}, ErrMalformed
+	}
+
+	sig, err := decode(parts[2])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+
+	key, err := keyFn(h.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	// The algorithm is dictated by the key, never by the token, so a token
+	// can't downgrade the verification.
+	alg, err := Algorithm(key)
+	if err != nil {
+		return Claims{}, err
+	}
+	if h.Alg != alg {
+		return Claims{}, ErrSignature
+	}
+
+	signed := parts[0] + "." + parts[1]
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		sum := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig); err != nil {
+			return Claims{}, ErrSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, []byte(signed), sig) {
+			return Claims{}, ErrSignature
+		}
+	}
+
+	payload, err := decode(parts[1])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Claims{}, ErrMalformed
+	}
+
+	return c, nil
+}
+
+// Validate checks the time based claims against now, allowing leeway for
+// clock skew, and the issuer and audience when they are not empty.
+func (c Claims) Validate(now time.Time, issuer string, audience string, leeway time.Duration) error {
+	if c.Subject == "" {
+		return fmt.Errorf("%w: missing subject", ErrClaims)
+	}
+	if issuer != "" && c.Issuer != issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrClaims, c.Issuer)
+	}
+	if audience != "" && c.Audience != audience {
+		return fmt.Errorf("%w: unexpected audience %q", ErrClaims, c.Audience)
+	}
+	if now.Add(-leeway).Unix() >= c.ExpiresAt {
+		return ErrExpired
+	}
+	if now.Add(leeway).Unix() < c.IssuedAt {
+		return fmt.Errorf("%w: issued in the future", ErrClaims)
+	}
+	return nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,167 @@
+package accesstoken_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/pkg/accesstoken"
+)
+
+// newSigners generates a key of every supported algorithm.
+func newSigners(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating rsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+
+	return map[string]crypto.Signer{
+		accesstoken.RS256: rsaKey,
+		accesstoken.EdDSA: edKey,
+	}
+}
+
+// keyOf returns a KeyFunc knowing only the public key of the signer as kid.
+func keyOf(kid string, s crypto.Signer) accesstoken.KeyFunc {
+	return func(got string) (crypto.PublicKey, error) {
+		if got != kid {
+			return nil, accesstoken.ErrUnknownKey
+		}
+		return s.Public(), nil
+	}
+}
+
+// testClaims returns the claims of a token issued now for the test user.
+func testClaims() accesstoken.Claims {
+	now := time.Now()
+	return accesstoken.Claims{
+		Issuer:    "go-tickets-auth",
+		Subject:   "uid-1",
+		Audience:  "go-tickets",
+		ID:        "jti-1",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		Email:     "ana@example.com",
+		Roles:     []string{"admin"},
+		Tenant:    "tenant-a",
+	}
+}
+
+func TestSignAndParse(t *testing.T) {
+	for alg, s := range newSigners(t) {
+		t.Run(alg, func(t *testing.T) {
+			want := testClaims()
+
+			tkn, err := accesstoken.Sign(s, "kid-1", want)
+			if err != nil {
+				t.Fatalf("signing: %v", err)
+			}
+
+			got, err := accesstoken.Parse(tkn, keyOf("kid-1", s))
+			if err != nil {
+				t.Fatalf("parsing: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got claims %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	signers := newSigners(t)
+	ed := signers[accesstoken.EdDSA]
+
+	tkn, err := accesstoken.Sign(ed, "kid-1", testClaims())
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	parts := strings.Split(tkn, ".")
+
+	// The header claims another algorithm for the same signature.
+	header, _ := json.Marshal(map[string]string{"alg": accesstoken.RS256, "typ": "JWT", "kid": "kid-1"})
+	otherAlg := base64.RawURLEncoding.EncodeToString(header) + "." + parts[1] + "." + parts[2]
+
+	// The payload grants more roles than the signed one.
+	c := testClaims()
+	c.Roles = []string{"admin", "organizer"}
+	payload, _ := json.Marshal(c)
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		keyFn accesstoken.KeyFunc
+		want  error
+	}{
+		{"malformed", "not-a-token", keyOf("kid-1", ed), accesstoken.ErrMalformed},
+		{"alg of the header", otherAlg, keyOf("kid-1", ed), accesstoken.ErrSignature},
+		{"alg of the key", tkn, keyOf("kid-1", signers[accesstoken.RS256]), accesstoken.ErrSignature},
+		{"tampered payload", tampered, keyOf("kid-1", ed), accesstoken.ErrSignature},
+		{"unknown key", tkn, keyOf("kid-2", ed), accesstoken.ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := accesstoken.Parse(tt.token, tt.keyFn); !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := testClaims()
+	c.IssuedAt = now.Add(-time.Minute).Unix()
+	c.ExpiresAt = now.Unix()
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		leeway time.Duration
+		want   error
+	}{
+		{"valid", now.Add(-time.Second), 0, nil},
+		{"expired", now, 0, accesstoken.ErrExpired},
+		{"expired within the leeway", now.Add(20 * time.Second), 30 * time.Second, nil},
+		{"expired past the leeway", now.Add(30 * time.Second), 30 * time.Second, accesstoken.ErrExpired},
+		{"issued in the future", now.Add(-2 * time.Minute), 30 * time.Second, accesstoken.ErrClaims},
+		{"issued in the future within the leeway", now.Add(-time.Minute - 20*time.Second), 30 * time.Second, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.Validate(tt.now, c.Issuer, c.Audience, tt.leeway)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateIssuerAndAudience(t *testing.T) {
+	c := testClaims()
+	now := time.Now()
+
+	if err := c.Validate(now, "other-issuer", c.Audience, 0); !errors.Is(err, accesstoken.ErrClaims) {
+		t.Fatalf("got error %v for another issuer, want %v", err, accesstoken.ErrClaims)
+	}
+	if err := c.Validate(now, c.Issuer, "other-audience", 0); !errors.Is(err, accesstoken.ErrClaims) {
+		t.Fatalf("got error %v for another audience, want %v", err, accesstoken.ErrClaims)
+	}
+}
@@ -0,0 +1,162 @@
+package accesstoken
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Config holds the settings of a Verifier.
+type Config struct {
+	// JWKSURL is the key set endpoint of the auth service,
+	// e.g. http://auth:3000/.well-known/jwks.json.
+	JWKSURL string
+	// Issuer and Audience are the ones of the auth service, a token
+	// issued for another audience is rejected.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew between the services.
+	Leeway time.Duration
+	// RefreshInterval is how often the key set is refreshed. An unknown key
+	// triggers an early refresh, at most once per MinRefreshInterval.
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+	// Client is used to fetch the key set. Defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+// Verifier verifies access tokens against the key set published by the
+// auth service. It is safe for concurrent use.
+type Verifier struct {
+	cfg Config
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+// NewVerifier constructs a Verifier. The key set is fetched lazily. The
+// issuer and the audience are required, the tokens of the auth service are
+// meant for the services of its audience only.
+func NewVerifier(cfg Config) (*Verifier, error) {
+	switch {
+	case cfg.JWKSURL == "":
+		return nil, errors.New("accesstoken: key set url must be set")
+	case cfg.Issuer == "":
+		return nil, errors.New("accesstoken: issuer must be set")
+	case cfg.Audience == "":
+		return nil, errors.New("accesstoken: audience must be set")
+	}
+
+	if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = time.Hour
+	}
+	if cfg.MinRefreshInterval == 0 {
+		cfg.MinRefreshInterval = 30 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	v := Verifier{
+		cfg:  cfg,
+		keys: make(map[string]crypto.PublicKey),
+	}
+	return &v, nil
+}
+
+// Verify checks the token signature and claims and returns the claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	c, err := Parse(token, func(kid string) (crypto.PublicKey, error) {
+		return v.key(ctx, kid)
+	})
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if err := c.Validate(time.Now(), v.cfg.Issuer, v.cfg.Audience, v.cfg.Leeway); err != nil {
+		return Claims{}, err
+	}
+	return c, nil
+}
+
+// key returns the public key identified by kid, refreshing the key set when
+// it is stale or does not contain the key.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	k, ok := v.keys[kid]
+	fresh := time.Since(v.fetchedAt) < v.cfg.RefreshInterval
+	throttled := time.Since(v.attemptedAt) < v.cfg.MinRefreshInterval
+	v.mu.RUnlock()
+
+	switch {
+	case ok && (fresh || throttled):
+		return k, nil
+	case !ok && throttled:
+		return nil, ErrUnknownKey
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		// Keep serving the cached key while the auth service is unreachable.
+		if ok {
+			return k, nil
+		}
+		return nil, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh fetches the key set and replaces the cached keys.
+func (v *Verifier) refresh(ctx context.Context) error {
+	v.mu.Lock()
+	v.attemptedAt = time.Now()
+	v.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return fmt.Errorf("accesstoken: %w", err)
+	}
+
+	resp, err := v.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("accesstoken: fetching key set: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("accesstoken: fetching key set: status %d", resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("accesstoken: decoding key set: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		k, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = k
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package accesstoken_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mroobert/go-tickets/auth/pkg/accesstoken"
+)
+
+// jwksServer publishes the public keys of the signers by kid and counts
+// the fetches of the key set.
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]crypto.Signer
+	fetches int
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+
+	s := jwksServer{keys: make(map[string]crypto.Signer)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+
+		var set accesstoken.JWKS
+		for kid, k := range s.keys {
+			jwk, err := accesstoken.NewJWK(kid, k.Public())
+			if err != nil {
+				t.Errorf("encoding key: %v", err)
+				return
+			}
+			set.Keys = append(set.Keys, jwk)
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+
+	return &s
+}
+
+// add generates a new key published as kid and returns it.
+func (s *jwksServer) add(t *testing.T, kid string) crypto.Signer {
+	t.Helper()
+
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[kid] = k
+	return k
+}
+
+// count returns the number of fetches of the key set.
+func (s *jwksServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+// newVerifier constructs a verifier of the server with the issuer and the
+// audience of testClaims.
+func newVerifier(t *testing.T, srv *jwksServer, minRefresh time.Duration) *accesstoken.Verifier {
+	t.Helper()
+
+	v, err := accesstoken.NewVerifier(accesstoken.Config{
+		JWKSURL:            srv.URL,
+		Issuer:             "go-tickets-auth",
+		Audience:           "go-tickets",
+		MinRefreshInterval: minRefresh,
+		Client:             srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("constructing verifier: %v", err)
+	}
+	return v
+}
+
+// sign signs testClaims with the key.
+func sign(t *testing.T, k crypto.Signer, kid string) string {
+	t.Helper()
+
+	tkn, err := accesstoken.Sign(k, kid, testClaims())
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	return tkn
+}
+
+func TestNewVerifierRequires(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  accesstoken.Config
+	}{
+		{"key set url", accesstoken.Config{Issuer: "go-tickets-auth", Audience: "go-tickets"}},
+		{"issuer", accesstoken.Config{JWKSURL: "http://auth/jwks", Audience: "go-tickets"}},
+		{"audience", accesstoken.Config{JWKSURL: "http://auth/jwks", Issuer: "go-tickets-auth"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := accesstoken.NewVerifier(tt.cfg); err == nil {
+				t.Fatal("got no error")
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	srv := newJWKSServer(t)
+	k := srv.add(t, "kid-1")
+	v := newVerifier(t, srv, time.Hour)
+
+	c, err := v.Verify(context.Background(), sign(t, k, "kid-1"))
+	if err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+	if c.Subject != "uid-1" {
+		t.Fatalf("got subject %q, want %q", c.Subject, "uid-1")
+	}
+
+	// The claims are checked against the ones of the verifier.
+	other, err := accesstoken.NewVerifier(accesstoken.Config{
+		JWKSURL:  srv.URL,
+		Issuer:   "go-tickets-auth",
+		Audience: "other-service",
+		Client:   srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("constructing verifier: %v", err)
+	}
+	if _, err := other.Verify(context.Background(), sign(t, k, "kid-1")); !errors.Is(err, accesstoken.ErrClaims) {
+		t.Fatalf("got error %v for another audience, want %v", err, accesstoken.ErrClaims)
+	}
+}
+
+func TestVerifyUnknownKeyThrottled(t *testing.T) {
+	srv := newJWKSServer(t)
+	k := srv.add(t, "kid-1")
+	v := newVerifier(t, srv, time.Hour)
+
+	if _, err := v.Verify(context.Background(), sign(t, k, "kid-1")); err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+
+	// Tokens of unknown keys don't make the verifier hammer the auth
+	// service, the key set was fetched less than MinRefreshInterval ago.
+	unknown := srv.add(t, "kid-2")
+	for i := 0; i < 5; i++ {
+		if _, err := v.Verify(context.Background(), sign(t, unknown, "kid-2")); !errors.Is(err, accesstoken.ErrUnknownKey) {
+			t.Fatalf("got error %v, want %v", err, accesstoken.ErrUnknownKey)
+		}
+	}
+	if got := srv.count(); got != 1 {
+		t.Fatalf("got %d fetches of the key set, want 1", got)
+	}
+}
+
+func TestVerifyUnknownKeyRefresh(t *testing.T) {
+	srv := newJWKSServer(t)
+	k := srv.add(t, "kid-1")
+	v := newVerifier(t, srv, time.Nanosecond)
+
+	if _, err := v.Verify(context.Background(), sign(t, k, "kid-1")); err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+
+	// A rotated key is fetched on its first token.
+	rotated := srv.add(t, "kid-2")
+	if _, err := v.Verify(context.Background(), sign(t, rotated, "kid-2")); err != nil {
+		t.Fatalf("verifying with the rotated key: %v", err)
+	}
+	if got := srv.count(); got != 2 {
+		t.Fatalf("got %d fetches of the key set, want 2", got)
+	}
+
+	// The known keys are served from the cache.
+	if _, err := v.Verify(context.Background(), sign(t, k, "kid-1")); err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+	if got := srv.count(); got != 2 {
+		t.Fatalf("got %d fetches of the key set, want 2", got)
+	}
+}
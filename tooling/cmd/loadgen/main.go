@@ -0,0 +1,118 @@
+// This program drives the scenarios of a file against a running API and
+// reports the latency and the errors of every step.
+//
+// The load is either a rate of scenario iterations per second (-rps) or a
+// number of virtual users looping over the scenarios (-concurrency). The
+// load ramps up linearly over -ramp, then holds for -duration. The results
+// of the first -warmup are discarded.
+//
+// All the virtual users share the address of the generator, so the rate
+// limits per client of the API reject most of the load. A scenarios file
+// comes with the configuration raising them, e.g. scenarios/auth-api.yaml
+// for scenarios/auth.json.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var cfg config
+	flag.StringVar(&cfg.Scenario, "scenario", "", "path of the scenarios file")
+	flag.StringVar(&cfg.BaseURL, "base", "", "base url of the api, overrides the one of the file")
+	flag.Float64Var(&cfg.RPS, "rps", 0, "scenario iterations started per second")
+	flag.IntVar(&cfg.Concurrency, "concurrency", 0, "virtual users looping over the scenarios")
+	flag.IntVar(&cfg.MaxInFlight, "max-inflight", 1000, "iterations in flight above which the rps mode drops iterations")
+	flag.DurationVar(&cfg.Ramp, "ramp", 0, "time to ramp the load up to the target")
+	flag.DurationVar(&cfg.Duration, "duration", time.Minute, "time to hold the target load after the ramp")
+	flag.DurationVar(&cfg.Warmup, "warmup", 0, "time from the start whose results are discarded")
+	flag.DurationVar(&cfg.Timeout, "timeout", 10*time.Second, "timeout of every request")
+	jsonOut := flag.String("json", "", "write the report as json to the file, - for stdout")
+	flag.Parse()
+
+	if err := cfg.validate(); err != nil {
+		flag.Usage()
+		return err
+	}
+
+	f, err := loadFile(cfg.Scenario)
+	if err != nil {
+		return err
+	}
+	if cfg.BaseURL != "" {
+		f.BaseURL = cfg.BaseURL
+	}
+
+	// Stop early on an interrupt, the report covers what ran.
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	r := newRunner(cfg, f, &http.Client{Timeout: cfg.Timeout})
+	rep := r.run(ctx)
+
+	if *jsonOut != "-" {
+		rep.writeText(os.Stdout)
+	}
+	if *jsonOut == "" {
+		return nil
+	}
+
+	out := os.Stdout
+	if *jsonOut != "-" {
+		if out, err = os.Create(*jsonOut); err != nil {
+			return fmt.Errorf("creating report: %w", err)
+		}
+		defer out.Close()
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// config holds the settings of a run.
+type config struct {
+	Scenario    string
+	BaseURL     string
+	RPS         float64
+	Concurrency int
+	MaxInFlight int
+	Ramp        time.Duration
+	Duration    time.Duration
+	Warmup      time.Duration
+	Timeout     time.Duration
+}
+
+func (cfg config) validate() error {
+	switch {
+	case cfg.Scenario == "":
+		return errors.New("a scenario file is required")
+	case (cfg.RPS > 0) == (cfg.Concurrency > 0):
+		return errors.New("exactly one of -rps and -concurrency is required")
+	case cfg.RPS < 0 || cfg.Concurrency < 0:
+		return errors.New("the load can't be negative")
+	case cfg.MaxInFlight < 1:
+		return errors.New("max-inflight must be at least 1")
+	case cfg.Duration <= 0:
+		return errors.New("duration must be positive")
+	case cfg.Warmup >= cfg.Ramp+cfg.Duration:
+		return errors.New("warmup must be shorter than the run")
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// stats collects the results of the steps by endpoint.
+type stats struct {
+	mu         sync.Mutex
+	endpoints  map[string]*endpoint
+	order      []string
+	iterations int
+	dropped    int
+}
+
+// endpoint holds the results of a step of a scenario.
+type endpoint struct {
+	scenario  string
+	step      string
+	method    string
+	url       string
+	latencies []time.Duration
+	errors    map[string]int
+}
+
+func newStats() *stats {
+	return &stats{
+		endpoints: make(map[string]*endpoint),
+	}
+}
+
+// record adds the result of a step, kind is empty on success.
+func (s *stats) record(scenario string, st *step, took time.Duration, kind string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := scenario + "/" + st.Name
+	e, ok := s.endpoints[key]
+	if !ok {
+		e = &endpoint{
+			scenario: scenario,
+			step:     st.Name,
+			method:   st.Method,
+			url:      st.URL,
+			errors:   make(map[string]int),
+		}
+		s.endpoints[key] = e
+		s.order = append(s.order, key)
+	}
+
+	e.latencies = append(e.latencies, took)
+	if kind != "" {
+		e.errors[kind]++
+	}
+}
+
+// iteration counts a scenario iteration that completed all its steps.
+func (s *stats) iteration() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.iterations++
+}
+
+// drop counts an iteration the rps mode couldn't start.
+func (s *stats) drop(measured bool) {
+	if !measured {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropped++
+}
+
+// =============================================================================
+
+// report is the outcome of a run.
+type report struct {
+	Mode       string           `json:"mode"`
+	Target     float64          `json:"target"`
+	Elapsed    float64          `json:"elapsedSeconds"`
+	Measured   float64          `json:"measuredSeconds"`
+	Iterations int              `json:"iterations"`
+	Dropped    int              `json:"dropped"`
+	Endpoints  []endpointReport `json:"endpoints"`
+}
+
+// endpointReport summarizes the results of a step.
+type endpointReport struct {
+	Scenario  string         `json:"scenario"`
+	Step      string         `json:"step"`
+	Method    string         `json:"method"`
+	URL       string         `json:"url"`
+	Requests  int            `json:"requests"`
+	RPS       float64        `json:"rps"`
+	Errors    int            `json:"errors"`
+	ErrorRate float64        `json:"errorRate"`
+	ByKind    map[string]int `json:"errorsByKind"`
+	Latency   latencyReport  `json:"latencyMs"`
+}
+
+// latencyReport holds the percentiles of the latency in milliseconds.
+type latencyReport struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// report summarizes the results of a run that lasted elapsed.
+func (s *stats) report(cfg config, elapsed time.Duration) report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rep := report{
+		Mode:       "concurrency",
+		Target:     float64(cfg.Concurrency),
+		Elapsed:    elapsed.Seconds(),
+		Iterations: s.iterations,
+		Dropped:    s.dropped,
+		Endpoints:  []endpointReport{},
+	}
+	if cfg.RPS > 0 {
+		rep.Mode = "rps"
+		rep.Target = cfg.RPS
+	}
+
+	measured := elapsed - cfg.Warmup
+	if measured < 0 {
+		measured = 0
+	}
+	rep.Measured = measured.Seconds()
+
+	for _, key := range s.order {
+		e := s.endpoints[key]
+		sort.Slice(e.latencies, func(i, j int) bool { return e.latencies[i] < e.latencies[j] })
+
+		er := endpointReport{
+			Scenario: e.scenario,
+			Step:     e.step,
+			Method:   e.method,
+			URL:      e.url,
+			Requests: len(e.latencies),
+			ByKind:   e.errors,
+			Latency: latencyReport{
+				P50: ms(percentile(e.latencies, 50)),
+				P90: ms(percentile(e.latencies, 90)),
+				P99: ms(percentile(e.latencies, 99)),
+				Max: ms(percentile(e.latencies, 100)),
+			},
+		}
+		for _, n := range e.errors {
+			er.Errors += n
+		}
+		if er.Requests > 0 {
+			er.ErrorRate = float64(er.Errors) / float64(er.Requests)
+		}
+		if measured > 0 {
+			er.RPS = float64(er.Requests) / measured.Seconds()
+		}
+
+		rep.Endpoints = append(rep.Endpoints, er)
+	}
+
+	return rep
+}
+
+// percentile returns the nearest-rank percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// writeText writes the report as a table.
+func (rep report) writeText(w io.Writer) {
+	fmt.Fprintf(w, "mode: %s  target: %g  elapsed: %.1fs  measured: %.1fs  iterations: %d  dropped: %d\n\n",
+		rep.Mode, rep.Target, rep.Elapsed, rep.Measured, rep.Iterations, rep.Dropped)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ENDPOINT\tREQS\tRPS\tERRORS\tP50 MS\tP90 MS\tP99 MS\tMAX MS\t")
+	for _, e := range rep.Endpoints {
+		fmt.Fprintf(tw, "%s/%s\t%d\t%.1f\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			e.Scenario, e.Step, e.Requests, e.RPS, e.Errors,
+			e.Latency.P50, e.Latency.P90, e.Latency.P99, e.Latency.Max)
+	}
+	tw.Flush()
+
+	var errs []string
+	for _, e := range rep.Endpoints {
+		kinds := make([]string, 0, len(e.ByKind))
+		for k := range e.ByKind {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+
+		for _, k := range kinds {
+			errs = append(errs, fmt.Sprintf("  %s/%s %s: %d", e.Scenario, e.Step, k, e.ByKind[k]))
+		}
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(w, "\nerrors:\n%s\n", strings.Join(errs, "\n"))
+	}
+}
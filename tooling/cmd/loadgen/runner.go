@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// runner schedules the scenario iterations and collects their results.
+type runner struct {
+	cfg    config
+	file   file
+	client *http.Client
+	stats  *stats
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	total int
+
+	wg    sync.WaitGroup
+	start time.Time
+}
+
+// newRunner constructs a runner of the scenarios.
+func newRunner(cfg config, f file, client *http.Client) *runner {
+	return &runner{
+		cfg:    cfg,
+		file:   f,
+		client: client,
+		stats:  newStats(),
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// run applies the load until the end of the run or the cancellation of
+// the context and returns the report. The iterations in flight at the end
+// are awaited so their requests are not reported as failures.
+func (r *runner) run(ctx context.Context) report {
+	r.start = time.Now()
+	end := r.start.Add(r.cfg.Ramp + r.cfg.Duration)
+
+	load, cancel := context.WithDeadline(ctx, end)
+	defer cancel()
+
+	if r.cfg.RPS > 0 {
+		r.open(ctx, load)
+	} else {
+		r.closed(ctx, load)
+	}
+	r.wg.Wait()
+
+	return r.stats.report(r.cfg, time.Since(r.start))
+}
+
+// open starts iterations at the target rate whatever the latency of the
+// api, up to the in-flight limit above which they are dropped.
+func (r *runner) open(ctx context.Context, load context.Context) {
+	const tick = 10 * time.Millisecond
+	t := time.NewTicker(tick)
+	defer t.Stop()
+
+	var (
+		credit   float64
+		inFlight = make(chan struct{}, r.cfg.MaxInFlight)
+		last     = r.start
+	)
+
+	for {
+		select {
+		case <-load.Done():
+			return
+		case now := <-t.C:
+			credit += r.rate(now) * now.Sub(last).Seconds()
+			last = now
+
+			for ; credit >= 1; credit-- {
+				select {
+				case inFlight <- struct{}{}:
+				default:
+					r.stats.drop(r.measured(now))
+					continue
+				}
+
+				r.wg.Add(1)
+				go func(iter int) {
+					defer r.wg.Done()
+					defer func() { <-inFlight }()
+					r.iterate(ctx, load, iter, iter)
+				}(r.next())
+			}
+		}
+	}
+}
+
+// rate returns the target rate of iterations per second at the time.
+func (r *runner) rate(now time.Time) float64 {
+	elapsed := now.Sub(r.start)
+	if elapsed >= r.cfg.Ramp {
+		return r.cfg.RPS
+	}
+	return r.cfg.RPS * float64(elapsed) / float64(r.cfg.Ramp)
+}
+
+// closed starts the virtual users spread over the ramp, each one loops
+// over the scenarios until the end of the run.
+func (r *runner) closed(ctx context.Context, load context.Context) {
+	for vu := 0; vu < r.cfg.Concurrency; vu++ {
+		delay := time.Duration(0)
+		if r.cfg.Concurrency > 1 {
+			delay = r.cfg.Ramp * time.Duration(vu) / time.Duration(r.cfg.Concurrency)
+		}
+
+		r.wg.Add(1)
+		go func(vu int) {
+			defer r.wg.Done()
+
+			select {
+			case <-load.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			for load.Err() == nil {
+				r.iterate(ctx, load, vu, r.next())
+			}
+		}(vu)
+	}
+}
+
+// next returns the number of the next iteration.
+func (r *runner) next() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.total++
+	return r.total
+}
+
+// pick returns a scenario at random according to the weights.
+func (r *runner) pick() *scenario {
+	var sum int
+	for _, sc := range r.file.Scenarios {
+		sum += sc.Weight
+	}
+
+	r.mu.Lock()
+	n := r.rng.Intn(sum)
+	r.mu.Unlock()
+
+	for i := range r.file.Scenarios {
+		sc := &r.file.Scenarios[i]
+		if n < sc.Weight {
+			return sc
+		}
+		n -= sc.Weight
+	}
+	return &r.file.Scenarios[len(r.file.Scenarios)-1]
+}
+
+// iterate runs the steps of a scenario until one fails. The requests run
+// on ctx so the end of the load doesn't cut them.
+func (r *runner) iterate(ctx context.Context, load context.Context, vu int, iter int) {
+	sc := r.pick()
+	it := newIteration(r.client, r.file, vu, iter)
+	measured := r.measured(time.Now())
+
+	for i := range sc.Steps {
+		if load.Err() != nil && i > 0 {
+			return
+		}
+
+		st := &sc.Steps[i]
+		start := time.Now()
+		kind, _ := it.do(ctx, st)
+		took := time.Since(start)
+
+		// An interrupt fails the requests in flight, they say nothing of the api.
+		if ctx.Err() != nil {
+			return
+		}
+
+		if measured {
+			r.stats.record(sc.Name, st, took, kind)
+		}
+		if kind != "" {
+			return
+		}
+	}
+
+	if measured {
+		r.stats.iteration()
+	}
+}
+
+// measured reports if the results of an iteration started at the time
+// count, the ones of the warm-up don't.
+func (r *runner) measured(t time.Time) bool {
+	return t.Sub(r.start) >= r.cfg.Warmup
+}
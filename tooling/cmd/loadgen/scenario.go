@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// file is the content of a scenarios file. The vars are the initial
+// variables of every iteration.
+type file struct {
+	BaseURL   string            `json:"baseURL"`
+	Vars      map[string]string `json:"vars"`
+	Scenarios []scenario        `json:"scenarios"`
+}
+
+// scenario is a sequence of steps run by an iteration, sharing the cookies
+// and the variables. The weight decides how often it is picked.
+type scenario struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+	Steps  []step `json:"steps"`
+}
+
+// step is a request of a scenario. The strings of the url, the headers and
+// the body are templates over the variables of the iteration. The extract
+// map stores fields of the json response, by dotted path, in variables.
+type step struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    interface{}       `json:"body"`
+	Expect  []int             `json:"expect"`
+	Extract map[string]string `json:"extract"`
+
+	url     *template.Template
+	headers map[string]*template.Template
+}
+
+// loadFile reads and checks a scenarios file, parsing its templates.
+func loadFile(path string) (file, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return file{}, fmt.Errorf("reading scenarios: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(b, &f); err != nil {
+		return file{}, fmt.Errorf("decoding scenarios: %w", err)
+	}
+	if len(f.Scenarios) == 0 {
+		return file{}, errors.New("the file has no scenarios")
+	}
+
+	for i := range f.Scenarios {
+		sc := &f.Scenarios[i]
+		if sc.Weight == 0 {
+			sc.Weight = 1
+		}
+		if sc.Weight < 0 || len(sc.Steps) == 0 {
+			return file{}, fmt.Errorf("scenario %q: needs steps and a positive weight", sc.Name)
+		}
+
+		for j := range sc.Steps {
+			st := &sc.Steps[j]
+			if st.Name == "" || st.URL == "" {
+				return file{}, fmt.Errorf("scenario %q: step %d needs a name and a url", sc.Name, j)
+			}
+			if st.Method == "" {
+				st.Method = http.MethodGet
+			}
+			if len(st.Expect) == 0 {
+				st.Expect = []int{http.StatusOK}
+			}
+
+			if st.url, err = parseTemplate(st.URL); err != nil {
+				return file{}, fmt.Errorf("scenario %q step %q: %w", sc.Name, st.Name, err)
+			}
+			st.headers = make(map[string]*template.Template, len(st.Headers))
+			for k, v := range st.Headers {
+				if st.headers[k], err = parseTemplate(v); err != nil {
+					return file{}, fmt.Errorf("scenario %q step %q: %w", sc.Name, st.Name, err)
+				}
+			}
+			if _, err := render(st.Body, map[string]string{}); err != nil && !isMissingKey(err) {
+				return file{}, fmt.Errorf("scenario %q step %q: %w", sc.Name, st.Name, err)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// funcs are the functions available to the templates.
+var funcs = template.FuncMap{
+	// unique returns a random hex string, e.g. to sign up new users.
+	"unique": func() string {
+		b := make([]byte, 8)
+		rand.Read(b)
+		return hex.EncodeToString(b)
+	},
+	"env": os.Getenv,
+}
+
+func parseTemplate(s string) (*template.Template, error) {
+	return template.New("").Funcs(funcs).Option("missingkey=error").Parse(s)
+}
+
+func execute(t *template.Template, vars map[string]string) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// render executes the string templates found in the json value.
+func render(v interface{}, vars map[string]string) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		t, err := parseTemplate(v)
+		if err != nil {
+			return nil, err
+		}
+		return execute(t, vars)
+
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			r, err := render(e, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			r, err := render(e, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// isMissingKey reports if the template failed on a variable only known
+// while running.
+func isMissingKey(err error) bool {
+	return strings.Contains(err.Error(), "map has no entry for key")
+}
+
+// =============================================================================
+
+// iteration runs the steps of a scenario for a new virtual user.
+type iteration struct {
+	client  *http.Client
+	baseURL string
+	vars    map[string]string
+}
+
+// newIteration constructs an iteration with its own cookies.
+func newIteration(client *http.Client, f file, vu int, iter int) *iteration {
+	jar, _ := cookiejar.New(nil)
+	c := *client
+	c.Jar = jar
+
+	vars := make(map[string]string, len(f.Vars)+2)
+	for k, v := range f.Vars {
+		vars[k] = v
+	}
+	vars["vu"] = strconv.Itoa(vu)
+	vars["iter"] = strconv.Itoa(iter)
+
+	return &iteration{
+		client:  &c,
+		baseURL: f.BaseURL,
+		vars:    vars,
+	}
+}
+
+// do runs a step and returns the error kind, empty on success.
+func (it *iteration) do(ctx context.Context, st *step) (kind string, err error) {
+	url, err := execute(st.url, it.vars)
+	if err != nil {
+		return "template", err
+	}
+	if strings.HasPrefix(url, "/") {
+		url = it.baseURL + url
+	}
+
+	var body io.Reader
+	if st.Body != nil {
+		v, err := render(st.Body, it.vars)
+		if err != nil {
+			return "template", err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "template", err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, st.Method, url, body)
+	if err != nil {
+		return "request", err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, t := range st.headers {
+		v, err := execute(t, it.vars)
+		if err != nil {
+			return "template", err
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := it.client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "Client.Timeout") {
+			return "timeout", err
+		}
+		return "network", err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "network", err
+	}
+
+	if !expected(st.Expect, resp.StatusCode) {
+		return "status " + strconv.Itoa(resp.StatusCode), fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if len(st.Extract) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return "extract", fmt.Errorf("decoding response: %w", err)
+		}
+		for name, path := range st.Extract {
+			v, ok := lookup(doc, path)
+			if !ok {
+				return "extract", fmt.Errorf("response has no %s", path)
+			}
+			it.vars[name] = v
+		}
+	}
+
+	return "", nil
+}
+
+func expected(codes []int, status int) bool {
+	for _, c := range codes {
+		if c == status {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the value at the dotted path of a json document.
+func lookup(doc interface{}, path string) (string, bool) {
+	for _, part := range strings.Split(path, ".") {
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if doc, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := doc.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", false
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}